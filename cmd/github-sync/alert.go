@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/git"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+)
+
+// smtpTimeout bounds the delivery of an alert to the SMTP server.
+const smtpTimeout = 30 * time.Second
+
+// failureAlerts emails an alert when a mirror failed to sync several times
+// in a row, and again at most every interval while it keeps failing. A
+// single failure, such as a transient outage of the primary's network, does
+// not alert anyone. A nil *failureAlerts sends nothing.
+type failureAlerts struct {
+	server   string
+	user     string
+	password string
+	from     string
+	to       []string
+
+	// after is the number of consecutive failures that triggers an alert
+	after    int
+	interval time.Duration
+}
+
+// addAlertFlags adds the flags configuring the failure alerts a to cmd.
+func addAlertFlags(cmd *cobra.Command, a *failureAlerts) {
+	cmd.Flags().StringVar(&a.server, "smtp-server", "", "SMTP server alerts are sent through, as host:port; STARTTLS is used when offered")
+	cmd.Flags().StringVar(&a.user, "smtp-user", "", "User name to authenticate to the SMTP server with (password from env SMTP_PASSWORD)")
+	cmd.Flags().StringVar(&a.from, "alert-from", "", "Sender address of failure alerts")
+	cmd.Flags().StringArrayVar(&a.to, "alert-to", nil, "Address to email when a mirror keeps failing to sync (repeatable)")
+	cmd.Flags().IntVar(&a.after, "alert-after", 3, "Consecutive failed syncs of a mirror that trigger an alert")
+	cmd.Flags().DurationVar(&a.interval, "alert-interval", 6*time.Hour, "Minimum time between alerts about the same mirror while it keeps failing")
+}
+
+// loadAlerts checks the alerts configured by the flags and returns them,
+// or nil when no recipient is set.
+func loadAlerts(flags *failureAlerts) (*failureAlerts, error) {
+	a := *flags
+	if len(a.to) == 0 {
+		return nil, nil
+	}
+	if a.server == "" || a.from == "" {
+		return nil, withExitCode(exitConfig, fmt.Errorf("--smtp-server and --alert-from are required with --alert-to"))
+	}
+	if _, _, err := net.SplitHostPort(a.server); err != nil {
+		return nil, withExitCode(exitConfig, fmt.Errorf("invalid --smtp-server %s: %w", a.server, err))
+	}
+	if a.after < 1 {
+		return nil, withExitCode(exitConfig, fmt.Errorf("--alert-after must be at least 1"))
+	}
+	a.password = os.Getenv("SMTP_PASSWORD")
+	redact.Secret(a.password)
+	return &a, nil
+}
+
+// check emails an alert about mirror when its state calls for one: after
+// enough consecutive failures unless alerted within the interval, or once
+// it synced again after an alert. The time of the last alert is kept in ms,
+// which is to be stored when true is returned.
+func (a *failureAlerts) check(ctx context.Context, log *logger.Logger, mirror, primary string, ms *git.MirrorState) bool {
+	if a == nil {
+		return false
+	}
+
+	var subject, body string
+	switch {
+	case ms.LastError == "" && !ms.LastAlert.IsZero():
+		subject = "Mirror " + mirror + " is syncing again"
+		body = fmt.Sprintf("The mirror %s of %s synced successfully at %s after failing.\n\nSynced commit: %s\n",
+			mirror, primary, ms.LastSuccess.Format(time.RFC1123), ms.SyncedSHA)
+	case ms.LastError != "" && ms.Failures >= a.after && time.Since(ms.LastAlert) >= a.interval:
+		lastSuccess := "never"
+		if !ms.LastSuccess.IsZero() {
+			lastSuccess = ms.LastSuccess.Format(time.RFC1123)
+		}
+		subject = fmt.Sprintf("Mirror %s failed to sync %d times in a row", mirror, ms.Failures)
+		body = fmt.Sprintf("The mirror %s of %s failed to sync %d times in a row.\n\nLast attempt: %s\nLast success: %s\nLast error: %s\n\n"+
+			"No further alert is sent about this mirror for %s unless it recovers.\n",
+			mirror, primary, ms.Failures, ms.LastAttempt.Format(time.RFC1123), lastSuccess, ms.LastError, a.interval)
+	default:
+		return false
+	}
+
+	if err := a.send(ctx, subject, body); err != nil {
+		log.Warn("Could not send failure alert", "error", err)
+		return false
+	}
+	log.Info("Sent alert", "subject", subject, "to", a.to)
+	if ms.LastError == "" {
+		ms.LastAlert = time.Time{}
+	} else {
+		ms.LastAlert = time.Now().UTC()
+	}
+	return true
+}
+
+// send delivers an email with subject and body to the recipients.
+func (a *failureAlerts) send(ctx context.Context, subject, body string) error {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", a.server)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	host, _, _ := net.SplitHostPort(a.server)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("failed to start TLS with SMTP server: %w", err)
+		}
+	}
+	if a.user != "" {
+		if err := client.Auth(smtp.PlainAuth("", a.user, a.password, host)); err != nil {
+			return fmt.Errorf("failed to authenticate to SMTP server: %w", err)
+		}
+	}
+
+	if err := client.Mail(a.from); err != nil {
+		return fmt.Errorf("SMTP server rejected sender %s: %w", a.from, err)
+	}
+	for _, to := range a.to {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("SMTP server rejected recipient %s: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	message := "From: " + a.from + "\r\n" +
+		"To: " + strings.Join(a.to, ", ") + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + strings.ReplaceAll(body, "\n", "\r\n")
+	if _, err := w.Write([]byte(message)); err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	return client.Quit()
+}
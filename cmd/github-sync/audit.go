@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/diff"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/provenance"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/workflow"
+)
+
+// Audit states of an installed workflow.
+const (
+	auditUpToDate = "up-to-date"
+	auditMissing  = "missing"
+	auditOutdated = "outdated"
+	auditModified = "modified"
+	auditFixed    = "fixed"
+	auditFailed   = "failed"
+)
+
+// auditResult records the drift state of one mirror.
+type auditResult struct {
+	Mirror string
+	State  string
+	Detail string
+}
+
+// newAuditCmd creates the audit subcommand.
+func newAuditCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	var fix, showDiff bool
+
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Report drift between installed and freshly generated workflows",
+		Long: "Fetch the workflow installed in each mirror repository, compare it with freshly generated output\n" +
+			"and report whether it is missing, outdated (older template version) or modified (edited by hand or\n" +
+			"generated with different options). Hand edits are told apart by the provenance recorded in generated\n" +
+			"workflows. With --fix, drifted workflows are reinstalled.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(log)
+			if err != nil {
+				return err
+			}
+
+			results := make([]auditResult, 0, len(cfg.MirrorRepos))
+			var failed []error
+			for _, mirror := range cfg.MirrorRepos {
+				mirrorCfg := *cfg
+				mirrorCfg.MirrorRepo = mirror
+
+				mirrorLog := log.With("mirror_repo", mirror)
+				result, err := auditMirror(ctx, &mirrorCfg, mirrorLog, fix, showDiff)
+				if err != nil {
+					mirrorLog.Error("Failed to audit mirror repository", "error", err)
+					result.State, result.Detail = auditFailed, err.Error()
+					failed = append(failed, err)
+				}
+				results = append(results, result)
+			}
+			printAuditReport(results)
+
+			if len(failed) > 0 {
+				err := fmt.Errorf("audit failed for %d of %d mirror repositories", len(failed), len(cfg.MirrorRepos))
+				return withExitCode(commonExitCode(failed), err)
+			}
+			for _, r := range results {
+				if r.State != auditUpToDate && r.State != auditFixed {
+					return fmt.Errorf("workflow drift detected (use --fix to reconcile)")
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&fix, "fix", false, "Reinstall workflows that drifted from the generated output")
+	cmd.Flags().BoolVar(&showDiff, "diff", false, "Print a unified diff for each drifted workflow")
+
+	return cmd
+}
+
+// auditMirror compares the workflow installed in the configured mirror with freshly generated output.
+func auditMirror(ctx context.Context, cfg *config.Config, log *logger.Logger, fix, showDiff bool) (auditResult, error) {
+	result := auditResult{Mirror: cfg.MirrorRepo}
+
+	workflowYAML, err := generateWorkflow(ctx, cfg, log)
+	if err != nil {
+		return result, err
+	}
+
+	githubClient, err := github.NewClient(ctx, cfg, log)
+	if err != nil {
+		return result, fmt.Errorf("failed to create GitHub client: %w", err)
+	}
+
+	installed, found, err := githubClient.GetWorkflow(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	switch {
+	case !found:
+		result.State, result.Detail = auditMissing, "workflow not installed"
+	case provenance.Equal(installed, workflowYAML):
+		result.State = auditUpToDate
+		return result, nil
+	default:
+		version, ok := workflow.InstalledTemplateVersion(installed)
+		switch {
+		case !ok:
+			result.State, result.Detail = auditOutdated, "no template version recorded"
+		case version < workflow.TemplateVersion:
+			result.State, result.Detail = auditOutdated, fmt.Sprintf("template version %d, current is %d", version, workflow.TemplateVersion)
+		default:
+			result.State, result.Detail = auditModified, modifiedDetail(installed, workflowYAML)
+		}
+
+		if showDiff {
+			path := githubClient.WorkflowPath()
+			fmt.Print(diff.Unified("a/"+path, "b/"+path, installed, workflowYAML))
+		}
+	}
+
+	if fix {
+		if _, err := githubClient.SetupWorkflow(ctx, workflowYAML); err != nil {
+			return result, fmt.Errorf("failed to fix workflow: %w", err)
+		}
+		result.Detail = "was " + result.State
+		result.State = auditFixed
+	}
+
+	return result, nil
+}
+
+// modifiedDetail explains how the installed workflow differs from the
+// generated one of the same template version, from the provenance recorded
+// in both.
+func modifiedDetail(installed, generated string) string {
+	recorded, err := provenance.Verify(installed)
+	switch {
+	case errors.Is(err, provenance.ErrMissing):
+		return "edited by hand or generated with different options"
+	case err != nil:
+		return "edited by hand after it was generated"
+	}
+
+	generatedAt := recorded.Generated.Local().Format(time.DateTime)
+	current, err := provenance.Verify(generated)
+	if err == nil && current.Config != recorded.Config {
+		return fmt.Sprintf("generated with different options at %s", generatedAt)
+	}
+	return fmt.Sprintf("generated by gh-mirror %s at %s", recorded.Tool, generatedAt)
+}
+
+// printAuditReport prints a summary table of the audit results.
+func printAuditReport(results []auditResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MIRROR\tSTATE\tDETAIL")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Mirror, r.State, r.Detail)
+	}
+	w.Flush()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+)
+
+// repoPair is a primary repository and its mirror, read by setup --stdin.
+type repoPair struct {
+	Primary string `json:"primary"`
+	Mirror  string `json:"mirror"`
+
+	// line is the input line the pair was read from
+	line int
+}
+
+// readRepoPairs reads one pair per line from r, either as a primary URL
+// followed by a mirror URL or as a JSON object with primary and mirror
+// fields. The mirror may be left out when --mirror-org names it. Blank lines
+// and lines starting with # are skipped.
+func readRepoPairs(r io.Reader) ([]repoPair, error) {
+	var pairs []repoPair
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		pair := repoPair{line: line}
+		if strings.HasPrefix(text, "{") {
+			if err := json.Unmarshal([]byte(text), &pair); err != nil {
+				return nil, fmt.Errorf("line %d: invalid JSON: %w", line, err)
+			}
+		} else {
+			fields := strings.Fields(text)
+			if len(fields) > 2 {
+				return nil, fmt.Errorf("line %d: expected a primary URL and a mirror URL, got %d fields", line, len(fields))
+			}
+			pair.Primary = fields[0]
+			if len(fields) == 2 {
+				pair.Mirror = fields[1]
+			}
+		}
+		if pair.Primary == "" {
+			return nil, fmt.Errorf("line %d: primary repository URL is required", line)
+		}
+		pairs = append(pairs, pair)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read repository pairs: %w", err)
+	}
+
+	return pairs, nil
+}
+
+// setupPairs installs the workflow for each primary and mirror pair,
+// continuing past failures like setupMirrors. Every pair is loaded first, so
+// a mistake in the input stops the batch before anything was changed.
+func setupPairs(ctx context.Context, log *logger.Logger, res *runResult, pairs []repoPair) error {
+	cfgs := make([]config.Config, 0, len(pairs))
+	for _, pair := range pairs {
+		cfg, err := flags.LoadRepos(pair.Primary, pair.Mirror)
+		if err != nil {
+			return withExitCode(exitConfig, fmt.Errorf("line %d: failed to load configuration: %w", pair.line, err))
+		}
+		cfgs = append(cfgs, *cfg)
+	}
+
+	// Standard input carries the pairs, so there is no terminal to confirm
+	// on; running the batch opts into its actions like scripts do
+	var failed []error
+	for i := range cfgs {
+		cfg := &cfgs[i]
+		pairLog := log.With("primary_repo", cfg.PrimaryRepo, "mirror_repo", cfg.MirrorRepo)
+		if err := setupRepo(ctx, cfg, pairLog, res); err != nil {
+			pairLog.Error("Failed to set up mirror repository", "error", err)
+			failed = append(failed, err)
+		}
+	}
+
+	if len(failed) > 0 {
+		err := fmt.Errorf("setup failed for %d of %d repository pairs", len(failed), len(cfgs))
+		return withExitCode(commonExitCode(failed), err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+// errAborted is returned when the user declines the confirmation prompt.
+var errAborted = errors.New("aborted, nothing was changed")
+
+// confirm lists the destructive actions a command is about to take and asks
+// on the terminal whether to go ahead, unless --yes was given. Without a
+// terminal to ask on, the command goes ahead: scripts opt into the actions
+// by running it.
+func confirm(cfg *config.Config, actions []string) error {
+	if cfg.AssumeYes || len(actions) == 0 || !isTerminal(os.Stdin) {
+		return nil
+	}
+
+	// The prompt goes to stderr, stdout may carry the workflow or a result
+	fmt.Fprintln(os.Stderr, "This will:")
+	for _, action := range actions {
+		fmt.Fprintf(os.Stderr, "  - %s\n", action)
+	}
+	fmt.Fprint(os.Stderr, "Continue? [y/N] ")
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return errAborted
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return errAborted
+}
+
+// forceActions returns the description of how syncs with cfg overwrite the
+// mirror, prefixed by what does the syncing, or nothing when they do not.
+func forceActions(cfg *config.Config, syncer string) []string {
+	switch {
+	case cfg.PushMirror:
+		return []string{syncer + " replicates all refs of the primary with git push --mirror, deleting mirror refs the primary does not have"}
+	case cfg.ForceSync:
+		branches := "branch " + cfg.MirrorBranch
+		if len(cfg.MirrorBranches) > 1 {
+			branches = "branches " + strings.Join(cfg.MirrorBranches, ", ")
+		}
+		return []string{fmt.Sprintf("%s force-pushes primary branch %s over mirror %s, discarding commits made only on the mirror (use --force=false to merge instead)",
+			syncer, cfg.PrimaryBranch, branches)}
+	}
+	return nil
+}
+
+// setupActions returns the actions of generating, and with setup
+// installing, the workflow for cfg.
+func setupActions(cfg *config.Config) []string {
+	if cfg.DryRun {
+		return nil
+	}
+
+	actions := forceActions(cfg, "generate a workflow that")
+	if cfg.SetupWorkflow {
+		actions = append(actions, "commit the workflow to "+strings.Join(cfg.MirrorRepos, ", "))
+	}
+	return actions
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/manifest"
+)
+
+// newDeployKeyCmd creates the deploy-key subcommand.
+func newDeployKeyCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "deploy-key",
+		Short: "Create an SSH deploy key for --auth-mode ssh",
+		Long: "Generate an SSH keypair, register the public key as a write-enabled deploy key on the mirror\n" +
+			"and store the private key as the Actions secret used by --auth-mode ssh.\n" +
+			"Deploy keys previously created by this command are replaced.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadMirrorConfig(log)
+			if err != nil {
+				return err
+			}
+
+			secretName := cfg.AuthSecret
+			if secretName == "" {
+				secretName = config.DefaultAuthSecrets[config.AuthModeSSH]
+			}
+
+			githubClient, err := github.NewClient(ctx, cfg, log)
+			if err != nil {
+				return fmt.Errorf("failed to create GitHub client: %w", err)
+			}
+
+			if err := githubClient.SetupDeployKey(ctx, secretName); err != nil {
+				return err
+			}
+			recordInstall(cfg, log, func(install *manifest.Install) {
+				install.DeployKey = true
+				install.AddSecret(manifest.Secret{Name: secretName, Environment: cfg.Environment})
+			})
+			log.Info("Deploy key ready, generate the workflow with --auth-mode ssh", "secret", secretName)
+			return nil
+		},
+	}
+}
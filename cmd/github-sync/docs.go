@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+)
+
+// newDocsCmd creates the docs subcommand.
+func newDocsCmd(log *logger.Logger) *cobra.Command {
+	var man, markdown bool
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:   "docs",
+		Short: "Generate man pages and the command reference",
+		Long: "Write a man page or a Markdown reference page for gh-mirror and each of its subcommands,\n" +
+			"generated from the commands and flags themselves. Man pages are written to the man1\n" +
+			"directory and Markdown pages to the markdown directory of --dir.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !man && !markdown {
+				return withExitCode(exitConfig, fmt.Errorf("--man or --markdown is required"))
+			}
+
+			// Pages without a generation date are reproducible
+			root := cmd.Root()
+			root.DisableAutoGenTag = true
+			if man {
+				manDir := filepath.Join(dir, "man1")
+				if err := os.MkdirAll(manDir, 0755); err != nil {
+					return fmt.Errorf("failed to create documentation directory: %w", err)
+				}
+				header := &doc.GenManHeader{Section: "1", Source: root.Name(), Manual: "User Commands"}
+				if err := doc.GenManTree(root, header, manDir); err != nil {
+					return fmt.Errorf("failed to generate man pages: %w", err)
+				}
+				log.Info("Man pages written", "dir", manDir)
+			}
+			if markdown {
+				markdownDir := filepath.Join(dir, "markdown")
+				if err := os.MkdirAll(markdownDir, 0755); err != nil {
+					return fmt.Errorf("failed to create documentation directory: %w", err)
+				}
+				if err := doc.GenMarkdownTree(root, markdownDir); err != nil {
+					return fmt.Errorf("failed to generate the Markdown reference: %w", err)
+				}
+				log.Info("Markdown reference written", "dir", markdownDir)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&man, "man", false, "Generate man pages")
+	cmd.Flags().BoolVar(&markdown, "markdown", false, "Generate the Markdown command reference")
+	cmd.Flags().StringVar(&dir, "dir", "docs", "Directory to write the documentation to")
+
+	return cmd
+}
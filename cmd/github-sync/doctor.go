@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/git"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+)
+
+// Doctor check states.
+const (
+	doctorOK   = "ok"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+)
+
+// proxyDialTimeout bounds the time spent connecting to a proxy.
+const proxyDialTimeout = 5 * time.Second
+
+// doctorCheck records the outcome of one diagnostic check.
+type doctorCheck struct {
+	Name   string
+	State  string
+	Detail string
+	Fix    string
+}
+
+// newDoctorCmd creates the doctor subcommand.
+func newDoctorCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose the environment and configuration",
+		Long: "Check that git is installed, that the GitHub credentials are present and have the required scopes,\n" +
+			"that the primary and mirror repositories are reachable through the configured proxies and that the\n" +
+			"output file can be written, then list the fixes for the problems found, most important first.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Missing repository URLs are reported as problems, not as errors
+			cfg, err := flags.LoadBase()
+			if err != nil {
+				return withExitCode(exitConfig, fmt.Errorf("failed to load configuration: %w", err))
+			}
+
+			checks := runDoctor(ctx, cfg, log)
+			printDoctorReport(checks)
+
+			failed := 0
+			for _, check := range checks {
+				if check.State == doctorFail {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return withExitCode(exitValidation, fmt.Errorf("doctor found %d problem(s)", failed))
+			}
+			return nil
+		},
+	}
+}
+
+// runDoctor runs all checks, ordered by the importance of their fixes: later
+// checks are of little use until earlier ones pass.
+func runDoctor(ctx context.Context, cfg *config.Config, log *logger.Logger) []doctorCheck {
+	checks := []doctorCheck{checkGit(ctx, cfg)}
+	checks = append(checks, checkCredentials(ctx, cfg, log)...)
+
+	checks = append(checks, checkRepo(ctx, cfg, log, "primary", cfg.PrimaryRepo))
+	if len(cfg.MirrorRepos) == 0 {
+		checks = append(checks, checkRepo(ctx, cfg, log, "mirror", ""))
+	}
+	for _, mirror := range cfg.MirrorRepos {
+		checks = append(checks, checkRepo(ctx, cfg, log, "mirror", mirror))
+	}
+
+	return append(checks, checkOutput(cfg))
+}
+
+// checkGit checks that git can be run. Only --require-signed cannot do
+// without it; otherwise a missing git is a warning.
+func checkGit(ctx context.Context, cfg *config.Config) doctorCheck {
+	check := doctorCheck{Name: "git"}
+	missing := doctorWarn
+	if cfg.RequireSigned {
+		missing = doctorFail
+	}
+
+	gitBinary, err := config.LookGit(cfg.GitPath)
+	if err != nil {
+		check.State, check.Detail = missing, err.Error()
+		check.Fix = "Install git and make sure it is in PATH, or set --git-path"
+		return check
+	}
+	output, err := exec.CommandContext(ctx, gitBinary, "--version").Output()
+	if err != nil {
+		check.State, check.Detail = missing, gitBinary+" cannot be run: "+err.Error()
+		check.Fix = "Reinstall git or point --git-path at a working git binary"
+		return check
+	}
+
+	check.State, check.Detail = doctorOK, strings.TrimSpace(string(output))
+	return check
+}
+
+// checkCredentials checks the GitHub credentials and their permissions on
+// each GitHub mirror, and the API token of mirrors outside GitHub.
+func checkCredentials(ctx context.Context, cfg *config.Config, log *logger.Logger) []doctorCheck {
+	var checks []doctorCheck
+
+	var githubMirrors, otherMirrors []string
+	for _, mirror := range cfg.MirrorRepos {
+		if config.IsGitHubURL(mirror) {
+			githubMirrors = append(githubMirrors, mirror)
+		} else {
+			otherMirrors = append(otherMirrors, mirror)
+		}
+	}
+
+	if len(otherMirrors) > 0 {
+		check := doctorCheck{Name: "mirror token", State: doctorOK, Detail: "MIRROR_TOKEN is set"}
+		if cfg.MirrorToken == "" {
+			check.State, check.Detail = doctorWarn, "MIRROR_TOKEN is not set"
+			check.Fix = "Set MIRROR_TOKEN to an API token of the mirror's forge, which setup needs for mirrors outside GitHub"
+		}
+		checks = append(checks, check)
+	}
+
+	if len(githubMirrors) == 0 {
+		return checks
+	}
+	if cfg.GithubToken == "" && cfg.AppID == 0 {
+		return append(checks, doctorCheck{
+			Name:   "github token",
+			State:  doctorFail,
+			Detail: "no GitHub token or App configured",
+			Fix:    "Set GH_TOKEN or GITHUB_TOKEN to a token with the repo and workflow scopes, or configure a GitHub App with --app-id",
+		})
+	}
+
+	for _, mirror := range githubMirrors {
+		check := doctorCheck{Name: "github token"}
+		mirrorCfg := *cfg
+		mirrorCfg.MirrorRepo = mirror
+
+		githubClient, err := github.NewClient(ctx, &mirrorCfg, log)
+		if err == nil {
+			err = githubClient.Preflight(ctx)
+		}
+		if err != nil {
+			check.State, check.Detail = doctorFail, "cannot set up "+mirror
+			check.Fix = capitalize(err.Error())
+		} else {
+			check.State, check.Detail = doctorOK, "can write contents and workflows of "+mirror
+		}
+		checks = append(checks, check)
+	}
+
+	return checks
+}
+
+// checkRepo checks that the primary or a mirror repository is configured and
+// reachable, including the proxy git connects through.
+func checkRepo(ctx context.Context, cfg *config.Config, log *logger.Logger, role, repoURL string) doctorCheck {
+	check := doctorCheck{Name: role + " repository"}
+	if repoURL == "" {
+		check.State, check.Detail = doctorFail, "not configured"
+		check.Fix = "Pass the " + role + " repository URL with --" + role
+		return check
+	}
+
+	proxy := git.ProxyFor(cfg, repoURL)
+	if proxy != "" {
+		if err := dialProxy(ctx, proxy); err != nil {
+			check.State, check.Detail = doctorFail, "proxy "+proxy+" is unreachable: "+err.Error()
+			check.Fix = proxyFix(cfg, repoURL)
+			return check
+		}
+	}
+
+	// Doctor contacts SSH repositories even when validation would not
+	probeCfg := *cfg
+	probeCfg.ValidateSSH = true
+	token := ""
+	if config.IsGitHubURL(repoURL) {
+		token = cfg.GithubToken
+	}
+
+	refs, err := git.NewClient(cfg.GitPath, log).ListRefs(ctx, &probeCfg, repoURL, token)
+	switch {
+	case err != nil && role == "mirror" && cfg.CreateMissing:
+		check.State, check.Detail = doctorWarn, err.Error()
+		check.Fix = "Make sure " + repoURL + " is reachable, unless it does not exist yet and --create-missing creates it"
+		return check
+	case err != nil:
+		check.State, check.Detail = doctorFail, err.Error()
+		check.Fix = "Check the URL, network connection and credentials of " + repoURL
+		return check
+	}
+
+	check.State, check.Detail = doctorOK, "reachable"
+	if proxy != "" {
+		check.Detail += " through " + proxy
+	}
+	if branch := cfg.PrimaryBranch; role == "primary" && refs != nil {
+		if _, ok := refs["refs/heads/"+branch]; !ok {
+			check.State, check.Detail = doctorFail, "branch "+branch+" not found"
+			check.Fix = "Pass the branch of " + repoURL + " to sync with --primary-branch"
+		}
+	}
+	return check
+}
+
+// dialProxy connects to the host of a proxy URL to check that it is running.
+func dialProxy(ctx context.Context, proxy string) error {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return err
+	}
+	dialer := net.Dialer{Timeout: proxyDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// proxyFix returns the fix for an unreachable proxy of repoURL.
+func proxyFix(cfg *config.Config, repoURL string) string {
+	switch {
+	case cfg.Proxy != "":
+		return "Start the proxy or correct --proxy"
+	case strings.Contains(repoURL, ".i2p"):
+		return "Start the I2P router or point --i2p-proxy at its HTTP proxy"
+	default:
+		return "Start Tor or point --tor-proxy at its SOCKS proxy"
+	}
+}
+
+// checkOutput checks that the workflow output file can be written.
+func checkOutput(cfg *config.Config) doctorCheck {
+	check := doctorCheck{Name: "output file"}
+	if cfg.OutputFile == "" {
+		check.State, check.Detail = doctorOK, "writing to stdout"
+		return check
+	}
+
+	// Missing directories are created when the workflow is written, in the
+	// closest directory that exists
+	dir := filepath.Dir(cfg.OutputFile)
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		check.State, check.Detail = doctorFail, dir+" is not a directory"
+		check.Fix = "Choose another --output"
+		return check
+	}
+
+	// Write access is checked without touching an existing workflow
+	var err error
+	if _, statErr := os.Stat(cfg.OutputFile); statErr == nil {
+		var f *os.File
+		if f, err = os.OpenFile(cfg.OutputFile, os.O_WRONLY, 0); err == nil {
+			f.Close()
+		}
+	} else {
+		var f *os.File
+		if f, err = os.CreateTemp(dir, ".gh-mirror-doctor-*"); err == nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}
+	if err != nil {
+		check.State, check.Detail = doctorFail, cfg.OutputFile+" is not writable"
+		check.Fix = "Fix the permissions of " + cfg.OutputFile + " or choose another --output"
+		return check
+	}
+
+	check.State, check.Detail = doctorOK, cfg.OutputFile+" is writable"
+	return check
+}
+
+// printDoctorReport prints the checks, followed by the fixes of failed
+// checks and then of warnings, each in check order.
+func printDoctorReport(checks []doctorCheck) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tSTATE\tDETAIL")
+	for _, check := range checks {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", check.Name, check.State, check.Detail)
+	}
+	w.Flush()
+
+	var fixes []string
+	for _, state := range []string{doctorFail, doctorWarn} {
+		for _, check := range checks {
+			if check.State == state && check.Fix != "" {
+				fixes = append(fixes, fmt.Sprintf("[%s] %s", check.Name, check.Fix))
+			}
+		}
+	}
+	if len(fixes) == 0 {
+		fmt.Println("\nNo problems found.")
+		return
+	}
+
+	fmt.Println("\nFixes, most important first:")
+	for i, fix := range fixes {
+		fmt.Printf("%d. %s\n", i+1, fix)
+	}
+}
+
+// capitalize returns s with its first letter in upper case.
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/events"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+)
+
+// emitEvent appends event about the mirror of cfg to the --events-file of
+// cfg, if set. The step it describes is done either way, so a failure to
+// write it is only logged.
+func emitEvent(cfg *config.Config, log *logger.Logger, event events.Event) {
+	event.Repo, event.Primary = cfg.MirrorRepo, cfg.PrimaryRepo
+	if err := events.Emit(cfg.EventsFile, event); err != nil {
+		log.Warn("Could not write event", "event", event.Type, "error", err)
+	}
+}
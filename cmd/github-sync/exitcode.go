@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/forge"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/git"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+)
+
+// Exit codes, documented in the README. Scripts branch on them, so existing
+// codes must keep their meaning.
+const (
+	exitOK          = 0
+	exitFailure     = 1
+	exitConfig      = 2
+	exitValidation  = 3
+	exitAPI         = 4
+	exitNetwork     = 5
+	exitRefused     = 6
+	exitTimeout     = 124
+	exitInterrupted = 130
+)
+
+// errTimedOut is the cause of the cancellation of the command context when
+// --timeout expires.
+var errTimedOut = errors.New("command timed out")
+
+// exitError attaches an exit code to an error whose class cannot be told
+// from its type, such as an invalid configuration.
+type exitError struct {
+	code int
+	err  error
+}
+
+// Error implements error.
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode returns err, making the process exit with code if the command
+// fails with it.
+func withExitCode(code int, err error) error {
+	return &exitError{code: code, err: err}
+}
+
+// exitCode returns the exit code for an error returned by a command. Codes
+// attached with withExitCode take precedence, other errors are classified by
+// their type or kind.
+func exitCode(err error) int {
+	var exitErr *exitError
+	var rewriteErr *git.RewriteError
+	var netErr net.Error
+	var forgeErr *forge.APIError
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &exitErr):
+		return exitErr.code
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.As(err, &rewriteErr), errors.Is(err, git.ErrUnsigned), errors.Is(err, git.ErrSecretsFound),
+		errors.Is(err, git.ErrHostKeyMismatch):
+		return exitRefused
+	case errors.Is(err, git.ErrHostKeyNotPinned):
+		return exitConfig
+	case errors.As(err, &netErr):
+		return exitNetwork
+	case github.IsAPIError(err), errors.As(err, &forgeErr), errors.Is(err, config.ErrRateLimited):
+		return exitAPI
+	case errors.Is(err, config.ErrRepoNotFound), errors.Is(err, config.ErrBranchMissing):
+		return exitValidation
+	}
+	return exitFailure
+}
+
+// commonExitCode returns the exit code for a command that failed with errs
+// on several repositories: the code they share, or exitFailure if they differ.
+func commonExitCode(errs []error) int {
+	code := exitFailure
+	for i, err := range errs {
+		if i > 0 && exitCode(err) != code {
+			return exitFailure
+		}
+		code = exitCode(err)
+	}
+	return code
+}
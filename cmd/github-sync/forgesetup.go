@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/events"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/forge"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/workflow"
+)
+
+// scheduleDescription identifies the pipeline schedule of the sync pipeline.
+const scheduleDescription = "gh-mirror sync"
+
+// setupForgeMirror prepares a mirror outside GitHub through the API of its
+// forge: the repository is created and described as configured, and a
+// Forgejo Actions workflow or GitLab pipeline is committed to it, along with
+// the pipeline schedule on GitLab. Workflows that do not run on the mirror's
+// forge are left to be installed where they run.
+func setupForgeMirror(ctx context.Context, cfg *config.Config, log *logger.Logger, workflowYAML string) (mirrorResult, error) {
+	path := workflow.Path(cfg.WorkflowFormat)
+	result := mirrorResult{WorkflowPath: path}
+
+	target, err := forge.NewTarget(ctx, cfg.MirrorForge, cfg.MirrorRepo, cfg.MirrorToken, log)
+	if err != nil {
+		return result, fmt.Errorf("failed to create forge client: %w", err)
+	}
+
+	exists, err := target.RepositoryExists(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to look up mirror repository: %w", err)
+	}
+	if !exists {
+		if !cfg.CreateMissing {
+			return result, config.WithKind(config.ErrRepoNotFound, fmt.Errorf("mirror repository %s does not exist (use --create-missing to create it)", cfg.MirrorRepo))
+		}
+		if cfg.DryRun {
+			log.Info("Would create mirror repository", "private", cfg.PrivateMirror)
+			result.Action = actionDryRun
+			return result, nil
+		}
+		err := target.CreateRepository(ctx, forge.Repository{
+			Description: "Mirror of " + cfg.PrimaryRepo,
+			Private:     cfg.PrivateMirror,
+		})
+		recordForgeChange(cfg, log, audit.OpCreateRepository, cfg.MirrorRepo, "", cfg.MirrorToken, err)
+		if err != nil {
+			return result, fmt.Errorf("failed to create mirror repository: %w", err)
+		}
+		log.Info("Created mirror repository", "private", cfg.PrivateMirror)
+	}
+
+	if cfg.SetMetadata {
+		description := cfg.MirrorDescription
+		if description == "" {
+			description = "Read-only mirror of " + cfg.PrimaryRepo
+		}
+		if cfg.DryRun {
+			log.Info("Would set mirror repository description", "description", description)
+		} else {
+			err := target.SetDescription(ctx, description)
+			recordForgeChange(cfg, log, audit.OpEditRepository, cfg.MirrorRepo, "description", cfg.MirrorToken, err)
+			if err != nil {
+				return result, fmt.Errorf("failed to set mirror repository description: %w", err)
+			}
+		}
+	}
+
+	if !runsOn(cfg.WorkflowFormat, target.Kind()) {
+		log.Info("Workflow is not installed on the mirror, add it to the repository where it runs (use --format forgejo or gitlab to run it on the mirror)",
+			"format", cfg.WorkflowFormat, "forge", target.Kind())
+		result.Action = actionNotInstalled
+		return result, nil
+	}
+
+	schedule := forge.Schedule{Description: scheduleDescription, Cron: workflow.CronSchedule(cfg.SyncInterval)}
+	if cfg.DryRun {
+		log.Info("Would commit workflow to mirror repository", "path", path)
+		if _, ok := target.(forge.Scheduler); ok {
+			log.Info("Would schedule pipeline", "cron", schedule.Cron)
+		}
+		result.Action, result.Changes = actionDryRun, []string{path}
+		return result, nil
+	}
+
+	committed, err := target.PutFile(ctx, path, workflowYAML, "Add repository sync workflow")
+	if committed || err != nil {
+		recordForgeChange(cfg, log, audit.OpCommit, cfg.MirrorRepo, path, cfg.MirrorToken, err)
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to set up %s workflow: %w", target.Kind(), err)
+	}
+	if committed {
+		log.Info("Workflow set up successfully", "path", path, "forge", target.Kind())
+		emitEvent(cfg, log, events.Event{Type: events.TypeCommitCreated, Path: path})
+		result.Action = actionCommitted
+	} else {
+		log.Info("Workflow is up to date", "path", path)
+		result.Action = actionUpToDate
+	}
+
+	// GitLab pipelines are scheduled through the API, not in the pipeline file
+	if scheduler, ok := target.(forge.Scheduler); ok {
+		changed, err := scheduler.EnsureSchedule(ctx, schedule)
+		if changed || err != nil {
+			recordForgeChange(cfg, log, audit.OpUpdateSchedule, cfg.MirrorRepo, scheduleDescription, cfg.MirrorToken, err)
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to schedule sync pipeline: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// runsOn reports whether workflows in format run on the forge of kind.
+func runsOn(format, kind string) bool {
+	switch format {
+	case config.FormatForgejo:
+		return kind == forge.KindGitea
+	case config.FormatGitLab:
+		return kind == forge.KindGitLab
+	}
+	return false
+}
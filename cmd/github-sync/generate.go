@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/diff"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/provenance"
+)
+
+// newGenerateCmd creates the generate subcommand.
+func newGenerateCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	var showDiff, remote bool
+
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate the sync workflow",
+		Long: "Generate the sync workflow and write it to the output file or stdout.\n" +
+			"With --diff, print a unified diff against the existing workflow instead of writing it.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("setup") || cmd.Flags().Changed("setup-via-pr") {
+				return withExitCode(exitConfig, fmt.Errorf("generate does not install the workflow, use the setup subcommand"))
+			}
+			if !showDiff {
+				return run(ctx, log)
+			}
+
+			cfg, err := loadConfig(log)
+			if err != nil {
+				return err
+			}
+			if err := validateRepos(ctx, cfg, log); err != nil {
+				return err
+			}
+
+			workflowYAML, err := generateWorkflow(ctx, cfg, log)
+			if err != nil {
+				return err
+			}
+
+			return diffWorkflow(ctx, cfg, log, workflowYAML, remote)
+		},
+	}
+
+	cmd.Flags().BoolVar(&showDiff, "diff", false, "Print a unified diff against the existing workflow instead of writing it")
+	cmd.Flags().BoolVar(&remote, "remote", false, "With --diff, compare against the workflow installed in the mirror repository instead of the local output file")
+
+	return cmd
+}
+
+// diffWorkflow prints a unified diff between the existing workflow and the generated one.
+func diffWorkflow(ctx context.Context, cfg *config.Config, log *logger.Logger, workflowYAML string, remote bool) error {
+	var existing, name string
+
+	if remote {
+		githubClient, err := github.NewClient(ctx, cfg, log)
+		if err != nil {
+			return fmt.Errorf("failed to create GitHub client: %w", err)
+		}
+		content, found, err := githubClient.GetWorkflow(ctx)
+		if err != nil {
+			return err
+		}
+		if !found {
+			log.Info("No workflow installed in mirror repository", "path", githubClient.WorkflowPath())
+		}
+		existing, name = content, githubClient.WorkflowPath()
+	} else {
+		if cfg.OutputFile == "" {
+			return fmt.Errorf("--diff requires --output or --remote to locate the existing workflow")
+		}
+		content, err := os.ReadFile(cfg.OutputFile)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to read existing workflow file: %w", err)
+		}
+		if err != nil {
+			log.Info("Existing workflow file not found", "file", cfg.OutputFile)
+		}
+		existing, name = string(content), cfg.OutputFile
+	}
+
+	out := diff.Unified("a/"+name, "b/"+name, existing, workflowYAML)
+	if out == "" || provenance.Equal(existing, workflowYAML) {
+		log.Info("Workflow is up to date, no changes", "path", name)
+		return nil
+	}
+
+	fmt.Print(out)
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/git"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+)
+
+// Webhook listener states reported by /healthz and /readyz.
+const (
+	listenerStarting  = "starting"
+	listenerListening = "listening"
+	listenerStopping  = "stopping"
+)
+
+// serveHealth reports the state of serve on /healthz and /readyz: the state
+// of the webhook listener and the last successful sync of each mirror.
+type serveHealth struct {
+	log     *logger.Logger
+	mirrors []string
+
+	// stateDir holds the sync state of the local syncs, empty with --dispatch
+	stateDir string
+
+	mu       sync.Mutex
+	listener string
+
+	// dispatched is the time of the last successful dispatch per mirror
+	dispatched map[string]time.Time
+}
+
+// healthStatus is the response of /healthz and /readyz.
+type healthStatus struct {
+	Status   string         `json:"status"`
+	Listener string         `json:"listener"`
+	Mirrors  []mirrorHealth `json:"mirrors,omitempty"`
+}
+
+// mirrorHealth describes the syncs of one mirror in /readyz.
+type mirrorHealth struct {
+	Repo        string     `json:"repo"`
+	LastSuccess *time.Time `json:"last_success"`
+	SyncedSHA   string     `json:"synced_sha,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	Failures    int        `json:"failures,omitempty"`
+}
+
+// newServeHealth returns the health of serve syncing mirrors, locally with
+// the sync state of stateDir or by dispatch when stateDir is empty.
+func newServeHealth(log *logger.Logger, mirrors []string, stateDir string) *serveHealth {
+	return &serveHealth{
+		log:        log,
+		mirrors:    mirrors,
+		stateDir:   stateDir,
+		listener:   listenerStarting,
+		dispatched: make(map[string]time.Time),
+	}
+}
+
+// setListener records the state of the webhook listener.
+func (h *serveHealth) setListener(state string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listener = state
+}
+
+// recordDispatch records a successful dispatch of the workflow of mirror.
+func (h *serveHealth) recordDispatch(mirror string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dispatched[mirror] = time.Now().UTC()
+}
+
+// register adds /healthz and /readyz to mux.
+func (h *serveHealth) register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", h.healthz)
+	mux.HandleFunc("/readyz", h.readyz)
+}
+
+// healthz answers liveness checks: serve is alive while it answers.
+func (h *serveHealth) healthz(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	status := healthStatus{Status: "ok", Listener: h.listener}
+	h.mu.Unlock()
+
+	writeHealth(w, http.StatusOK, status)
+}
+
+// readyz answers readiness checks: serve is ready while the webhook
+// listener accepts requests. Mirrors that never synced successfully do not
+// make it unready, since a sync only happens after the first push.
+func (h *serveHealth) readyz(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	status := healthStatus{Status: "ready", Listener: h.listener}
+	dispatched := make(map[string]time.Time, len(h.dispatched))
+	for mirror, at := range h.dispatched {
+		dispatched[mirror] = at
+	}
+	h.mu.Unlock()
+
+	var state *git.SyncState
+	if h.stateDir != "" {
+		var err error
+		if state, err = git.LoadSyncState(h.stateDir); err != nil {
+			h.log.Warn("Could not read sync state for readiness check", "error", err)
+		}
+	}
+
+	for _, mirror := range h.mirrors {
+		mh := mirrorHealth{Repo: mirror}
+		if at, ok := dispatched[mirror]; ok {
+			mh.LastSuccess = &at
+		}
+		if ms := stateOf(state, mirror); ms != nil {
+			if !ms.LastSuccess.IsZero() {
+				mh.LastSuccess = &ms.LastSuccess
+			}
+			mh.SyncedSHA, mh.LastError, mh.Failures = ms.SyncedSHA, ms.LastError, ms.Failures
+		}
+		status.Mirrors = append(status.Mirrors, mh)
+	}
+
+	code := http.StatusOK
+	if status.Listener != listenerListening {
+		status.Status, code = "not ready", http.StatusServiceUnavailable
+	}
+	writeHealth(w, code, status)
+}
+
+// stateOf returns the recorded state of mirror, or nil.
+func stateOf(state *git.SyncState, mirror string) *git.MirrorState {
+	if state == nil {
+		return nil
+	}
+	return state.Mirrors[mirror]
+}
+
+// writeHealth writes status as the JSON response of a health check.
+func writeHealth(w http.ResponseWriter, code int, status healthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(status)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/git"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/mirror"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/report"
+)
+
+// lagLimits are how far a mirror may trail its primary before it is
+// reported. Zero limits are not checked.
+type lagLimits struct {
+	commits int
+	age     time.Duration
+}
+
+// addLagFlags adds the flags configuring the lag limits l to cmd.
+func addLagFlags(cmd *cobra.Command, l *lagLimits) {
+	cmd.Flags().IntVar(&l.commits, "max-lag-commits", 0, "Number of primary commits a mirror may be missing before it is reported (0 for no limit)")
+	cmd.Flags().DurationVar(&l.age, "max-lag", 0, "Age of the oldest primary commit a mirror may be missing before it is reported, e.g. 6h (0 for no limit)")
+}
+
+// set reports whether any limit is set.
+func (l lagLimits) set() bool {
+	return l.commits > 0 || l.age > 0
+}
+
+// check returns an error describing how lag exceeds the limits, or nil.
+func (l lagLimits) check(lag *git.Lag) error {
+	switch {
+	case l.commits > 0 && lag.Commits > l.commits:
+		return fmt.Errorf("mirror is missing %d primary commits, more than --max-lag-commits %d", lag.Commits, l.commits)
+	case l.age > 0 && lag.Behind > l.age:
+		return fmt.Errorf("mirror is missing a primary commit from %s ago, more than --max-lag %s", lag.Behind.Round(time.Second), l.age)
+	}
+	return nil
+}
+
+// newLagCmd creates the lag subcommand.
+func newLagCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	var limits lagLimits
+
+	cmd := &cobra.Command{
+		Use:   "lag",
+		Short: "Show how far each mirror trails the primary",
+		Long: "Compare the primary branch with the mirror branch of each mirror and show how many primary\n" +
+			"commits each mirror is missing and how long ago the oldest of them was committed.\n" +
+			"The branch heads are compared by listing the refs; only mirrors that differ are fetched into\n" +
+			"the working copies of --work-dir shared with run. The command fails when a mirror trails\n" +
+			"the primary by more than --max-lag-commits or --max-lag.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(log)
+			if err != nil {
+				return err
+			}
+			if cfg.WorkDir == "" {
+				return withExitCode(exitConfig, fmt.Errorf("--work-dir is required when the user cache directory is unknown"))
+			}
+			if len(cfg.FilterPaths) > 0 || cfg.StripBlobsBiggerThan != "" || cfg.Subdirectory != "" {
+				return withExitCode(exitConfig, fmt.Errorf("the history of mirrors synced with --filter-path, --strip-blobs-bigger-than or --subdirectory differs from the primary, their lag cannot be measured"))
+			}
+			return printLag(ctx, cfg, log, limits)
+		},
+	}
+
+	addLagFlags(cmd, &limits)
+	return cmd
+}
+
+// printLag prints the lag of each mirror of cfg, failing when a mirror
+// cannot be measured or exceeds limits.
+func printLag(ctx context.Context, cfg *config.Config, log *logger.Logger, limits lagLimits) error {
+	var failed []error
+	var exceeded int
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MIRROR\tBRANCH\tPRIMARY SHA\tMIRROR SHA\tCOMMITS\tBEHIND\tSTATUS")
+	for _, mirrorRepo := range cfg.MirrorRepos {
+		mirrorCfg := *cfg
+		mirrorCfg.MirrorRepo = mirrorRepo
+
+		lag, err := mirror.Lag(ctx, &mirrorCfg, log.With("mirror_repo", mirrorRepo))
+		if err != nil {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\t%s\n", mirrorRepo, cfg.MirrorBranch, err)
+			failed = append(failed, err)
+			continue
+		}
+		status := "ok"
+		if err := limits.check(lag); err != nil {
+			status = err.Error()
+			exceeded++
+		}
+		mirrorSHA := "-"
+		if lag.MirrorSHA != "" {
+			mirrorSHA = lag.MirrorSHA[:min(12, len(lag.MirrorSHA))]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", mirrorRepo, cfg.MirrorBranch, lag.PrimarySHA[:min(12, len(lag.PrimarySHA))],
+			mirrorSHA, lag.Commits, lag.Behind.Round(time.Second), status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		err := fmt.Errorf("lag could not be measured for %d of %d mirror repositories", len(failed), len(cfg.MirrorRepos))
+		return withExitCode(commonExitCode(failed), err)
+	}
+	if exceeded > 0 {
+		return fmt.Errorf("%d of %d mirror repositories trail the primary beyond the limits", exceeded, len(cfg.MirrorRepos))
+	}
+	return nil
+}
+
+// checkLag measures the lag of the mirror of cfg after a sync by run or
+// serve, recording it in metrics and reporting it when it exceeds the
+// limits of opts. Nothing is measured unless there are metrics or limits.
+func checkLag(ctx context.Context, cfg *config.Config, log *logger.Logger, metrics *syncMetrics, opts syncOptions) {
+	if metrics == nil && !opts.lag.set() {
+		return
+	}
+
+	lag, err := mirror.Lag(ctx, cfg, log, metrics.githubOptions()...)
+	if err != nil {
+		log.Warn("Could not measure the lag of the mirror", "error", err)
+		return
+	}
+	metrics.recordLag(cfg.MirrorRepo, lag)
+	if err := opts.lag.check(lag); err != nil {
+		log.Warn("Mirror trails the primary beyond the limit", "commits", lag.Commits, "behind", lag.Behind.Round(time.Second), "error", err)
+		sendReport(ctx, log, opts.reporter, report.Report{
+			Level:   report.LevelError,
+			Message: err.Error(),
+			Repo:    cfg.MirrorRepo,
+			Primary: cfg.PrimaryRepo,
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/workflow"
+)
+
+// newListCmd creates the list subcommand.
+func newListCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	var remote bool
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the configured syncs",
+		Long: "Print each configured primary and mirror pair with the branches it syncs and its schedule.\n" +
+			"With --remote, also show whether the sync workflow is installed in each GitHub mirror and\n" +
+			"the conclusion of its last run.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := flags.LoadBase()
+			if err != nil {
+				return withExitCode(exitConfig, fmt.Errorf("failed to load configuration: %w", err))
+			}
+			if len(cfg.MirrorRepos) == 0 {
+				return withExitCode(exitConfig, fmt.Errorf("mirror repository URL is required"))
+			}
+
+			primary := cfg.PrimaryRepo
+			if primary == "" {
+				primary = "-"
+			}
+			branches := cfg.PrimaryBranch + " -> " + strings.Join(cfg.MirrorBranches, ", ")
+			schedule := fmt.Sprintf("%s (%s)", cfg.SyncInterval, workflow.CronSchedule(cfg.SyncInterval))
+			if cfg.PushMirror {
+				branches = "all refs"
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			if remote {
+				fmt.Fprintln(w, "PRIMARY\tMIRROR\tBRANCHES\tSCHEDULE\tWORKFLOW\tLAST RUN")
+			} else {
+				fmt.Fprintln(w, "PRIMARY\tMIRROR\tBRANCHES\tSCHEDULE")
+			}
+			for _, mirror := range cfg.MirrorRepos {
+				if !remote {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", primary, mirror, branches, schedule)
+					continue
+				}
+
+				mirrorCfg := *cfg
+				mirrorCfg.MirrorRepo = mirror
+				installed, lastRun := remoteStatus(ctx, &mirrorCfg, log.With("mirror_repo", mirror))
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", primary, mirror, branches, schedule, installed, lastRun)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().BoolVar(&remote, "remote", false, "Also show whether the workflow is installed in each GitHub mirror and its last run conclusion")
+
+	return cmd
+}
+
+// remoteStatus reports whether the sync workflow is installed in the mirror
+// of cfg and describes its last run. Mirrors outside GitHub, and failures to
+// look them up, are reported as unknown.
+func remoteStatus(ctx context.Context, cfg *config.Config, log *logger.Logger) (string, string) {
+	if !config.IsGitHubURL(cfg.MirrorRepo) || (cfg.GithubToken == "" && cfg.AppID == 0) {
+		return "unknown", "-"
+	}
+
+	githubClient, err := github.NewClient(ctx, cfg, log)
+	if err != nil {
+		log.Debug("Could not create GitHub client", "error", err)
+		return "unknown", "-"
+	}
+	_, found, err := githubClient.GetWorkflow(ctx)
+	switch {
+	case err != nil:
+		log.Debug("Could not look up the sync workflow", "error", err)
+		return "unknown", "-"
+	case !found:
+		return "not installed", "-"
+	}
+	return "installed", lastRunStatus(ctx, githubClient)
+}
+
+// lastRunStatus describes the last sync workflow run on a GitHub mirror.
+func lastRunStatus(ctx context.Context, githubClient *github.Client) string {
+	runs, err := githubClient.RecentRuns(ctx, 1, false)
+	switch {
+	case err != nil:
+		return "unknown"
+	case len(runs) == 0:
+		return "no runs"
+	}
+
+	result := runs[0].Status
+	if runs[0].Conclusion != "" {
+		result = runs[0].Conclusion
+	}
+	return result + " " + ago(runs[0].StartedAt)
+}
@@ -3,112 +3,269 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
 
 	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/events"
 	"i2pgit.org/go-i2p/go-github-sync/pkg/git"
-	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
 	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
 	"i2pgit.org/go-i2p/go-github-sync/pkg/workflow"
-	"github.com/spf13/cobra"
 )
 
+// flags holds the command line flags shared by all subcommands.
+var flags = config.NewFlags()
+
 func main() {
-	log := logger.New(false)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	log := logger.New()
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
 
-	// Setup signal handling
-	c := make(chan os.Signal, 1)
+	// Setup signal handling: the first signal cancels the running command,
+	// which lets long-running commands finish their current work, a second
+	// one exits immediately
+	c := make(chan os.Signal, 2)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
 		log.Info("Received termination signal, shutting down...")
-		cancel()
-		os.Exit(1)
+		cancel(nil)
+		<-c
+		os.Exit(exitInterrupted)
 	}()
 
 	rootCmd := &cobra.Command{
 		Use:   "gh-mirror",
 		Short: "GitHub Mirror Sync Tool",
-		Long:  "Tool for generating GitHub Actions workflow to sync external repositories to GitHub mirrors",
+		Long: "Tool for generating GitHub Actions workflow to sync external repositories to GitHub mirrors.\n" +
+			"Use generate to print or write the workflow, validate to check the repositories, setup to\n" +
+			"install the workflow in the mirrors, run to sync from this machine, status to show recent\n" +
+			"workflow runs and remove to uninstall the workflow. Without a subcommand, generate is run.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := flags.ApplyConfigFile(cmd); err != nil {
+				return withExitCode(exitConfig, err)
+			}
+
+			// Replace the logger in place before any component is created,
+			// subcommands hold the same pointer
+			logger.SetVerbosity(flags.Verbosity())
+			if err := logger.SetFormat(flags.LogFormat()); err != nil {
+				return withExitCode(exitConfig, err)
+			}
+			if err := logger.SetColor(flags.Color()); err != nil {
+				return withExitCode(exitConfig, err)
+			}
+			if flags.OutputFormat() == config.OutputFormatJSON {
+				logger.LogToStderr()
+			}
+			*log = *logger.New()
+
+			// Bound everything the command does, the context is shared by
+			// all subcommands
+			if timeout := flags.Timeout(); timeout > 0 {
+				time.AfterFunc(timeout, func() { cancel(errTimedOut) })
+			}
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return run(ctx, log)
 		},
 	}
 
+	// Credentials in errors printed by cobra are masked like in the logs
+	rootCmd.SetErr(redact.Writer(os.Stderr))
+
 	// Add flags
-	config.AddFlags(rootCmd)
+	flags.AddFlags(rootCmd)
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return withExitCode(exitConfig, err)
+	})
+
+	// Add subcommands
+	rootCmd.AddCommand(newGenerateCmd(ctx, log))
+	rootCmd.AddCommand(newValidateCmd(ctx, log))
+	rootCmd.AddCommand(newSetupCmd(ctx, log))
+	rootCmd.AddCommand(newRemoveCmd(ctx, log))
+	rootCmd.AddCommand(newUninstallCmd(ctx, log))
+	rootCmd.AddCommand(newPlanCmd(ctx, log))
+	rootCmd.AddCommand(newApplyCmd(ctx, log))
+	rootCmd.AddCommand(newSecretsCmd(ctx, log))
+	rootCmd.AddCommand(newStatusCmd(ctx, log))
+	rootCmd.AddCommand(newLagCmd(ctx, log))
+	rootCmd.AddCommand(newOrgSetupCmd(ctx, log))
+	rootCmd.AddCommand(newDeployKeyCmd(ctx, log))
+	rootCmd.AddCommand(newWebhookCmd(ctx, log))
+	rootCmd.AddCommand(newAuditCmd(ctx, log))
+	rootCmd.AddCommand(newRunCmd(ctx, log))
+	rootCmd.AddCommand(newDoctorCmd(ctx, log))
+	rootCmd.AddCommand(newTUICmd(ctx, log))
+	rootCmd.AddCommand(newListCmd(ctx, log))
+	rootCmd.AddCommand(newServeCmd(ctx, log))
+	rootCmd.AddCommand(newDocsCmd(log))
 
 	if err := rootCmd.Execute(); err != nil {
+		if errors.Is(context.Cause(ctx), errTimedOut) {
+			err = withExitCode(exitTimeout, fmt.Errorf("timed out after %s: %w", flags.Timeout(), err))
+		}
 		log.Error("Command execution failed", "error", err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
 func run(ctx context.Context, log *logger.Logger) error {
-	// Parse configuration
-	cfg, err := config.Load()
+	return withResult(log, func(log *logger.Logger, res *runResult) error {
+		return generateOrSetup(ctx, log, res)
+	})
+}
+
+// withResult runs fn, which records its outcome in a result, and prints the
+// result when --output-format json is selected.
+func withResult(log *logger.Logger, fn func(log *logger.Logger, res *runResult) error) error {
+	if flags.OutputFormat() != config.OutputFormatJSON {
+		return fn(log, &runResult{})
+	}
+
+	// Warnings are reported in the result, so they are recorded from the start
+	warnings := logger.RecordWarnings()
+	res := &runResult{}
+	err := fn(logger.New(), res)
+	if printErr := printResult(res, err, warnings); printErr != nil && err == nil {
+		return printErr
+	}
+	return err
+}
+
+// generateOrSetup generates the workflow, and installs it in the mirror
+// repositories when requested, recording the outcome in res.
+func generateOrSetup(ctx context.Context, log *logger.Logger, res *runResult) error {
+	cfg, err := loadConfig(log)
+	if err != nil {
+		return err
+	}
+	if err := confirm(cfg, setupActions(cfg)); err != nil {
+		return err
+	}
+
+	// Install the same workflow in every mirror
+	if cfg.SetupWorkflow && len(cfg.MirrorRepos) > 1 {
+		return setupMirrors(ctx, cfg, log, res)
+	}
+	if cfg.SetupWorkflow {
+		return setupRepo(ctx, cfg, log, res)
+	}
+
+	// Validate Git repositories
+	if err := validateRepos(ctx, cfg, log); err != nil {
+		return err
+	}
+	res.addValidated(append([]string{cfg.PrimaryRepo}, cfg.MirrorRepos...)...)
+
+	// Generate workflow file
+	workflowYAML, err := generateWorkflow(ctx, cfg, log)
+	if err != nil {
+		return err
+	}
+
+	mirror := mirrorResult{Repo: cfg.MirrorRepo, Action: actionWritten, WorkflowPath: cfg.OutputFile}
+	if cfg.OutputFile == "" {
+		mirror.Action = actionGenerated
+	}
+	if cfg.OutputFormat == config.OutputFormatJSON && cfg.OutputFile == "" {
+		// The workflow is part of the result instead of being printed
+		mirror.Workflow = workflowYAML
+		res.Mirrors = append(res.Mirrors, mirror)
+		return nil
+	}
+	if err := writeWorkflow(cfg, log, workflowYAML); err != nil {
+		return err
+	}
+	res.Mirrors = append(res.Mirrors, mirror)
+	return nil
+}
+
+// loadConfig parses the configuration and logs it.
+func loadConfig(log *logger.Logger) (*config.Config, error) {
+	cfg, err := flags.Load()
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return nil, withExitCode(exitConfig, fmt.Errorf("failed to load configuration: %w", err))
 	}
 	log.Info("Configuration loaded successfully",
 		"primary_repo", cfg.PrimaryRepo,
-		"mirror_repo", cfg.MirrorRepo,
+		"mirror_repos", cfg.MirrorRepos,
 		"primary_branch", cfg.PrimaryBranch,
 		"mirror_branch", cfg.MirrorBranch,
 		"sync_interval", cfg.SyncInterval)
-
-	// Update logger verbosity if needed
-	if cfg.Verbose {
-		log = logger.New(true)
+	if cfg.InsecureSkipVerify {
+		log.Warn("TLS certificates of repositories outside GitHub are NOT verified: anyone on the network can intercept the connections and the credentials sent over them; use --ca-cert for a private CA instead of --insecure-skip-verify")
 	}
 
-	// Validate Git repositories
-	gitClient := git.NewClient(log)
-	err = gitClient.ValidateRepos(ctx, cfg)
+	return cfg, nil
+}
+
+// loadMirrorConfig parses the configuration for commands that only use the GitHub API.
+func loadMirrorConfig(log *logger.Logger) (*config.Config, error) {
+	cfg, err := flags.LoadMirror()
 	if err != nil {
-		return fmt.Errorf("repository validation failed: %w", err)
+		return nil, withExitCode(exitConfig, fmt.Errorf("failed to load configuration: %w", err))
+	}
+	log.Debug("Configuration loaded successfully", "mirror_repo", cfg.MirrorRepo)
+
+	return cfg, nil
+}
+
+// validateRepos checks that the configured repositories are accessible.
+func validateRepos(ctx context.Context, cfg *config.Config, log *logger.Logger) error {
+	gitClient := git.NewClient(cfg.GitPath, log)
+	if err := gitClient.ValidateRepos(ctx, cfg); err != nil {
+		return withExitCode(exitValidation, fmt.Errorf("repository validation failed: %w", err))
 	}
 	log.Info("Git repositories validated successfully")
+	emitEvent(cfg, log, events.Event{Type: events.TypeValidated})
+	return nil
+}
 
-	// Setup GitHub client
-	githubClient, err := github.NewClient(ctx, cfg, log)
+// generateWorkflow renders the workflow YAML for the given configuration.
+func generateWorkflow(ctx context.Context, cfg *config.Config, log *logger.Logger) (string, error) {
+	// The SSH host keys of the primary are pinned in the workflow
+	knownHosts, err := git.NewClient(cfg.GitPath, log).HostKeys(ctx, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to create GitHub client: %w", err)
+		return "", fmt.Errorf("failed to pin SSH host keys: %w", err)
 	}
-	log.Info("GitHub client initialized successfully")
+	cfg.KnownHosts = knownHosts
 
-	// Generate workflow file
-	generator := workflow.NewGenerator(cfg, log)
-	workflowYAML, err := generator.Generate()
+	generator := workflow.NewGenerator(workflow.OptionsFromConfig(cfg), log)
+	workflowYAML, err := generator.Generate(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to generate workflow file: %w", err)
+		return "", fmt.Errorf("failed to generate workflow file: %w", err)
 	}
 	log.Info("Workflow file generated successfully")
+	emitEvent(cfg, log, events.Event{Type: events.TypeWorkflowGenerated})
+	return workflowYAML, nil
+}
 
-	// Setup GitHub repository (optional)
-	if cfg.SetupWorkflow {
-		err = githubClient.SetupWorkflow(ctx, workflowYAML)
-		if err != nil {
-			return fmt.Errorf("failed to setup GitHub workflow: %w", err)
-		}
-		log.Info("GitHub workflow set up successfully")
-	} else {
-		// Write workflow to stdout or file
-		if cfg.OutputFile != "" {
-			err = os.WriteFile(cfg.OutputFile, []byte(workflowYAML), 0644)
-			if err != nil {
-				return fmt.Errorf("failed to write workflow to file: %w", err)
-			}
-			log.Info("Workflow written to file", "file", cfg.OutputFile)
-		} else {
-			fmt.Println(workflowYAML)
-		}
+// writeWorkflow writes the workflow to the configured output file or stdout.
+func writeWorkflow(cfg *config.Config, log *logger.Logger, workflowYAML string) error {
+	if cfg.OutputFile == "" {
+		fmt.Println(workflowYAML)
+		return nil
 	}
 
+	// The default output is in .github/workflows, which may not exist yet
+	if err := os.MkdirAll(filepath.Dir(cfg.OutputFile), 0755); err != nil {
+		return fmt.Errorf("failed to create workflow directory: %w", err)
+	}
+	if err := os.WriteFile(cfg.OutputFile, []byte(workflowYAML), 0644); err != nil {
+		return fmt.Errorf("failed to write workflow to file: %w", err)
+	}
+	log.Info("Workflow written to file", "file", cfg.OutputFile)
+	emitEvent(cfg, log, events.Event{Type: events.TypeWorkflowWritten, Path: cfg.OutputFile})
 	return nil
 }
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/git"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+)
+
+// syncDurationBuckets are the upper bounds in seconds of the buckets of the
+// sync duration histogram.
+var syncDurationBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800}
+
+// syncMetrics counts the syncs of serve and run --watch for the /metrics
+// endpoint of --metrics-listen. A nil *syncMetrics records nothing.
+type syncMetrics struct {
+	mu sync.Mutex
+
+	// Per mirror repository
+	attempted map[string]int64
+	succeeded map[string]int64
+	failed    map[string]int64
+	fetched   map[string]int64
+	durations map[string]*histogram
+
+	// Lag of each mirror repository measured after its last sync
+	lagCommits map[string]int64
+	lagSeconds map[string]float64
+
+	// rateLimitRemaining is the remaining GitHub API quota reported by the
+	// last API response, -1 before the first
+	rateLimitRemaining int64
+}
+
+// histogram counts observations in syncDurationBuckets.
+type histogram struct {
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+// newSyncMetrics returns metrics without any sync recorded.
+func newSyncMetrics() *syncMetrics {
+	return &syncMetrics{
+		attempted:          make(map[string]int64),
+		succeeded:          make(map[string]int64),
+		failed:             make(map[string]int64),
+		fetched:            make(map[string]int64),
+		durations:          make(map[string]*histogram),
+		lagCommits:         make(map[string]int64),
+		lagSeconds:         make(map[string]float64),
+		rateLimitRemaining: -1,
+	}
+}
+
+// recordSync records a sync of mirror that took elapsed and fetched the given
+// number of bytes, failing with err unless nil.
+func (m *syncMetrics) recordSync(mirror string, elapsed time.Duration, fetched int64, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.attempted[mirror]++
+	if err != nil {
+		m.failed[mirror]++
+	} else {
+		m.succeeded[mirror]++
+	}
+	m.fetched[mirror] += fetched
+
+	h := m.durations[mirror]
+	if h == nil {
+		h = &histogram{counts: make([]int64, len(syncDurationBuckets))}
+		m.durations[mirror] = h
+	}
+	seconds := elapsed.Seconds()
+	for i, bound := range syncDurationBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// recordLag records lag as the current lag of mirror.
+func (m *syncMetrics) recordLag(mirror string, lag *git.Lag) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lagCommits[mirror] = int64(lag.Commits)
+	m.lagSeconds[mirror] = lag.Behind.Seconds()
+}
+
+// githubOptions returns the options of the GitHub clients reporting the
+// remaining API quota to m.
+func (m *syncMetrics) githubOptions() []github.Option {
+	if m == nil {
+		return nil
+	}
+	return []github.Option{github.WithHooks(github.Hooks{
+		Response: func(req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+			if resp == nil {
+				return
+			}
+			remaining, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Remaining"), 10, 64)
+			if err != nil {
+				return
+			}
+			m.mu.Lock()
+			m.rateLimitRemaining = remaining
+			m.mu.Unlock()
+		},
+	})}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *syncMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// write writes the metrics in the Prometheus text format to w.
+func (m *syncMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counters := []struct {
+		name, help string
+		values     map[string]int64
+	}{
+		{"gh_mirror_syncs_attempted_total", "Syncs attempted per mirror repository.", m.attempted},
+		{"gh_mirror_syncs_succeeded_total", "Syncs that succeeded per mirror repository.", m.succeeded},
+		{"gh_mirror_syncs_failed_total", "Syncs that failed per mirror repository.", m.failed},
+		{"gh_mirror_fetched_bytes_total", "Growth of the object store of the working copy per mirror repository, an estimate of the bytes fetched.", m.fetched},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, mirror := range sortedKeys(c.values) {
+			fmt.Fprintf(w, "%s{mirror=%s} %d\n", c.name, quoteLabel(mirror), c.values[mirror])
+		}
+	}
+
+	const duration = "gh_mirror_sync_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Duration of the syncs per mirror repository.\n# TYPE %s histogram\n", duration, duration)
+	for _, mirror := range sortedKeys(m.durations) {
+		h, label := m.durations[mirror], quoteLabel(mirror)
+		for i, bound := range syncDurationBuckets {
+			fmt.Fprintf(w, "%s_bucket{mirror=%s,le=\"%s\"} %d\n", duration, label, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{mirror=%s,le=\"+Inf\"} %d\n", duration, label, h.count)
+		fmt.Fprintf(w, "%s_sum{mirror=%s} %s\n", duration, label, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{mirror=%s} %d\n", duration, label, h.count)
+	}
+
+	// The lag is only measured with limits or metrics, after each sync
+	if len(m.lagCommits) > 0 {
+		const commits, seconds = "gh_mirror_lag_commits", "gh_mirror_lag_seconds"
+		fmt.Fprintf(w, "# HELP %s Primary commits missing on the mirror branch after the last sync per mirror repository.\n# TYPE %s gauge\n", commits, commits)
+		for _, mirror := range sortedKeys(m.lagCommits) {
+			fmt.Fprintf(w, "%s{mirror=%s} %d\n", commits, quoteLabel(mirror), m.lagCommits[mirror])
+		}
+		fmt.Fprintf(w, "# HELP %s Age of the oldest primary commit missing on the mirror branch after the last sync per mirror repository.\n# TYPE %s gauge\n", seconds, seconds)
+		for _, mirror := range sortedKeys(m.lagSeconds) {
+			fmt.Fprintf(w, "%s{mirror=%s} %s\n", seconds, quoteLabel(mirror), strconv.FormatFloat(m.lagSeconds[mirror], 'f', 0, 64))
+		}
+	}
+
+	// The quota is only known once the GitHub API was used
+	if m.rateLimitRemaining >= 0 {
+		const remaining = "gh_mirror_github_rate_limit_remaining"
+		fmt.Fprintf(w, "# HELP %s Remaining GitHub API requests reported by the last API response.\n# TYPE %s gauge\n", remaining, remaining)
+		fmt.Fprintf(w, "%s %d\n", remaining, m.rateLimitRemaining)
+	}
+}
+
+// serveMetrics serves the metrics on /metrics of listen until ctx is done.
+// The listener is opened before returning, so a busy address is reported
+// right away.
+func serveMetrics(ctx context.Context, log *logger.Logger, listen string, m *syncMetrics) error {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return withExitCode(exitNetwork, fmt.Errorf("failed to serve metrics: %w", err))
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("Failed to serve metrics", "error", err)
+		}
+	}()
+
+	log.Info("Serving metrics", "address", listener.Addr().String(), "path", "/metrics")
+	return nil
+}
+
+// sortedKeys returns the keys of values in order.
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// quoteLabel quotes a label value, escaping backslashes, double quotes and
+// newlines as the Prometheus text format requires.
+func quoteLabel(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/notify"
+)
+
+// sendNotification posts event to the --notify-url of cfg, if set. Failing
+// to notify is only worth a warning, the sync or setup is done either way.
+func sendNotification(ctx context.Context, cfg *config.Config, log *logger.Logger, event notify.Event) {
+	if cfg.NotifyURL == "" {
+		return
+	}
+	event.Primary = cfg.PrimaryRepo
+	if err := notify.Post(ctx, cfg.NotifyURL, event); err != nil {
+		log.Warn("Could not send notification", "event", event.Event, "error", err)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/provenance"
+)
+
+// orgResult records the outcome of installing the workflow in one repository.
+type orgResult struct {
+	Repo    string
+	Primary string
+	Result  string
+	Detail  string
+}
+
+// newOrgSetupCmd creates the org-setup subcommand.
+func newOrgSetupCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	var org, primaryBase, mappingFile string
+	var includeForks bool
+	var skip []string
+
+	cmd := &cobra.Command{
+		Use:   "org-setup",
+		Short: "Install the sync workflow in every repository of a GitHub organization",
+		Long: "Enumerate the repositories of a GitHub organization and install the sync workflow in each.\n" +
+			"Primary URLs are derived as <primary-base>/<repo>.git unless listed in a mapping file,\n" +
+			"a YAML document mapping GitHub repository names to primary repository URLs.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := flags.LoadBase()
+			if err != nil {
+				return withExitCode(exitConfig, fmt.Errorf("failed to load configuration: %w", err))
+			}
+			if cfg.GithubToken == "" && cfg.AppID == 0 {
+				return fmt.Errorf("GitHub token not found in environment (GH_TOKEN or GITHUB_TOKEN) but required for org-setup")
+			}
+			if primaryBase == "" && mappingFile == "" {
+				return fmt.Errorf("--primary-base or --mapping is required to derive primary repository URLs")
+			}
+
+			actions := append([]string{"commit the sync workflow to every repository of " + org}, forceActions(cfg, "the workflow")...)
+			if err := confirm(cfg, actions); err != nil {
+				return err
+			}
+
+			mapping, err := loadMapping(mappingFile)
+			if err != nil {
+				return err
+			}
+
+			orgClient, err := github.NewOrgClient(ctx, cfg, log, org)
+			if err != nil {
+				return fmt.Errorf("failed to create GitHub client: %w", err)
+			}
+			repos, err := orgClient.ListRepositories(ctx)
+			if err != nil {
+				return err
+			}
+
+			skipped := make(map[string]bool)
+			for _, name := range skip {
+				skipped[name] = true
+			}
+
+			results := make([]orgResult, 0, len(repos))
+			var candidates []string
+			for _, repo := range repos {
+				name := repo.GetName()
+				result := orgResult{Repo: name}
+
+				primary, mapped := mapping[name]
+				switch {
+				case skipped[name]:
+					result.Result, result.Detail = "skipped", "listed in --skip"
+				case repo.GetArchived():
+					result.Result, result.Detail = "skipped", "archived"
+				case repo.GetFork() && !includeForks:
+					result.Result, result.Detail = "skipped", "fork"
+				case !mapped && primaryBase == "":
+					result.Result, result.Detail = "skipped", "not in mapping"
+				default:
+					if !mapped {
+						primary = strings.TrimSuffix(primaryBase, "/") + "/" + name + ".git"
+					}
+					result.Primary = primary
+					candidates = append(candidates, name)
+				}
+				results = append(results, result)
+			}
+
+			// Fetch the installed workflows in bulk to skip repositories that are up to date
+			metadata, err := orgClient.RepositoryMetadata(ctx, candidates)
+			if err != nil {
+				log.Warn("Could not fetch repository metadata, setting up every repository", "error", err)
+			}
+
+			failed := 0
+			for i, repo := range repos {
+				result := &results[i]
+				if result.Primary == "" {
+					continue
+				}
+
+				repoCfg := *cfg
+				repoCfg.PrimaryRepo = result.Primary
+				repoCfg.MirrorRepo = repo.GetHTMLURL()
+				repoCfg.SetupWorkflow = true
+
+				repoLog := log.With("repo", result.Repo)
+				if upToDate(ctx, &repoCfg, repoLog, metadata[result.Repo]) {
+					result.Result = "up-to-date"
+					continue
+				}
+				if err := setupRepo(ctx, &repoCfg, repoLog, &runResult{}); err != nil {
+					repoLog.Error("Failed to set up repository", "error", err)
+					result.Result, result.Detail = "failed", err.Error()
+					failed++
+				} else {
+					result.Result = "installed"
+				}
+			}
+
+			printOrgReport(results)
+			if failed > 0 {
+				return fmt.Errorf("setup failed for %d of %d repositories", failed, len(repos))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&org, "org", "", "GitHub organization whose repositories receive the workflow (required)")
+	cmd.Flags().StringVar(&primaryBase, "primary-base", "", "Base URL of the primary repositories, e.g. https://i2pgit.org/go-i2p")
+	cmd.Flags().StringVar(&mappingFile, "mapping", "", "YAML file mapping GitHub repository names to primary repository URLs")
+	cmd.Flags().BoolVar(&includeForks, "include-forks", false, "Also install the workflow in forked repositories")
+	cmd.Flags().StringSliceVar(&skip, "skip", nil, "Repository names to leave untouched")
+	cmd.MarkFlagRequired("org")
+
+	return cmd
+}
+
+// upToDate reports whether the installed workflow described by meta matches
+// the generated one and the configuration requests no other setup step, so
+// the repository can be skipped without further API calls.
+func upToDate(ctx context.Context, cfg *config.Config, log *logger.Logger, meta *github.RepoMetadata) bool {
+	if meta == nil || !meta.HasWorkflow {
+		return false
+	}
+	if cfg.ReadmeBanner || cfg.SetMetadata || cfg.ReplaceExisting || cfg.ConfigureProtection ||
+		cfg.Environment != "" || cfg.VerifyRun || cfg.DryRun {
+		return false
+	}
+
+	workflowYAML, err := generateWorkflow(ctx, cfg, log)
+	if err != nil {
+		return false
+	}
+	if !provenance.Equal(meta.Workflow, workflowYAML) {
+		return false
+	}
+
+	if meta.Protects(cfg.MirrorBranch) {
+		log.Info("Mirror branch is protected, make sure the sync workflow may push to it", "branch", cfg.MirrorBranch)
+	}
+	return true
+}
+
+// loadMapping reads a YAML mapping of repository names to primary URLs.
+func loadMapping(path string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	if path == "" {
+		return mapping, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping file: %w", err)
+	}
+
+	return mapping, nil
+}
+
+// printOrgReport prints a summary table of the org-setup results.
+func printOrgReport(results []orgResult) {
+	counts := make(map[string]int)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tPRIMARY\tRESULT\tDETAIL")
+	for _, r := range results {
+		counts[r.Result]++
+		primary := r.Primary
+		if primary == "" {
+			primary = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Repo, primary, r.Result, r.Detail)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d installed, %d up to date, %d skipped, %d failed\n", counts["installed"], counts["up-to-date"], counts["skipped"], counts["failed"])
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/diff"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/manifest"
+)
+
+// planVersion is the version of the plan file format written by plan.
+const planVersion = 1
+
+// How planned secrets are added.
+const (
+	// secretDeployKey secrets are added by apply, generating a deploy key
+	secretDeployKey = "deploy-key"
+	// secretManual secrets hold values only the user has, apply checks them
+	secretManual = "manual"
+)
+
+// rolloutPlan is the set of changes computed by plan and executed by apply.
+type rolloutPlan struct {
+	Version int          `json:"version"`
+	Created time.Time    `json:"created"`
+	Primary string       `json:"primary_repo"`
+	Mirrors []mirrorPlan `json:"mirrors"`
+}
+
+// mirrorPlan is the set of changes planned for one mirror repository.
+type mirrorPlan struct {
+	Repo             string               `json:"repo"`
+	CreateRepository bool                 `json:"create_repository,omitempty"`
+	Private          bool                 `json:"private,omitempty"`
+	Environment      string               `json:"environment,omitempty"`
+	Workflow         *github.WorkflowPlan `json:"workflow"`
+	Secrets          []plannedSecret      `json:"secrets,omitempty"`
+}
+
+// plannedSecret is an Actions secret the workflow needs that is missing.
+type plannedSecret struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`
+}
+
+// empty reports whether nothing is planned for the mirror.
+func (m *mirrorPlan) empty() bool {
+	return !m.CreateRepository && len(m.Workflow.Files) == 0 && len(m.Secrets) == 0
+}
+
+// newPlanCmd creates the plan subcommand.
+func newPlanCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	var out string
+	var showDiff bool
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Show the changes setting up the mirrors would make",
+		Long: "Compute the changes installing the workflow would make to each GitHub mirror: repositories\n" +
+			"to create, workflow and README files to create or update, and Actions secrets to add.\n" +
+			"With --out, save them to a plan file that apply executes later, so a rollout can be\n" +
+			"reviewed before anything is changed. Nothing is written to the mirrors.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(log)
+			if err != nil {
+				return err
+			}
+			if err := validateRepos(ctx, cfg, log); err != nil {
+				return err
+			}
+			workflowYAML, err := generateWorkflow(ctx, cfg, log)
+			if err != nil {
+				return err
+			}
+
+			plan := &rolloutPlan{Version: planVersion, Created: time.Now().UTC(), Primary: cfg.PrimaryRepo}
+			var failed []error
+			for _, mirror := range cfg.MirrorRepos {
+				mirrorLog := log.With("mirror_repo", mirror)
+				if !config.IsGitHubURL(mirror) {
+					mirrorLog.Warn("Plans only cover GitHub mirrors, use setup for this mirror")
+					continue
+				}
+
+				mirrorCfg := *cfg
+				mirrorCfg.MirrorRepo = mirror
+				mp, err := planMirror(ctx, &mirrorCfg, mirrorLog, workflowYAML)
+				if err != nil {
+					mirrorLog.Error("Failed to plan mirror repository", "error", err)
+					failed = append(failed, err)
+					continue
+				}
+				plan.Mirrors = append(plan.Mirrors, *mp)
+			}
+			if len(failed) > 0 {
+				err := fmt.Errorf("planning failed for %d of %d mirror repositories", len(failed), len(cfg.MirrorRepos))
+				return withExitCode(commonExitCode(failed), err)
+			}
+
+			if cfg.OutputFormat == config.OutputFormatJSON {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(plan)
+			}
+			printPlan(plan, showDiff)
+
+			if out == "" {
+				return nil
+			}
+			data, err := json.MarshalIndent(plan, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode plan: %w", err)
+			}
+			if err := os.WriteFile(out, append(data, '\n'), 0644); err != nil {
+				return fmt.Errorf("failed to write plan file: %w", err)
+			}
+			log.Info("Plan saved, run apply to execute it", "file", out)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&out, "out", "", "Save the plan to this file for apply")
+	cmd.Flags().BoolVar(&showDiff, "diff", false, "Print a unified diff of each planned file")
+
+	return cmd
+}
+
+// planMirror computes the changes setting up the mirror of cfg would make.
+func planMirror(ctx context.Context, cfg *config.Config, log *logger.Logger, workflowYAML string) (*mirrorPlan, error) {
+	githubClient, err := github.NewClient(ctx, cfg, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client: %w", err)
+	}
+
+	mp := &mirrorPlan{Repo: cfg.MirrorRepo, Environment: cfg.Environment}
+	exists, err := githubClient.RepositoryExists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if !cfg.CreateMissing {
+			return nil, config.WithKind(config.ErrRepoNotFound, fmt.Errorf("mirror repository %s does not exist (use --create-missing to create it)", cfg.MirrorRepo))
+		}
+		mp.CreateRepository, mp.Private = true, cfg.PrivateMirror
+	}
+
+	if mp.Workflow, err = githubClient.PlanWorkflow(ctx, workflowYAML); err != nil {
+		return nil, fmt.Errorf("failed to plan workflow setup: %w", err)
+	}
+
+	for _, name := range []string{cfg.AuthSecret, cfg.TorProxySecret} {
+		if name == "" {
+			continue
+		}
+		found := false
+		if exists {
+			if found, err = githubClient.HasSecret(ctx, name); err != nil {
+				return nil, err
+			}
+		}
+		if found {
+			continue
+		}
+		action := secretManual
+		if name == cfg.AuthSecret && cfg.AuthMode == config.AuthModeSSH {
+			action = secretDeployKey
+		}
+		mp.Secrets = append(mp.Secrets, plannedSecret{Name: name, Action: action})
+	}
+
+	return mp, nil
+}
+
+// printPlan prints a summary of plan, with the diff of each planned file if
+// showDiff is set.
+func printPlan(plan *rolloutPlan, showDiff bool) {
+	color := isTerminal(os.Stdout)
+	var repos, created, updated, secrets int
+
+	for _, mp := range plan.Mirrors {
+		if mp.empty() {
+			fmt.Printf("  %s: up to date\n", mp.Repo)
+			continue
+		}
+		fmt.Printf("~ %s\n", mp.Repo)
+		if mp.CreateRepository {
+			visibility := "public"
+			if mp.Private {
+				visibility = "private"
+			}
+			fmt.Printf("    + create %s repository\n", visibility)
+			repos++
+		}
+		for _, file := range mp.Workflow.Files {
+			if file.Previous == "" {
+				fmt.Printf("    + create %s\n", file.Path)
+				created++
+			} else {
+				fmt.Printf("    ~ update %s\n", file.Path)
+				updated++
+			}
+			if showDiff {
+				out := diff.Unified("a/"+file.Path, "b/"+file.Path, file.Previous, file.Content)
+				if color {
+					out = diff.Colorize(out)
+				}
+				fmt.Print(out)
+			}
+		}
+		for _, secret := range mp.Secrets {
+			if secret.Action == secretDeployKey {
+				fmt.Printf("    + add secret %s with a new deploy key\n", secret.Name)
+			} else {
+				fmt.Printf("    ! add secret %s before apply (secrets set %s)\n", secret.Name, secret.Name)
+			}
+			secrets++
+		}
+	}
+
+	fmt.Printf("\nPlan: %d repositories to create, %d files to create, %d to update, %d secrets to add\n",
+		repos, created, updated, secrets)
+}
+
+// newApplyCmd creates the apply subcommand.
+func newApplyCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "apply PLAN",
+		Short: "Execute a plan saved by plan",
+		Long: "Make the changes saved by plan --out: create the planned repositories, commit the planned files\n" +
+			"and add the deploy key secrets. Mirrors whose files changed since the plan was made are not\n" +
+			"changed, plan again for them. Secrets that hold values only you have must be added with\n" +
+			"secrets set; apply checks that they exist.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := flags.LoadBase()
+			if err != nil {
+				return withExitCode(exitConfig, fmt.Errorf("failed to load configuration: %w", err))
+			}
+			if cfg.GithubToken == "" && cfg.AppID == 0 {
+				return withExitCode(exitConfig, fmt.Errorf("GitHub token not found in environment (GH_TOKEN or GITHUB_TOKEN) but required for apply"))
+			}
+
+			plan, err := loadPlan(args[0])
+			if err != nil {
+				return withExitCode(exitConfig, err)
+			}
+
+			var actions []string
+			for _, mp := range plan.Mirrors {
+				if !mp.empty() {
+					actions = append(actions, "apply the planned changes to "+mp.Repo)
+				}
+			}
+			if len(actions) == 0 {
+				log.Info("Plan has no changes", "file", args[0])
+				return nil
+			}
+			if err := confirm(cfg, actions); err != nil {
+				return err
+			}
+
+			var failed []error
+			for _, mp := range plan.Mirrors {
+				if mp.empty() {
+					continue
+				}
+				mirrorCfg := *cfg
+				mirrorCfg.PrimaryRepo = plan.Primary
+				mirrorCfg.MirrorRepo = mp.Repo
+				mirrorCfg.CreateMissing, mirrorCfg.PrivateMirror = mp.CreateRepository, mp.Private
+				mirrorCfg.Environment = mp.Environment
+
+				mirrorLog := log.With("mirror_repo", mp.Repo)
+				if err := applyMirror(ctx, &mirrorCfg, mirrorLog, &mp); err != nil {
+					mirrorLog.Error("Failed to apply plan", "error", err)
+					failed = append(failed, err)
+				}
+			}
+
+			if len(failed) > 0 {
+				err := fmt.Errorf("apply failed for %d of %d mirror repositories", len(failed), len(actions))
+				return withExitCode(commonExitCode(failed), err)
+			}
+			return nil
+		},
+	}
+}
+
+// loadPlan reads a plan file written by plan.
+func loadPlan(path string) (*rolloutPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %w", err)
+	}
+
+	var plan rolloutPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan file: %w", err)
+	}
+	if plan.Version != planVersion {
+		return nil, fmt.Errorf("unsupported plan file version %d, plan again with this version", plan.Version)
+	}
+	for i := range plan.Mirrors {
+		if plan.Mirrors[i].Workflow == nil {
+			plan.Mirrors[i].Workflow = &github.WorkflowPlan{}
+		}
+	}
+	return &plan, nil
+}
+
+// applyMirror makes the changes of mp to the mirror repository of cfg.
+func applyMirror(ctx context.Context, cfg *config.Config, log *logger.Logger, mp *mirrorPlan) error {
+	githubClient, err := github.NewClient(ctx, cfg, log)
+	if err != nil {
+		return fmt.Errorf("failed to create GitHub client: %w", err)
+	}
+
+	if mp.CreateRepository {
+		if err := githubClient.EnsureRepository(ctx); err != nil {
+			return err
+		}
+	}
+
+	if _, err := githubClient.ApplyWorkflowPlan(ctx, mp.Workflow); err != nil {
+		return err
+	}
+	recordInstall(cfg, log, func(install *manifest.Install) {
+		for _, file := range mp.Workflow.Files {
+			if file.Path == githubClient.WorkflowPath() {
+				install.WorkflowFormat = config.FormatGitHub
+			} else {
+				install.ReadmeBanner = true
+			}
+		}
+	})
+
+	for _, secret := range mp.Secrets {
+		if secret.Action == secretDeployKey {
+			if err := githubClient.SetupDeployKey(ctx, secret.Name); err != nil {
+				return err
+			}
+			recordInstall(cfg, log, func(install *manifest.Install) {
+				install.DeployKey = true
+				install.AddSecret(manifest.Secret{Name: secret.Name, Environment: cfg.Environment})
+			})
+			continue
+		}
+		found, err := githubClient.HasSecret(ctx, secret.Name)
+		if err != nil {
+			return err
+		}
+		if !found {
+			log.Warn("Secret is still missing, the workflow fails until it is added with secrets set", "secret", secret.Name)
+		}
+	}
+	return nil
+}
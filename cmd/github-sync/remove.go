@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/forge"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/workflow"
+)
+
+// newRemoveCmd creates the remove subcommand.
+func newRemoveCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove",
+		Short: "Remove the sync workflow from the mirror repositories",
+		Long: "Delete the sync workflow installed by setup from each mirror repository, and on GitLab\n" +
+			"the pipeline schedule that runs it. The mirrored branches are left untouched.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := flags.LoadBase()
+			if err != nil {
+				return withExitCode(exitConfig, fmt.Errorf("failed to load configuration: %w", err))
+			}
+			if len(cfg.MirrorRepos) == 0 {
+				return withExitCode(exitConfig, fmt.Errorf("mirror repository URL is required"))
+			}
+			if err := confirm(cfg, []string{"remove the sync workflow from " + strings.Join(cfg.MirrorRepos, ", ")}); err != nil {
+				return err
+			}
+
+			var failed []error
+			for _, mirror := range cfg.MirrorRepos {
+				mirrorCfg := *cfg
+				mirrorCfg.MirrorRepo = mirror
+
+				mirrorLog := log.With("mirror_repo", mirror)
+				if err := removeWorkflow(ctx, &mirrorCfg, mirrorLog); err != nil {
+					mirrorLog.Error("Failed to remove sync workflow", "error", err)
+					failed = append(failed, err)
+				}
+			}
+
+			if len(failed) > 0 {
+				err := fmt.Errorf("removal failed for %d of %d mirror repositories", len(failed), len(cfg.MirrorRepos))
+				return withExitCode(commonExitCode(failed), err)
+			}
+			return nil
+		},
+	}
+}
+
+// removeWorkflow deletes the sync workflow from the mirror repository of cfg.
+func removeWorkflow(ctx context.Context, cfg *config.Config, log *logger.Logger) error {
+	if !config.IsGitHubURL(cfg.MirrorRepo) {
+		return removeForgeWorkflow(ctx, cfg, log)
+	}
+
+	githubClient, err := github.NewClient(ctx, cfg, log)
+	if err != nil {
+		return fmt.Errorf("failed to create GitHub client: %w", err)
+	}
+	removed, err := githubClient.RemoveSyncWorkflow(ctx)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		log.Info("Sync workflow is not installed", "path", githubClient.WorkflowPath())
+	}
+	return nil
+}
+
+// removeForgeWorkflow deletes the sync workflow, and its pipeline schedule,
+// from a mirror repository on another forge.
+func removeForgeWorkflow(ctx context.Context, cfg *config.Config, log *logger.Logger) error {
+	path := workflow.Path(cfg.WorkflowFormat)
+
+	target, err := forge.NewTarget(ctx, cfg.MirrorForge, cfg.MirrorRepo, cfg.MirrorToken, log)
+	if err != nil {
+		return fmt.Errorf("failed to create forge client: %w", err)
+	}
+	if !runsOn(cfg.WorkflowFormat, target.Kind()) {
+		log.Info("Workflow is not installed on the mirror, remove it from the repository where it runs",
+			"format", cfg.WorkflowFormat, "forge", target.Kind())
+		return nil
+	}
+
+	removed, err := target.DeleteFile(ctx, path, "Remove repository sync workflow")
+	if removed || err != nil {
+		recordForgeChange(cfg, log, audit.OpDeleteFile, cfg.MirrorRepo, path, cfg.MirrorToken, err)
+	}
+	if err != nil {
+		return err
+	}
+	if removed {
+		log.Info("Removed sync workflow", "path", path)
+	} else {
+		log.Info("Sync workflow is not installed", "path", path)
+	}
+
+	if scheduler, ok := target.(forge.Scheduler); ok {
+		removed, err := scheduler.RemoveSchedule(ctx, scheduleDescription)
+		if removed || err != nil {
+			recordForgeChange(cfg, log, audit.OpDeleteSchedule, cfg.MirrorRepo, scheduleDescription, cfg.MirrorToken, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
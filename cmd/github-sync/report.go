@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/report"
+)
+
+// errorReportFlags configure where failed syncs and panics are reported.
+type errorReportFlags struct {
+	sentryDSN string
+	command   string
+}
+
+// addReportFlags adds the flags configuring error reports f to cmd.
+func addReportFlags(cmd *cobra.Command, f *errorReportFlags) {
+	cmd.Flags().StringVar(&f.sentryDSN, "sentry-dsn", "", "Sentry DSN to report failed syncs and panics to (env SENTRY_DSN)")
+	cmd.Flags().StringVar(&f.command, "error-command", "", "Shell command run for each failed sync and panic, with the report as JSON on standard input")
+}
+
+// loadReporter returns the reporter configured by the flags, or nil when
+// errors are not reported.
+func loadReporter(f *errorReportFlags) (report.Reporter, error) {
+	dsn := f.sentryDSN
+	if dsn == "" {
+		dsn = os.Getenv("SENTRY_DSN")
+	}
+	switch {
+	case dsn != "" && f.command != "":
+		return nil, withExitCode(exitConfig, fmt.Errorf("--sentry-dsn and --error-command cannot be combined"))
+	case dsn != "":
+		sentry, err := report.NewSentry(dsn)
+		if err != nil {
+			return nil, withExitCode(exitConfig, err)
+		}
+		return sentry, nil
+	case f.command != "":
+		return report.Command(f.command), nil
+	}
+	return nil, nil
+}
+
+// sendReport sends r to reporter unless it is nil. A report that cannot be
+// sent is only logged, it must not make matters worse.
+func sendReport(ctx context.Context, log *logger.Logger, reporter report.Reporter, r report.Report) {
+	if reporter == nil {
+		return
+	}
+	r.Message, r.Stack = redact.String(r.Message), redact.String(r.Stack)
+	r.Time = time.Now().UTC()
+	if err := reporter.Report(ctx, r); err != nil {
+		log.Warn("Could not report error", "message", r.Message, "error", err)
+	}
+}
+
+// reportPanic reports a panic of the calling function, naming the mirror
+// *repo it was syncing, and panics again, so the process still crashes as it
+// would without a reporter. It must be deferred.
+func reportPanic(ctx context.Context, log *logger.Logger, reporter report.Reporter, primary string, repo *string) {
+	if reporter == nil {
+		return
+	}
+	if v := recover(); v != nil {
+		sendReport(context.WithoutCancel(ctx), log, reporter, report.Report{
+			Level:   report.LevelFatal,
+			Message: fmt.Sprintf("panic: %v", v),
+			Repo:    *repo,
+			Primary: primary,
+			Stack:   string(debug.Stack()),
+		})
+		panic(v)
+	}
+}
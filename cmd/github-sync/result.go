@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+)
+
+// Actions taken for a mirror repository, reported by --output-format json.
+const (
+	actionGenerated    = "generated"
+	actionWritten      = "written"
+	actionCommitted    = "committed"
+	actionUpToDate     = "up-to-date"
+	actionPullRequest  = "pull-request"
+	actionDryRun       = "dry-run"
+	actionNotInstalled = "not-installed"
+	actionFailed       = "failed"
+)
+
+// runResult is the outcome of the root command, printed as a JSON object by
+// --output-format json.
+type runResult struct {
+	Status    string           `json:"status"`
+	Error     string           `json:"error,omitempty"`
+	Validated []string         `json:"validated_repos"`
+	Mirrors   []mirrorResult   `json:"mirrors"`
+	Warnings  []logger.Warning `json:"warnings"`
+}
+
+// mirrorResult is the outcome for one mirror repository.
+type mirrorResult struct {
+	Repo         string `json:"repo"`
+	Action       string `json:"action"`
+	WorkflowPath string `json:"workflow_path,omitempty"`
+
+	// Commit is the SHA of the commit created, if known
+	Commit      string `json:"commit,omitempty"`
+	PullRequest string `json:"pull_request,omitempty"`
+
+	// Changes lists the files a dry run would commit
+	Changes []string `json:"changes,omitempty"`
+
+	// Workflow is the generated workflow when no output file is set
+	Workflow string `json:"workflow,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// addValidated records repositories that passed validation, once each.
+func (r *runResult) addValidated(repos ...string) {
+	for _, repo := range repos {
+		known := false
+		for _, validated := range r.Validated {
+			known = known || validated == repo
+		}
+		if !known {
+			r.Validated = append(r.Validated, repo)
+		}
+	}
+}
+
+// printResult completes the result with the outcome of the command and the
+// recorded warnings, and prints it to stdout.
+func printResult(res *runResult, err error, warnings *logger.Warnings) error {
+	res.Status = "ok"
+	if err != nil {
+		res.Status, res.Error = "error", redact.String(err.Error())
+	}
+	res.Warnings = warnings.List()
+
+	// Empty lists are printed as [] rather than null
+	if res.Validated == nil {
+		res.Validated = []string{}
+	}
+	if res.Mirrors == nil {
+		res.Mirrors = []mirrorResult{}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(res); err != nil {
+		return fmt.Errorf("failed to print result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/manifest"
+)
+
+// newSecretsCmd creates the secrets subcommand.
+func newSecretsCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Manage GitHub Actions secrets of the mirror repository",
+	}
+
+	cmd.AddCommand(newSecretsSetCmd(ctx, log))
+	return cmd
+}
+
+// newSecretsSetCmd creates the secrets set subcommand.
+func newSecretsSetCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	var value string
+
+	cmd := &cobra.Command{
+		Use:   "set NAME",
+		Short: "Encrypt and upload an Actions secret to the mirror repository",
+		Long: "Encrypt a value with the mirror repository's public key and store it as a GitHub Actions secret.\n" +
+			"The value is read from --value or, if omitted, from stdin.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadMirrorConfig(log)
+			if err != nil {
+				return err
+			}
+
+			if !cmd.Flags().Changed("value") {
+				value, err = readSecretValue(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+			}
+			if value == "" {
+				return fmt.Errorf("secret value must not be empty")
+			}
+
+			githubClient, err := github.NewClient(ctx, cfg, log)
+			if err != nil {
+				return fmt.Errorf("failed to create GitHub client: %w", err)
+			}
+
+			if err := githubClient.SetSecret(ctx, args[0], value); err != nil {
+				return err
+			}
+			recordInstall(cfg, log, func(install *manifest.Install) {
+				install.AddSecret(manifest.Secret{Name: args[0], Environment: cfg.Environment})
+			})
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&value, "value", "", "Secret value (read from stdin if not specified)")
+	return cmd
+}
+
+// isTerminal reports whether f is connected to a terminal. Other character
+// devices, such as /dev/null, are not terminals.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// readSecretValue reads a secret value from r, prompting when r is a terminal.
+func readSecretValue(r io.Reader) (string, error) {
+	if f, ok := r.(*os.File); ok && isTerminal(f) {
+		fmt.Fprint(os.Stderr, "Secret value: ")
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret value: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/forge"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/report"
+)
+
+// shutdownTimeout is how long serve waits for webhook requests in progress
+// when terminated.
+const shutdownTimeout = 10 * time.Second
+
+// newServeCmd creates the serve subcommand.
+func newServeCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	var listen, path, secret, metricsListen string
+	var dispatch bool
+	var alertFlags failureAlerts
+	var reportFlags errorReportFlags
+	var lag lagLimits
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Sync the mirrors when the primary sends a push webhook",
+		Long: "Listen for push webhooks of the primary Gitea, Forgejo or GitLab repository and sync the\n" +
+			"mirrors right away, on this machine like the run command or, with --dispatch, by triggering\n" +
+			"the sync workflow of each GitHub mirror. Requests are verified with the webhook secret:\n" +
+			"Gitea and Forgejo sign them with it, GitLab sends it as the webhook token.\n" +
+			"Pushes arriving during a sync are coalesced into one more sync once it completes.\n" +
+			"With --metrics-listen, Prometheus metrics of the syncs are served at /metrics.\n" +
+			"/healthz and /readyz answer liveness and readiness checks with the state of the\n" +
+			"webhook listener and the last successful sync of each mirror.\n" +
+			"With --alert-to, an email is sent when a mirror failed to sync --alert-after times in a row.\n" +
+			"Failed syncs and dispatches and panics are reported to Sentry with --sentry-dsn, or to --error-command.\n" +
+			"With --max-lag-commits or --max-lag, a mirror still trailing the primary by more after its sync\n" +
+			"is reported; the lag is also served as a metric.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(log)
+			if err != nil {
+				return err
+			}
+
+			if secret == "" {
+				secret = os.Getenv("WEBHOOK_SECRET")
+			}
+			redact.Secret(secret)
+			if secret == "" {
+				return withExitCode(exitConfig, fmt.Errorf("webhook secret is required (--secret or WEBHOOK_SECRET)"))
+			}
+			if config.IsLocalURL(cfg.PrimaryRepo) {
+				return withExitCode(exitConfig, fmt.Errorf("serve requires a primary repository hosted on a forge that sends webhooks"))
+			}
+
+			alerts, err := loadAlerts(&alertFlags)
+			if err != nil {
+				return err
+			}
+			reporter, err := loadReporter(&reportFlags)
+			if err != nil {
+				return err
+			}
+
+			if dispatch {
+				if alerts != nil {
+					return withExitCode(exitConfig, fmt.Errorf("--alert-to applies to syncs on this machine, the syncs of --dispatch run on GitHub"))
+				}
+				if lag.set() {
+					return withExitCode(exitConfig, fmt.Errorf("--max-lag-commits and --max-lag apply to syncs on this machine, the syncs of --dispatch run on GitHub"))
+				}
+				if cfg.GithubToken == "" && cfg.AppID == 0 {
+					return withExitCode(exitConfig, fmt.Errorf("GitHub token not found in environment (GH_TOKEN or GITHUB_TOKEN) but required for --dispatch"))
+				}
+			} else {
+				if cfg.WorkDir == "" {
+					return withExitCode(exitConfig, fmt.Errorf("--work-dir is required when the user cache directory is unknown"))
+				}
+				if cfg.StateDir == "" {
+					return withExitCode(exitConfig, fmt.Errorf("--state-dir is required when the user home directory is unknown"))
+				}
+				if len(cfg.FilterPaths) > 0 || cfg.StripBlobsBiggerThan != "" || cfg.Subdirectory != "" {
+					return withExitCode(exitConfig, fmt.Errorf("--filter-path, --strip-blobs-bigger-than and --subdirectory are only applied by the generated workflow, use --dispatch"))
+				}
+				if err := confirm(cfg, forceActions(cfg, "sync "+strings.Join(cfg.MirrorRepos, ", ")+" on every push, which")); err != nil {
+					return err
+				}
+			}
+
+			var metrics *syncMetrics
+			if metricsListen != "" {
+				metrics = newSyncMetrics()
+				if err := serveMetrics(ctx, log, metricsListen, metrics); err != nil {
+					return err
+				}
+			}
+
+			stateDir := cfg.StateDir
+			if dispatch {
+				stateDir = ""
+			}
+			health := newServeHealth(log, cfg.MirrorRepos, stateDir)
+
+			trigger := make(chan struct{}, 1)
+			go serveSyncs(ctx, cfg, log, trigger, dispatch, syncOptions{alerts: alerts, reporter: reporter, lag: lag}, metrics, health)
+
+			mux := http.NewServeMux()
+			mux.Handle(path, webhookHandler(cfg, log, secret, trigger))
+			health.register(mux)
+			server := &http.Server{
+				Handler:           mux,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+
+			listener, err := net.Listen("tcp", listen)
+			if err != nil {
+				return withExitCode(exitNetwork, fmt.Errorf("failed to serve webhooks: %w", err))
+			}
+			health.setListener(listenerListening)
+
+			go func() {
+				<-ctx.Done()
+				// Fail readiness checks while requests in progress complete
+				health.setListener(listenerStopping)
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+				defer cancel()
+				server.Shutdown(shutdownCtx)
+			}()
+
+			log.Info("Listening for push webhooks", "address", listen, "path", path, "primary_repo", cfg.PrimaryRepo, "dispatch", dispatch)
+			if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return withExitCode(exitNetwork, fmt.Errorf("failed to serve webhooks: %w", err))
+			}
+			log.Info("Stopped listening for push webhooks")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&listen, "listen", ":8080", "Address to listen on for webhook requests")
+	cmd.Flags().StringVar(&path, "path", "/webhook", "URL path that receives webhook requests")
+	cmd.Flags().StringVar(&secret, "secret", "", "Secret shared with the primary's webhook (env WEBHOOK_SECRET)")
+	cmd.Flags().BoolVar(&dispatch, "dispatch", false, "Trigger the sync workflow of each GitHub mirror instead of syncing on this machine")
+	cmd.Flags().StringVar(&metricsListen, "metrics-listen", "", "Address to serve Prometheus metrics on at /metrics, e.g. 127.0.0.1:9090")
+	addAlertFlags(cmd, &alertFlags)
+	addReportFlags(cmd, &reportFlags)
+	addLagFlags(cmd, &lag)
+
+	return cmd
+}
+
+// webhookHandler verifies push webhooks and queues a sync for pushes to the
+// primary branch of the primary repository. A sync already queued absorbs
+// the push.
+func webhookHandler(cfg *config.Config, log *logger.Logger, secret string, trigger chan<- struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		event, err := forge.ParsePush(r, secret)
+		switch {
+		case errors.Is(err, forge.ErrInvalidSignature):
+			log.Warn("Rejected webhook request", "remote", r.RemoteAddr, "error", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		case err != nil:
+			log.Warn("Rejected webhook request", "remote", r.RemoteAddr, "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case event == nil:
+			fmt.Fprintln(w, "ignored, not a push event")
+			return
+		case !event.Matches(cfg.PrimaryRepo):
+			log.Debug("Ignoring push to another repository", "repos", event.Repos)
+			fmt.Fprintln(w, "ignored, not the primary repository")
+			return
+		case event.Branch() != cfg.PrimaryBranch && !cfg.PushMirror:
+			log.Debug("Ignoring push to another branch", "ref", event.Ref)
+			fmt.Fprintln(w, "ignored, not the primary branch")
+			return
+		}
+
+		log.Info("Received push webhook", "forge", event.Forge, "ref", event.Ref)
+		select {
+		case trigger <- struct{}{}:
+		default:
+			log.Debug("Sync already queued")
+		}
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(w, "sync queued")
+	})
+}
+
+// serveSyncs syncs the mirrors each time trigger fires until ctx is done,
+// with opts unless dispatching, recording the syncs in metrics and the
+// dispatches in health. Failed dispatches are sent to the reporter of opts.
+func serveSyncs(ctx context.Context, cfg *config.Config, log *logger.Logger, trigger <-chan struct{}, dispatch bool, opts syncOptions, metrics *syncMetrics, health *serveHealth) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-trigger:
+		}
+
+		// A sync in progress is completed even when a shutdown was requested
+		syncCtx := context.WithoutCancel(ctx)
+		var err error
+		if dispatch {
+			err = dispatchAll(syncCtx, cfg, log, metrics, health)
+			if err != nil {
+				sendReport(syncCtx, log, opts.reporter, report.Report{Level: report.LevelError, Message: err.Error(), Primary: cfg.PrimaryRepo})
+			}
+		} else {
+			err = syncAll(syncCtx, cfg, log, metrics, opts)
+		}
+		if err != nil {
+			log.Error("Sync triggered by webhook failed", "error", err)
+		}
+	}
+}
+
+// dispatchAll triggers the sync workflow of each GitHub mirror. The syncs run
+// on GitHub, so only the API quota is recorded in metrics, and successful
+// dispatches in health.
+func dispatchAll(ctx context.Context, cfg *config.Config, log *logger.Logger, metrics *syncMetrics, health *serveHealth) error {
+	var failed []error
+	for _, mirror := range cfg.MirrorRepos {
+		mirrorLog := log.With("mirror_repo", mirror)
+		if !config.IsGitHubURL(mirror) {
+			mirrorLog.Warn("Cannot dispatch the workflow of a mirror outside GitHub, skipping")
+			continue
+		}
+
+		mirrorCfg := *cfg
+		mirrorCfg.MirrorRepo = mirror
+		githubClient, err := github.NewClient(ctx, &mirrorCfg, mirrorLog, metrics.githubOptions()...)
+		if err == nil {
+			err = githubClient.DispatchWorkflow(ctx)
+		}
+		if err != nil {
+			mirrorLog.Error("Failed to dispatch sync workflow", "error", err)
+			failed = append(failed, err)
+			continue
+		}
+		health.recordDispatch(mirror)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("dispatch failed for %d of %d mirror repositories", len(failed), len(cfg.MirrorRepos))
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/diff"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/manifest"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/mirror"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/notify"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+)
+
+// newSetupCmd creates the setup subcommand.
+func newSetupCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	var viaPR, stdin bool
+
+	cmd := &cobra.Command{
+		Use:   "setup",
+		Short: "Install the sync workflow in the mirror repositories",
+		Long: "Validate the repositories, generate the sync workflow and commit it to each mirror repository,\n" +
+			"preparing the mirror first as requested by the other flags.\n" +
+			"With --via-pr, open a pull request with the workflow instead of committing it.\n" +
+			"With --stdin, set up each primary and mirror pair read from standard input, given one per\n" +
+			"line as \"primary_url mirror_url\" or as a JSON object with primary and mirror fields.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags.EnableSetup(viaPR)
+			if !stdin {
+				return run(ctx, log)
+			}
+
+			if cmd.Flags().Changed("primary") || cmd.Flags().Changed("mirror") {
+				return withExitCode(exitConfig, fmt.Errorf("--stdin reads the repositories from standard input and cannot be combined with --primary or --mirror"))
+			}
+			pairs, err := readRepoPairs(os.Stdin)
+			if err != nil {
+				return withExitCode(exitConfig, err)
+			}
+			if len(pairs) == 0 {
+				return withExitCode(exitConfig, fmt.Errorf("no repository pairs read from standard input"))
+			}
+			return withResult(log, func(log *logger.Logger, res *runResult) error {
+				return setupPairs(ctx, log, res, pairs)
+			})
+		},
+	}
+
+	cmd.Flags().BoolVar(&viaPR, "via-pr", false, "Open a pull request with the workflow instead of committing it to the default branch")
+	cmd.Flags().BoolVar(&stdin, "stdin", false, "Read primary and mirror repository pairs from standard input, one per line")
+
+	return cmd
+}
+
+// setupRepo validates, generates and installs the workflow for the single
+// mirror repository of cfg, recording the outcome in res and posting it to
+// the notification URL.
+func setupRepo(ctx context.Context, cfg *config.Config, log *logger.Logger, res *runResult) error {
+	start := time.Now()
+	mirror, err := installWorkflow(ctx, cfg, log, res)
+	mirror.Repo = cfg.MirrorRepo
+	if err != nil {
+		mirror.Action, mirror.Error = actionFailed, redact.String(err.Error())
+	}
+	if mirror.Action != actionDryRun {
+		event := notify.NewEvent(notify.EventSetup, cfg.MirrorRepo, time.Since(start), err)
+		event.Action, event.NewSHA, event.Updated = mirror.Action, mirror.Commit, mirror.Commit != ""
+		sendNotification(ctx, cfg, log, event)
+	}
+	switch mirror.Action {
+	case actionCommitted, actionUpToDate, actionPullRequest:
+		recordInstall(cfg, log, func(install *manifest.Install) {
+			install.WorkflowFormat = cfg.WorkflowFormat
+			install.ReadmeBanner = install.ReadmeBanner || (cfg.ReadmeBanner && config.IsGitHubURL(cfg.MirrorRepo))
+		})
+	}
+	res.Mirrors = append(res.Mirrors, mirror)
+	return err
+}
+
+// installWorkflow does the work of setupRepo.
+func installWorkflow(ctx context.Context, cfg *config.Config, log *logger.Logger, res *runResult) (mirrorResult, error) {
+	if err := validateRepos(ctx, cfg, log); err != nil {
+		return mirrorResult{}, err
+	}
+	res.addValidated(cfg.PrimaryRepo, cfg.MirrorRepo)
+
+	workflowYAML, err := generateWorkflow(ctx, cfg, log)
+	if err != nil {
+		return mirrorResult{}, err
+	}
+	if !config.IsGitHubURL(cfg.MirrorRepo) {
+		return setupForgeMirror(ctx, cfg, log, workflowYAML)
+	}
+
+	githubClient, err := github.NewClient(ctx, cfg, log)
+	if err != nil {
+		return mirrorResult{}, fmt.Errorf("failed to create GitHub client: %w", err)
+	}
+	log.Info("GitHub client initialized successfully")
+
+	if cfg.DryRun {
+		return previewSetup(ctx, cfg, log, githubClient, workflowYAML)
+	}
+	return setupMirror(ctx, cfg, log, githubClient, workflowYAML)
+}
+
+// setupMirrors installs the workflow in each configured mirror repository,
+// continuing past failures so one unreachable mirror does not block the rest.
+func setupMirrors(ctx context.Context, cfg *config.Config, log *logger.Logger, res *runResult) error {
+	var failed []error
+	for _, mirror := range cfg.MirrorRepos {
+		mirrorCfg := *cfg
+		mirrorCfg.MirrorRepo = mirror
+
+		mirrorLog := log.With("mirror_repo", mirror)
+		if err := setupRepo(ctx, &mirrorCfg, mirrorLog, res); err != nil {
+			mirrorLog.Error("Failed to set up mirror repository", "error", err)
+			failed = append(failed, err)
+		}
+	}
+
+	if len(failed) > 0 {
+		err := fmt.Errorf("setup failed for %d of %d mirror repositories", len(failed), len(cfg.MirrorRepos))
+		return withExitCode(commonExitCode(failed), err)
+	}
+	return nil
+}
+
+// setupMirror installs the generated workflow in the mirror repository,
+// preparing the repository first as requested by the configuration.
+func setupMirror(ctx context.Context, cfg *config.Config, log *logger.Logger, githubClient *github.Client, workflowYAML string) (mirrorResult, error) {
+	installed, err := mirror.Install(ctx, cfg, log, githubClient, workflowYAML)
+	result := mirrorResult{WorkflowPath: installed.WorkflowPath, Commit: installed.Commit, PullRequest: installed.PullRequest}
+	switch {
+	case installed.PullRequest != "":
+		result.Action = actionPullRequest
+	case installed.Commit != "":
+		result.Action = actionCommitted
+	default:
+		result.Action = actionUpToDate
+	}
+	return result, err
+}
+
+// previewSetup prints the changes setupMirror would commit to the mirror
+// repository, along with the commit message, without making them. With
+// --output-format json, the changed files are only listed in the result.
+func previewSetup(ctx context.Context, cfg *config.Config, log *logger.Logger, githubClient *github.Client, workflowYAML string) (mirrorResult, error) {
+	result := mirrorResult{Action: actionDryRun, WorkflowPath: githubClient.WorkflowPath()}
+
+	plan, err := githubClient.PlanWorkflow(ctx, workflowYAML)
+	if err != nil {
+		return result, fmt.Errorf("failed to plan workflow setup: %w", err)
+	}
+	if len(plan.Files) == 0 {
+		log.Info("Workflow is up to date, nothing would be committed", "path", githubClient.WorkflowPath())
+		return result, nil
+	}
+	for _, file := range plan.Files {
+		result.Changes = append(result.Changes, file.Path)
+	}
+	if cfg.OutputFormat == config.OutputFormatJSON {
+		return result, nil
+	}
+
+	color := isTerminal(os.Stdout)
+	fmt.Printf("Commit message: %s\n\n", plan.Message)
+	for _, file := range plan.Files {
+		out := diff.Unified("a/"+file.Path, "b/"+file.Path, file.Previous, file.Content)
+		if color {
+			out = diff.Colorize(out)
+		}
+		fmt.Print(out)
+	}
+	return result, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/git"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/mirror"
+)
+
+// newStatusCmd creates the status subcommand.
+func newStatusCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	var limit int
+	var noSHA, local bool
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show recent sync workflow runs of the mirror repository",
+		Long: "Show the recent sync workflow runs of the mirror repository, or with --local the outcome\n" +
+			"of the last sync of each mirror by the run and serve commands, read from --state-dir\n" +
+			"without any API call.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if local {
+				return localSyncStatus()
+			}
+
+			cfg, err := loadMirrorConfig(log)
+			if err != nil {
+				return err
+			}
+
+			githubClient, err := github.NewClient(ctx, cfg, log)
+			if err != nil {
+				return fmt.Errorf("failed to create GitHub client: %w", err)
+			}
+
+			previous, err := githubClient.FollowRenames(ctx)
+			if err != nil {
+				return err
+			}
+			if previous != "" {
+				mirror.UpdateRemote(ctx, cfg, log, previous)
+			}
+
+			// The state recorded with --record-state tells the last sync
+			// without reading job logs
+			state, err := githubClient.SyncState(ctx)
+			if err != nil {
+				log.Warn("Could not read the recorded sync state", "error", err)
+			}
+			if state != nil {
+				fmt.Printf("Last recorded sync of %s: %s at %s (gh-mirror %s, template version %d)\n\n",
+					state.MirrorBranch, state.SyncedSHA, state.SyncedAt.Local().Format(time.DateTime), state.ToolVersion, state.TemplateVersion)
+			}
+
+			runs, err := githubClient.RecentRuns(ctx, limit, !noSHA)
+			if err != nil {
+				return err
+			}
+			if len(runs) == 0 {
+				log.Info("No sync workflow runs found", "mirror_repo", cfg.MirrorRepo)
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "STARTED\tEVENT\tSTATUS\tDURATION\tSYNCED SHA\tURL")
+			for _, run := range runs {
+				result := run.Status
+				if run.Conclusion != "" {
+					result = run.Conclusion
+				}
+				duration := "-"
+				if run.Duration > 0 {
+					duration = run.Duration.Round(time.Second).String()
+				}
+				sha := "-"
+				if run.SyncedSHA != "" {
+					sha = run.SyncedSHA[:12]
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					run.StartedAt.Local().Format(time.DateTime), run.Event, result, duration, sha, run.URL)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "n", 10, "Number of runs to show")
+	cmd.Flags().BoolVar(&noSHA, "no-sha", false, "Skip downloading job logs to determine the synced commit")
+	cmd.Flags().BoolVar(&local, "local", false, "Show the local syncs recorded in --state-dir instead of the workflow runs")
+	return cmd
+}
+
+// localSyncStatus prints the sync state of the configured mirrors, or of
+// every mirror synced locally when none is configured.
+func localSyncStatus() error {
+	cfg, err := flags.LoadBase()
+	if err != nil {
+		return withExitCode(exitConfig, fmt.Errorf("failed to load configuration: %w", err))
+	}
+	if cfg.StateDir == "" {
+		return withExitCode(exitConfig, fmt.Errorf("--state-dir is required when the user home directory is unknown"))
+	}
+	state, err := git.LoadSyncState(cfg.StateDir)
+	if err != nil {
+		return err
+	}
+
+	mirrors := cfg.MirrorRepos
+	if len(mirrors) == 0 {
+		mirrors = sortedKeys(state.Mirrors)
+	}
+	if state.Run != nil {
+		fmt.Printf("A run started at %s was interrupted, continue it with run --resume\n\n", state.Run.StartedAt.Local().Format(time.DateTime))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MIRROR\tPRIMARY\tLAST SUCCESS\tSYNCED SHA\tFAILURES\tLAST ERROR")
+	for _, mirror := range mirrors {
+		ms := state.Mirrors[mirror]
+		if ms == nil {
+			fmt.Fprintf(w, "%s\t-\tnever\t-\t0\t-\n", mirror)
+			continue
+		}
+		primary, success, sha, lastErr := "-", "never", "-", "-"
+		if ms.PrimaryRepo != "" {
+			primary = ms.PrimaryRepo + " " + ms.PrimaryBranch
+		}
+		if !ms.LastSuccess.IsZero() {
+			success = ms.LastSuccess.Local().Format(time.DateTime)
+		}
+		if ms.SyncedSHA != "" {
+			sha = ms.SyncedSHA[:min(12, len(ms.SyncedSHA))]
+		}
+		if ms.LastError != "" {
+			lastErr = ms.LastError
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", mirror, primary, success, sha, ms.Failures, lastErr)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/git"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/mirror"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/notify"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/report"
+)
+
+// watchJitter is the largest fraction of the sync interval added to or
+// removed from each wait in watch mode, so many mirrors do not hit the
+// primary at the same moment.
+const watchJitter = 0.1
+
+// newRunCmd creates the run subcommand.
+func newRunCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	var watch bool
+	var metricsListen string
+	var opts syncOptions
+	var alertFlags failureAlerts
+	var reportFlags errorReportFlags
+
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Sync the mirror locally instead of through GitHub Actions",
+		Long: "Perform the mirror sync of the generated workflow on this machine: fetch the primary,\n" +
+			"reset or merge it onto the mirror branch and push the result to each mirror.\n" +
+			"Working copies are kept in --work-dir and the state of each mirror in --state-dir between runs.\n" +
+			"With --watch, keep running and sync again every --interval until terminated, serving\n" +
+			"Prometheus metrics of the syncs with --metrics-listen.\n" +
+			"The state is saved after each mirror: --resume continues a run that was interrupted, and\n" +
+			"--incremental skips mirrors whose primary branch has not moved since their last sync.\n" +
+			"With --alert-to, an email is sent when a mirror failed to sync --alert-after times in a row.\n" +
+			"Failed syncs and panics are reported to Sentry with --sentry-dsn, or to --error-command.\n" +
+			"With --max-lag-commits or --max-lag, a mirror still trailing the primary by more after its sync\n" +
+			"is reported; the lag is also served as a metric.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(log)
+			if err != nil {
+				return err
+			}
+			if cfg.WorkDir == "" {
+				return withExitCode(exitConfig, fmt.Errorf("--work-dir is required when the user cache directory is unknown"))
+			}
+			if cfg.StateDir == "" {
+				return withExitCode(exitConfig, fmt.Errorf("--state-dir is required when the user home directory is unknown"))
+			}
+			if len(cfg.FilterPaths) > 0 || cfg.StripBlobsBiggerThan != "" || cfg.Subdirectory != "" {
+				return withExitCode(exitConfig, fmt.Errorf("--filter-path, --strip-blobs-bigger-than and --subdirectory are only applied by the generated workflow, not by run"))
+			}
+
+			if opts.alerts, err = loadAlerts(&alertFlags); err != nil {
+				return err
+			}
+			if opts.reporter, err = loadReporter(&reportFlags); err != nil {
+				return err
+			}
+			if err := confirm(cfg, forceActions(cfg, "sync "+strings.Join(cfg.MirrorRepos, ", ")+", which")); err != nil {
+				return err
+			}
+
+			if !watch {
+				return syncAll(ctx, cfg, log, nil, opts)
+			}
+
+			var metrics *syncMetrics
+			if metricsListen != "" {
+				metrics = newSyncMetrics()
+				if err := serveMetrics(ctx, log, metricsListen, metrics); err != nil {
+					return err
+				}
+			}
+			return watchMirrors(ctx, cfg, log, metrics, opts)
+		},
+	}
+
+	cmd.Flags().Bool("once", true, "Sync each mirror once and exit (default)")
+	cmd.Flags().BoolVar(&watch, "watch", false, "Keep running and sync again every --interval, with jitter")
+	cmd.Flags().StringVar(&metricsListen, "metrics-listen", "", "Address to serve Prometheus metrics on at /metrics with --watch, e.g. 127.0.0.1:9090")
+	cmd.Flags().BoolVar(&opts.resume, "resume", false, "Continue the run that was interrupted, skipping the mirrors it already synced")
+	cmd.Flags().BoolVar(&opts.incremental, "incremental", false, "Skip mirrors whose primary branch has not moved since their last successful sync")
+	addAlertFlags(cmd, &alertFlags)
+	addReportFlags(cmd, &reportFlags)
+	addLagFlags(cmd, &opts.lag)
+	cmd.MarkFlagsMutuallyExclusive("once", "watch")
+
+	return cmd
+}
+
+// watchMirrors syncs all mirrors every sync interval until ctx is done,
+// recording the syncs in metrics. Only the first sync resumes an interrupted
+// run.
+func watchMirrors(ctx context.Context, cfg *config.Config, log *logger.Logger, metrics *syncMetrics, opts syncOptions) error {
+	interval := cfg.SyncPeriod()
+	log.Info("Watching mirrors", "interval", interval, "mirrors", len(cfg.MirrorRepos))
+
+	for {
+		// A sync in progress is completed even when a shutdown was requested
+		if err := syncAll(context.WithoutCancel(ctx), cfg, log, metrics, opts); err != nil {
+			log.Error("Sync failed, retrying at the next interval", "error", err)
+		}
+		opts.resume = false
+
+		wait := jitter(interval)
+		log.Debug("Waiting for the next sync", "delay", wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Info("Stopped watching mirrors")
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// jitter returns interval randomly shifted by up to watchJitter of its length.
+func jitter(interval time.Duration) time.Duration {
+	spread := time.Duration(float64(interval) * watchJitter)
+	if spread <= 0 {
+		return interval
+	}
+	return interval - spread + time.Duration(rand.Int63n(int64(2*spread)))
+}
+
+// syncOptions adjust the syncs of syncAll.
+type syncOptions struct {
+	// resume continues a batch left behind by an interrupted run, skipping
+	// the mirrors it already synced
+	resume bool
+
+	// incremental skips mirrors whose primary branch has not moved since
+	// their last successful sync
+	incremental bool
+
+	// alerts are sent when a mirror keeps failing, unless nil
+	alerts *failureAlerts
+
+	// reporter receives failed syncs and panics, unless nil
+	reporter report.Reporter
+
+	// lag limits how far mirrors may trail the primary after a sync
+	lag lagLimits
+}
+
+// syncAll syncs each configured mirror once and records the outcome in the
+// sync state of the state directory, and in metrics unless nil. The state is
+// saved after each mirror, so an interrupted batch can be resumed.
+func syncAll(ctx context.Context, cfg *config.Config, log *logger.Logger, metrics *syncMetrics, opts syncOptions) error {
+	var current string
+	defer reportPanic(ctx, log, opts.reporter, cfg.PrimaryRepo, &current)
+
+	state, err := git.LoadSyncState(cfg.StateDir)
+	if err != nil {
+		return err
+	}
+	resumed, err := state.BeginRun(cfg.MirrorRepos, opts.resume)
+	warnSyncState(err, log)
+	if resumed {
+		log.Info("Resuming interrupted run", "started_at", state.Run.StartedAt)
+	}
+
+	var failed []error
+	for _, mirrorRepo := range cfg.MirrorRepos {
+		mirrorCfg := *cfg
+		mirrorCfg.MirrorRepo = mirrorRepo
+		mirrorLog := log.With("mirror_repo", mirrorRepo)
+		current = mirrorRepo
+
+		if opts.resume && state.Done(mirrorRepo) {
+			mirrorLog.Info("Mirror repository already synced by the interrupted run, skipping")
+			continue
+		}
+		if opts.incremental && state.Unchanged(&mirrorCfg, primaryHead(ctx, &mirrorCfg, mirrorLog)) {
+			mirrorLog.Info("Primary branch unchanged since the last sync, skipping")
+			continue
+		}
+
+		var previous string
+		if ms := state.Mirrors[mirrorRepo]; ms != nil {
+			previous = ms.SyncedSHA
+		}
+
+		start := time.Now()
+		result, err := mirror.Sync(ctx, &mirrorCfg, mirrorLog, metrics.githubOptions()...)
+		elapsed := time.Since(start)
+		event := notify.NewEvent(notify.EventSync, mirrorRepo, elapsed, err)
+		event.OldSHA = previous
+		var fetched int64
+		if result != nil {
+			fetched = result.Fetched
+			event.NewSHA, event.Updated = result.SHA, result.Updated
+		}
+		metrics.recordSync(mirrorRepo, elapsed, fetched, err)
+		sendNotification(ctx, &mirrorCfg, mirrorLog, event)
+		ms, stateErr := state.Record(&mirrorCfg, result, err)
+		warnSyncState(stateErr, log)
+		if opts.alerts.check(ctx, mirrorLog, mirrorRepo, cfg.PrimaryRepo, ms) {
+			_, stateErr = state.Update(mirrorRepo, func(stored *git.MirrorState) { stored.LastAlert = ms.LastAlert })
+			warnSyncState(stateErr, log)
+		}
+		if err != nil {
+			mirrorLog.Error("Failed to sync mirror repository", "error", err, "consecutive_failures", ms.Failures)
+			sendReport(ctx, mirrorLog, opts.reporter, report.Report{
+				Level:    report.LevelError,
+				Message:  err.Error(),
+				Repo:     mirrorRepo,
+				Primary:  cfg.PrimaryRepo,
+				Failures: ms.Failures,
+			})
+			failed = append(failed, err)
+		}
+		checkLag(ctx, &mirrorCfg, mirrorLog, metrics, opts)
+	}
+
+	warnSyncState(state.EndRun(), log)
+
+	if len(failed) > 0 {
+		err := fmt.Errorf("sync failed for %d of %d mirror repositories", len(failed), len(cfg.MirrorRepos))
+		return withExitCode(commonExitCode(failed), err)
+	}
+	return nil
+}
+
+// primaryHead returns the commit at the primary branch of cfg, or an empty
+// string when it is unknown. Only the primary branch is compared, so it is
+// unknown when other refs are synced as well.
+func primaryHead(ctx context.Context, cfg *config.Config, log *logger.Logger) string {
+	if cfg.PushMirror || cfg.SyncNotes || len(cfg.Refspecs) > 0 {
+		return ""
+	}
+	refs, err := git.NewClient(cfg.GitPath, log).ListRefs(ctx, cfg, cfg.PrimaryRepo, "")
+	if err != nil {
+		log.Debug("Could not list the refs of the primary repository", "error", err)
+		return ""
+	}
+	return refs["refs/heads/"+cfg.PrimaryBranch]
+}
+
+// warnSyncState logs err of storing the sync state, only as a warning since
+// the syncs themselves are not affected.
+func warnSyncState(err error, log *logger.Logger) {
+	if err != nil {
+		log.Warn("Could not save sync state", "error", err)
+	}
+}
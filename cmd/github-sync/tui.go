@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/git"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+)
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// dashboardKeys is the key help shown below the mirror list.
+const dashboardKeys = "up/down select  g generate  s setup  r run  x remove  u refresh  q quit"
+
+// dashboardRow is the status of one mirror shown by the dashboard.
+type dashboardRow struct {
+	Mirror   string
+	Local    string
+	Workflow string
+}
+
+// dashboard is the interactive mirror dashboard of the tui subcommand.
+type dashboard struct {
+	ctx context.Context
+	cfg *config.Config
+	log *logger.Logger
+
+	rows     []dashboardRow
+	selected int
+	message  string
+}
+
+// newTUICmd creates the tui subcommand.
+func newTUICmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "tui",
+		Short: "Interactive dashboard of the configured mirrors",
+		Long: "List the configured mirrors with the outcome of their last local sync and of their last sync\n" +
+			"workflow run, and generate, set up, run or remove the sync of the selected mirror.\n" +
+			"Each action asks for confirmation as the corresponding command does.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(log)
+			if err != nil {
+				return err
+			}
+			if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+				return withExitCode(exitConfig, fmt.Errorf("tui requires a terminal"))
+			}
+
+			d := &dashboard{ctx: ctx, cfg: cfg, log: log}
+			return d.run()
+		},
+	}
+}
+
+// run shows the dashboard and handles key presses until the user quits.
+func (d *dashboard) run() error {
+	d.refresh()
+	for {
+		d.render()
+		key, err := readKey()
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "up", "k":
+			if d.selected > 0 {
+				d.selected--
+			}
+		case "down", "j":
+			if d.selected < len(d.rows)-1 {
+				d.selected++
+			}
+		case "u":
+			d.message = "Refreshed"
+			d.refresh()
+		case "g", "s", "r", "x":
+			d.perform(key)
+		case "q", "ctrl-c":
+			fmt.Print(clearScreen)
+			return nil
+		}
+	}
+}
+
+// refresh reloads the local sync state and the last workflow run of each
+// mirror.
+func (d *dashboard) refresh() {
+	var state *git.SyncState
+	if d.cfg.StateDir != "" {
+		var err error
+		if state, err = git.LoadSyncState(d.cfg.StateDir); err != nil {
+			d.message = err.Error()
+		}
+	}
+
+	d.rows = d.rows[:0]
+	for _, mirror := range d.cfg.MirrorRepos {
+		row := dashboardRow{Mirror: mirror, Local: "never run", Workflow: "-"}
+		if state != nil {
+			if ms := state.Mirrors[mirror]; ms != nil {
+				row.Local = localStatus(ms)
+			}
+		}
+		if config.IsGitHubURL(mirror) && (d.cfg.GithubToken != "" || d.cfg.AppID != 0) {
+			row.Workflow = d.workflowStatus(mirror)
+		}
+		d.rows = append(d.rows, row)
+	}
+}
+
+// localStatus describes the outcome of the last local sync of a mirror.
+func localStatus(ms *git.MirrorState) string {
+	if ms.LastError != "" {
+		return fmt.Sprintf("failed %d time(s), last %s", ms.Failures, ago(ms.LastAttempt))
+	}
+	sha := ms.SyncedSHA
+	if len(sha) > 12 {
+		sha = sha[:12]
+	}
+	return fmt.Sprintf("synced %s %s", sha, ago(ms.LastSuccess))
+}
+
+// workflowStatus describes the last sync workflow run on a GitHub mirror.
+func (d *dashboard) workflowStatus(mirror string) string {
+	mirrorCfg := *d.cfg
+	mirrorCfg.MirrorRepo = mirror
+
+	githubClient, err := github.NewClient(d.ctx, &mirrorCfg, d.log)
+	if err != nil {
+		return "unknown"
+	}
+	return lastRunStatus(d.ctx, githubClient)
+}
+
+// ago formats the time elapsed since t.
+func ago(t time.Time) string {
+	elapsed := time.Since(t)
+	switch {
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		return fmt.Sprintf("%dm ago", int(elapsed.Minutes()))
+	case elapsed < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(elapsed.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(elapsed.Hours()/24))
+}
+
+// render draws the dashboard.
+func (d *dashboard) render() {
+	var b strings.Builder
+	b.WriteString(clearScreen)
+	fmt.Fprintf(&b, "gh-mirror: %s\n\n", d.cfg.PrimaryRepo)
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tMIRROR\tLOCAL SYNC\tWORKFLOW")
+	for i, row := range d.rows {
+		marker := " "
+		if i == d.selected {
+			marker = ">"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", marker, row.Mirror, row.Local, row.Workflow)
+	}
+	w.Flush()
+
+	fmt.Fprintf(&b, "\n%s\n", dashboardKeys)
+	if d.message != "" {
+		fmt.Fprintf(&b, "\n%s\n", d.message)
+	}
+	fmt.Print(b.String())
+}
+
+// readKey reads a key press with the terminal in raw mode.
+func readKey() (string, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", fmt.Errorf("failed to set up terminal: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	buf := make([]byte, 8)
+	n, err := os.Stdin.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("failed to read key: %w", err)
+	}
+
+	switch key := string(buf[:n]); key {
+	case "\x1b[A", "\x1bOA":
+		return "up", nil
+	case "\x1b[B", "\x1bOB":
+		return "down", nil
+	case "\x03":
+		return "ctrl-c", nil
+	default:
+		return key, nil
+	}
+}
+
+// perform runs the action of key on the selected mirror with the terminal
+// in normal mode, so that logs and confirmation prompts show as usual.
+func (d *dashboard) perform(key string) {
+	if len(d.rows) == 0 {
+		return
+	}
+	mirror := d.rows[d.selected].Mirror
+	mirrorCfg := *d.cfg
+	mirrorCfg.MirrorRepo = mirror
+	mirrorCfg.MirrorRepos = []string{mirror}
+
+	var name string
+	var action func() error
+	switch key {
+	case "g":
+		name, action = "Generate", func() error { return d.generate(&mirrorCfg, d.log) }
+	case "s":
+		name, action = "Setup", func() error { return d.setup(&mirrorCfg, d.log) }
+	case "r":
+		name, action = "Run", func() error { return d.sync(&mirrorCfg, d.log) }
+	case "x":
+		name, action = "Remove", func() error { return d.remove(&mirrorCfg, d.log) }
+	}
+
+	fmt.Print(clearScreen)
+	fmt.Printf("%s %s\n\n", name, mirror)
+	if err := action(); err != nil {
+		d.message = fmt.Sprintf("%s %s failed: %v", name, mirror, err)
+	} else {
+		d.message = fmt.Sprintf("%s %s done", name, mirror)
+	}
+
+	fmt.Print("\nPress Enter to return to the dashboard")
+	bufio.NewReader(os.Stdin).ReadString('\n')
+	d.refresh()
+}
+
+// generate writes the workflow of a mirror like the generate command.
+func (d *dashboard) generate(cfg *config.Config, log *logger.Logger) error {
+	if err := confirm(cfg, forceActions(cfg, "generate a workflow that")); err != nil {
+		return err
+	}
+	if err := validateRepos(d.ctx, cfg, log); err != nil {
+		return err
+	}
+	workflowYAML, err := generateWorkflow(d.ctx, cfg, log)
+	if err != nil {
+		return err
+	}
+	return writeWorkflow(cfg, log, workflowYAML)
+}
+
+// setup installs the workflow in a mirror like the setup command.
+func (d *dashboard) setup(cfg *config.Config, log *logger.Logger) error {
+	cfg.SetupWorkflow = true
+	if err := confirm(cfg, setupActions(cfg)); err != nil {
+		return err
+	}
+	return setupRepo(d.ctx, cfg, log, &runResult{})
+}
+
+// sync syncs a mirror on this machine like the run command.
+func (d *dashboard) sync(cfg *config.Config, log *logger.Logger) error {
+	if cfg.WorkDir == "" {
+		return fmt.Errorf("--work-dir is required when the user cache directory is unknown")
+	}
+	if cfg.StateDir == "" {
+		return fmt.Errorf("--state-dir is required when the user home directory is unknown")
+	}
+	if err := confirm(cfg, forceActions(cfg, "sync "+cfg.MirrorRepo+" now, which")); err != nil {
+		return err
+	}
+	return syncAll(d.ctx, cfg, log, nil, syncOptions{})
+}
+
+// remove deletes the sync workflow from a mirror like the remove command.
+func (d *dashboard) remove(cfg *config.Config, log *logger.Logger) error {
+	if err := confirm(cfg, []string{"remove the sync workflow from " + cfg.MirrorRepo}); err != nil {
+		return err
+	}
+	return removeWorkflow(d.ctx, cfg, log)
+}
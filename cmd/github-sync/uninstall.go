@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/forge"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/manifest"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+)
+
+// newUninstallCmd creates the uninstall subcommand.
+func newUninstallCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	var primaryToken string
+
+	cmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove everything the tool set up on the mirror repositories",
+		Long: "Reverse what setup, deploy-key, secrets set and webhook did for each mirror, as recorded in\n" +
+			"the --manifest file: delete the sync workflow, the README mirror notice, the deploy keys, the\n" +
+			"secrets and the webhooks registered on the primary. Mirrors set up from another machine have\n" +
+			"no record; for them only the workflow and the deploy keys, which can be recognized, are removed.\n" +
+			"Repositories, branch protection, metadata and environments are left untouched.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := flags.LoadBase()
+			if err != nil {
+				return withExitCode(exitConfig, fmt.Errorf("failed to load configuration: %w", err))
+			}
+			if len(cfg.MirrorRepos) == 0 {
+				return withExitCode(exitConfig, fmt.Errorf("mirror repository URL is required"))
+			}
+			if cfg.ManifestFile == "" {
+				return withExitCode(exitConfig, fmt.Errorf("--manifest is required when the user home directory is unknown"))
+			}
+			if primaryToken == "" {
+				primaryToken = os.Getenv("PRIMARY_TOKEN")
+			}
+			redact.Secret(primaryToken)
+
+			m, err := manifest.Load(cfg.ManifestFile)
+			if err != nil {
+				return err
+			}
+			if err := confirm(cfg, []string{"remove everything set up on " + strings.Join(cfg.MirrorRepos, ", ")}); err != nil {
+				return err
+			}
+
+			var failed []error
+			for _, mirror := range cfg.MirrorRepos {
+				mirrorCfg := *cfg
+				mirrorCfg.MirrorRepo = mirror
+
+				mirrorLog := log.With("mirror_repo", mirror)
+				install, recorded := m.Mirrors[mirror]
+				if !recorded {
+					mirrorLog.Warn("No record of what was set up on the mirror, removing the sync workflow and deploy keys only")
+					install = &manifest.Install{WorkflowFormat: cfg.WorkflowFormat, DeployKey: config.IsGitHubURL(mirror)}
+				}
+
+				if err := uninstallMirror(ctx, &mirrorCfg, mirrorLog, install, primaryToken); err != nil {
+					mirrorLog.Error("Failed to uninstall from mirror repository", "error", err)
+					failed = append(failed, err)
+					continue
+				}
+				delete(m.Mirrors, mirror)
+			}
+
+			if err := m.Save(); err != nil {
+				log.Warn("Could not update the install manifest", "error", err)
+			}
+
+			if len(failed) > 0 {
+				err := fmt.Errorf("uninstall failed for %d of %d mirror repositories", len(failed), len(cfg.MirrorRepos))
+				return withExitCode(commonExitCode(failed), err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&primaryToken, "primary-token", "", "API token for the primary forge, to delete webhooks (env PRIMARY_TOKEN)")
+
+	return cmd
+}
+
+// uninstallMirror removes what install records from the mirror of cfg and
+// its primary.
+func uninstallMirror(ctx context.Context, cfg *config.Config, log *logger.Logger, install *manifest.Install, primaryToken string) error {
+	if install.WorkflowFormat != "" {
+		cfg.WorkflowFormat = install.WorkflowFormat
+		if err := removeWorkflow(ctx, cfg, log); err != nil {
+			return err
+		}
+	}
+
+	if install.ReadmeBanner || install.DeployKey {
+		githubClient, err := github.NewClient(ctx, cfg, log)
+		if err != nil {
+			return fmt.Errorf("failed to create GitHub client: %w", err)
+		}
+		if install.ReadmeBanner {
+			if _, err := githubClient.RemoveReadmeBanner(ctx); err != nil {
+				return err
+			}
+		}
+		if install.DeployKey {
+			if err := githubClient.RemoveDeployKeys(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, secret := range install.Secrets {
+		// Secrets are scoped to the environment they were stored in
+		secretCfg := *cfg
+		secretCfg.Environment = secret.Environment
+		githubClient, err := github.NewClient(ctx, &secretCfg, log)
+		if err != nil {
+			return fmt.Errorf("failed to create GitHub client: %w", err)
+		}
+		if _, err := githubClient.DeleteSecret(ctx, secret.Name); err != nil {
+			return err
+		}
+	}
+
+	for _, hook := range install.Webhooks {
+		if primaryToken == "" {
+			return withExitCode(exitConfig, errors.New("primary forge token is required to delete webhooks (--primary-token or PRIMARY_TOKEN)"))
+		}
+		primary, err := forge.New(ctx, hook.Forge, hook.Primary, primaryToken, log)
+		if err != nil {
+			return fmt.Errorf("failed to create primary forge client: %w", err)
+		}
+		removed, err := primary.RemoveWebhook(ctx, hook.URL)
+		if removed || err != nil {
+			recordForgeChange(cfg, log, audit.OpDeleteWebhook, hook.Primary, hook.URL, primaryToken, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordInstall records in the install manifest what was set up on the
+// mirror of cfg, so that uninstall can remove it. Failing to record is only
+// worth a warning, the setup itself succeeded.
+func recordInstall(cfg *config.Config, log *logger.Logger, update func(install *manifest.Install)) {
+	if cfg.ManifestFile == "" {
+		return
+	}
+
+	m, err := manifest.Load(cfg.ManifestFile)
+	if err == nil {
+		update(m.Mirror(cfg.MirrorRepo))
+		err = m.Save()
+	}
+	if err != nil {
+		log.Warn("Could not record the setup in the install manifest, uninstall will not know about it", "error", err)
+	}
+}
+
+// recordForgeChange records a change made to repo through the API of its
+// forge with token, or the failure to make it, in the audit log of cfg.
+func recordForgeChange(cfg *config.Config, log *logger.Logger, operation, repo, target, token string, err error) {
+	entry := audit.Entry{Operation: operation, Repo: repo, Target: target, Credential: audit.Credential(token, 0)}
+	if auditErr := audit.Record(cfg.AuditLog, entry, err); auditErr != nil {
+		log.Warn("Could not record change in the audit log", "operation", operation, "error", auditErr)
+	}
+}
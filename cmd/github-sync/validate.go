@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+)
+
+// newValidateCmd creates the validate subcommand.
+func newValidateCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Check that the primary and mirror repositories are accessible",
+		Long: "Check the configuration and that the primary repository and its branch and each mirror\n" +
+			"repository can be reached, without generating or installing anything.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(log)
+			if err != nil {
+				return err
+			}
+			return validateRepos(ctx, cfg, log)
+		},
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/forge"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/manifest"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+)
+
+// newWebhookCmd creates the webhook subcommand.
+func newWebhookCmd(ctx context.Context, log *logger.Logger) *cobra.Command {
+	var kind, primaryToken, dispatchToken string
+
+	cmd := &cobra.Command{
+		Use:   "webhook",
+		Short: "Register a push webhook on the primary that triggers the sync workflow",
+		Long: "Register a push webhook on the primary Gitea, Forgejo or GitLab repository that calls GitHub's\n" +
+			"workflow_dispatch API, so the mirror updates right after a push instead of on the cron schedule.\n" +
+			"The dispatch uses the pushed ref, so the primary branch must also exist on the mirror.\n" +
+			"The dispatch token should be a fine-grained token limited to 'Actions: Read and write' on the mirror.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(log)
+			if err != nil {
+				return err
+			}
+
+			if primaryToken == "" {
+				primaryToken = os.Getenv("PRIMARY_TOKEN")
+			}
+			if dispatchToken == "" {
+				dispatchToken = os.Getenv("GH_DISPATCH_TOKEN")
+			}
+			redact.Secret(primaryToken, dispatchToken)
+			if primaryToken == "" {
+				return fmt.Errorf("primary forge token is required (--primary-token or PRIMARY_TOKEN)")
+			}
+			if dispatchToken == "" {
+				return fmt.Errorf("GitHub dispatch token is required (--dispatch-token or GH_DISPATCH_TOKEN)")
+			}
+			if cfg.PrimaryBranch != cfg.MirrorBranch {
+				log.Warn("Primary and mirror branch names differ, dispatches for the primary branch will fail",
+					"primary_branch", cfg.PrimaryBranch, "mirror_branch", cfg.MirrorBranch)
+			}
+
+			githubClient, err := github.NewClient(ctx, cfg, log)
+			if err != nil {
+				return fmt.Errorf("failed to create GitHub client: %w", err)
+			}
+
+			primary, err := forge.New(ctx, kind, cfg.PrimaryRepo, primaryToken, log)
+			if err != nil {
+				return fmt.Errorf("failed to create primary forge client: %w", err)
+			}
+
+			created, err := primary.EnsureWebhook(ctx, forge.Webhook{
+				URL:           githubClient.DispatchURL(),
+				Branch:        cfg.PrimaryBranch,
+				Authorization: "Bearer " + dispatchToken,
+			})
+			if created || err != nil {
+				recordForgeChange(cfg, log, audit.OpAddWebhook, cfg.PrimaryRepo, githubClient.DispatchURL(), primaryToken, err)
+			}
+			if err != nil {
+				return err
+			}
+			recordInstall(cfg, log, func(install *manifest.Install) {
+				install.AddWebhook(manifest.Webhook{Primary: cfg.PrimaryRepo, Forge: primary.Kind(), URL: githubClient.DispatchURL()})
+			})
+			if !created {
+				log.Info("Webhook already registered on primary repository")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&kind, "forge", "", "Primary forge kind (gitea, gitlab); detected if not specified")
+	cmd.Flags().StringVar(&primaryToken, "primary-token", "", "API token for the primary forge (env PRIMARY_TOKEN)")
+	cmd.Flags().StringVar(&dispatchToken, "dispatch-token", "", "GitHub token the webhook uses to dispatch the workflow (env GH_DISPATCH_TOKEN)")
+
+	return cmd
+}
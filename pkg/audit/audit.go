@@ -0,0 +1,136 @@
+// Package audit appends a record of each change made to the mirrors to an
+// audit log, for reviewing what the mirror automation did.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+)
+
+// Operations recorded in the audit log.
+const (
+	OpCreateRepository  = "create-repository"
+	OpEditRepository    = "edit-repository"
+	OpCommit            = "commit"
+	OpDeleteFile        = "delete-file"
+	OpCreateBranch      = "create-branch"
+	OpOpenPullRequest   = "open-pull-request"
+	OpOpenIssue         = "open-issue"
+	OpSetSecret         = "set-secret"
+	OpDeleteSecret      = "delete-secret"
+	OpAddDeployKey      = "add-deploy-key"
+	OpDeleteDeployKey   = "delete-deploy-key"
+	OpCreateEnvironment = "create-environment"
+	OpUpdateProtection  = "update-protection"
+	OpDispatchWorkflow  = "dispatch-workflow"
+	OpSetCommitStatus   = "set-commit-status"
+	OpAddWebhook        = "add-webhook"
+	OpDeleteWebhook     = "delete-webhook"
+	OpUpdateSchedule    = "update-schedule"
+	OpDeleteSchedule    = "delete-schedule"
+	OpPush              = "push"
+)
+
+// Entry is one line of the audit log.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	Repo      string    `json:"repo"`
+
+	// Target is what was changed in the repository: a file, a secret, a
+	// branch or the refs pushed
+	Target string `json:"target,omitempty"`
+	Detail string `json:"detail,omitempty"`
+
+	// Actor is who ran gh-mirror, Credential the fingerprint of the
+	// credentials it made the change with
+	Actor      string `json:"actor"`
+	Credential string `json:"credential,omitempty"`
+
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// mu serializes the writes of one process, so concurrent syncs do not
+// interleave their lines.
+var mu sync.Mutex
+
+// Record appends entry to the audit log at path, filling in the time, the
+// actor and the result of err. Nothing is recorded when path is empty.
+func Record(path string, entry Entry, err error) error {
+	if path == "" {
+		return nil
+	}
+
+	entry.Time = time.Now().UTC()
+	if entry.Actor == "" {
+		entry.Actor = Actor()
+	}
+	entry.Result = "ok"
+	if err != nil {
+		entry.Result, entry.Error = "failed", redact.String(err.Error())
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit log entry: %w", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	// Each entry is appended with a single write, so entries of processes
+	// sharing the log are not mixed up either
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return file.Close()
+}
+
+// Actor returns who runs gh-mirror: the user that triggered a GitHub Actions
+// run, or else the local user and host.
+func Actor() string {
+	if actor := os.Getenv("GITHUB_ACTOR"); actor != "" {
+		return "github:" + actor
+	}
+
+	name := "unknown"
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name
+}
+
+// Credential returns the fingerprint of the credentials of a change: the
+// start of the SHA-256 hash of token, which identifies it without revealing
+// it, or the GitHub App whose installation tokens are used.
+func Credential(token string, appID int64) string {
+	switch {
+	case appID != 0:
+		return "app:" + strconv.FormatInt(appID, 10)
+	case token != "":
+		sum := sha256.Sum256([]byte(token))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	}
+	return ""
+}
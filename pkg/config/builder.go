@@ -0,0 +1,572 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+)
+
+// Builder builds a Config for programs using the packages as a library,
+// starting from Default and checking the result like the command line does.
+// Nothing is read from flags or environment variables:
+//
+//	cfg, err := config.NewBuilder().
+//		Primary("https://i2pgit.org/go-i2p/reseed-tools.git").
+//		Mirrors("https://github.com/go-i2p/reseed-tools").
+//		Interval("daily").
+//		Token(os.Getenv("GH_TOKEN")).
+//		Build()
+//
+// Mistakes in the values given to the methods are reported by Build, all of
+// them at once. A Builder can build several configurations; each is a copy.
+type Builder struct {
+	cfg  Config
+	errs []error
+}
+
+// NewBuilder returns a Builder starting from Default. The auth mode is left
+// empty, to be picked by Build from the mirrors like --auth-mode does.
+func NewBuilder() *Builder {
+	b := &Builder{cfg: *Default()}
+	b.cfg.AuthMode = ""
+	return b
+}
+
+// Primary sets the primary repository URL; local paths are turned into
+// absolute file:// URLs.
+func (b *Builder) Primary(repoURL string) *Builder {
+	primary, err := localRepoURL(repoURL)
+	if err != nil {
+		b.errs = append(b.errs, err)
+	}
+	b.cfg.PrimaryRepo = primary
+	return b
+}
+
+// Mirrors sets the mirror repository URLs.
+func (b *Builder) Mirrors(repoURLs ...string) *Builder {
+	b.cfg.MirrorRepos = append([]string(nil), repoURLs...)
+	return b
+}
+
+// PrimaryBranch sets the branch synced from the primary repository.
+func (b *Builder) PrimaryBranch(branch string) *Builder {
+	b.cfg.PrimaryBranch = branch
+	return b
+}
+
+// MirrorBranches sets the branches the primary branch is pushed to.
+func (b *Builder) MirrorBranches(branches ...string) *Builder {
+	b.cfg.MirrorBranches = append([]string(nil), branches...)
+	return b
+}
+
+// Interval sets how often the workflow syncs: hourly, daily or weekly.
+func (b *Builder) Interval(interval string) *Builder {
+	b.cfg.SyncInterval = strings.ToLower(interval)
+	return b
+}
+
+// Force sets whether the mirror branch is reset to the primary branch
+// instead of merging it.
+func (b *Builder) Force(force bool) *Builder {
+	b.cfg.ForceSync = force
+	return b
+}
+
+// PushMirror replicates all refs of the primary with git push --mirror.
+func (b *Builder) PushMirror() *Builder {
+	b.cfg.PushMirror = true
+	return b
+}
+
+// Token sets the GitHub token of the API requests.
+func (b *Builder) Token(token string) *Builder {
+	b.cfg.GithubToken = token
+	redact.Secret(token)
+	return b
+}
+
+// App authenticates the API requests as a GitHub App installation instead
+// of with a token; a zero installationID looks the installation up.
+func (b *Builder) App(appID, installationID int64, privateKey string) *Builder {
+	b.cfg.AppID, b.cfg.AppInstallationID, b.cfg.AppPrivateKey = appID, installationID, privateKey
+	redact.Secret(privateKey)
+	return b
+}
+
+// MirrorToken sets the API token of mirrors outside GitHub.
+func (b *Builder) MirrorToken(token string) *Builder {
+	b.cfg.MirrorToken = token
+	redact.Secret(token)
+	return b
+}
+
+// Auth sets the credentials the workflow pushes with, one of the AuthMode
+// constants, and the secret holding them; an empty secret uses the one of
+// DefaultAuthSecrets. Without it, GitHub mirrors are pushed to with
+// AuthModeToken and others with AuthModePAT.
+func (b *Builder) Auth(mode, secret string) *Builder {
+	b.cfg.AuthMode = strings.ToLower(mode)
+	b.cfg.AuthSecret = secret
+	return b
+}
+
+// Format sets the format of the generated workflow, one of the Format
+// constants.
+func (b *Builder) Format(format string) *Builder {
+	b.cfg.WorkflowFormat = strings.ToLower(format)
+	return b
+}
+
+// Refspecs adds refs to mirror besides the branch, in the notation of
+// ParseRefspec.
+func (b *Builder) Refspecs(specs ...string) *Builder {
+	for _, spec := range specs {
+		refspec, err := ParseRefspec(spec)
+		if err != nil {
+			b.errs = append(b.errs, err)
+			continue
+		}
+		b.cfg.Refspecs = append(b.cfg.Refspecs, refspec)
+	}
+	return b
+}
+
+// SyncNotes mirrors the git notes of the primary as well.
+func (b *Builder) SyncNotes() *Builder {
+	b.cfg.SyncNotes = true
+	return b
+}
+
+// RecordState has the workflow record each sync in a state file on the
+// mirror.
+func (b *Builder) RecordState() *Builder {
+	b.cfg.RecordState = true
+	return b
+}
+
+// CommitStatus marks each synced mirror commit with a commit status naming
+// the primary.
+func (b *Builder) CommitStatus() *Builder {
+	b.cfg.CommitStatus = true
+	return b
+}
+
+// BadgeBranch publishes the badge of the last sync to branch of the mirror.
+func (b *Builder) BadgeBranch(branch string) *Builder {
+	b.cfg.BadgeBranch = branch
+	return b
+}
+
+// Set exposes value to the workflow templates as .Extra.<key>.
+func (b *Builder) Set(key, value string) *Builder {
+	if !isValidExtraKey(key) {
+		b.errs = append(b.errs, fmt.Errorf("invalid template key %q: must be letters, digits and underscores, not starting with a digit", key))
+		return b
+	}
+	if b.cfg.Extra == nil {
+		b.cfg.Extra = make(map[string]string)
+	}
+	b.cfg.Extra[key] = value
+	return b
+}
+
+// TemplateDir sets the directory of templates overriding the built-in
+// workflow templates of the same name.
+func (b *Builder) TemplateDir(dir string) *Builder {
+	b.cfg.TemplateDir = dir
+	return b
+}
+
+// Hooks sets the scripts the workflow runs before and after the sync step.
+func (b *Builder) Hooks(preSync, postSync string) *Builder {
+	b.cfg.PreSyncHook, b.cfg.PostSyncHook = preSync, postSync
+	return b
+}
+
+// FilterPaths removes paths from the primary history before it is pushed.
+func (b *Builder) FilterPaths(paths ...string) *Builder {
+	b.cfg.FilterPaths = append(b.cfg.FilterPaths, paths...)
+	return b
+}
+
+// StripBlobsBiggerThan removes blobs above size, e.g. 10M, from the primary
+// history before it is pushed.
+func (b *Builder) StripBlobsBiggerThan(size string) *Builder {
+	b.cfg.StripBlobsBiggerThan = size
+	return b
+}
+
+// Subdirectory publishes a subdirectory of the primary as the root of the
+// mirror.
+func (b *Builder) Subdirectory(dir string) *Builder {
+	b.cfg.Subdirectory = strings.Trim(dir, "/")
+	return b
+}
+
+// RequireSigned refuses to sync primary commits not signed by one of the SSH
+// signers of allowedSignersFile or the GPG keys of signingKeysFile; either
+// may be empty. The files are read by Build.
+func (b *Builder) RequireSigned(allowedSignersFile, signingKeysFile string) *Builder {
+	b.cfg.RequireSigned = true
+	b.cfg.AllowedSignersFile, b.cfg.SigningKeysFile = allowedSignersFile, signingKeysFile
+	return b
+}
+
+// KnownHosts pins the SSH host keys of the primary in the workflow to the
+// entries of the known_hosts file at path, which is read by Build. It needs
+// the ssh auth mode.
+func (b *Builder) KnownHosts(path string) *Builder {
+	b.cfg.KnownHostsFile = path
+	return b
+}
+
+// HostKeyFingerprints pins the SSH host keys of the primary in the workflow
+// to those with the given SHA256 fingerprints, which are fetched from the
+// primary when the workflow is generated. It needs the ssh auth mode.
+func (b *Builder) HostKeyFingerprints(fingerprints ...string) *Builder {
+	b.cfg.HostKeyFingerprints = append(b.cfg.HostKeyFingerprints, fingerprints...)
+	return b
+}
+
+// ScanSecrets refuses to sync primary commits in which gitleaks finds
+// credentials, before they are published on the mirror.
+func (b *Builder) ScanSecrets() *Builder {
+	b.cfg.ScanSecrets = true
+	return b
+}
+
+// RewritePolicy sets what a sync does when the primary history was
+// rewritten, one of the RewritePolicy constants.
+func (b *Builder) RewritePolicy(policy string) *Builder {
+	b.cfg.RewritePolicy = strings.ToLower(policy)
+	return b
+}
+
+// Proxy sets the proxy of HTTP(S) git connections.
+func (b *Builder) Proxy(proxy string) *Builder {
+	b.cfg.Proxy = proxy
+	return b
+}
+
+// CACert trusts the PEM certificates of the file at path instead of the
+// system roots for HTTPS git connections to repositories outside GitHub.
+func (b *Builder) CACert(path string) *Builder {
+	b.cfg.CACert = path
+	return b
+}
+
+// InsecureSkipVerify disables the verification of TLS certificates of HTTPS
+// git connections to repositories outside GitHub. Anyone on the network can
+// then intercept the connections, and the credentials sent over them.
+func (b *Builder) InsecureSkipVerify() *Builder {
+	b.cfg.InsecureSkipVerify = true
+	return b
+}
+
+// Environment sets the GitHub Environment the sync job runs in.
+func (b *Builder) Environment(name string) *Builder {
+	b.cfg.Environment = name
+	return b
+}
+
+// Setup installs the workflow in the mirrors, in a pull request when viaPR
+// is set.
+func (b *Builder) Setup(viaPR bool) *Builder {
+	b.cfg.SetupWorkflow, b.cfg.SetupViaPR = true, viaPR
+	return b
+}
+
+// CreateMissing creates mirror repositories that do not exist, private ones
+// when private is set.
+func (b *Builder) CreateMissing(private bool) *Builder {
+	b.cfg.CreateMissing, b.cfg.PrivateMirror = true, private
+	return b
+}
+
+// Metadata sets the description, homepage and topics of the mirror
+// repositories during setup.
+func (b *Builder) Metadata(description, homepage string, topics ...string) *Builder {
+	b.cfg.SetMetadata = true
+	b.cfg.MirrorDescription, b.cfg.MirrorHomepage = description, homepage
+	if len(topics) > 0 {
+		b.cfg.MirrorTopics = append([]string(nil), topics...)
+	}
+	return b
+}
+
+// VerifyRun awaits a run of the installed workflow for at most timeout.
+func (b *Builder) VerifyRun(timeout time.Duration) *Builder {
+	b.cfg.VerifyRun, b.cfg.VerifyTimeout = true, timeout
+	return b
+}
+
+// Apply calls fn with the configuration being built, for fields without a
+// method of their own. Build checks them like the others; MirrorRepo and
+// MirrorBranch are set to the first of MirrorRepos and MirrorBranches.
+func (b *Builder) Apply(fn func(*Config)) *Builder {
+	fn(&b.cfg)
+	return b
+}
+
+// Build returns the configuration, or the mistakes found in it. The primary
+// repository and at least one mirror are required.
+func (b *Builder) Build() (*Config, error) {
+	cfg, err := b.BuildBase()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PrimaryRepo == "" {
+		return nil, fmt.Errorf("primary repository URL is required")
+	}
+	if cfg.MirrorRepo == "" {
+		return nil, fmt.Errorf("mirror repository URL is required")
+	}
+	return cfg, nil
+}
+
+// BuildBase returns the configuration like Build, but does not require the
+// repository URLs, for configurations shared by several repositories such as
+// the one of mirror.Orchestrator.
+func (b *Builder) BuildBase() (*Config, error) {
+	cfg := b.cfg
+	cfg.MirrorRepos = append([]string(nil), b.cfg.MirrorRepos...)
+	cfg.MirrorBranches = append([]string(nil), b.cfg.MirrorBranches...)
+	cfg.Refspecs = append([]Refspec(nil), b.cfg.Refspecs...)
+	cfg.FilterPaths = append([]string(nil), b.cfg.FilterPaths...)
+	cfg.MirrorTopics = append([]string(nil), b.cfg.MirrorTopics...)
+	if b.cfg.Extra != nil {
+		cfg.Extra = make(map[string]string, len(b.cfg.Extra))
+		for key, value := range b.cfg.Extra {
+			cfg.Extra[key] = value
+		}
+	}
+
+	if len(cfg.MirrorRepos) == 0 && cfg.MirrorRepo != "" {
+		cfg.MirrorRepos = []string{cfg.MirrorRepo}
+	}
+	cfg.MirrorRepo = ""
+	if len(cfg.MirrorRepos) > 0 {
+		cfg.MirrorRepo = cfg.MirrorRepos[0]
+	}
+	cfg.MirrorBranch = ""
+	if len(cfg.MirrorBranches) > 0 {
+		cfg.MirrorBranch = cfg.MirrorBranches[0]
+	}
+
+	genericMirror := false
+	for _, mirror := range cfg.MirrorRepos {
+		genericMirror = genericMirror || !IsGitHubURL(mirror)
+	}
+	if cfg.AuthMode == "" {
+		cfg.AuthMode = AuthModeToken
+		if genericMirror {
+			cfg.AuthMode = AuthModePAT
+		}
+	}
+	if cfg.AuthSecret == "" {
+		cfg.AuthSecret = DefaultAuthSecrets[cfg.AuthMode]
+	}
+
+	errs := append([]error(nil), b.errs...)
+	if cfg.RequireSigned {
+		var err error
+		if cfg.AllowedSigners, err = readTrustFile(cfg.AllowedSignersFile); err != nil {
+			errs = append(errs, err)
+		}
+		if cfg.SigningKeys, err = readTrustFile(cfg.SigningKeysFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if cfg.KnownHostsFile != "" {
+		var err error
+		if cfg.KnownHosts, err = readTrustFile(cfg.KnownHostsFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, cfg.check(genericMirror)...)
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// check returns the mistakes in the configuration, following the checks of
+// Flags.LoadBase. genericMirror tells whether a mirror is outside GitHub.
+func (c *Config) check(genericMirror bool) []error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	githubMirror := len(c.MirrorRepos) == 0
+
+	for _, mirror := range c.MirrorRepos {
+		if IsGitHubURL(mirror) {
+			githubMirror = true
+		} else if !strings.HasPrefix(mirror, "https://") {
+			fail("mirror repositories outside GitHub must use an https:// URL: %s", mirror)
+		}
+	}
+
+	switch c.SyncInterval {
+	case "hourly", "daily", "weekly":
+	default:
+		fail("invalid sync interval: %s (must be hourly, daily, or weekly)", c.SyncInterval)
+	}
+
+	switch c.AuthMode {
+	case AuthModeToken, AuthModePAT, AuthModeSSH:
+		if genericMirror && c.AuthMode != AuthModePAT {
+			fail("auth mode %s is only supported for GitHub mirrors, use %s", c.AuthMode, AuthModePAT)
+		}
+	default:
+		fail("invalid auth mode: %s (must be token, pat, or ssh)", c.AuthMode)
+	}
+	for _, secret := range []string{c.AuthSecret, c.TorProxySecret} {
+		if secret == "" {
+			continue
+		}
+		if err := ValidateSecretName(secret); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.SetupWorkflow && githubMirror && c.GithubToken == "" && c.AppID == 0 {
+		fail("a GitHub token or GitHub App is required for setup")
+	}
+	if c.SetupWorkflow && genericMirror && c.MirrorToken == "" {
+		fail("a mirror API token is required for setup of mirrors outside GitHub")
+	}
+
+	switch c.WorkflowFormat {
+	case FormatGitHub, FormatForgejo:
+	case FormatGitLab:
+		if githubMirror {
+			fail("the gitlab format is only supported for mirrors outside GitHub")
+		}
+	default:
+		fail("invalid workflow format: %s (must be github, forgejo or gitlab)", c.WorkflowFormat)
+	}
+
+	if c.OutputFormat != OutputFormatText && c.OutputFormat != OutputFormatJSON {
+		fail("invalid output format: %s (must be text or json)", c.OutputFormat)
+	}
+	if c.Retries < 0 {
+		fail("invalid number of retries: %d (must not be negative)", c.Retries)
+	}
+	for _, topic := range c.MirrorTopics {
+		if !isValidTopic(topic) {
+			fail("invalid topic: %s (must be lowercase letters, numbers and hyphens, at most 50 characters)", topic)
+		}
+	}
+
+	if c.PushMirror && !c.ForceSync {
+		fail("a mirror push overwrites the mirror and cannot be combined with merging")
+	}
+	if c.PushMirror && (c.SyncNotes || len(c.Refspecs) > 0) {
+		fail("a mirror push already replicates all refs, notes and refspecs are not needed")
+	}
+	if c.PushMirror && c.RecordState {
+		fail("a mirror push would delete the state ref, which is not on the primary")
+	}
+	if c.BadgeBranch != "" && !isValidBranch(c.BadgeBranch) {
+		fail("invalid badge branch: %q", c.BadgeBranch)
+	}
+	if c.PushMirror && c.BadgeBranch != "" {
+		fail("a mirror push would delete the badge branch, which is not on the primary")
+	}
+	for _, branch := range c.MirrorBranches {
+		if c.BadgeBranch == branch {
+			fail("the badge branch must differ from the mirror branches: %s", branch)
+		}
+	}
+
+	for _, path := range c.FilterPaths {
+		if path == "" || strings.HasPrefix(path, "/") || strings.Contains(path, "'") {
+			fail("invalid filter path: %q (must be relative to the repository root)", path)
+		}
+	}
+	if c.StripBlobsBiggerThan != "" && !isValidSize(c.StripBlobsBiggerThan) {
+		fail("invalid size: %s (must be a number with an optional K, M or G suffix)", c.StripBlobsBiggerThan)
+	}
+	if c.Subdirectory != "" {
+		if strings.Contains(c.Subdirectory, "'") || strings.Contains("/"+c.Subdirectory+"/", "/../") {
+			fail("invalid subdirectory: %q (must be relative to the repository root)", c.Subdirectory)
+		}
+		if len(c.FilterPaths) > 0 {
+			fail("a subdirectory cannot be combined with filter paths")
+		}
+	}
+	if (len(c.FilterPaths) > 0 || c.StripBlobsBiggerThan != "" || c.Subdirectory != "") && (c.SyncNotes || len(c.Refspecs) > 0) {
+		fail("history filters cannot be combined with notes or refspecs")
+	}
+
+	if c.RequireSigned {
+		if c.AllowedSignersFile == "" && c.SigningKeysFile == "" {
+			fail("signed commits require an allowed signers or signing keys file")
+		}
+		if c.PushMirror || len(c.Refspecs) > 0 {
+			fail("signed commits are only verified on the primary branch and cannot be combined with a mirror push or refspecs")
+		}
+	}
+	if c.ScanSecrets && (c.PushMirror || len(c.Refspecs) > 0) {
+		fail("secrets are only scanned for on the primary branch, which cannot be combined with a mirror push or refspecs")
+	}
+
+	if !isValidBranch(c.PrimaryBranch) {
+		fail("invalid primary branch: %q", c.PrimaryBranch)
+	}
+	if len(c.MirrorBranches) == 0 {
+		fail("at least one mirror branch is required")
+	}
+	seenBranches := make(map[string]bool)
+	for _, branch := range c.MirrorBranches {
+		if !isValidBranch(branch) {
+			fail("invalid mirror branch: %q", branch)
+		} else if seenBranches[branch] {
+			fail("mirror branch %s was given more than once", branch)
+		}
+		seenBranches[branch] = true
+	}
+	if len(c.MirrorBranches) > 1 && c.PushMirror {
+		fail("a mirror push replicates the primary's branches and cannot push to several mirror branches")
+	}
+
+	switch c.RewritePolicy {
+	case RewritePolicyForce:
+	case RewritePolicyRefuse, RewritePolicyBackup, RewritePolicyIssue:
+		if !c.ForceSync || c.PushMirror {
+			fail("rewrite policy %s requires forced syncs and cannot be combined with a mirror push", c.RewritePolicy)
+		}
+		if c.RewritePolicy == RewritePolicyIssue && (genericMirror || c.WorkflowFormat != FormatGitHub) {
+			fail("rewrite policy issue is only supported for GitHub mirrors with the github format")
+		}
+	default:
+		fail("invalid rewrite policy: %s (must be force, refuse, backup or issue)", c.RewritePolicy)
+	}
+
+	if c.TemplateDir != "" {
+		if info, err := os.Stat(c.TemplateDir); err != nil || !info.IsDir() {
+			fail("template directory %s is not a directory", c.TemplateDir)
+		}
+	}
+	for _, p := range []string{c.Proxy, c.I2PProxy, c.TorProxy} {
+		if err := validateProxyURL(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := validateTLS(c.CACert, c.InsecureSkipVerify); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateHostKeys(c.AuthMode, c.KnownHosts, c.HostKeyFingerprints); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
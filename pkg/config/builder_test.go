@@ -0,0 +1,158 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+func TestBuilder(t *testing.T) {
+	const (
+		primary  = "https://i2pgit.org/go-i2p/reseed-tools.git"
+		github   = "https://github.com/go-i2p/reseed-tools"
+		codeberg = "https://codeberg.org/go-i2p/reseed-tools"
+	)
+	tests := []struct {
+		name  string
+		build func(*config.Builder) *config.Builder
+		check func(*testing.T, *config.Config)
+		// errs are parts of the expected error, which must all be reported
+		errs []string
+	}{
+		{
+			name: "GitHub mirror",
+			build: func(b *config.Builder) *config.Builder {
+				return b.Primary(primary).Mirrors(github).Interval("Daily")
+			},
+			check: func(t *testing.T, cfg *config.Config) {
+				if cfg.MirrorRepo != github || cfg.MirrorBranch != "main" || cfg.SyncInterval != "daily" {
+					t.Errorf("mirror %s branch %s interval %s, want %s main daily", cfg.MirrorRepo, cfg.MirrorBranch, cfg.SyncInterval, github)
+				}
+				if cfg.AuthMode != config.AuthModeToken || cfg.AuthSecret != config.DefaultAuthSecrets[config.AuthModeToken] {
+					t.Errorf("auth %s with secret %s, want the token mode and its default secret", cfg.AuthMode, cfg.AuthSecret)
+				}
+			},
+		},
+		{
+			name: "mirror outside GitHub",
+			build: func(b *config.Builder) *config.Builder {
+				return b.Primary(primary).Mirrors(codeberg, github).MirrorBranches("mirror", "stable")
+			},
+			check: func(t *testing.T, cfg *config.Config) {
+				if cfg.MirrorRepo != codeberg || cfg.MirrorBranch != "mirror" || len(cfg.MirrorRepos) != 2 {
+					t.Errorf("mirror %s branch %s of %v, want %s mirror", cfg.MirrorRepo, cfg.MirrorBranch, cfg.MirrorRepos, codeberg)
+				}
+				if cfg.AuthMode != config.AuthModePAT {
+					t.Errorf("auth mode %s, want %s", cfg.AuthMode, config.AuthModePAT)
+				}
+			},
+		},
+		{
+			name: "explicit auth and refspecs",
+			build: func(b *config.Builder) *config.Builder {
+				return b.Primary(primary).Mirrors(github).Auth("SSH", "").Refspecs("refs/tags/*", "+refs/notes/*:refs/notes/*")
+			},
+			check: func(t *testing.T, cfg *config.Config) {
+				if cfg.AuthMode != config.AuthModeSSH || cfg.AuthSecret != config.DefaultAuthSecrets[config.AuthModeSSH] {
+					t.Errorf("auth %s with secret %s, want the ssh mode and its default secret", cfg.AuthMode, cfg.AuthSecret)
+				}
+				if len(cfg.Refspecs) != 2 || !cfg.Refspecs[1].Force {
+					t.Errorf("refspecs %v, want 2 with the second forced", cfg.Refspecs)
+				}
+			},
+		},
+		{
+			name: "Apply",
+			build: func(b *config.Builder) *config.Builder {
+				return b.Primary(primary).Apply(func(cfg *config.Config) { cfg.MirrorRepo = github })
+			},
+			check: func(t *testing.T, cfg *config.Config) {
+				if cfg.MirrorRepo != github || len(cfg.MirrorRepos) != 1 {
+					t.Errorf("mirror %s of %v, want %s", cfg.MirrorRepo, cfg.MirrorRepos, github)
+				}
+			},
+		},
+		{
+			name:  "no primary",
+			build: func(b *config.Builder) *config.Builder { return b.Mirrors(github) },
+			errs:  []string{"primary repository URL is required"},
+		},
+		{
+			name:  "no mirror",
+			build: func(b *config.Builder) *config.Builder { return b.Primary(primary) },
+			errs:  []string{"mirror repository URL is required"},
+		},
+		{
+			name: "all mistakes at once",
+			build: func(b *config.Builder) *config.Builder {
+				return b.Primary(primary).Mirrors(github).Interval("monthly").Format("jenkins").Refspecs("main").BadgeBranch("a..b")
+			},
+			errs: []string{"invalid sync interval: monthly", "invalid workflow format: jenkins", "invalid refspec \"main\"", "invalid badge branch"},
+		},
+		{
+			name: "token auth for a mirror outside GitHub",
+			build: func(b *config.Builder) *config.Builder {
+				return b.Primary(primary).Mirrors(codeberg).Auth(config.AuthModeToken, "")
+			},
+			errs: []string{"auth mode token is only supported for GitHub mirrors"},
+		},
+		{
+			name: "plain HTTP mirror",
+			build: func(b *config.Builder) *config.Builder {
+				return b.Primary(primary).Mirrors("http://codeberg.org/go-i2p/reseed-tools")
+			},
+			errs: []string{"must use an https:// URL"},
+		},
+		{
+			name: "mirror push with merging",
+			build: func(b *config.Builder) *config.Builder {
+				return b.Primary(primary).Mirrors(github).PushMirror().Force(false)
+			},
+			errs: []string{"cannot be combined with merging"},
+		},
+		{
+			name: "setup without credentials",
+			build: func(b *config.Builder) *config.Builder {
+				return b.Primary(primary).Mirrors(github).Setup(false)
+			},
+			errs: []string{"a GitHub token or GitHub App is required for setup"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := tt.build(config.NewBuilder()).Build()
+			if len(tt.errs) > 0 {
+				if err == nil {
+					t.Fatalf("Build succeeded, want errors %q", tt.errs)
+				}
+				for _, want := range tt.errs {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("Build error %q does not report %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestBuilderCopies(t *testing.T) {
+	b := config.NewBuilder().Primary("https://i2pgit.org/go-i2p/reseed-tools.git").Mirrors("https://github.com/go-i2p/reseed-tools").Set("key", "first")
+	first, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	first.MirrorRepos[0] = "changed"
+	second, err := b.Set("key", "second").Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if second.MirrorRepos[0] == "changed" || first.Extra["key"] != "first" || second.Extra["key"] != "second" {
+		t.Errorf("configurations built by one Builder share their values: %v %v, %v %v", first.MirrorRepos, first.Extra, second.MirrorRepos, second.Extra)
+	}
+}
@@ -1,139 +1,735 @@
 // Package config handles the configuration settings for the GitHub mirror sync tool.
+// A Config is built from the command line by Flags, and by programs using the
+// packages as a library with a Builder, or filled in directly starting from
+// Default.
 package config
 
 import (
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
-	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+)
+
+// Auth modes select the credentials the generated workflow pushes with.
+const (
+	// AuthModeToken pushes with the GITHUB_TOKEN provided by GitHub Actions
+	AuthModeToken = "token"
+	// AuthModePAT pushes with a personal access token stored as a secret
+	AuthModePAT = "pat"
+	// AuthModeSSH pushes over SSH with a deploy key stored as a secret
+	AuthModeSSH = "ssh"
+)
+
+// Workflow formats.
+const (
+	// FormatGitHub generates a GitHub Actions workflow
+	FormatGitHub = "github"
+	// FormatForgejo generates a Forgejo Actions workflow, which runs on the
+	// mirror when it is hosted on Forgejo or Gitea
+	FormatForgejo = "forgejo"
+	// FormatGitLab generates a GitLab CI/CD pipeline, which runs on a
+	// GitLab mirror
+	FormatGitLab = "gitlab"
+)
+
+// Output formats of the result of the root command.
+const (
+	// OutputFormatText prints the workflow and human-readable messages
+	OutputFormatText = "text"
+	// OutputFormatJSON prints a single JSON result object to stdout
+	OutputFormatJSON = "json"
+)
+
+// CredentialHelperNone disables the git credential helpers.
+const CredentialHelperNone = "none"
+
+// Rewrite policies select what a sync does when the primary branch history
+// was rewritten since the last sync.
+const (
+	// RewritePolicyForce overwrites the mirror with the rewritten history
+	RewritePolicyForce = "force"
+	// RewritePolicyRefuse fails the sync and leaves the mirror unchanged
+	RewritePolicyRefuse = "refuse"
+	// RewritePolicyBackup keeps the previous mirror history in a backup
+	// branch, then overwrites the mirror
+	RewritePolicyBackup = "backup"
+	// RewritePolicyIssue fails the sync and opens an issue on the mirror
+	RewritePolicyIssue = "issue"
 )
 
+// DefaultAuthSecrets are the secret names used by each auth mode unless overridden.
+var DefaultAuthSecrets = map[string]string{
+	AuthModePAT: "MIRROR_PAT",
+	AuthModeSSH: "MIRROR_DEPLOY_KEY",
+}
+
 // Config holds the application configuration.
 type Config struct {
 	// GitHub token for authentication
 	GithubToken string
 
+	// GitHub App authentication, used instead of the token when AppID is set
+	AppID             int64
+	AppInstallationID int64
+	AppPrivateKey     string
+
+	// Maximum time to wait for a GitHub API rate limit to reset
+	RateLimitWait time.Duration
+
+	// Directory of the on-disk GitHub API response cache, empty to disable
+	CacheDir string
+
+	// Proxy for HTTP(S) git connections, e.g. socks5h://127.0.0.1:4447
+	Proxy string
+
+	// HTTP proxy of the I2P router used for .i2p primaries
+	I2PProxy string
+
+	// SOCKS proxy of the Tor client used for .onion primaries, and the
+	// secret holding the proxy the workflow uses instead of starting Tor
+	TorProxy       string
+	TorProxySecret string
+
+	// Check SSH repository URLs by connecting to them, optionally with a key
+	ValidateSSH bool
+	SSHKey      string
+
+	// Git credential helper for HTTPS repositories, empty to use the helpers
+	// from the git configuration and CredentialHelperNone to use none
+	CredentialHelper string
+
+	// PEM bundle of the CA certificates trusted besides the system roots, or
+	// whether certificates are not verified at all, for HTTPS git
+	// connections to repositories outside GitHub, such as self-hosted forges
+	// with a private CA
+	CACert             string
+	InsecureSkipVerify bool
+
+	// Git binary to run, empty to look git up in PATH
+	GitPath string
+
+	// Directory holding the working copies of the local sync engine
+	WorkDir string
+
+	// Directory holding the sync state and the install manifest
+	StateDir string
+
+	// File recording what was set up on each mirror, for uninstall
+	ManifestFile string
+
+	// JSON lines file every change made to the mirrors is appended to,
+	// empty to keep no audit log
+	AuditLog string
+
+	// URL the outcome of each local sync and setup is posted to, empty to
+	// send no notifications
+	NotifyURL string
+
+	// JSON lines file each significant step, e.g. a commit or push, is
+	// appended to, empty to write no events
+	EventsFile string
+
+	// Retries of transient GitHub API errors
+	Retries    int
+	RetryDelay time.Duration
+
 	// Repository URLs
 	PrimaryRepo string
 	MirrorRepo  string
 
+	// MirrorRepos lists every mirror given on the command line; MirrorRepo
+	// is the first of them, or the one currently being set up
+	MirrorRepos []string
+
 	// Branch names
 	PrimaryBranch string
 	MirrorBranch  string
 
+	// MirrorBranches lists every mirror branch the primary branch is pushed
+	// to; MirrorBranch is the first of them, which is synced and tracked
+	MirrorBranches []string
+
 	// Synchronization settings
 	SyncInterval string
 	ForceSync    bool
 
+	// Replicate all refs of the primary with git push --mirror instead of
+	// syncing a single branch
+	PushMirror bool
+
+	// Cache the objects of the primary between workflow runs
+	CachePrimary bool
+
+	// Paths and blobs above a size, e.g. 10M, removed from the primary
+	// history by the workflow before it is pushed
+	FilterPaths          []string
+	StripBlobsBiggerThan string
+
+	// Subdirectory of the primary published as the root of the mirror
+	Subdirectory string
+
+	// Refuse to sync primary commits that are not signed by one of the SSH
+	// signers or GPG keys read from the given files
+	RequireSigned      bool
+	AllowedSignersFile string
+	AllowedSigners     string
+	SigningKeysFile    string
+	SigningKeys        string
+
+	// Refuse to sync primary commits in which gitleaks finds credentials
+	ScanSecrets bool
+
+	// What to do when the primary branch history was rewritten since the
+	// last sync, one of the RewritePolicy constants
+	RewritePolicy string
+
+	// Credentials used by the workflow to push to the mirror
+	AuthMode   string
+	AuthSecret string
+
+	// SSH host keys of the primary trusted by the workflow in the ssh auth
+	// mode: the known_hosts entries read from KnownHostsFile, or else the
+	// keys fetched from the primary whose fingerprints, e.g.
+	// SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU, are listed in
+	// HostKeyFingerprints
+	KnownHostsFile      string
+	KnownHosts          string
+	HostKeyFingerprints []string
+
+	// User name sent with the token to mirrors outside GitHub
+	MirrorUser string
+
+	// GitHub Environment the sync job runs in
+	Environment string
+
+	// Additional refs to mirror besides the branch
+	SyncNotes bool
+	Refspecs  []Refspec
+
+	// Record each sync of the workflow in a state file on the mirror
+	RecordState bool
+
+	// Mark each synced mirror commit with a commit status naming the primary
+	CommitStatus bool
+
+	// Branch of the mirror the badge of the last sync is published to, if
+	// not empty
+	BadgeBranch string
+
+	// Values given with --set, exposed to the workflow templates as .Extra
+	Extra map[string]string
+
+	// Directory of templates overriding the built-in workflow templates of
+	// the same name
+	TemplateDir string
+
+	// Scripts the generated workflow runs before and after the sync step,
+	// set by the hooks of the config file
+	PreSyncHook  string
+	PostSyncHook string
+
+	// Format of the generated workflow, FormatGitHub, FormatForgejo or FormatGitLab
+	WorkflowFormat string
+
+	// Forge kind and API token of a mirror outside GitHub, used by setup
+	MirrorForge string
+	MirrorToken string
+
 	// Output configuration
 	OutputFile    string
 	SetupWorkflow bool
-	Verbose       bool
-}
-
-var (
-	config Config
-
-	// Flags
-	primaryRepo   string
-	mirrorRepo    string
-	primaryBranch string
-	mirrorBranch  string
-	syncInterval  string
-	forceSync     bool
-	outputFile    string
-	setupWorkflow bool
-	verbose       bool
-)
+	SetupViaPR    bool
+
+	// Format of the result printed by the root command, OutputFormatText or OutputFormatJSON
+	OutputFormat string
+
+	// Show what setup would change instead of changing it
+	DryRun bool
+
+	// Skip the confirmation prompt before overwriting mirror content
+	AssumeYes bool
+
+	// Rewrite local git remotes when the mirror was renamed or transferred
+	UpdateRemote bool
+
+	// Remove other sync workflows found on the mirror during setup
+	ReplaceExisting bool
+
+	// Branch protection handling
+	ConfigureProtection bool
+	BypassApp           string
+
+	// Post-setup verification
+	VerifyRun     bool
+	VerifyTimeout time.Duration
+
+	// Mirror repository creation
+	CreateMissing bool
+	PrivateMirror bool
+
+	// Maintain a mirror notice in the mirror README
+	ReadmeBanner bool
+
+	// Mirror repository metadata
+	SetMetadata       bool
+	MirrorDescription string
+	MirrorHomepage    string
+	MirrorTopics      []string
+}
+
+// Default returns the configuration the command line starts from, for
+// programs using the packages as a library. The repositories and the
+// credentials are left to be filled in.
+func Default() *Config {
+	stateDir := defaultStateDir()
+	manifestFile := ""
+	if stateDir != "" {
+		manifestFile = filepath.Join(stateDir, "manifest.json")
+	}
+
+	return &Config{
+		RateLimitWait:  15 * time.Minute,
+		CacheDir:       defaultCacheDir(),
+		WorkDir:        defaultWorkDir(),
+		StateDir:       stateDir,
+		ManifestFile:   manifestFile,
+		I2PProxy:       "http://127.0.0.1:4444",
+		TorProxy:       "socks5h://127.0.0.1:9050",
+		Retries:        3,
+		RetryDelay:     time.Second,
+		PrimaryBranch:  "main",
+		MirrorBranch:   "main",
+		MirrorBranches: []string{"main"},
+		SyncInterval:   "hourly",
+		ForceSync:      true,
+		AuthMode:       AuthModeToken,
+		WorkflowFormat: FormatGitHub,
+		RewritePolicy:  RewritePolicyForce,
+		BypassApp:      "github-actions",
+		VerifyTimeout:  10 * time.Minute,
+		MirrorTopics:   []string{"mirror", "unofficial-mirror"},
+		OutputFormat:   OutputFormatText,
+	}
+}
+
+// ErrGitNotFound is returned by LookGit when git is not installed.
+var ErrGitNotFound = errors.New("git not found in PATH, install it or point --git-path at it")
 
-// AddFlags adds the configuration flags to the given command.
-func AddFlags(cmd *cobra.Command) {
-	cmd.Flags().StringVarP(&primaryRepo, "primary", "p", "", "Primary repository URL (required)")
-	cmd.Flags().StringVarP(&mirrorRepo, "mirror", "m", detectGithubRemote(), "GitHub mirror repository URL (required)")
-	cmd.Flags().StringVar(&primaryBranch, "primary-branch", "main", "Primary repository branch name")
-	cmd.Flags().StringVar(&mirrorBranch, "mirror-branch", "main", "GitHub mirror repository branch name")
-	cmd.Flags().StringVarP(&syncInterval, "interval", "i", "hourly", "Sync interval (hourly, daily, weekly)")
-	cmd.Flags().BoolVar(&forceSync, "force", true, "Force sync by overwriting mirror with primary content")
-	cmd.Flags().StringVarP(&outputFile, "output", "o", ".github/workflows/sync.yaml", "Output file for workflow YAML (writes to stdout if not specified)")
-	cmd.Flags().BoolVar(&setupWorkflow, "setup", false, "Automatically setup the workflow in the GitHub repository")
-	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
+// LookGit returns the git binary to run: path when it is set, or else git
+// looked up in PATH, where git.exe is found on Windows.
+func LookGit(path string) (string, error) {
+	if path != "" {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			return "", fmt.Errorf("git not found at %s", path)
+		}
+		return path, nil
+	}
+
+	binary, err := exec.LookPath("git")
+	if err != nil {
+		return "", ErrGitNotFound
+	}
+	return binary, nil
+}
 
-	cmd.MarkFlagRequired("primary")
+// SyncPeriod returns the time between syncs for the configured sync interval.
+func (c *Config) SyncPeriod() time.Duration {
+	switch strings.ToLower(c.SyncInterval) {
+	case "daily":
+		return 24 * time.Hour
+	case "weekly":
+		return 7 * 24 * time.Hour
+	default:
+		return time.Hour
+	}
+}
+
+// DetectsRewrites reports whether syncs check the primary branch for
+// rewritten history, which the default force policy does not.
+func (c *Config) DetectsRewrites() bool {
+	return c.RewritePolicy != "" && c.RewritePolicy != RewritePolicyForce
 }
 
-// Load parses the flags and environment variables to build the configuration.
-func Load() (*Config, error) {
-	// Get GitHub token from environment
+// CommitStatusContext identifies the commit status set with CommitStatus
+// among the other statuses of a mirror commit.
+const CommitStatusContext = "gh-mirror"
+
+// BadgeFile is the file of the branch set with BadgeBranch holding the badge
+// of the last sync.
+const BadgeFile = "gh-mirror-badge.json"
+
+// maxStatusDescription is the longest commit status description GitHub
+// accepts.
+const maxStatusDescription = 140
+
+// CommitStatusDescription returns the description of the commit status
+// marking a mirror commit as synced from primaryRepo at shortSHA, the first
+// 12 characters of the primary commit. Long primary URLs are shortened from
+// the start, keeping the repository name.
+func CommitStatusDescription(primaryRepo, shortSHA string) string {
+	primary := strings.TrimSuffix(primaryRepo, ".git")
+	if i := strings.Index(primary, "://"); i >= 0 {
+		primary = primary[i+3:]
+	}
+	const fixed = len("Mirrored from  @ ") + 12
+	if len(primary) > maxStatusDescription-fixed {
+		primary = "..." + primary[len(primary)-(maxStatusDescription-fixed-3):]
+	}
+	return "Mirrored from " + primary + " @ " + shortSHA
+}
+
+// tokenFromEnv returns the GitHub token from the environment, if any.
+func tokenFromEnv() string {
 	githubToken := os.Getenv("GH_TOKEN")
 	if githubToken == "" {
 		githubToken = os.Getenv("GITHUB_TOKEN")
 	}
-	if githubToken == "" && setupWorkflow {
-		return nil, fmt.Errorf("GitHub token not found in environment (GH_TOKEN or GITHUB_TOKEN) but required for --setup")
+	redact.Secret(githubToken)
+	return githubToken
+}
+
+// validateProxyURL checks that a proxy URL, when not empty, uses a scheme
+// supported by git.
+func validateProxyURL(proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || proxyURL.Host == "" {
+		return fmt.Errorf("invalid proxy URL: %s", proxy)
 	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks4", "socks4a", "socks5", "socks5h":
+		return nil
+	default:
+		return fmt.Errorf("unsupported proxy scheme: %s (must be http, https, socks4, socks4a, socks5 or socks5h)", proxyURL.Scheme)
+	}
+}
 
-	// Validate repositories
-	if primaryRepo == "" {
-		return nil, fmt.Errorf("primary repository URL is required")
+// validateNotifyURL checks that a notification URL, when not empty, is an
+// absolute HTTP or HTTPS URL.
+func validateNotifyURL(notifyURL string) error {
+	if notifyURL == "" {
+		return nil
 	}
-	if mirrorRepo == "" {
-		return nil, fmt.Errorf("mirror repository URL is required")
+	parsed, err := url.Parse(notifyURL)
+	if err != nil || parsed.Host == "" || parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("invalid --notify-url: %s (must be an http:// or https:// URL)", notifyURL)
 	}
+	return nil
+}
 
-	// Validate sync interval
-	switch strings.ToLower(syncInterval) {
-	case "hourly", "daily", "weekly":
-		// valid
-	default:
-		return nil, fmt.Errorf("invalid sync interval: %s (must be hourly, daily, or weekly)", syncInterval)
+// ValidateSecretName checks a secret name against GitHub's naming rules.
+func ValidateSecretName(name string) error {
+	if name == "" {
+		return fmt.Errorf("secret name must not be empty")
+	}
+	if strings.HasPrefix(strings.ToUpper(name), "GITHUB_") {
+		return fmt.Errorf("invalid secret name %s: must not start with GITHUB_", name)
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		return fmt.Errorf("invalid secret name %s: must not start with a number", name)
+	}
+	for _, r := range name {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return fmt.Errorf("invalid secret name %s: only letters, numbers and underscores are allowed", name)
+		}
+	}
+	return nil
+}
+
+// CertPool returns the roots trusted with CACert: the system roots and the
+// PEM certificates of the file at path, as the git connections of run trust
+// them.
+func CertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificate found in %s", path)
+	}
+	return pool, nil
+}
+
+// validateHostKeys checks the SSH host key options of the workflow: they
+// need the ssh auth mode, and knownHosts, the content of a known_hosts file,
+// and fingerprints exclude each other.
+func validateHostKeys(authMode, knownHosts string, fingerprints []string) error {
+	if knownHosts == "" && len(fingerprints) == 0 {
+		return nil
+	}
+	if authMode != AuthModeSSH {
+		return fmt.Errorf("--known-hosts and --host-key-fingerprint pin the SSH host key of the primary for --auth-mode ssh")
+	}
+	if knownHosts != "" && len(fingerprints) > 0 {
+		return fmt.Errorf("--known-hosts and --host-key-fingerprint cannot be combined")
+	}
+	for _, fingerprint := range fingerprints {
+		if !strings.HasPrefix(fingerprint, "SHA256:") {
+			return fmt.Errorf("invalid host key fingerprint %s: must be a SHA256 fingerprint as shown by ssh-keygen -l, e.g. SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU", fingerprint)
+		}
+	}
+	for _, line := range strings.Split(knownHosts, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, _, _, _, _, err := ssh.ParseKnownHosts([]byte(line)); err != nil {
+			return fmt.Errorf("invalid known_hosts entry %q: %w", line, err)
+		}
 	}
+	return nil
+}
+
+// validateTLS checks the TLS options of git connections.
+func validateTLS(caCert string, insecureSkipVerify bool) error {
+	if caCert == "" {
+		return nil
+	}
+	if insecureSkipVerify {
+		return fmt.Errorf("--ca-cert has no effect with --insecure-skip-verify, which trusts any certificate")
+	}
+	_, err := CertPool(caCert)
+	return err
+}
 
-	// Set the values in the config struct
-	config = Config{
-		GithubToken:   githubToken,
-		PrimaryRepo:   primaryRepo,
-		MirrorRepo:    mirrorRepo,
-		PrimaryBranch: primaryBranch,
-		MirrorBranch:  mirrorBranch,
-		SyncInterval:  syncInterval,
-		ForceSync:     forceSync,
-		OutputFile:    outputFile,
-		SetupWorkflow: setupWorkflow,
-		Verbose:       verbose,
+// ValidateBranch checks that branch is a git branch name that is safe to use
+// unquoted in the generated sync script. Only letters, numbers and the
+// characters . _ - / + @ # , = are allowed, which the shell takes literally,
+// and the name must start with a letter, number or underscore; names git
+// rejects, such as those containing .. or ending with .lock, are refused
+// too.
+func ValidateBranch(branch string) error {
+	if branch == "" {
+		return fmt.Errorf("branch name must not be empty")
+	}
+	for i, r := range branch {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+		case i > 0 && strings.ContainsRune(".-/+@#,=", r):
+		default:
+			return fmt.Errorf("invalid branch name %q: character %q is not allowed", branch, r)
+		}
+	}
+	if strings.Contains(branch, "..") || strings.Contains(branch, "//") || strings.Contains(branch, "/.") ||
+		strings.HasSuffix(branch, "/") || strings.HasSuffix(branch, ".") || strings.HasSuffix(branch, ".lock") {
+		return fmt.Errorf("invalid branch name %q: not a valid git ref name", branch)
 	}
+	return nil
+}
 
-	return &config, nil
+// isValidBranch reports whether branch passes ValidateBranch.
+func isValidBranch(branch string) bool {
+	return ValidateBranch(branch) == nil
 }
 
-// detectGithubRemote attempts to detect a GitHub remote URL from the current git repository
-func detectGithubRemote() string {
-	// Execute git remote -v command
-	cmd := exec.Command("git", "remote", "-v")
-	output, err := cmd.Output()
+// isValidTopic reports whether topic is acceptable as a GitHub repository topic.
+func isValidTopic(topic string) bool {
+	if topic == "" || len(topic) > 50 || strings.HasPrefix(topic, "-") {
+		return false
+	}
+	for _, r := range topic {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// readTrustFile returns the trimmed contents of a signers or keys file, or an
+// empty string when path is empty.
+func readTrustFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
 	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	content := strings.TrimSpace(string(data))
+	if content == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return content, nil
+}
+
+// isValidSize reports whether size is a byte count with an optional K, M or
+// G suffix, as accepted by git-filter-repo.
+func isValidSize(size string) bool {
+	digits := strings.TrimRight(size, "KMG")
+	if digits == "" || len(size)-len(digits) > 1 {
+		return false
+	}
+	_, err := strconv.ParseUint(digits, 10, 64)
+	return err == nil
+}
+
+// Refspec describes additional refs fetched from the primary and pushed to the mirror.
+type Refspec struct {
+	// Source is the ref pattern in the primary repository
+	Source string
+	// Destination is the ref pattern in the mirror repository
+	Destination string
+	// Force allows non-fast-forward updates of the destination refs
+	Force bool
+}
+
+// String returns the refspec in git notation.
+func (r Refspec) String() string {
+	spec := r.Source + ":" + r.Destination
+	if r.Force {
+		spec = "+" + spec
+	}
+	return spec
+}
+
+// ParseRefspec parses a refspec of the form [+]<src>[:<dst>].
+// When the destination is omitted the source pattern is reused.
+func ParseRefspec(spec string) (Refspec, error) {
+	var refspec Refspec
+
+	if strings.HasPrefix(spec, "+") {
+		refspec.Force = true
+		spec = strings.TrimPrefix(spec, "+")
+	}
+
+	src, dst, found := strings.Cut(spec, ":")
+	if !found {
+		dst = src
+	}
+	refspec.Source, refspec.Destination = src, dst
+
+	for _, ref := range []string{src, dst} {
+		if !strings.HasPrefix(ref, "refs/") {
+			return Refspec{}, fmt.Errorf("invalid refspec %q: refs must start with refs/", spec)
+		}
+		if strings.ContainsAny(ref, " \t\n'\"\\:;&|$`") {
+			return Refspec{}, fmt.Errorf("invalid refspec %q: contains forbidden characters", spec)
+		}
+	}
+	if strings.Count(src, "*") != strings.Count(dst, "*") || strings.Count(src, "*") > 1 {
+		return Refspec{}, fmt.Errorf("invalid refspec %q: source and destination must both contain a single * or none", spec)
+	}
+
+	return refspec, nil
+}
+
+// ParseExtra parses template values of the form key=value. A key given
+// several times takes the last value.
+func ParseExtra(values []string) (map[string]string, error) {
+	extra := make(map[string]string, len(values))
+	for _, value := range values {
+		key, val, found := strings.Cut(value, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid --set value %q: must be key=value", value)
+		}
+		if !isValidExtraKey(key) {
+			return nil, fmt.Errorf("invalid --set key %q: must be letters, digits and underscores, not starting with a digit", key)
+		}
+		extra[key] = val
+	}
+	return extra, nil
+}
+
+// isValidExtraKey reports whether key can be used as .Extra.<key> in a template.
+func isValidExtraKey(key string) bool {
+	if key == "" || key[0] >= '0' && key[0] <= '9' {
+		return false
+	}
+	for _, r := range key {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsGitHubURL reports whether repoURL refers to a repository on github.com.
+func IsGitHubURL(repoURL string) bool {
+	for _, prefix := range []string{"https://github.com/", "http://github.com/", "git@github.com:", "ssh://git@github.com/"} {
+		if strings.HasPrefix(repoURL, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLocalURL reports whether repoURL is a file:// URL of a repository on disk.
+func IsLocalURL(repoURL string) bool {
+	return strings.HasPrefix(repoURL, "file://")
+}
+
+// IsSSHURL reports whether repoURL is an SSH or scp-like Git URL.
+func IsSSHURL(repoURL string) bool {
+	return strings.HasPrefix(repoURL, "git@") || strings.HasPrefix(repoURL, "ssh://")
+}
+
+// I2PHost returns the host name of an HTTP repository URL on the I2P
+// network, or an empty string for any other URL.
+func I2PHost(repoURL string) string {
+	parsed, err := url.Parse(repoURL)
+	if err != nil || parsed.Scheme != "http" || !strings.HasSuffix(parsed.Hostname(), ".i2p") {
 		return ""
 	}
+	return parsed.Host
+}
+
+// OnionOrigin returns the scheme and host of an HTTP(S) repository URL of a
+// Tor onion service, or an empty string for any other URL.
+func OnionOrigin(repoURL string) string {
+	parsed, err := url.Parse(repoURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || !strings.HasSuffix(parsed.Hostname(), ".onion") {
+		return ""
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
 
-	// Parse the output to find GitHub remotes
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "github.com") && strings.Contains(line, "(push)") {
-			// Extract the GitHub repository URL
-			parts := strings.Fields(line)
-			if len(parts) >= 2 {
-				url := parts[1]
-				// Convert SSH URL to HTTPS URL if needed
-				if strings.HasPrefix(url, "git@github.com:") {
-					url = strings.Replace(url, "git@github.com:", "https://github.com/", 1)
-				}
-				// Remove .git suffix if present
-				url = strings.TrimSuffix(url, ".git")
-				return url
-			}
+// localRepoURL returns the absolute file:// URL of a repository given as a
+// local path, following git's rule that a path has no colon before its first
+// slash. Other URLs are returned unchanged.
+func localRepoURL(repo string) (string, error) {
+	if IsLocalURL(repo) {
+		if !strings.HasPrefix(repo, "file:///") {
+			return "", fmt.Errorf("invalid file URL: %s (must be file:// followed by an absolute path)", repo)
 		}
+		return repo, nil
+	}
+	colon, slash := strings.Index(repo, ":"), strings.Index(repo, "/")
+	if strings.Contains(repo, "://") || (colon >= 0 && (slash < 0 || colon < slash)) {
+		return repo, nil
 	}
 
-	return ""
+	path, err := filepath.Abs(repo)
+	if err != nil {
+		return "", fmt.Errorf("invalid repository path %s: %w", repo, err)
+	}
+	return "file://" + filepath.ToSlash(path), nil
+}
+
+// RepoName returns the name of the repository at repoURL, its last path
+// element without a .git suffix.
+func RepoName(repoURL string) string {
+	path := strings.TrimSuffix(strings.TrimRight(repoURL, "/"), ".git")
+	if i := strings.LastIndexAny(path, "/:"); i >= 0 {
+		path = path[i+1:]
+	}
+	return path
 }
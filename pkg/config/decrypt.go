@@ -0,0 +1,91 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Headers of files encrypted with age, in the binary and the ASCII-armored
+// format.
+const (
+	ageHeader        = "age-encryption.org/v1\n"
+	ageArmoredHeader = "-----BEGIN AGE ENCRYPTED FILE-----"
+)
+
+// decryptConfigFile returns the content of the config file at path, which
+// holds data, decrypted when it is encrypted with age or sops, or else data
+// itself. age files are decrypted with the age identity file identity; sops
+// files with it as their age key, if given, or else with the keys sops finds
+// itself, like PGP keys or cloud KMS credentials. The age and sops binaries
+// write the plaintext to a pipe, so that it is only ever held in memory.
+func decryptConfigFile(path string, data []byte, identity string) ([]byte, error) {
+	switch {
+	case isAgeEncrypted(data):
+		if identity == "" {
+			return nil, fmt.Errorf("config file %s is encrypted with age: pass the identity file decrypting it with --config-identity", path)
+		}
+		cmd, err := decryptCommand("age", "--decrypt", "--identity", identity)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Stdin = bytes.NewReader(data)
+		return runDecrypt(cmd, path)
+
+	case isSopsEncrypted(data):
+		cmd, err := decryptCommand("sops", "--decrypt", "--input-type", "yaml", "--output-type", "yaml", path)
+		if err != nil {
+			return nil, err
+		}
+		if identity != "" {
+			cmd.Env = append(os.Environ(), "SOPS_AGE_KEY_FILE="+identity)
+		}
+		return runDecrypt(cmd, path)
+	}
+	return data, nil
+}
+
+// isAgeEncrypted reports whether data is a file encrypted with age.
+func isAgeEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(ageHeader)) ||
+		bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte(ageArmoredHeader))
+}
+
+// isSopsEncrypted reports whether data is a YAML file encrypted with sops,
+// which records how in its sops mapping.
+func isSopsEncrypted(data []byte) bool {
+	var file struct {
+		Sops map[string]interface{} `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return false
+	}
+	_, ok := file.Sops["mac"]
+	return ok
+}
+
+// decryptCommand returns the command running the decrypting binary name with
+// args.
+func decryptCommand(name string, args ...string) (*exec.Cmd, error) {
+	binary, err := exec.LookPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s is required to decrypt the config file: %w", name, err)
+	}
+	return exec.Command(binary, args...), nil
+}
+
+// runDecrypt runs cmd and returns the decrypted config file at path it
+// writes to its standard output.
+func runDecrypt(cmd *exec.Cmd, path string) ([]byte, error) {
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	plaintext, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt config file %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,52 @@
+package config
+
+import "errors"
+
+// Kinds of failures reported by the packages of gh-mirror. The errors they
+// return are marked with them by WithKind, so that callers can tell failures
+// apart with errors.Is, whichever package or API reported them:
+//
+//	if errors.Is(err, config.ErrRateLimited) {
+//		// try again later
+//	}
+var (
+	// ErrRepoNotFound marks failures on repositories that do not exist, or
+	// that the credentials cannot see
+	ErrRepoNotFound = errors.New("repository not found")
+
+	// ErrAuthFailed marks requests rejected for missing, invalid or
+	// insufficient credentials
+	ErrAuthFailed = errors.New("authentication failed")
+
+	// ErrBranchMissing marks failures on branches missing in a repository
+	ErrBranchMissing = errors.New("branch not found")
+
+	// ErrRateLimited marks API requests rejected by a rate limit
+	ErrRateLimited = errors.New("rate limit exceeded")
+)
+
+// WithKind returns err marked as a failure of kind, one of the errors above.
+// The message of err is kept, and errors.Is and errors.As still find the
+// errors it wraps. WithKind returns nil when err is nil.
+func WithKind(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// kindError is an error marked with its kind.
+type kindError struct {
+	kind error
+	err  error
+}
+
+// Error implements error.
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error and its kind.
+func (e *kindError) Unwrap() []error {
+	return []error{e.err, e.kind}
+}
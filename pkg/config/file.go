@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// ApplyConfigFile sets the flags of cmd that were not given on the command
+// line from the --config file, a YAML mapping of flag names to values, with
+// lists for repeatable flags:
+//
+//	mirror-org: go-i2p
+//	interval: daily
+//	mirror-branch: [main, stable]
+//
+// The values act as defaults, flags given on the command line override them.
+// Names of flags belonging to other subcommands are skipped, so one file can
+// serve them all. The hooks mapping, which has no flags, holds the pre_sync
+// and post_sync scripts of the generated workflow. A missing file is only an
+// error when --config was given.
+//
+// A file holding tokens or webhook secrets may be encrypted with age or sops;
+// it is decrypted in memory with the --config-identity file, see
+// decryptConfigFile.
+func (f *Flags) ApplyConfigFile(cmd *cobra.Command) error {
+	if f.configFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.configFile)
+	if errors.Is(err, os.ErrNotExist) && !cmd.Flags().Changed("config") {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	identity := f.configIdentity
+	if identity == "" {
+		identity = os.Getenv("GH_MIRROR_CONFIG_IDENTITY")
+	}
+	if data, err = decryptConfigFile(f.configFile, data, identity); err != nil {
+		return err
+	}
+
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", f.configFile, err)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "config" || name == "config-identity" {
+			return fmt.Errorf("config file %s: %s cannot be set in the config file", f.configFile, name)
+		}
+		if name == "hooks" {
+			if err := f.applyHooks(values[name]); err != nil {
+				return fmt.Errorf("config file %s: %w", f.configFile, err)
+			}
+			continue
+		}
+
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil {
+			if !isFlag(cmd.Root(), name) {
+				return fmt.Errorf("config file %s: unknown flag %q", f.configFile, name)
+			}
+			continue
+		}
+		if flag.Changed {
+			continue
+		}
+
+		var elems []interface{}
+		switch value := values[name].(type) {
+		case nil:
+			continue
+		case []interface{}:
+			if !isListFlag(flag.Value.Type()) {
+				return fmt.Errorf("config file %s: %s takes a single value, not a list", f.configFile, name)
+			}
+			elems = value
+		case map[string]interface{}:
+			return fmt.Errorf("config file %s: %s takes a value, not a mapping", f.configFile, name)
+		default:
+			elems = []interface{}{value}
+		}
+
+		for _, elem := range elems {
+			if err := flag.Value.Set(fmt.Sprint(elem)); err != nil {
+				return fmt.Errorf("config file %s: invalid value for %s: %w", f.configFile, name, err)
+			}
+		}
+		// Keep the value a default, subcommands check Changed for flags
+		// given on the command line
+		flag.Changed = false
+	}
+
+	return nil
+}
+
+// applyHooks sets the sync hook scripts from the hooks mapping of the config
+// file:
+//
+//	hooks:
+//	  pre_sync: ./scripts/check-license.sh
+//	  post_sync: curl -fsS -X POST "$DOWNSTREAM_HOOK"
+func (f *Flags) applyHooks(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	hooks, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("hooks must map pre_sync and post_sync to scripts")
+	}
+
+	names := make([]string, 0, len(hooks))
+	for name := range hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		script, ok := hooks[name].(string)
+		if !ok && hooks[name] != nil {
+			return fmt.Errorf("hooks.%s must be a script", name)
+		}
+		switch name {
+		case "pre_sync":
+			f.preSyncHook = script
+		case "post_sync":
+			f.postSyncHook = script
+		default:
+			return fmt.Errorf("unknown hook %q (must be pre_sync or post_sync)", name)
+		}
+	}
+	return nil
+}
+
+// isFlag reports whether cmd or one of its subcommands has a flag named name.
+func isFlag(cmd *cobra.Command, name string) bool {
+	if cmd.Flags().Lookup(name) != nil || cmd.PersistentFlags().Lookup(name) != nil {
+		return true
+	}
+	for _, sub := range cmd.Commands() {
+		if isFlag(sub, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isListFlag reports whether a flag of type typ takes repeated values.
+func isListFlag(typ string) bool {
+	return strings.HasSuffix(typ, "Slice") || strings.HasSuffix(typ, "Array")
+}
@@ -0,0 +1,906 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+)
+
+// Flags holds the command line flags of gh-mirror, from which Load and its
+// variants build the configuration. Programs using the packages as a library
+// fill in a Config directly instead.
+type Flags struct {
+	appID             int64
+	appInstallationID int64
+	appPrivateKey     string
+	rateLimitWait     time.Duration
+	timeout           time.Duration
+	cacheDir          string
+	workDir           string
+	stateDir          string
+	manifestFile      string
+	auditLog          string
+	notifyURL         string
+	eventsFile        string
+	configFile        string
+	configIdentity    string
+	proxy             string
+	i2pProxy          string
+	torProxy          string
+	torProxySecret    string
+	validateSSH       bool
+	sshKey            string
+	credentialHelper  string
+	caCert            string
+	insecureTLS       bool
+	noCache           bool
+	retries           int
+	retryDelay        time.Duration
+	primaryRepo       string
+	mirrorRepos       []string
+	mirrorOrg         string
+	gitPath           string
+	primaryBranch     string
+	mirrorBranches    []string
+	syncInterval      string
+	forceSync         bool
+	pushMirror        bool
+	cachePrimary      bool
+	filterPaths       []string
+	stripBlobs        string
+	subdirectory      string
+	requireSigned     bool
+	scanSecrets       bool
+	allowedSigners    string
+	knownHosts        string
+	hostKeys          []string
+	signingKeys       string
+	rewritePolicy     string
+	syncNotes         bool
+	recordState       bool
+	commitStatus      bool
+	badgeBranch       string
+	authMode          string
+	mirrorUser        string
+	workflowFormat    string
+	mirrorForge       string
+	authSecret        string
+	environment       string
+	refspecs          []string
+	extraValues       []string
+	templateDir       string
+	preSyncHook       string
+	postSyncHook      string
+	outputFile        string
+	setupWorkflow     bool
+	setupViaPR        bool
+	dryRun            bool
+	verbosity         int
+	logFormat         string
+	colorMode         string
+	outputFormat      string
+	assumeYes         bool
+	replaceExisting   bool
+	updateRemote      bool
+	configProtection  bool
+	bypassApp         string
+	verifyRun         bool
+	verifyTimeout     time.Duration
+	createMissing     bool
+	privateMirror     bool
+	readmeBanner      bool
+	setMetadata       bool
+	description       string
+	homepage          string
+	topics            []string
+}
+
+// NewFlags returns flags holding no values until they are added to a command.
+func NewFlags() *Flags {
+	return &Flags{}
+}
+
+// AddFlags adds the configuration flags to the given command and its subcommands.
+func (f *Flags) AddFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVarP(&f.primaryRepo, "primary", "p", "", "Primary repository URL or local path (required for generating workflows)")
+	cmd.PersistentFlags().StringArrayVarP(&f.mirrorRepos, "mirror", "m", nil, "Mirror repository URL, on GitHub or any other git host (required, repeatable; default: GitHub remote of the current git repository)")
+	cmd.PersistentFlags().StringVar(&f.mirrorOrg, "mirror-org", "", "GitHub organization or user of the mirror when --mirror is not given; the mirror is named like the primary repository")
+	cmd.PersistentFlags().StringVar(&f.primaryBranch, "primary-branch", "main", "Primary repository branch name")
+	cmd.PersistentFlags().StringArrayVar(&f.mirrorBranches, "mirror-branch", []string{"main"}, "GitHub mirror repository branch name; repeat it to push the primary branch to several mirror branches")
+	cmd.PersistentFlags().StringVarP(&f.syncInterval, "interval", "i", "hourly", "Sync interval (hourly, daily, weekly)")
+	cmd.PersistentFlags().BoolVar(&f.forceSync, "force", true, "Force sync by overwriting mirror with primary content")
+	cmd.PersistentFlags().BoolVar(&f.pushMirror, "push-mirror", false, "Replicate all refs of the primary exactly, including deletions, instead of syncing a single branch")
+	cmd.PersistentFlags().BoolVar(&f.cachePrimary, "cache-primary", false, "Cache the primary's objects between workflow runs so each sync only fetches new history")
+	cmd.PersistentFlags().StringArrayVar(&f.filterPaths, "filter-path", nil, "File or directory removed from the primary's history before it is pushed to the mirror (repeatable)")
+	cmd.PersistentFlags().StringVar(&f.stripBlobs, "strip-blobs-bigger-than", "", "Remove files larger than this size, e.g. 10M, from the primary's history before it is pushed to the mirror")
+	cmd.PersistentFlags().StringVar(&f.subdirectory, "subdirectory", "", "Publish only this subdirectory of the primary, with its history, as the root of the mirror")
+	cmd.PersistentFlags().BoolVar(&f.requireSigned, "require-signed", false, "Refuse to sync primary commits that are not signed by an allowed signer (see --allowed-signers and --signing-keys)")
+	cmd.PersistentFlags().StringVar(&f.knownHosts, "known-hosts", "", "known_hosts file of the SSH host keys of the primary the workflow trusts with --auth-mode ssh")
+	cmd.PersistentFlags().StringArrayVar(&f.hostKeys, "host-key-fingerprint", nil, "SHA256 fingerprint of an SSH host key of the primary, fetched when the workflow is generated and trusted by it with --auth-mode ssh; may be repeated")
+	cmd.PersistentFlags().StringVar(&f.allowedSigners, "allowed-signers", "", "SSH allowed signers file listing the keys trusted by --require-signed")
+	cmd.PersistentFlags().StringVar(&f.signingKeys, "signing-keys", "", "File of ASCII-armored GPG public keys trusted by --require-signed")
+	cmd.PersistentFlags().BoolVar(&f.scanSecrets, "scan-secrets", false, "Scan new primary commits for credentials with gitleaks and refuse to push them to the mirror when any are found")
+	cmd.PersistentFlags().StringVar(&f.rewritePolicy, "rewrite-policy", RewritePolicyForce, "What to do when the primary branch history was rewritten since the last sync (force, refuse, backup, issue)")
+	cmd.PersistentFlags().StringVar(&f.authMode, "auth-mode", "", "Credentials the workflow pushes with (token, pat, ssh; default token for GitHub mirrors, pat otherwise)")
+	cmd.PersistentFlags().StringVar(&f.workflowFormat, "format", FormatGitHub, "Workflow format (github, forgejo, gitlab)")
+	cmd.PersistentFlags().StringVar(&f.mirrorForge, "mirror-forge", "", "Forge kind of a mirror outside GitHub for setup (gitea, gitlab); detected if not specified")
+	cmd.PersistentFlags().StringVar(&f.mirrorUser, "mirror-user", "", "User name sent with the token when pushing to a mirror outside GitHub (default: owner in the mirror URL)")
+	cmd.PersistentFlags().StringVar(&f.authSecret, "auth-secret", "", "Name of the secret holding the PAT or deploy key (default MIRROR_PAT or MIRROR_DEPLOY_KEY)")
+	cmd.PersistentFlags().StringVar(&f.environment, "environment", "", "GitHub Environment to run the sync job in; created during setup if missing, and used to scope secrets")
+	cmd.PersistentFlags().BoolVar(&f.syncNotes, "sync-notes", false, "Also mirror git notes (refs/notes/*)")
+	cmd.PersistentFlags().BoolVar(&f.recordState, "record-state", false, "Have the workflow record each sync in .github/sync-state.json on the mirror ref refs/gh-mirror/state/<branch>")
+	cmd.PersistentFlags().StringVar(&f.badgeBranch, "badge-branch", "", "Branch of the mirror, e.g. gh-pages, to publish a shields.io badge of the last sync to as gh-mirror-badge.json")
+	cmd.PersistentFlags().BoolVar(&f.commitStatus, "commit-status", false, "Mark each synced mirror commit with a commit status naming the primary and its commit")
+	cmd.PersistentFlags().StringArrayVar(&f.refspecs, "refspec", nil, "Additional refspec to mirror, e.g. 'refs/review/*:refs/review/*' (repeatable)")
+	cmd.PersistentFlags().StringArrayVar(&f.extraValues, "set", nil, "Value exposed to the workflow templates as .Extra.<key>, given as key=value (repeatable)")
+	cmd.PersistentFlags().StringVar(&f.templateDir, "template-dir", "", "Directory of templates overriding the built-in workflow templates of the same name (sync-branch.sh.tmpl, push-mirror.sh.tmpl)")
+	cmd.PersistentFlags().StringVarP(&f.outputFile, "output", "o", ".github/workflows/sync.yaml", "Output file for workflow YAML (writes to stdout if not specified)")
+	cmd.PersistentFlags().BoolVar(&f.setupWorkflow, "setup", false, "Automatically setup the workflow in the GitHub repository")
+	cmd.PersistentFlags().BoolVar(&f.createMissing, "create-missing", false, "Create the GitHub mirror repository during setup if it does not exist")
+	cmd.PersistentFlags().BoolVar(&f.privateMirror, "private", false, "Make the mirror repository private when it is created by --create-missing")
+	cmd.PersistentFlags().BoolVar(&f.readmeBanner, "readme-banner", false, "During setup, maintain a notice in the mirror README pointing at the primary repository")
+	cmd.PersistentFlags().BoolVar(&f.setMetadata, "set-metadata", false, "Set the mirror repository description, homepage and topics during setup")
+	cmd.PersistentFlags().StringVar(&f.description, "description", "", "Mirror repository description (default \"Read-only mirror of <primary>\")")
+	cmd.PersistentFlags().StringVar(&f.homepage, "homepage", "", "Mirror repository homepage (default: web URL of the primary repository)")
+	cmd.PersistentFlags().StringSliceVar(&f.topics, "topics", []string{"mirror", "unofficial-mirror"}, "Mirror repository topics")
+	cmd.PersistentFlags().Int64Var(&f.appID, "app-id", 0, "GitHub App ID to authenticate as instead of using a token (env GH_APP_ID)")
+	cmd.PersistentFlags().Int64Var(&f.appInstallationID, "app-installation-id", 0, "GitHub App installation ID (looked up from the mirror repository if not specified)")
+	cmd.PersistentFlags().StringVar(&f.appPrivateKey, "app-private-key", "", "Path to the GitHub App private key PEM file (env GH_APP_PRIVATE_KEY)")
+	cmd.PersistentFlags().DurationVar(&f.rateLimitWait, "rate-limit-wait", 15*time.Minute, "Maximum time to wait for a GitHub API rate limit to reset before failing (0 disables waiting)")
+	cmd.PersistentFlags().StringVar(&f.cacheDir, "cache-dir", defaultCacheDir(), "Directory of the on-disk GitHub API response cache")
+	cmd.PersistentFlags().StringVar(&f.proxy, "proxy", "", "Proxy for git connections during validation and run, e.g. socks5h://127.0.0.1:4447 (default: HTTPS_PROXY/ALL_PROXY)")
+	cmd.PersistentFlags().StringVar(&f.i2pProxy, "i2p-proxy", "http://127.0.0.1:4444", "HTTP proxy of the local I2P router, used for .i2p primaries unless --proxy is set")
+	cmd.PersistentFlags().StringVar(&f.torProxy, "tor-proxy", "socks5h://127.0.0.1:9050", "SOCKS proxy of the local Tor client, used for .onion primaries unless --proxy is set")
+	cmd.PersistentFlags().StringVar(&f.torProxySecret, "tor-proxy-secret", "", "Secret holding a SOCKS proxy URL the workflow uses for .onion primaries instead of starting Tor")
+	cmd.PersistentFlags().BoolVar(&f.validateSSH, "validate-ssh", false, "Validate SSH repository URLs by listing their refs over SSH instead of only checking the format")
+	cmd.PersistentFlags().StringVar(&f.sshKey, "ssh-key", "", "Private key for SSH repositories during validation with --validate-ssh and run (default: keys from the SSH agent)")
+	cmd.PersistentFlags().StringVar(&f.gitPath, "git-path", "", "Path of the git binary, used by --require-signed and to read and update the remotes of the current repository (default: git found in PATH)")
+	cmd.PersistentFlags().StringVar(&f.caCert, "ca-cert", "", "PEM bundle of the CA certificates trusted besides the system roots for HTTPS git connections to repositories outside GitHub during validation and run")
+	cmd.PersistentFlags().BoolVar(&f.insecureTLS, "insecure-skip-verify", false, "INSECURE: do not verify the TLS certificates of repositories outside GitHub during validation and run, which lets anyone on the network intercept the connections")
+	cmd.PersistentFlags().StringVar(&f.credentialHelper, "credential-helper", "", "Git credential helper for authenticated HTTPS repositories during validation and run, e.g. store, cache or manager; none disables helpers (default: helpers from git config)")
+	cmd.PersistentFlags().StringVar(&f.workDir, "work-dir", defaultWorkDir(), "Directory holding the working copies used by the run subcommand")
+	cmd.PersistentFlags().StringVar(&f.stateDir, "state-dir", defaultStateDir(), "Directory holding the sync state of the run subcommand and the install manifest")
+	cmd.PersistentFlags().StringVar(&f.manifestFile, "manifest", "", "File recording what was set up on each mirror, read by the uninstall subcommand (default: manifest.json in --state-dir)")
+	cmd.PersistentFlags().StringVar(&f.auditLog, "audit-log", "", "JSON lines file recording every commit, secret, repository creation and push made to the mirrors, with the actor and a fingerprint of the credentials")
+	cmd.PersistentFlags().StringVar(&f.eventsFile, "events-file", "", "JSON lines file each step is appended to as an event: validation passed, workflow generated, commit created, push done")
+	cmd.PersistentFlags().StringVar(&f.notifyURL, "notify-url", "", "URL the outcome of each local sync and setup is posted to as JSON")
+	cmd.PersistentFlags().StringVar(&f.configFile, "config", defaultConfigFile(), "YAML file of default flag values, optionally encrypted with age or sops")
+	cmd.PersistentFlags().StringVar(&f.configIdentity, "config-identity", "", "age identity file decrypting an encrypted --config file (env GH_MIRROR_CONFIG_IDENTITY)")
+	cmd.PersistentFlags().BoolVar(&f.noCache, "no-cache", false, "Disable the on-disk GitHub API response cache")
+	cmd.PersistentFlags().DurationVar(&f.timeout, "timeout", 0, "Maximum time a command may run, bounding API calls, validation and syncs (0 for no limit)")
+	cmd.PersistentFlags().IntVar(&f.retries, "retries", 3, "Number of retries for transient GitHub API errors")
+	cmd.PersistentFlags().DurationVar(&f.retryDelay, "retry-delay", time.Second, "Initial delay between retries, doubled after each attempt")
+	cmd.PersistentFlags().BoolVar(&f.updateRemote, "update-remote", false, "Update local git remotes when the mirror repository was renamed or transferred")
+	cmd.PersistentFlags().BoolVar(&f.replaceExisting, "replace-existing", false, "During setup, remove other sync or mirror workflows found on the mirror")
+	cmd.PersistentFlags().BoolVar(&f.configProtection, "configure-protection", false, "During setup, allow the sync app through the mirror branch protection (requires an admin token)")
+	cmd.PersistentFlags().StringVar(&f.bypassApp, "bypass-app", "github-actions", "Slug of the app that pushes to the mirror branch")
+	cmd.PersistentFlags().BoolVar(&f.verifyRun, "verify-run", false, "After setup, dispatch a workflow run and wait for it to succeed")
+	cmd.PersistentFlags().DurationVar(&f.verifyTimeout, "verify-timeout", 10*time.Minute, "Maximum time to wait for the verification run to complete")
+	cmd.PersistentFlags().BoolVarP(&f.assumeYes, "yes", "y", false, "Do not ask for confirmation before generating force-sync workflows or writing to the mirror")
+	cmd.PersistentFlags().BoolVar(&f.dryRun, "dry-run", false, "With setup, print a diff of what would change in the mirror repository without changing it")
+	cmd.PersistentFlags().BoolVar(&f.setupViaPR, "setup-via-pr", false, "Like --setup, but commit the workflow to a branch and open a pull request")
+	cmd.PersistentFlags().CountVarP(&f.verbosity, "verbose", "v", "Increase log verbosity: -v logs progress, -vv debug messages, -vvv every API request and git output")
+	cmd.PersistentFlags().StringVar(&f.logFormat, "log-format", "text", "Log format (text, json); json logs go to stderr so stdout only carries command output")
+	cmd.PersistentFlags().StringVar(&f.colorMode, "color", "auto", "Color log levels (auto, always, never); auto colors on terminals and in GitHub, GitLab, Gitea and Forgejo CI logs unless NO_COLOR is set")
+	cmd.PersistentFlags().StringVar(&f.outputFormat, "output-format", OutputFormatText, "Result format (text, json); json prints a result object with the action taken, the commit created, the repositories validated and warnings")
+
+	// Setting up is a subcommand of its own; the flags keep working for existing scripts
+	cmd.PersistentFlags().MarkDeprecated("setup", "use the setup subcommand")
+	cmd.PersistentFlags().MarkDeprecated("setup-via-pr", "use setup --via-pr")
+}
+
+// EnableSetup makes Load configure the installation of the workflow in the
+// mirror repositories, as the deprecated --setup flag does, optionally
+// through a pull request.
+func (f *Flags) EnableSetup(viaPR bool) {
+	f.setupWorkflow = true
+	f.setupViaPR = f.setupViaPR || viaPR
+}
+
+// LogFormat returns the log format selected with --log-format. It is needed
+// before the configuration is loaded, so that loading errors are logged in it.
+func (f *Flags) LogFormat() string {
+	return f.logFormat
+}
+
+// Color returns the log color mode selected with --color, needed like the
+// log format before the configuration is loaded.
+func (f *Flags) Color() string {
+	return strings.ToLower(f.colorMode)
+}
+
+// Verbosity returns the number of -v flags given, needed like the log format
+// before the configuration is loaded.
+func (f *Flags) Verbosity() int {
+	return f.verbosity
+}
+
+// Timeout returns the maximum run time selected with --timeout, applied to
+// the context of the command before it starts.
+func (f *Flags) Timeout() time.Duration {
+	return f.timeout
+}
+
+// OutputFormat returns the result format selected with --output-format, which
+// also decides where logs go before the configuration is loaded.
+func (f *Flags) OutputFormat() string {
+	return strings.ToLower(f.outputFormat)
+}
+
+// Load parses the flags and environment variables to build the configuration.
+func (f *Flags) Load() (*Config, error) {
+	cfg, err := f.LoadBase()
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate repositories
+	if cfg.PrimaryRepo == "" {
+		return nil, fmt.Errorf("primary repository URL is required")
+	}
+	if cfg.MirrorRepo == "" {
+		return nil, fmt.Errorf("mirror repository URL is required")
+	}
+
+	return cfg, nil
+}
+
+// LoadRepos builds the configuration like Load for the primary and mirror
+// repositories given instead of those of the flags, for commands that read
+// repository pairs from elsewhere. Without a mirror, the mirror is named after
+// the primary in --mirror-org.
+func (f *Flags) LoadRepos(primary, mirror string) (*Config, error) {
+	if mirror == "" {
+		if f.mirrorOrg == "" {
+			return nil, fmt.Errorf("no mirror repository given for %s and no --mirror-org to name one", primary)
+		}
+		var err error
+		if mirror, err = f.orgMirror(primary); err != nil {
+			return nil, err
+		}
+	}
+
+	f.primaryRepo, f.mirrorRepos = primary, []string{mirror}
+	return f.Load()
+}
+
+// LoadBase builds the configuration like Load, but does not require the
+// repository URLs. It is used by commands that fill them in per repository.
+func (f *Flags) LoadBase() (*Config, error) {
+	githubToken := tokenFromEnv()
+	app, err := f.loadAppAuth()
+	if err != nil {
+		return nil, err
+	}
+	// Opening a pull request is a way of setting up the workflow
+	if f.setupViaPR {
+		f.setupWorkflow = true
+	}
+
+	// Local primaries are used through absolute file:// URLs, because git
+	// runs in a working copy elsewhere
+	if f.primaryRepo != "" {
+		var err error
+		if f.primaryRepo, err = localRepoURL(f.primaryRepo); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := f.resolveMirrors(); err != nil {
+		return nil, err
+	}
+
+	// Validate sync interval
+	switch strings.ToLower(f.syncInterval) {
+	case "hourly", "daily", "weekly":
+		// valid
+	default:
+		return nil, fmt.Errorf("invalid sync interval: %s (must be hourly, daily, or weekly)", f.syncInterval)
+	}
+
+	// Validate auth mode; GITHUB_TOKEN and deploy keys only work for GitHub mirrors
+	mode := strings.ToLower(f.authMode)
+	genericMirror, githubMirror := false, len(f.mirrorRepos) == 0
+	for _, mirror := range f.mirrorRepos {
+		if mirror == "" || IsGitHubURL(mirror) {
+			githubMirror = true
+			continue
+		}
+		// The workflow pushes to other hosts with a token over HTTPS
+		if !strings.HasPrefix(mirror, "https://") {
+			return nil, fmt.Errorf("mirror repositories outside GitHub must use an https:// URL: %s", mirror)
+		}
+		genericMirror = true
+	}
+	if mode == "" {
+		mode = AuthModeToken
+		if genericMirror {
+			mode = AuthModePAT
+		}
+	}
+	switch mode {
+	case AuthModeToken, AuthModePAT, AuthModeSSH:
+		// valid
+	default:
+		return nil, fmt.Errorf("invalid auth mode: %s (must be token, pat, or ssh)", mode)
+	}
+	if genericMirror && mode != AuthModePAT {
+		return nil, fmt.Errorf("auth mode %s is only supported for GitHub mirrors, use --auth-mode pat", mode)
+	}
+
+	if githubToken == "" && app.AppID == 0 && f.setupWorkflow && githubMirror {
+		return nil, fmt.Errorf("GitHub token not found in environment (GH_TOKEN or GITHUB_TOKEN) but required for setup")
+	}
+	mirrorToken := os.Getenv("MIRROR_TOKEN")
+	redact.Secret(mirrorToken)
+	if mirrorToken == "" && f.setupWorkflow && genericMirror {
+		return nil, fmt.Errorf("mirror API token not found in environment (MIRROR_TOKEN) but required for setup of mirrors outside GitHub")
+	}
+
+	// Validate workflow format
+	f.workflowFormat = strings.ToLower(f.workflowFormat)
+	switch f.workflowFormat {
+	case FormatGitHub, FormatForgejo:
+		// valid
+	case FormatGitLab:
+		// The pipeline runs on the mirror and pushes to it with a token
+		if githubMirror {
+			return nil, fmt.Errorf("--format gitlab is only supported for mirrors outside GitHub")
+		}
+	default:
+		return nil, fmt.Errorf("invalid workflow format: %s (must be github, forgejo or gitlab)", f.workflowFormat)
+	}
+	secret := f.authSecret
+	if secret == "" {
+		secret = DefaultAuthSecrets[mode]
+	}
+	if secret != "" {
+		if err := ValidateSecretName(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	if f.torProxySecret != "" {
+		if err := ValidateSecretName(f.torProxySecret); err != nil {
+			return nil, err
+		}
+	}
+
+	f.outputFormat = strings.ToLower(f.outputFormat)
+	if f.outputFormat != OutputFormatText && f.outputFormat != OutputFormatJSON {
+		return nil, fmt.Errorf("invalid output format: %s (must be text or json)", f.outputFormat)
+	}
+
+	if f.retries < 0 {
+		return nil, fmt.Errorf("invalid number of retries: %d (must not be negative)", f.retries)
+	}
+
+	// Validate repository topics
+	for _, topic := range f.topics {
+		if !isValidTopic(topic) {
+			return nil, fmt.Errorf("invalid topic: %s (must be lowercase letters, numbers and hyphens, at most 50 characters)", topic)
+		}
+	}
+
+	// Validate additional refspecs
+	parsedRefspecs := make([]Refspec, 0, len(f.refspecs))
+	for _, spec := range f.refspecs {
+		refspec, err := ParseRefspec(spec)
+		if err != nil {
+			return nil, err
+		}
+		parsedRefspecs = append(parsedRefspecs, refspec)
+	}
+
+	// Validate template values
+	extra, err := ParseExtra(f.extraValues)
+	if err != nil {
+		return nil, err
+	}
+
+	// A mirror push replicates every ref as it is on the primary
+	if f.pushMirror && !f.forceSync {
+		return nil, fmt.Errorf("--push-mirror overwrites the mirror and cannot be combined with --force=false")
+	}
+	if f.pushMirror && (f.syncNotes || len(f.refspecs) > 0) {
+		return nil, fmt.Errorf("--push-mirror already replicates all refs, --sync-notes and --refspec are not needed")
+	}
+	if f.pushMirror && f.recordState {
+		return nil, fmt.Errorf("--push-mirror would delete the state ref of --record-state, which is not on the primary")
+	}
+
+	// Validate history filters; additional refs would republish unfiltered history
+	for _, path := range f.filterPaths {
+		if path == "" || strings.HasPrefix(path, "/") || strings.Contains(path, "'") {
+			return nil, fmt.Errorf("invalid filter path: %q (must be relative to the repository root)", path)
+		}
+	}
+	if f.stripBlobs != "" && !isValidSize(f.stripBlobs) {
+		return nil, fmt.Errorf("invalid size: %s (must be a number with an optional K, M or G suffix)", f.stripBlobs)
+	}
+	f.subdirectory = strings.Trim(f.subdirectory, "/")
+	if f.subdirectory != "" {
+		if strings.Contains(f.subdirectory, "'") || strings.Contains("/"+f.subdirectory+"/", "/../") {
+			return nil, fmt.Errorf("invalid subdirectory: %q (must be relative to the repository root)", f.subdirectory)
+		}
+		// Filtered paths would be relative to the subdirectory, and inverting
+		// them would invert the subdirectory selection as well
+		if len(f.filterPaths) > 0 {
+			return nil, fmt.Errorf("--subdirectory cannot be combined with --filter-path")
+		}
+	}
+	if (len(f.filterPaths) > 0 || f.stripBlobs != "" || f.subdirectory != "") && (f.syncNotes || len(f.refspecs) > 0) {
+		return nil, fmt.Errorf("--filter-path, --strip-blobs-bigger-than and --subdirectory cannot be combined with --sync-notes or --refspec")
+	}
+
+	if f.scanSecrets && (f.pushMirror || len(f.refspecs) > 0) {
+		return nil, fmt.Errorf("--scan-secrets only scans the primary branch and cannot be combined with --push-mirror or --refspec")
+	}
+
+	// Read the signers trusted for primary commits
+	var signersData, keysData string
+	if f.requireSigned {
+		if f.allowedSigners == "" && f.signingKeys == "" {
+			return nil, fmt.Errorf("--require-signed needs --allowed-signers or --signing-keys")
+		}
+		if f.pushMirror || len(f.refspecs) > 0 {
+			return nil, fmt.Errorf("--require-signed only verifies the primary branch and cannot be combined with --push-mirror or --refspec")
+		}
+		var err error
+		if signersData, err = readTrustFile(f.allowedSigners); err != nil {
+			return nil, err
+		}
+		if keysData, err = readTrustFile(f.signingKeys); err != nil {
+			return nil, err
+		}
+	}
+
+	// Read the host keys pinned in the workflow
+	var knownHostsData string
+	if f.knownHosts != "" {
+		var err error
+		if knownHostsData, err = readTrustFile(f.knownHosts); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateHostKeys(mode, knownHostsData, f.hostKeys); err != nil {
+		return nil, err
+	}
+
+	// Validate the branches, which are used unquoted in the sync script
+	if !isValidBranch(f.primaryBranch) {
+		return nil, fmt.Errorf("invalid primary branch: %q", f.primaryBranch)
+	}
+	// The mirror branches are pushed in the same run
+	seenBranches := make(map[string]bool)
+	for _, branch := range f.mirrorBranches {
+		if !isValidBranch(branch) {
+			return nil, fmt.Errorf("invalid mirror branch: %q", branch)
+		}
+		if seenBranches[branch] {
+			return nil, fmt.Errorf("mirror branch %s was given more than once", branch)
+		}
+		seenBranches[branch] = true
+	}
+	if f.badgeBranch != "" {
+		if !isValidBranch(f.badgeBranch) {
+			return nil, fmt.Errorf("invalid badge branch: %q", f.badgeBranch)
+		}
+		if seenBranches[f.badgeBranch] {
+			return nil, fmt.Errorf("--badge-branch must differ from the mirror branches, which are overwritten by each sync")
+		}
+		if f.pushMirror {
+			return nil, fmt.Errorf("--push-mirror would delete the branch of --badge-branch, which is not on the primary")
+		}
+	}
+	if len(f.mirrorBranches) > 1 && f.pushMirror {
+		return nil, fmt.Errorf("--push-mirror replicates the primary's branches and cannot push to several --mirror-branch")
+	}
+
+	// Validate the rewrite policy; without --force rewritten history is
+	// merged, and a mirror push has no single branch to track
+	f.rewritePolicy = strings.ToLower(f.rewritePolicy)
+	switch f.rewritePolicy {
+	case RewritePolicyForce:
+		// default
+	case RewritePolicyRefuse, RewritePolicyBackup, RewritePolicyIssue:
+		if !f.forceSync || f.pushMirror {
+			return nil, fmt.Errorf("--rewrite-policy %s requires --force and cannot be combined with --push-mirror", f.rewritePolicy)
+		}
+		// The alert issue is opened with the GitHub token of the workflow
+		if f.rewritePolicy == RewritePolicyIssue && (genericMirror || f.workflowFormat != FormatGitHub) {
+			return nil, fmt.Errorf("--rewrite-policy issue is only supported for GitHub mirrors with --format github")
+		}
+	default:
+		return nil, fmt.Errorf("invalid rewrite policy: %s (must be force, refuse, backup or issue)", f.rewritePolicy)
+	}
+
+	// A missing template directory would silently leave the built-in
+	// templates in place
+	if f.templateDir != "" {
+		if info, err := os.Stat(f.templateDir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("--template-dir %s is not a directory", f.templateDir)
+		}
+	}
+
+	if err := validateNotifyURL(f.notifyURL); err != nil {
+		return nil, err
+	}
+
+	// Validate proxy URLs
+	for _, p := range []string{f.proxy, f.i2pProxy, f.torProxy} {
+		if err := validateProxyURL(p); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateTLS(f.caCert, f.insecureTLS); err != nil {
+		return nil, err
+	}
+
+	// Set the values in the config struct
+	config := Config{
+		GithubToken:         githubToken,
+		AppID:               app.AppID,
+		AppInstallationID:   app.AppInstallationID,
+		AppPrivateKey:       app.AppPrivateKey,
+		RateLimitWait:       f.rateLimitWait,
+		CacheDir:            f.cacheDirectory(),
+		WorkDir:             f.workDir,
+		Proxy:               f.proxy,
+		I2PProxy:            f.i2pProxy,
+		TorProxy:            f.torProxy,
+		TorProxySecret:      f.torProxySecret,
+		ValidateSSH:         f.validateSSH,
+		SSHKey:              f.sshKey,
+		CredentialHelper:    f.credentialHelper,
+		CACert:              f.caCert,
+		InsecureSkipVerify:  f.insecureTLS,
+		GitPath:             f.gitPath,
+		Retries:             f.retries,
+		RetryDelay:          f.retryDelay,
+		PrimaryRepo:         f.primaryRepo,
+		MirrorRepo:          f.firstMirror(),
+		MirrorRepos:         f.mirrorRepos,
+		PrimaryBranch:       f.primaryBranch,
+		MirrorBranch:        f.firstBranch(),
+		SyncInterval:        f.syncInterval,
+		ForceSync:           f.forceSync,
+		PushMirror:          f.pushMirror,
+		CachePrimary:        f.cachePrimary,
+		AuthMode:            mode,
+		MirrorUser:          f.mirrorUser,
+		WorkflowFormat:      f.workflowFormat,
+		MirrorForge:         f.mirrorForge,
+		MirrorToken:         mirrorToken,
+		AuthSecret:          secret,
+		Environment:         f.environment,
+		SyncNotes:           f.syncNotes,
+		RecordState:         f.recordState,
+		CommitStatus:        f.commitStatus,
+		BadgeBranch:         f.badgeBranch,
+		Refspecs:            parsedRefspecs,
+		Extra:               extra,
+		TemplateDir:         f.templateDir,
+		PreSyncHook:         f.preSyncHook,
+		PostSyncHook:        f.postSyncHook,
+		OutputFile:          filepath.FromSlash(f.outputFile),
+		SetupWorkflow:       f.setupWorkflow,
+		SetupViaPR:          f.setupViaPR,
+		DryRun:              f.dryRun,
+		ReplaceExisting:     f.replaceExisting,
+		UpdateRemote:        f.updateRemote,
+		ConfigureProtection: f.configProtection,
+		BypassApp:           f.bypassApp,
+		VerifyRun:           f.verifyRun,
+		VerifyTimeout:       f.verifyTimeout,
+		CreateMissing:       f.createMissing,
+		PrivateMirror:       f.privateMirror,
+		MirrorBranches:      f.mirrorBranches,
+
+		ReadmeBanner:      f.readmeBanner,
+		SetMetadata:       f.setMetadata,
+		MirrorDescription: f.description,
+		MirrorHomepage:    f.homepage,
+		MirrorTopics:      f.topics,
+
+		FilterPaths:          f.filterPaths,
+		StripBlobsBiggerThan: f.stripBlobs,
+		Subdirectory:         f.subdirectory,
+
+		RequireSigned:      f.requireSigned,
+		AllowedSignersFile: f.allowedSigners,
+		AllowedSigners:     signersData,
+		SigningKeysFile:    f.signingKeys,
+		SigningKeys:        keysData,
+
+		ScanSecrets: f.scanSecrets,
+
+		KnownHostsFile:      f.knownHosts,
+		KnownHosts:          knownHostsData,
+		HostKeyFingerprints: f.hostKeys,
+
+		RewritePolicy: f.rewritePolicy,
+
+		OutputFormat: f.outputFormat,
+		AssumeYes:    f.assumeYes,
+
+		StateDir:     f.stateDir,
+		ManifestFile: f.manifestPath(),
+		AuditLog:     f.auditLog,
+		NotifyURL:    f.notifyURL,
+		EventsFile:   f.eventsFile,
+	}
+
+	return &config, nil
+}
+
+// LoadMirror builds a configuration for commands that only operate on the
+// GitHub mirror repository through the API. A GitHub token is required.
+func (f *Flags) LoadMirror() (*Config, error) {
+	githubToken := tokenFromEnv()
+	app, err := f.loadAppAuth()
+	if err != nil {
+		return nil, err
+	}
+	if githubToken == "" && app.AppID == 0 {
+		return nil, fmt.Errorf("GitHub token not found in environment (GH_TOKEN or GITHUB_TOKEN) and no GitHub App configured")
+	}
+	if err := f.resolveMirrors(); err != nil {
+		return nil, err
+	}
+	if len(f.mirrorRepos) == 0 || f.mirrorRepos[0] == "" {
+		return nil, fmt.Errorf("mirror repository URL is required")
+	}
+	if len(f.mirrorRepos) > 1 {
+		return nil, fmt.Errorf("this command operates on a single mirror repository, but --mirror was given %d times", len(f.mirrorRepos))
+	}
+	if !IsGitHubURL(f.mirrorRepos[0]) {
+		return nil, fmt.Errorf("this command requires a GitHub mirror repository")
+	}
+
+	config := Config{
+		GithubToken:       githubToken,
+		AppID:             app.AppID,
+		AppInstallationID: app.AppInstallationID,
+		AppPrivateKey:     app.AppPrivateKey,
+		RateLimitWait:     f.rateLimitWait,
+		CacheDir:          f.cacheDirectory(),
+		Retries:           f.retries,
+		RetryDelay:        f.retryDelay,
+		PrimaryRepo:       f.primaryRepo,
+		MirrorRepo:        f.firstMirror(),
+		MirrorRepos:       f.mirrorRepos,
+		PrimaryBranch:     f.primaryBranch,
+		MirrorBranch:      f.firstBranch(),
+		MirrorBranches:    f.mirrorBranches,
+		AuthMode:          f.authMode,
+		AuthSecret:        f.authSecret,
+		Environment:       f.environment,
+		UpdateRemote:      f.updateRemote,
+		GitPath:           f.gitPath,
+		StateDir:          f.stateDir,
+		ManifestFile:      f.manifestPath(),
+		AuditLog:          f.auditLog,
+		EventsFile:        f.eventsFile,
+	}
+
+	return &config, nil
+}
+
+// loadAppAuth resolves the GitHub App settings from flags and environment variables.
+// The returned Config only has the App fields populated.
+func (f *Flags) loadAppAuth() (Config, error) {
+	app := Config{
+		AppID:             f.appID,
+		AppInstallationID: f.appInstallationID,
+		AppPrivateKey:     f.appPrivateKey,
+	}
+
+	if app.AppID == 0 {
+		if env := os.Getenv("GH_APP_ID"); env != "" {
+			id, err := strconv.ParseInt(env, 10, 64)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid GH_APP_ID: %w", err)
+			}
+			app.AppID = id
+		}
+	}
+	if app.AppPrivateKey == "" {
+		app.AppPrivateKey = os.Getenv("GH_APP_PRIVATE_KEY")
+	}
+	redact.Secret(app.AppPrivateKey)
+
+	if app.AppID != 0 && app.AppPrivateKey == "" {
+		return Config{}, fmt.Errorf("GitHub App private key is required when an app ID is set (--app-private-key or GH_APP_PRIVATE_KEY)")
+	}
+	if app.AppID == 0 && (app.AppInstallationID != 0 || app.AppPrivateKey != "") {
+		return Config{}, fmt.Errorf("GitHub App ID is required when app credentials are provided (--app-id or GH_APP_ID)")
+	}
+
+	return app, nil
+}
+
+// cacheDirectory returns the cache directory to use, or an empty string when
+// caching is disabled.
+func (f *Flags) cacheDirectory() string {
+	if f.noCache {
+		return ""
+	}
+	return f.cacheDir
+}
+
+// manifestPath returns the install manifest file to use. A manifest left in
+// the user config directory by earlier versions is kept in use until one
+// exists in the state directory.
+func (f *Flags) manifestPath() string {
+	if f.manifestFile != "" || f.stateDir == "" {
+		return f.manifestFile
+	}
+
+	path := filepath.Join(f.stateDir, "manifest.json")
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if dir, err := os.UserConfigDir(); err == nil {
+			legacy := filepath.Join(dir, "gh-mirror", "manifest.json")
+			if _, err := os.Stat(legacy); err == nil {
+				return legacy
+			}
+		}
+	}
+	return path
+}
+
+// resolveMirrors fills in the mirror when none was given: the repository of
+// --mirror-org named like the primary repository, or else the GitHub remote
+// of the git repository in the current directory.
+func (f *Flags) resolveMirrors() error {
+	if len(f.mirrorRepos) > 0 {
+		return nil
+	}
+	if f.mirrorOrg == "" {
+		if remote := f.detectGithubRemote(); remote != "" {
+			f.mirrorRepos = []string{remote}
+		}
+		return nil
+	}
+
+	mirror, err := f.orgMirror(f.primaryRepo)
+	if err != nil {
+		return err
+	}
+	f.mirrorRepos = []string{mirror}
+	return nil
+}
+
+// orgMirror returns the URL of the repository of --mirror-org named like
+// the primary repository.
+func (f *Flags) orgMirror(primary string) (string, error) {
+	if strings.ContainsAny(f.mirrorOrg, "/: ") {
+		return "", fmt.Errorf("invalid mirror organization: %s (must be a GitHub organization or user name)", f.mirrorOrg)
+	}
+	if primary == "" {
+		return "", fmt.Errorf("--mirror-org requires the primary repository URL to name the mirror after")
+	}
+
+	name := RepoName(primary)
+	if name == "" {
+		return "", fmt.Errorf("cannot determine the repository name of %s for --mirror-org", primary)
+	}
+	return "https://github.com/" + f.mirrorOrg + "/" + name, nil
+}
+
+// firstMirror returns the first mirror repository URL, or an empty string.
+func (f *Flags) firstMirror() string {
+	if len(f.mirrorRepos) == 0 {
+		return ""
+	}
+	return f.mirrorRepos[0]
+}
+
+// firstBranch returns the first mirror branch name, or an empty string.
+func (f *Flags) firstBranch() string {
+	if len(f.mirrorBranches) == 0 {
+		return ""
+	}
+	return f.mirrorBranches[0]
+}
+
+// detectGithubRemote attempts to detect a GitHub remote URL from the current
+// git repository. Nothing is detected outside a git repository or without git.
+func (f *Flags) detectGithubRemote() string {
+	binary, err := LookGit(f.gitPath)
+	if err != nil {
+		return ""
+	}
+
+	// Execute git remote -v command
+	cmd := exec.Command(binary, "remote", "-v")
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	// Parse the output to find GitHub remotes
+	lines := strings.Split(string(output), "\n")
+	for _, line := range lines {
+		if strings.Contains(line, "github.com") && strings.Contains(line, "(push)") {
+			// Extract the GitHub repository URL
+			parts := strings.Fields(line)
+			if len(parts) >= 2 {
+				url := parts[1]
+				// Convert SSH URL to HTTPS URL if needed
+				if strings.HasPrefix(url, "git@github.com:") {
+					url = strings.Replace(url, "git@github.com:", "https://github.com/", 1)
+				}
+				// Remove .git suffix if present
+				url = strings.TrimSuffix(url, ".git")
+				return url
+			}
+		}
+	}
+
+	return ""
+}
+
+// defaultCacheDir returns the default location of the API response cache.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gh-mirror", "http")
+}
+
+// defaultWorkDir returns the default location of the local sync working copies.
+func defaultWorkDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gh-mirror", "repos")
+}
+
+// defaultStateDir returns the default location of the sync state and the
+// install manifest: XDG_STATE_HOME when set, the local application data
+// directory on Windows, the user config directory on macOS and
+// ~/.local/state elsewhere.
+func defaultStateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "gh-mirror")
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		if dir := os.Getenv("LocalAppData"); dir != "" {
+			return filepath.Join(dir, "gh-mirror", "state")
+		}
+	case "darwin", "ios":
+		if dir, err := os.UserConfigDir(); err == nil {
+			return filepath.Join(dir, "gh-mirror", "state")
+		}
+	default:
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, ".local", "state", "gh-mirror")
+		}
+	}
+	return ""
+}
+
+// defaultConfigFile returns the default location of the config file, in the
+// user config directory, which follows XDG_CONFIG_HOME.
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gh-mirror", "config.yaml")
+}
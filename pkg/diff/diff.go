@@ -0,0 +1,206 @@
+// Package diff produces unified diffs between two versions of a text file
+// and merges the changes two versions made to a common one.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change.
+const contextLines = 3
+
+// op identifies the kind of a single line in an edit script.
+type op int
+
+const (
+	opEqual op = iota
+	opDelete
+	opInsert
+)
+
+// edit is a single line of an edit script.
+type edit struct {
+	op   op
+	text string
+	// aLine and bLine are the zero-based line numbers in the old and new text.
+	aLine int
+	bLine int
+}
+
+// Unified returns a unified diff transforming oldText into newText.
+// An empty string is returned when both texts are identical.
+func Unified(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+
+	a := splitLines(oldText)
+	b := splitLines(newText)
+	edits := computeEdits(a, b)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n", oldName)
+	fmt.Fprintf(&sb, "+++ %s\n", newName)
+
+	for _, h := range groupHunks(edits) {
+		writeHunk(&sb, h)
+	}
+
+	return sb.String()
+}
+
+// ANSI escape sequences used by Colorize.
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// Colorize highlights a unified diff produced by Unified for display in a terminal.
+func Colorize(unified string) string {
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(unified, "\n") {
+		if line == "" {
+			continue
+		}
+		text := strings.TrimSuffix(line, "\n")
+		color := ""
+		switch {
+		case strings.HasPrefix(text, "--- "), strings.HasPrefix(text, "+++ "):
+			color = colorBold
+		case strings.HasPrefix(text, "@@"):
+			color = colorCyan
+		case strings.HasPrefix(text, "-"):
+			color = colorRed
+		case strings.HasPrefix(text, "+"):
+			color = colorGreen
+		}
+		if color == "" {
+			sb.WriteString(line)
+			continue
+		}
+		sb.WriteString(color + text + colorReset + line[len(text):])
+	}
+	return sb.String()
+}
+
+// splitLines splits text into lines with their trailing newlines, so that a
+// missing newline at the end is a change of the last line.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// computeEdits builds a line-based edit script using a longest common subsequence table.
+func computeEdits(a, b []string) []edit {
+	// lcs[i][j] holds the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{op: opEqual, text: a[i], aLine: i, bLine: j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			// Deletions come before insertions, as in diff(1)
+			edits = append(edits, edit{op: opDelete, text: a[i], aLine: i, bLine: j})
+			i++
+		default:
+			edits = append(edits, edit{op: opInsert, text: b[j], aLine: i, bLine: j})
+			j++
+		}
+	}
+	return edits
+}
+
+// groupHunks splits an edit script into hunks with surrounding context.
+func groupHunks(edits []edit) [][]edit {
+	var hunks [][]edit
+	start, end := -1, -1
+
+	for idx, e := range edits {
+		if e.op == opEqual {
+			continue
+		}
+		lo := max(idx-contextLines, 0)
+		hi := min(idx+contextLines+1, len(edits))
+		if start >= 0 && lo <= end {
+			end = hi
+			continue
+		}
+		if start >= 0 {
+			hunks = append(hunks, edits[start:end])
+		}
+		start, end = lo, hi
+	}
+	if start >= 0 {
+		hunks = append(hunks, edits[start:end])
+	}
+
+	return hunks
+}
+
+// writeHunk writes a single hunk, including its range header.
+func writeHunk(sb *strings.Builder, hunk []edit) {
+	aCount, bCount := 0, 0
+	for _, e := range hunk {
+		if e.op != opInsert {
+			aCount++
+		}
+		if e.op != opDelete {
+			bCount++
+		}
+	}
+
+	aStart, bStart := hunk[0].aLine+1, hunk[0].bLine+1
+	if aCount == 0 {
+		aStart--
+	}
+	if bCount == 0 {
+		bStart--
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+	for _, e := range hunk {
+		switch e.op {
+		case opEqual:
+			sb.WriteString(" ")
+		case opDelete:
+			sb.WriteString("-")
+		case opInsert:
+			sb.WriteString("+")
+		}
+		text, ok := strings.CutSuffix(e.text, "\n")
+		sb.WriteString(text)
+		sb.WriteString("\n")
+		if !ok {
+			sb.WriteString("\\ No newline at end of file\n")
+		}
+	}
+}
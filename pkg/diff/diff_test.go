@@ -0,0 +1,74 @@
+package diff_test
+
+import (
+	"testing"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/diff"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldText string
+		newText string
+		want    string
+	}{
+		{
+			name:    "identical",
+			oldText: "a\nb\n",
+			newText: "a\nb\n",
+			want:    "",
+		},
+		{
+			name:    "changed line",
+			oldText: "a\nb\nc\n",
+			newText: "a\nB\nc\n",
+			want:    "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:    "created",
+			oldText: "",
+			newText: "a\nb\n",
+			want:    "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:    "emptied",
+			oldText: "a\n",
+			newText: "",
+			want:    "--- old\n+++ new\n@@ -1,1 +0,0 @@\n-a\n",
+		},
+		{
+			name:    "separate hunks",
+			oldText: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			newText: "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			want: "--- old\n+++ new\n" +
+				"@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n" +
+				"@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+		{
+			name:    "newline removed",
+			oldText: "a\nb\n",
+			newText: "a\nb",
+			want:    "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			name:    "newline added",
+			oldText: "a\nb",
+			newText: "a\nb\n",
+			want:    "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name:    "line added after a last line without newline",
+			oldText: "a",
+			newText: "a\nb",
+			want:    "--- old\n+++ new\n@@ -1,1 +1,2 @@\n-a\n\\ No newline at end of file\n+a\n+b\n\\ No newline at end of file\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diff.Unified("old", "new", tt.oldText, tt.newText); got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package diff
+
+import "strings"
+
+// Merge merges the changes ours and theirs made to base line by line, like
+// git merge-file. Changes to different lines are combined; ok is false when
+// both sides changed the same lines differently, and merged is then empty.
+func Merge(base, ours, theirs string) (merged string, ok bool) {
+	b := splitLines(base)
+	o := splitLines(ours)
+	t := splitLines(theirs)
+	inOurs := matches(b, o)
+	inTheirs := matches(b, t)
+
+	var sb strings.Builder
+	i, j, k := 0, 0, 0
+	for {
+		// Find the next base line both sides kept
+		next := i
+		for next < len(b) && (inOurs[next] < 0 || inTheirs[next] < 0) {
+			next++
+		}
+		oEnd, tEnd := len(o), len(t)
+		if next < len(b) {
+			oEnd, tEnd = inOurs[next], inTheirs[next]
+		}
+
+		baseChunk := strings.Join(b[i:next], "")
+		oursChunk := strings.Join(o[j:oEnd], "")
+		theirsChunk := strings.Join(t[k:tEnd], "")
+		switch {
+		case oursChunk == baseChunk:
+			sb.WriteString(theirsChunk)
+		case theirsChunk == baseChunk, theirsChunk == oursChunk:
+			sb.WriteString(oursChunk)
+		default:
+			return "", false
+		}
+
+		if next == len(b) {
+			return sb.String(), true
+		}
+		sb.WriteString(b[next])
+		i, j, k = next+1, oEnd+1, tEnd+1
+	}
+}
+
+// matches returns, for each line of a, the index of the line of b it is
+// kept as by the edit script from a to b, or -1 when it is deleted.
+func matches(a, b []string) []int {
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+	for _, e := range computeEdits(a, b) {
+		if e.op == opEqual {
+			match[e.aLine] = e.bLine
+		}
+	}
+	return match
+}
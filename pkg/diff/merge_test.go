@@ -0,0 +1,39 @@
+package diff_test
+
+import (
+	"testing"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/diff"
+)
+
+func TestMerge(t *testing.T) {
+	const base = "a\nb\nc\nd\ne\n"
+	tests := []struct {
+		name   string
+		ours   string
+		theirs string
+		want   string
+		ok     bool
+	}{
+		{"unchanged", base, base, base, true},
+		{"changed by ours", "a\nB\nc\nd\ne\n", base, "a\nB\nc\nd\ne\n", true},
+		{"changed by theirs", base, "a\nb\nc\nD\ne\n", "a\nb\nc\nD\ne\n", true},
+		{"different lines", "A\nb\nc\nd\ne\n", "a\nb\nc\nd\nE\n", "A\nb\nc\nd\nE\n", true},
+		{"same change", "a\nB\nc\nd\ne\n", "a\nB\nc\nd\ne\n", "a\nB\nc\nd\ne\n", true},
+		{"insertions and deletion", "0\na\nb\nc\nd\ne\n", "a\nb\nd\ne\nf\n", "0\na\nb\nd\ne\nf\n", true},
+		{"everything deleted by ours", "", base, "", true},
+		{"newline removed by theirs", "A\nb\nc\nd\ne\n", "a\nb\nc\nd\ne", "A\nb\nc\nd\ne", true},
+		{"same line", "a\nB\nc\nd\ne\n", "a\nX\nc\nd\ne\n", "", false},
+		{"insertions at the same place", "a\nb\nx\nc\nd\ne\n", "a\nb\ny\nc\nd\ne\n", "", false},
+		{"changed by ours, deleted by theirs", "a\nB\nc\nd\ne\n", "a\nc\nd\ne\n", "", false},
+		{"newline removed by theirs, last line changed by ours", "a\nb\nc\nd\nE\n", "a\nb\nc\nd\ne", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := diff.Merge(base, tt.ours, tt.theirs)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Merge() = %q, %v; want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
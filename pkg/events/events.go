@@ -0,0 +1,74 @@
+// Package events writes a stream of the significant steps of a gh-mirror
+// run as JSON lines, so pipelines can follow and audit the run step by step.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Types of events.
+const (
+	TypeValidated         = "validation-passed"
+	TypeWorkflowGenerated = "workflow-generated"
+	TypeWorkflowWritten   = "workflow-written"
+	TypeCommitCreated     = "commit-created"
+	TypePullRequestOpened = "pull-request-opened"
+	TypePushed            = "push-done"
+)
+
+// Event is one line of the events file.
+type Event struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"event"`
+
+	// Repo is the mirror repository and Primary its primary
+	Repo    string `json:"repo,omitempty"`
+	Primary string `json:"primary,omitempty"`
+
+	// Path is the file written or the files committed, Ref the branch or
+	// refs committed or pushed to and Commit the commit created
+	Path   string `json:"path,omitempty"`
+	Ref    string `json:"ref,omitempty"`
+	Commit string `json:"commit,omitempty"`
+
+	// URL is the web address of an opened pull request
+	URL string `json:"url,omitempty"`
+}
+
+// mu serializes the writes of one process, so concurrent syncs do not
+// interleave their lines.
+var mu sync.Mutex
+
+// Emit appends event to the events file at path, filling in the time.
+// Nothing is written when path is empty.
+func Emit(path string, event Event) error {
+	if path == "" {
+		return nil
+	}
+
+	event.Time = time.Now().UTC()
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create events file directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open events file: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write events file: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,306 @@
+// Package forge provides clients for the APIs of non-GitHub Git forges.
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+)
+
+// Supported forge kinds.
+const (
+	KindGitea  = "gitea"
+	KindGitLab = "gitlab"
+)
+
+// Webhook describes a push webhook to register on a forge.
+type Webhook struct {
+	// URL receives the webhook requests
+	URL string
+	// Branch limits the webhook to pushes to this branch, if set
+	Branch string
+	// Authorization is sent as the Authorization header of webhook requests, if set
+	Authorization string
+}
+
+// Forge is implemented by the supported forge API clients.
+type Forge interface {
+	// Kind returns the forge kind, e.g. KindGitea.
+	Kind() string
+	// EnsureWebhook registers hook on the repository unless a webhook with the
+	// same URL exists. It reports whether a webhook was created.
+	EnsureWebhook(ctx context.Context, hook Webhook) (bool, error)
+	// RemoveWebhook deletes the webhooks with the given URL. It reports
+	// whether one was deleted.
+	RemoveWebhook(ctx context.Context, hookURL string) (bool, error)
+}
+
+// client holds what all forge API clients share.
+type client struct {
+	baseURL    string
+	repoPath   string
+	authHeader string
+	authValue  string
+	httpClient *http.Client
+	log        *logger.Logger
+}
+
+// New creates a client for the forge hosting repoURL. When kind is empty the
+// forge kind is detected by probing the host's API.
+func New(ctx context.Context, kind, repoURL, token string, log *logger.Logger) (Forge, error) {
+	baseURL, repoPath, err := SplitRepoURL(repoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	c := client{
+		baseURL:    baseURL,
+		repoPath:   repoPath,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		log:        log,
+	}
+
+	if kind == "" {
+		kind, err = c.detect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		log.Debug("Detected forge", "kind", kind, "url", baseURL)
+	}
+
+	switch kind {
+	case KindGitea:
+		c.authHeader, c.authValue = "Authorization", "token "+token
+		return &Gitea{client: c}, nil
+	case KindGitLab:
+		c.authHeader, c.authValue = "PRIVATE-TOKEN", token
+		return &GitLab{client: c}, nil
+	default:
+		return nil, fmt.Errorf("unsupported forge kind: %s (must be gitea or gitlab)", kind)
+	}
+}
+
+// SplitRepoURL splits a clone URL into the forge's web base URL and the
+// repository path, e.g. https://host and org/repo.
+func SplitRepoURL(repoURL string) (string, string, error) {
+	var host, repoPath, scheme string
+
+	switch {
+	case strings.HasPrefix(repoURL, "git@"):
+		// git@host:path
+		rest := strings.TrimPrefix(repoURL, "git@")
+		host, repoPath, _ = strings.Cut(rest, ":")
+		scheme = "https"
+	default:
+		parsed, err := url.Parse(repoURL)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid repository URL: %w", err)
+		}
+		host, repoPath, scheme = parsed.Host, parsed.Path, parsed.Scheme
+		if scheme == "ssh" {
+			host, scheme = parsed.Hostname(), "https"
+		}
+	}
+
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	if host == "" || !strings.Contains(repoPath, "/") {
+		return "", "", fmt.Errorf("invalid repository URL: %s", repoURL)
+	}
+
+	return scheme + "://" + host, repoPath, nil
+}
+
+// detect determines the forge kind from the version endpoints of its API.
+func (c *client) detect(ctx context.Context) (string, error) {
+	probes := []struct {
+		kind string
+		path string
+	}{
+		{KindGitea, "/api/v1/version"},
+		{KindGitLab, "/api/v4/version"},
+	}
+
+	for _, probe := range probes {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+probe.path, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("failed to reach forge API: %w", err)
+		}
+		resp.Body.Close()
+
+		// GitLab answers its version endpoint with 401 when unauthenticated
+		if resp.StatusCode == http.StatusOK || probe.kind == KindGitLab && resp.StatusCode == http.StatusUnauthorized {
+			return probe.kind, nil
+		}
+	}
+
+	return "", fmt.Errorf("could not detect forge kind of %s, specify it explicitly", c.baseURL)
+}
+
+// do sends an authenticated JSON API request and decodes the response into out.
+func (c *client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set(c.authHeader, c.authValue)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &APIError{Method: method, Path: path, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response of %s %s: %w", method, path, err)
+		}
+	}
+	return nil
+}
+
+// APIError is returned when a forge API request fails with an error status.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s returned status %d: %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// Is reports whether the request failed with the kind of failure target,
+// one of the kinds of package config: status 401 and 403 are
+// config.ErrAuthFailed, 429 is config.ErrRateLimited.
+func (e *APIError) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return target == config.ErrAuthFailed
+	case http.StatusTooManyRequests:
+		return target == config.ErrRateLimited
+	}
+	return false
+}
+
+// Repository describes a mirror repository to create on a forge.
+type Repository struct {
+	Description string
+	Private     bool
+}
+
+// Target is implemented by the forge API clients that can set up a mirror
+// repository on their forge.
+type Target interface {
+	Forge
+	// RepositoryExists reports whether the repository exists.
+	RepositoryExists(ctx context.Context) (bool, error)
+	// CreateRepository creates the repository, in the organization of its
+	// path unless that is the authenticated user.
+	CreateRepository(ctx context.Context, repo Repository) error
+	// SetDescription sets the repository description.
+	SetDescription(ctx context.Context, description string) error
+	// PutFile commits content to path on the default branch unless the file
+	// already has that content, apart from the provenance recorded in it (see
+	// provenance.Equal). It reports whether a commit was made.
+	PutFile(ctx context.Context, path, content, message string) (bool, error)
+	// DeleteFile deletes path from the default branch if it exists. It
+	// reports whether a commit was made.
+	DeleteFile(ctx context.Context, path, message string) (bool, error)
+}
+
+// Schedule describes a recurring run of the mirror's CI pipeline.
+type Schedule struct {
+	// Description identifies the schedule among others of the repository
+	Description string
+	// Cron is the schedule in cron syntax
+	Cron string
+}
+
+// Scheduler is implemented by the Target clients of forges whose pipelines
+// are scheduled through the API instead of in the pipeline file.
+type Scheduler interface {
+	// EnsureSchedule creates or updates the schedule with the same
+	// description. It reports whether a change was made.
+	EnsureSchedule(ctx context.Context, schedule Schedule) (bool, error)
+	// RemoveSchedule deletes the schedules with the given description. It
+	// reports whether one was deleted.
+	RemoveSchedule(ctx context.Context, description string) (bool, error)
+}
+
+// CommitStatus describes a successful status reported on a commit.
+type CommitStatus struct {
+	// Context identifies the status among others of the commit
+	Context     string
+	Description string
+	// TargetURL links the status to its details, if not empty
+	TargetURL string
+}
+
+// StatusSetter is implemented by the Target clients of forges that show
+// statuses on commits.
+type StatusSetter interface {
+	// EnsureCommitStatus sets status on the commit sha unless the commit
+	// has a status with the same context. It reports whether a status was
+	// created.
+	EnsureCommitStatus(ctx context.Context, sha string, status CommitStatus) (bool, error)
+}
+
+// NewTarget creates a client for the forge hosting the mirror repoURL, like
+// New, and fails when mirror setup is not supported for the forge.
+func NewTarget(ctx context.Context, kind, repoURL, token string, log *logger.Logger) (Target, error) {
+	f, err := New(ctx, kind, repoURL, token, log)
+	if err != nil {
+		return nil, err
+	}
+	target, ok := f.(Target)
+	if !ok {
+		return nil, fmt.Errorf("mirror setup is not supported on %s", f.Kind())
+	}
+	return target, nil
+}
+
+// splitRepoPath splits a repository path into its namespace and name.
+func splitRepoPath(repoPath string) (string, string) {
+	i := strings.LastIndex(repoPath, "/")
+	return repoPath[:i], repoPath[i+1:]
+}
+
+// isNotFound reports whether err is an API error with status 404.
+func isNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
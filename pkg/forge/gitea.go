@@ -0,0 +1,239 @@
+package forge
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/provenance"
+)
+
+// Gitea is a client for the Gitea and Forgejo API. It implements Target and
+// StatusSetter.
+type Gitea struct {
+	client
+}
+
+// giteaHook is a webhook as represented by the Gitea API.
+type giteaHook struct {
+	ID                  int64             `json:"id,omitempty"`
+	Type                string            `json:"type"`
+	Config              map[string]string `json:"config"`
+	Events              []string          `json:"events"`
+	BranchFilter        string            `json:"branch_filter,omitempty"`
+	AuthorizationHeader string            `json:"authorization_header,omitempty"`
+	Active              bool              `json:"active"`
+}
+
+// Kind implements Forge.
+func (g *Gitea) Kind() string {
+	return KindGitea
+}
+
+// EnsureWebhook implements Forge.
+func (g *Gitea) EnsureWebhook(ctx context.Context, hook Webhook) (bool, error) {
+	hooksPath := "/api/v1/repos/" + g.repoPath + "/hooks"
+
+	var existing []giteaHook
+	if err := g.do(ctx, http.MethodGet, hooksPath, nil, &existing); err != nil {
+		return false, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	for _, h := range existing {
+		if h.Config["url"] == hook.URL {
+			g.log.Debug("Webhook already registered", "id", h.ID, "url", hook.URL)
+			return false, nil
+		}
+	}
+
+	create := giteaHook{
+		Type: "gitea",
+		Config: map[string]string{
+			"url":          hook.URL,
+			"content_type": "json",
+		},
+		Events:              []string{"push"},
+		BranchFilter:        hook.Branch,
+		AuthorizationHeader: hook.Authorization,
+		Active:              true,
+	}
+	if err := g.do(ctx, http.MethodPost, hooksPath, create, nil); err != nil {
+		return false, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	g.log.Info("Registered webhook on primary repository", "forge", KindGitea, "repo", g.repoPath, "url", hook.URL)
+	return true, nil
+}
+
+// RemoveWebhook implements Forge.
+func (g *Gitea) RemoveWebhook(ctx context.Context, hookURL string) (bool, error) {
+	hooksPath := "/api/v1/repos/" + g.repoPath + "/hooks"
+
+	var existing []giteaHook
+	if err := g.do(ctx, http.MethodGet, hooksPath, nil, &existing); err != nil {
+		return false, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	removed := false
+	for _, h := range existing {
+		if h.Config["url"] != hookURL {
+			continue
+		}
+		if err := g.do(ctx, http.MethodDelete, fmt.Sprintf("%s/%d", hooksPath, h.ID), nil, nil); err != nil {
+			return removed, fmt.Errorf("failed to delete webhook: %w", err)
+		}
+		g.log.Info("Deleted webhook on primary repository", "forge", KindGitea, "repo", g.repoPath, "url", hookURL)
+		removed = true
+	}
+	return removed, nil
+}
+
+// giteaRepo is a repository as represented by the Gitea API.
+type giteaRepo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+}
+
+// giteaContent is a file as represented by the Gitea contents API.
+type giteaContent struct {
+	SHA     string `json:"sha,omitempty"`
+	Content string `json:"content"`
+	Message string `json:"message,omitempty"`
+}
+
+// repoAPIPath returns the API path of the repository.
+func (g *Gitea) repoAPIPath() string {
+	return "/api/v1/repos/" + g.repoPath
+}
+
+// RepositoryExists implements Target.
+func (g *Gitea) RepositoryExists(ctx context.Context) (bool, error) {
+	err := g.do(ctx, http.MethodGet, g.repoAPIPath(), nil, nil)
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up repository: %w", err)
+	}
+	return true, nil
+}
+
+// CreateRepository implements Target.
+func (g *Gitea) CreateRepository(ctx context.Context, repo Repository) error {
+	owner, name := splitRepoPath(g.repoPath)
+
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := g.do(ctx, http.MethodGet, "/api/v1/user", nil, &user); err != nil {
+		return fmt.Errorf("failed to look up authenticated user: %w", err)
+	}
+
+	createPath := "/api/v1/orgs/" + owner + "/repos"
+	if strings.EqualFold(user.Login, owner) {
+		createPath = "/api/v1/user/repos"
+	}
+	create := giteaRepo{Name: name, Description: repo.Description, Private: repo.Private}
+	if err := g.do(ctx, http.MethodPost, createPath, create, nil); err != nil {
+		return fmt.Errorf("failed to create repository: %w", err)
+	}
+
+	g.log.Info("Created mirror repository", "forge", KindGitea, "repo", g.repoPath, "private", repo.Private)
+	return nil
+}
+
+// SetDescription implements Target.
+func (g *Gitea) SetDescription(ctx context.Context, description string) error {
+	body := map[string]string{"description": description}
+	if err := g.do(ctx, http.MethodPatch, g.repoAPIPath(), body, nil); err != nil {
+		return fmt.Errorf("failed to set repository description: %w", err)
+	}
+	return nil
+}
+
+// PutFile implements Target.
+func (g *Gitea) PutFile(ctx context.Context, path, content, message string) (bool, error) {
+	contentPath := g.repoAPIPath() + "/contents/" + path
+
+	var existing giteaContent
+	err := g.do(ctx, http.MethodGet, contentPath, nil, &existing)
+	found := err == nil
+	if err != nil && !isNotFound(err) {
+		return false, fmt.Errorf("failed to check for existing %s: %w", path, err)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(content))
+	if found {
+		// The API wraps base64 content, so compare decoded content
+		current, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(existing.Content, "\n", ""))
+		if err == nil && provenance.Equal(string(current), content) {
+			g.log.Debug("File is up to date", "path", path, "sha", existing.SHA)
+			return false, nil
+		}
+	}
+
+	update := giteaContent{Content: encoded, Message: message}
+	method := http.MethodPost
+	if found {
+		update.SHA, method = existing.SHA, http.MethodPut
+	}
+	if err := g.do(ctx, method, contentPath, update, nil); err != nil {
+		return false, fmt.Errorf("failed to create/update %s: %w", path, err)
+	}
+
+	return true, nil
+}
+
+// DeleteFile implements Target.
+func (g *Gitea) DeleteFile(ctx context.Context, path, message string) (bool, error) {
+	contentPath := g.repoAPIPath() + "/contents/" + path
+
+	var existing giteaContent
+	err := g.do(ctx, http.MethodGet, contentPath, nil, &existing)
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check for existing %s: %w", path, err)
+	}
+
+	body := map[string]string{"sha": existing.SHA, "message": message}
+	if err := g.do(ctx, http.MethodDelete, contentPath, body, nil); err != nil {
+		return false, fmt.Errorf("failed to delete %s: %w", path, err)
+	}
+
+	return true, nil
+}
+
+// giteaStatus is a commit status as represented by the Gitea API, which
+// reports the state as status.
+type giteaStatus struct {
+	State       string `json:"state,omitempty"`
+	Status      string `json:"status,omitempty"`
+	Context     string `json:"context"`
+	Description string `json:"description"`
+	TargetURL   string `json:"target_url,omitempty"`
+}
+
+// EnsureCommitStatus implements StatusSetter.
+func (g *Gitea) EnsureCommitStatus(ctx context.Context, sha string, status CommitStatus) (bool, error) {
+	var existing []giteaStatus
+	if err := g.do(ctx, http.MethodGet, g.repoAPIPath()+"/commits/"+sha+"/statuses", nil, &existing); err != nil {
+		return false, fmt.Errorf("failed to list commit statuses: %w", err)
+	}
+	for _, s := range existing {
+		if s.Context == status.Context {
+			g.log.Debug("Commit status already set", "sha", sha, "description", s.Description)
+			return false, nil
+		}
+	}
+
+	create := giteaStatus{State: "success", Context: status.Context, Description: status.Description, TargetURL: status.TargetURL}
+	if err := g.do(ctx, http.MethodPost, g.repoAPIPath()+"/statuses/"+sha, create, nil); err != nil {
+		return false, fmt.Errorf("failed to create commit status: %w", err)
+	}
+	g.log.Info("Set commit status", "forge", KindGitea, "repo", g.repoPath, "sha", sha)
+	return true, nil
+}
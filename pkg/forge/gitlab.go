@@ -0,0 +1,345 @@
+package forge
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/provenance"
+)
+
+// GitLab is a client for the GitLab API. It implements Target, Scheduler and
+// StatusSetter.
+type GitLab struct {
+	client
+}
+
+// gitlabHeader is a custom header sent with GitLab webhook requests.
+type gitlabHeader struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// gitlabHook is a project webhook as represented by the GitLab API.
+type gitlabHook struct {
+	ID                     int64          `json:"id,omitempty"`
+	URL                    string         `json:"url"`
+	PushEvents             bool           `json:"push_events"`
+	PushEventsBranchFilter string         `json:"push_events_branch_filter,omitempty"`
+	EnableSSLVerification  bool           `json:"enable_ssl_verification"`
+	CustomHeaders          []gitlabHeader `json:"custom_headers,omitempty"`
+}
+
+// Kind implements Forge.
+func (g *GitLab) Kind() string {
+	return KindGitLab
+}
+
+// projectPath returns the API path of the project.
+func (g *GitLab) projectPath() string {
+	return "/api/v4/projects/" + url.PathEscape(g.repoPath)
+}
+
+// EnsureWebhook implements Forge.
+func (g *GitLab) EnsureWebhook(ctx context.Context, hook Webhook) (bool, error) {
+	hooksPath := g.projectPath() + "/hooks"
+
+	var existing []gitlabHook
+	if err := g.do(ctx, http.MethodGet, hooksPath, nil, &existing); err != nil {
+		return false, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	for _, h := range existing {
+		if h.URL == hook.URL {
+			g.log.Debug("Webhook already registered", "id", h.ID, "url", hook.URL)
+			return false, nil
+		}
+	}
+
+	create := gitlabHook{
+		URL:                    hook.URL,
+		PushEvents:             true,
+		PushEventsBranchFilter: hook.Branch,
+		EnableSSLVerification:  true,
+	}
+	if hook.Authorization != "" {
+		create.CustomHeaders = []gitlabHeader{{Key: "Authorization", Value: hook.Authorization}}
+	}
+	if err := g.do(ctx, http.MethodPost, hooksPath, create, nil); err != nil {
+		return false, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	g.log.Info("Registered webhook on primary repository", "forge", KindGitLab, "repo", g.repoPath, "url", hook.URL)
+	return true, nil
+}
+
+// RemoveWebhook implements Forge.
+func (g *GitLab) RemoveWebhook(ctx context.Context, hookURL string) (bool, error) {
+	hooksPath := g.projectPath() + "/hooks"
+
+	var existing []gitlabHook
+	if err := g.do(ctx, http.MethodGet, hooksPath, nil, &existing); err != nil {
+		return false, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	removed := false
+	for _, h := range existing {
+		if h.URL != hookURL {
+			continue
+		}
+		if err := g.do(ctx, http.MethodDelete, fmt.Sprintf("%s/%d", hooksPath, h.ID), nil, nil); err != nil {
+			return removed, fmt.Errorf("failed to delete webhook: %w", err)
+		}
+		g.log.Info("Deleted webhook on primary repository", "forge", KindGitLab, "repo", g.repoPath, "url", hookURL)
+		removed = true
+	}
+	return removed, nil
+}
+
+// gitlabProject is a project as represented by the GitLab API.
+type gitlabProject struct {
+	Name          string `json:"name,omitempty"`
+	Path          string `json:"path,omitempty"`
+	NamespaceID   int64  `json:"namespace_id,omitempty"`
+	Description   string `json:"description,omitempty"`
+	Visibility    string `json:"visibility,omitempty"`
+	DefaultBranch string `json:"default_branch,omitempty"`
+}
+
+// gitlabFile is a repository file as represented by the GitLab API.
+type gitlabFile struct {
+	Branch        string `json:"branch,omitempty"`
+	Content       string `json:"content"`
+	Encoding      string `json:"encoding,omitempty"`
+	CommitMessage string `json:"commit_message,omitempty"`
+}
+
+// gitlabSchedule is a pipeline schedule as represented by the GitLab API.
+type gitlabSchedule struct {
+	ID          int64  `json:"id,omitempty"`
+	Description string `json:"description"`
+	Ref         string `json:"ref"`
+	Cron        string `json:"cron"`
+	Active      bool   `json:"active"`
+}
+
+// RepositoryExists implements Target.
+func (g *GitLab) RepositoryExists(ctx context.Context) (bool, error) {
+	err := g.do(ctx, http.MethodGet, g.projectPath(), nil, nil)
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up project: %w", err)
+	}
+	return true, nil
+}
+
+// CreateRepository implements Target. The project is created in the group
+// or user namespace of its path.
+func (g *GitLab) CreateRepository(ctx context.Context, repo Repository) error {
+	namespace, name := splitRepoPath(g.repoPath)
+
+	var ns struct {
+		ID int64 `json:"id"`
+	}
+	if err := g.do(ctx, http.MethodGet, "/api/v4/namespaces/"+url.PathEscape(namespace), nil, &ns); err != nil {
+		return fmt.Errorf("failed to look up namespace %s: %w", namespace, err)
+	}
+
+	create := gitlabProject{
+		Name:        name,
+		Path:        name,
+		NamespaceID: ns.ID,
+		Description: repo.Description,
+		Visibility:  "public",
+	}
+	if repo.Private {
+		create.Visibility = "private"
+	}
+	if err := g.do(ctx, http.MethodPost, "/api/v4/projects", create, nil); err != nil {
+		return fmt.Errorf("failed to create project: %w", err)
+	}
+
+	g.log.Info("Created mirror repository", "forge", KindGitLab, "repo", g.repoPath, "private", repo.Private)
+	return nil
+}
+
+// SetDescription implements Target.
+func (g *GitLab) SetDescription(ctx context.Context, description string) error {
+	body := map[string]string{"description": description}
+	if err := g.do(ctx, http.MethodPut, g.projectPath(), body, nil); err != nil {
+		return fmt.Errorf("failed to set project description: %w", err)
+	}
+	return nil
+}
+
+// defaultBranch returns the default branch of the project, or main for a
+// project without commits, whose first commit creates it.
+func (g *GitLab) defaultBranch(ctx context.Context) (string, error) {
+	var project gitlabProject
+	if err := g.do(ctx, http.MethodGet, g.projectPath(), nil, &project); err != nil {
+		return "", fmt.Errorf("failed to look up project: %w", err)
+	}
+	if project.DefaultBranch == "" {
+		return "main", nil
+	}
+	return project.DefaultBranch, nil
+}
+
+// PutFile implements Target.
+func (g *GitLab) PutFile(ctx context.Context, path, content, message string) (bool, error) {
+	branch, err := g.defaultBranch(ctx)
+	if err != nil {
+		return false, err
+	}
+	filePath := g.projectPath() + "/repository/files/" + url.PathEscape(path)
+
+	var existing gitlabFile
+	err = g.do(ctx, http.MethodGet, filePath+"?ref="+url.QueryEscape(branch), nil, &existing)
+	found := err == nil
+	if err != nil && !isNotFound(err) {
+		return false, fmt.Errorf("failed to check for existing %s: %w", path, err)
+	}
+	if found {
+		current, err := base64.StdEncoding.DecodeString(existing.Content)
+		if err == nil && provenance.Equal(string(current), content) {
+			g.log.Debug("File is up to date", "path", path, "branch", branch)
+			return false, nil
+		}
+	}
+
+	update := gitlabFile{
+		Branch:        branch,
+		Content:       base64.StdEncoding.EncodeToString([]byte(content)),
+		Encoding:      "base64",
+		CommitMessage: message,
+	}
+	method := http.MethodPost
+	if found {
+		method = http.MethodPut
+	}
+	if err := g.do(ctx, method, filePath, update, nil); err != nil {
+		return false, fmt.Errorf("failed to create/update %s: %w", path, err)
+	}
+
+	return true, nil
+}
+
+// DeleteFile implements Target.
+func (g *GitLab) DeleteFile(ctx context.Context, path, message string) (bool, error) {
+	branch, err := g.defaultBranch(ctx)
+	if err != nil {
+		return false, err
+	}
+	filePath := g.projectPath() + "/repository/files/" + url.PathEscape(path)
+
+	var existing gitlabFile
+	err = g.do(ctx, http.MethodGet, filePath+"?ref="+url.QueryEscape(branch), nil, &existing)
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check for existing %s: %w", path, err)
+	}
+
+	body := map[string]string{"branch": branch, "commit_message": message}
+	if err := g.do(ctx, http.MethodDelete, filePath, body, nil); err != nil {
+		return false, fmt.Errorf("failed to delete %s: %w", path, err)
+	}
+
+	return true, nil
+}
+
+// EnsureSchedule implements Scheduler. The schedule runs on the default
+// branch, where PutFile commits the pipeline.
+func (g *GitLab) EnsureSchedule(ctx context.Context, schedule Schedule) (bool, error) {
+	branch, err := g.defaultBranch(ctx)
+	if err != nil {
+		return false, err
+	}
+	schedulesPath := g.projectPath() + "/pipeline_schedules"
+
+	var existing []gitlabSchedule
+	if err := g.do(ctx, http.MethodGet, schedulesPath, nil, &existing); err != nil {
+		return false, fmt.Errorf("failed to list pipeline schedules: %w", err)
+	}
+
+	want := gitlabSchedule{Description: schedule.Description, Ref: branch, Cron: schedule.Cron, Active: true}
+	for _, s := range existing {
+		if s.Description != schedule.Description {
+			continue
+		}
+		if s.Ref == want.Ref && s.Cron == want.Cron && s.Active {
+			g.log.Debug("Pipeline schedule up to date", "id", s.ID, "cron", s.Cron)
+			return false, nil
+		}
+		if err := g.do(ctx, http.MethodPut, fmt.Sprintf("%s/%d", schedulesPath, s.ID), want, nil); err != nil {
+			return false, fmt.Errorf("failed to update pipeline schedule: %w", err)
+		}
+		g.log.Info("Updated pipeline schedule", "forge", KindGitLab, "repo", g.repoPath, "cron", want.Cron)
+		return true, nil
+	}
+
+	if err := g.do(ctx, http.MethodPost, schedulesPath, want, nil); err != nil {
+		return false, fmt.Errorf("failed to create pipeline schedule: %w", err)
+	}
+	g.log.Info("Created pipeline schedule", "forge", KindGitLab, "repo", g.repoPath, "cron", want.Cron)
+	return true, nil
+}
+
+// RemoveSchedule implements Scheduler.
+func (g *GitLab) RemoveSchedule(ctx context.Context, description string) (bool, error) {
+	schedulesPath := g.projectPath() + "/pipeline_schedules"
+
+	var existing []gitlabSchedule
+	if err := g.do(ctx, http.MethodGet, schedulesPath, nil, &existing); err != nil {
+		return false, fmt.Errorf("failed to list pipeline schedules: %w", err)
+	}
+
+	removed := false
+	for _, s := range existing {
+		if s.Description != description {
+			continue
+		}
+		if err := g.do(ctx, http.MethodDelete, fmt.Sprintf("%s/%d", schedulesPath, s.ID), nil, nil); err != nil {
+			return removed, fmt.Errorf("failed to delete pipeline schedule: %w", err)
+		}
+		g.log.Info("Deleted pipeline schedule", "forge", KindGitLab, "repo", g.repoPath, "id", s.ID)
+		removed = true
+	}
+	return removed, nil
+}
+
+// gitlabStatus is a commit status as represented by the GitLab API, which
+// calls the context of a status its name and reports the state as status.
+type gitlabStatus struct {
+	State       string `json:"state,omitempty"`
+	Status      string `json:"status,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	TargetURL   string `json:"target_url,omitempty"`
+}
+
+// EnsureCommitStatus implements StatusSetter.
+func (g *GitLab) EnsureCommitStatus(ctx context.Context, sha string, status CommitStatus) (bool, error) {
+	var existing []gitlabStatus
+	listPath := g.projectPath() + "/repository/commits/" + sha + "/statuses?name=" + url.QueryEscape(status.Context)
+	if err := g.do(ctx, http.MethodGet, listPath, nil, &existing); err != nil {
+		return false, fmt.Errorf("failed to list commit statuses: %w", err)
+	}
+	for _, s := range existing {
+		if s.Name == status.Context {
+			g.log.Debug("Commit status already set", "sha", sha, "description", s.Description)
+			return false, nil
+		}
+	}
+
+	create := gitlabStatus{State: "success", Name: status.Context, Description: status.Description, TargetURL: status.TargetURL}
+	if err := g.do(ctx, http.MethodPost, g.projectPath()+"/statuses/"+sha, create, nil); err != nil {
+		return false, fmt.Errorf("failed to create commit status: %w", err)
+	}
+	g.log.Info("Set commit status", "forge", KindGitLab, "repo", g.repoPath, "sha", sha)
+	return true, nil
+}
@@ -0,0 +1,161 @@
+package forge
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxPushPayload bounds the size of a webhook request body.
+const maxPushPayload = 25 << 20
+
+// ErrInvalidSignature is returned for webhook requests that are not signed
+// with the shared secret.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// PushEvent is a push reported by a forge webhook.
+type PushEvent struct {
+	// Forge is the kind of forge that sent the event
+	Forge string
+	// Ref is the pushed ref, e.g. refs/heads/main
+	Ref string
+	// Repos holds the URLs of the pushed repository
+	Repos []string
+}
+
+// Branch returns the pushed branch, or an empty string for other refs.
+func (e *PushEvent) Branch() string {
+	branch, ok := strings.CutPrefix(e.Ref, "refs/heads/")
+	if !ok {
+		return ""
+	}
+	return branch
+}
+
+// Matches reports whether the pushed repository is repoURL.
+func (e *PushEvent) Matches(repoURL string) bool {
+	want, ok := repoKey(repoURL)
+	if !ok {
+		return false
+	}
+	for _, repo := range e.Repos {
+		if got, ok := repoKey(repo); ok && got == want {
+			return true
+		}
+	}
+	return false
+}
+
+// repoKey returns the host and path of repoURL, which identify a repository
+// regardless of the protocol it is cloned with.
+func repoKey(repoURL string) (string, bool) {
+	baseURL, repoPath, err := SplitRepoURL(repoURL)
+	if err != nil {
+		return "", false
+	}
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return "", false
+	}
+	return strings.ToLower(parsed.Hostname() + "/" + repoPath), true
+}
+
+// giteaPush is the part of a Gitea or Forgejo push payload that is used.
+type giteaPush struct {
+	Ref        string `json:"ref"`
+	Repository struct {
+		HTMLURL  string `json:"html_url"`
+		CloneURL string `json:"clone_url"`
+		SSHURL   string `json:"ssh_url"`
+	} `json:"repository"`
+}
+
+// gitlabPush is the part of a GitLab push payload that is used.
+type gitlabPush struct {
+	Ref     string `json:"ref"`
+	Project struct {
+		WebURL     string `json:"web_url"`
+		GitHTTPURL string `json:"git_http_url"`
+		GitSSHURL  string `json:"git_ssh_url"`
+	} `json:"project"`
+}
+
+// ParsePush reads the push webhook request r sent by a Gitea, Forgejo or
+// GitLab forge and verifies it with secret: Gitea and Forgejo sign the body
+// with an HMAC-SHA256 of the secret, GitLab sends the secret as a token.
+// Requests for other events return a nil event.
+func ParsePush(r *http.Request, secret string) (*PushEvent, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPushPayload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook request: %w", err)
+	}
+
+	switch {
+	case r.Header.Get("X-Gitlab-Event") != "":
+		token := r.Header.Get("X-Gitlab-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			return nil, ErrInvalidSignature
+		}
+		if r.Header.Get("X-Gitlab-Event") != "Push Hook" {
+			return nil, nil
+		}
+
+		var push gitlabPush
+		if err := json.Unmarshal(body, &push); err != nil {
+			return nil, fmt.Errorf("failed to parse push payload: %w", err)
+		}
+		return &PushEvent{
+			Forge: KindGitLab,
+			Ref:   push.Ref,
+			Repos: []string{push.Project.WebURL, push.Project.GitHTTPURL, push.Project.GitSSHURL},
+		}, nil
+
+	case r.Header.Get("X-Gitea-Event") != "" || r.Header.Get("X-Forgejo-Event") != "":
+		signature := r.Header.Get("X-Gitea-Signature")
+		if signature == "" {
+			signature = r.Header.Get("X-Forgejo-Signature")
+		}
+		if !validHMAC(body, signature, secret) {
+			return nil, ErrInvalidSignature
+		}
+		event := r.Header.Get("X-Gitea-Event")
+		if event == "" {
+			event = r.Header.Get("X-Forgejo-Event")
+		}
+		if event != "push" {
+			return nil, nil
+		}
+
+		var push giteaPush
+		if err := json.Unmarshal(body, &push); err != nil {
+			return nil, fmt.Errorf("failed to parse push payload: %w", err)
+		}
+		return &PushEvent{
+			Forge: KindGitea,
+			Ref:   push.Ref,
+			Repos: []string{push.Repository.HTMLURL, push.Repository.CloneURL, push.Repository.SSHURL},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported webhook request, expected a Gitea, Forgejo or GitLab event")
+}
+
+// validHMAC reports whether signature is the hex HMAC-SHA256 of body keyed
+// with secret.
+func validHMAC(body []byte, signature, secret string) bool {
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
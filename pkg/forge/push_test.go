@@ -0,0 +1,162 @@
+package forge_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/forge"
+)
+
+func TestParsePush(t *testing.T) {
+	const (
+		secret      = "webhook-secret"
+		giteaBody   = `{"ref":"refs/heads/main","repository":{"html_url":"https://git.example.org/go-i2p/repo","clone_url":"https://git.example.org/go-i2p/repo.git","ssh_url":"git@git.example.org:go-i2p/repo.git"}}`
+		gitlabBody  = `{"ref":"refs/heads/main","project":{"web_url":"https://gitlab.example.org/go-i2p/repo","git_http_url":"https://gitlab.example.org/go-i2p/repo.git","git_ssh_url":"git@gitlab.example.org:go-i2p/repo.git"}}`
+		giteaRepo   = "https://git.example.org/go-i2p/repo.git"
+		gitlabRepo  = "https://gitlab.example.org/go-i2p/repo.git"
+		invalidJSON = "{"
+	)
+	sign := func(body, key string) string {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(body))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		headers map[string]string
+		forge   string
+		repo    string
+		ignored bool
+		// err is the error expected, or a part of its message
+		err string
+	}{
+		{
+			name:    "Gitea push",
+			body:    giteaBody,
+			headers: map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": sign(giteaBody, secret)},
+			forge:   forge.KindGitea,
+			repo:    giteaRepo,
+		},
+		{
+			name:    "Forgejo push",
+			body:    giteaBody,
+			headers: map[string]string{"X-Forgejo-Event": "push", "X-Forgejo-Signature": sign(giteaBody, secret)},
+			forge:   forge.KindGitea,
+			repo:    giteaRepo,
+		},
+		{
+			name:    "prefixed signature",
+			body:    giteaBody,
+			headers: map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": "sha256=" + sign(giteaBody, secret)},
+			forge:   forge.KindGitea,
+			repo:    giteaRepo,
+		},
+		{
+			name:    "Gitea event other than push",
+			body:    `{}`,
+			headers: map[string]string{"X-Gitea-Event": "issues", "X-Gitea-Signature": sign(`{}`, secret)},
+			ignored: true,
+		},
+		{
+			name:    "signature with another secret",
+			body:    giteaBody,
+			headers: map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": sign(giteaBody, "other-secret")},
+			err:     forge.ErrInvalidSignature.Error(),
+		},
+		{
+			name:    "signature of another body",
+			body:    giteaBody,
+			headers: map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": sign(`{}`, secret)},
+			err:     forge.ErrInvalidSignature.Error(),
+		},
+		{
+			name:    "missing signature",
+			body:    giteaBody,
+			headers: map[string]string{"X-Gitea-Event": "push"},
+			err:     forge.ErrInvalidSignature.Error(),
+		},
+		{
+			name:    "malformed signature",
+			body:    giteaBody,
+			headers: map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": "not hex"},
+			err:     forge.ErrInvalidSignature.Error(),
+		},
+		{
+			name:    "unsigned event other than push",
+			body:    `{}`,
+			headers: map[string]string{"X-Forgejo-Event": "issues"},
+			err:     forge.ErrInvalidSignature.Error(),
+		},
+		{
+			name:    "GitLab push",
+			body:    gitlabBody,
+			headers: map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": secret},
+			forge:   forge.KindGitLab,
+			repo:    gitlabRepo,
+		},
+		{
+			name:    "GitLab event other than push",
+			body:    `{}`,
+			headers: map[string]string{"X-Gitlab-Event": "Issue Hook", "X-Gitlab-Token": secret},
+			ignored: true,
+		},
+		{
+			name:    "wrong GitLab token",
+			body:    gitlabBody,
+			headers: map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "other-secret"},
+			err:     forge.ErrInvalidSignature.Error(),
+		},
+		{
+			name:    "missing GitLab token",
+			body:    gitlabBody,
+			headers: map[string]string{"X-Gitlab-Event": "Push Hook"},
+			err:     forge.ErrInvalidSignature.Error(),
+		},
+		{
+			name:    "invalid payload",
+			body:    invalidJSON,
+			headers: map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": secret},
+			err:     "failed to parse push payload",
+		},
+		{
+			name: "unknown forge",
+			body: giteaBody,
+			err:  "unsupported webhook request",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/webhook", strings.NewReader(tt.body))
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+
+			push, err := forge.ParsePush(r, secret)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ParsePush() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePush: %v", err)
+			}
+			if tt.ignored {
+				if push != nil {
+					t.Errorf("ParsePush() = %+v, want no event", push)
+				}
+				return
+			}
+			if push == nil || push.Forge != tt.forge || push.Ref != "refs/heads/main" || !slices.Contains(push.Repos, tt.repo) {
+				t.Errorf("ParsePush() = %+v, want a %s push of refs/heads/main to %s", push, tt.forge, tt.repo)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+// Badge is a shields.io endpoint badge describing the last sync of a mirror,
+// shown with https://img.shields.io/endpoint?url=<URL of config.BadgeFile>.
+type Badge struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+}
+
+// NewBadge returns the badge of a sync that ended at the given time with err.
+func NewBadge(at time.Time, err error) Badge {
+	badge := Badge{SchemaVersion: 1, Label: "mirror", Message: "synced", Color: "brightgreen"}
+	if err != nil {
+		badge.Message, badge.Color = "sync failed", "red"
+	}
+	badge.Message += " " + at.UTC().Format("2006-01-02 15:04 UTC")
+	return badge
+}
+
+// PublishBadge commits badge to config.BadgeFile on cfg.BadgeBranch of the
+// mirror and pushes it, keeping the other files of the branch, e.g. of a
+// GitHub Pages site. The branch is created when missing. The commit is made
+// in the object store of the working copy of the mirror, which must have
+// been created by an earlier sync; its branches are left untouched.
+func (c *Client) PublishBadge(ctx context.Context, cfg *config.Config, token string, badge Badge) error {
+	repoPath, err := mirrorPath(cfg.MirrorRepo)
+	if err != nil {
+		return fmt.Errorf("failed to parse mirror repository URL: %w", err)
+	}
+	wc, err := c.openWorkingCopy(ctx, cfg, filepath.Join(cfg.WorkDir, filepath.FromSlash(repoPath)), token, false)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(badge)
+	if err != nil {
+		return fmt.Errorf("failed to encode badge: %w", err)
+	}
+	blob, err := writeBlob(wc.Storer, append(content, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write badge: %w", err)
+	}
+
+	const badgeRef = "refs/gh-mirror/badge"
+	branchRef := "refs/heads/" + cfg.BadgeBranch
+	files := make(map[string]object.TreeEntry)
+	var parents []plumbing.Hash
+	if err := fetch(ctx, wc.Repository, wc.origin.fetch("origin", "+"+branchRef+":"+badgeRef)); err == nil {
+		ref, err := wc.Reference(badgeRef, true)
+		if err != nil {
+			return err
+		}
+		parent, err := wc.CommitObject(ref.Hash())
+		if err != nil {
+			return err
+		}
+		tree, err := parent.Tree()
+		if err != nil {
+			return err
+		}
+		if files, err = treeFiles(tree); err != nil {
+			return err
+		}
+		parents = []plumbing.Hash{parent.Hash}
+	} else {
+		c.log.Debug("Badge branch not found on the mirror, creating it", "branch", cfg.BadgeBranch, "error", err)
+	}
+	files[config.BadgeFile] = object.TreeEntry{Mode: filemode.Regular, Hash: blob}
+
+	tree, err := writeTree(wc.Storer, files)
+	if err != nil {
+		return fmt.Errorf("failed to write badge tree: %w", err)
+	}
+	sig := signature()
+	commit, err := storeObject(wc.Storer, &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      "Update mirror badge: " + badge.Message + "\n",
+		TreeHash:     tree,
+		ParentHashes: parents,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write badge commit: %w", err)
+	}
+	if err := wc.Storer.SetReference(plumbing.NewHashReference(badgeRef, commit)); err != nil {
+		return err
+	}
+
+	if err := c.push(ctx, cfg, wc.Repository, wc.origin.push("origin", badgeRef+":"+branchRef), commit.String()+":"+branchRef); err != nil {
+		return fmt.Errorf("failed to push badge: %w", err)
+	}
+	c.log.Debug("Published badge", "branch", cfg.BadgeBranch, "message", badge.Message)
+	return nil
+}
@@ -0,0 +1,139 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+// Errors of HostKeys.
+var (
+	// ErrHostKeyNotPinned is returned when the workflow would connect to an
+	// SSH primary whose host key is not pinned
+	ErrHostKeyNotPinned = errors.New("SSH host key of the primary is not pinned")
+
+	// ErrHostKeyMismatch is returned when the primary presents no host key
+	// with a pinned fingerprint
+	ErrHostKeyMismatch = errors.New("no SSH host key of the primary matches the pinned fingerprint")
+)
+
+// HostKeys returns the known_hosts entries of the SSH host keys of the
+// primary that the workflow of cfg trusts in the ssh auth mode:
+// cfg.KnownHosts when set, or else the keys fetched from the primary with
+// ssh-keyscan whose fingerprints are listed in cfg.HostKeyFingerprints. Each
+// listed fingerprint must match a fetched key, so a key that changed or an
+// attacker in between is noticed when the workflow is generated rather than
+// trusted by it. An SSH primary outside GitHub, whose host key the checkout
+// does not know, must be pinned; ErrHostKeyNotPinned then reports the
+// fingerprints of its keys for the user to confirm out of band.
+func (c *Client) HostKeys(ctx context.Context, cfg *config.Config) (string, error) {
+	if cfg.KnownHosts != "" || cfg.AuthMode != config.AuthModeSSH {
+		return cfg.KnownHosts, nil
+	}
+	host, port, ok := sshHostPort(cfg.PrimaryRepo)
+	switch {
+	case !ok && len(cfg.HostKeyFingerprints) > 0:
+		return "", fmt.Errorf("--host-key-fingerprint pins the host key of an SSH primary, %s is none", cfg.PrimaryRepo)
+	case !ok, config.IsGitHubURL(cfg.PrimaryRepo) && len(cfg.HostKeyFingerprints) == 0:
+		// The checkout knows the host keys of GitHub
+		return "", nil
+	}
+
+	keys, err := c.scanHostKeys(ctx, host, port)
+	if err != nil {
+		return "", err
+	}
+	if len(cfg.HostKeyFingerprints) == 0 {
+		fingerprints := make([]string, 0, len(keys))
+		for _, key := range keys {
+			fingerprints = append(fingerprints, key.fingerprint)
+		}
+		return "", fmt.Errorf("%w: %s presents the host keys %s; confirm one with the operator of the host and pass it with --host-key-fingerprint, or pass its known_hosts entries with --known-hosts",
+			ErrHostKeyNotPinned, host, strings.Join(fingerprints, ", "))
+	}
+
+	var entries []string
+	for _, fingerprint := range cfg.HostKeyFingerprints {
+		i := slices.IndexFunc(keys, func(key hostKey) bool {
+			return key.fingerprint == strings.TrimRight(fingerprint, "=")
+		})
+		if i < 0 {
+			return "", fmt.Errorf("%w %s: %s presents other keys, refusing to pin them", ErrHostKeyMismatch, fingerprint, host)
+		}
+		entries = append(entries, keys[i].entry)
+	}
+	c.log.Info("Pinned SSH host keys of the primary", "host", host, "fingerprints", cfg.HostKeyFingerprints)
+	return strings.Join(entries, "\n"), nil
+}
+
+// hostKey is a host key fetched by ssh-keyscan.
+type hostKey struct {
+	// entry is the known_hosts line of the key
+	entry string
+	// fingerprint is the SHA256 fingerprint of the key
+	fingerprint string
+}
+
+// scanHostKeys fetches the host keys of the SSH server at host and port.
+func (c *Client) scanHostKeys(ctx context.Context, host, port string) ([]hostKey, error) {
+	binary, err := exec.LookPath("ssh-keyscan")
+	if err != nil {
+		return nil, fmt.Errorf("ssh-keyscan is required to fetch the SSH host keys of the primary: %w", err)
+	}
+
+	c.log.Debug("Fetching SSH host keys", "host", host, "port", port)
+	var stderr strings.Builder
+	cmd := exec.CommandContext(ctx, binary, "-T", "15", "-p", port, host)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ssh-keyscan failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var keys []hostKey
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		_, _, key, _, _, err := ssh.ParseKnownHosts([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse host key %q: %w", line, err)
+		}
+		keys = append(keys, hostKey{entry: line, fingerprint: ssh.FingerprintSHA256(key)})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no SSH host keys fetched from %s: %s", host, strings.TrimSpace(stderr.String()))
+	}
+	return keys, nil
+}
+
+// sshHostPort returns the host and port of an SSH or scp-like repository
+// URL; ok is false for any other URL.
+func sshHostPort(repoURL string) (host, port string, ok bool) {
+	if !config.IsSSHURL(repoURL) {
+		return "", "", false
+	}
+	if strings.HasPrefix(repoURL, "ssh://") {
+		parsed, err := url.Parse(repoURL)
+		if err != nil || parsed.Hostname() == "" {
+			return "", "", false
+		}
+		port = parsed.Port()
+		if port == "" {
+			port = "22"
+		}
+		return parsed.Hostname(), port, true
+	}
+	// git@host:path
+	host, _, found := strings.Cut(strings.TrimPrefix(repoURL, "git@"), ":")
+	return host, "22", found && host != ""
+}
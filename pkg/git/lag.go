@@ -0,0 +1,116 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+// Lag is how far the mirror branch trails the primary branch.
+type Lag struct {
+	// PrimarySHA and MirrorSHA are the heads of the branches, MirrorSHA is
+	// empty when the mirror branch does not exist yet
+	PrimarySHA string
+	MirrorSHA  string
+
+	// Commits is the number of commits of the primary branch missing on
+	// the mirror branch
+	Commits int
+
+	// Behind is how long ago the oldest missing commit was committed
+	Behind time.Duration
+}
+
+// Lag measures how far cfg.MirrorBranch of the mirror trails the primary
+// branch. The heads are compared by listing the refs first; only when they
+// differ are both fetched into the working copy of the mirror in
+// cfg.WorkDir, which is shared with Sync, to count the missing commits.
+// Mirrors whose history was rewritten by filtering share no commits with
+// the primary and cannot be measured.
+func (c *Client) Lag(ctx context.Context, cfg *config.Config, token string) (*Lag, error) {
+	primaryRefs, err := c.ListRefs(ctx, cfg, cfg.PrimaryRepo, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list primary repository refs: %w", err)
+	}
+	lag := &Lag{PrimarySHA: primaryRefs["refs/heads/"+cfg.PrimaryBranch]}
+	if lag.PrimarySHA == "" {
+		return nil, config.WithKind(config.ErrBranchMissing, fmt.Errorf("primary branch %s not found in primary repository", cfg.PrimaryBranch))
+	}
+	mirrorRefs, err := c.ListRefs(ctx, cfg, cfg.MirrorRepo, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mirror repository refs: %w", err)
+	}
+	lag.MirrorSHA = mirrorRefs["refs/heads/"+cfg.MirrorBranch]
+	if lag.MirrorSHA == lag.PrimarySHA {
+		return lag, nil
+	}
+
+	repoPath, err := mirrorPath(cfg.MirrorRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mirror repository URL: %w", err)
+	}
+	dir := filepath.Join(cfg.WorkDir, filepath.FromSlash(repoPath))
+	wc, err := c.prepareWorkingCopy(ctx, cfg, dir, token)
+	if err != nil {
+		return nil, err
+	}
+
+	primary, err := branchCommit(wc, "primary", cfg.PrimaryBranch)
+	if err != nil {
+		return nil, err
+	}
+	// The walk of the primary stops at the commits of the mirror
+	onMirror := make(map[plumbing.Hash]bool)
+	if lag.MirrorSHA != "" {
+		mirror, err := branchCommit(wc, "origin", cfg.MirrorBranch)
+		if err != nil {
+			return nil, err
+		}
+		bases, err := primary.MergeBase(mirror)
+		if err != nil {
+			return nil, err
+		}
+		if len(bases) == 0 {
+			return nil, fmt.Errorf("mirror branch %s shares no history with primary branch %s, e.g. because it is filtered", cfg.MirrorBranch, cfg.PrimaryBranch)
+		}
+		err = object.NewCommitPreorderIter(mirror, nil, nil).ForEach(func(commit *object.Commit) error {
+			onMirror[commit.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var oldest time.Time
+	err = object.NewCommitPreorderIter(primary, onMirror, nil).ForEach(func(commit *object.Commit) error {
+		lag.Commits++
+		if oldest.IsZero() || commit.Committer.When.Before(oldest) {
+			oldest = commit.Committer.When
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if lag.Commits == 0 {
+		return lag, nil
+	}
+	lag.Behind = max(time.Since(oldest), 0)
+	return lag, nil
+}
+
+// branchCommit returns the commit of branch fetched from remote into wc.
+func branchCommit(wc *workingCopy, remote, branch string) (*object.Commit, error) {
+	ref, err := wc.Reference(plumbing.NewRemoteReferenceName(remote, branch), true)
+	if err != nil {
+		return nil, fmt.Errorf("branch %s of %s not fetched: %w", branch, remote, err)
+	}
+	return wc.CommitObject(ref.Hash())
+}
@@ -0,0 +1,241 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/diff"
+)
+
+// signature returns the author and committer of the commits made by the
+// local sync engine.
+func signature() object.Signature {
+	return object.Signature{Name: "gh-mirror", Email: "gh-mirror@localhost", When: time.Now()}
+}
+
+// treeFiles returns the entries of the files, symlinks and submodules of
+// tree by path. A nil tree has none.
+func treeFiles(tree *object.Tree) (map[string]object.TreeEntry, error) {
+	files := make(map[string]object.TreeEntry)
+	if tree == nil {
+		return files, nil
+	}
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry.Mode != filemode.Dir {
+			files[name] = entry
+		}
+	}
+}
+
+// mergeTrees merges the changes ours and theirs made to the files of base
+// path by path, as the merge of the generated workflow does: a path changed
+// on one side only takes the version of that side, and the lines of a text
+// file both sides changed are merged, see diff.Merge. A path both sides
+// changed in conflicting ways, or that is a file on one side and a directory
+// on the other, takes the version of theirs. Whether there were conflicts is
+// reported along with the merged files, whose merged contents are stored in
+// s.
+func mergeTrees(s storer.EncodedObjectStorer, base, ours, theirs map[string]object.TreeEntry) (map[string]object.TreeEntry, bool, error) {
+	merged := make(map[string]object.TreeEntry)
+	conflicts := false
+	for _, files := range []map[string]object.TreeEntry{base, ours, theirs} {
+		for name := range files {
+			if _, done := merged[name]; done {
+				continue
+			}
+			b, inBase := base[name]
+			o, inOurs := ours[name]
+			t, inTheirs := theirs[name]
+
+			entry, keep := t, inTheirs
+			switch {
+			case sameEntry(o, inOurs, t, inTheirs), sameEntry(b, inBase, o, inOurs):
+				// Only theirs changed the path, if any side did
+			case sameEntry(b, inBase, t, inTheirs):
+				entry, keep = o, inOurs
+			case inBase && inOurs && inTheirs:
+				resolved, ok, err := mergeFile(s, b, o, t)
+				if err != nil {
+					return nil, false, fmt.Errorf("failed to merge %s: %w", name, err)
+				}
+				if ok {
+					entry = resolved
+				} else {
+					conflicts = true
+				}
+			default:
+				conflicts = true
+			}
+			if keep {
+				merged[name] = entry
+			}
+		}
+	}
+
+	// A file of one side may stand where the other has a directory
+	for name := range merged {
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if _, clash := merged[dir]; !clash {
+				continue
+			}
+			conflicts = true
+			if t, ok := theirs[dir]; ok && sameEntry(t, true, merged[dir], true) {
+				delete(merged, name)
+			} else {
+				delete(merged, dir)
+			}
+			break
+		}
+	}
+	return merged, conflicts, nil
+}
+
+// mergeFile merges the lines ours and theirs changed in the file base and
+// stores the result in s. It reports false when the changes conflict, or
+// when the versions are not all regular text files.
+func mergeFile(s storer.EncodedObjectStorer, base, ours, theirs object.TreeEntry) (object.TreeEntry, bool, error) {
+	for _, entry := range []object.TreeEntry{base, ours, theirs} {
+		if !entry.Mode.IsFile() || entry.Mode == filemode.Symlink {
+			return object.TreeEntry{}, false, nil
+		}
+	}
+	mode := theirs.Mode
+	switch {
+	case theirs.Mode == base.Mode:
+		mode = ours.Mode
+	case ours.Mode != base.Mode && ours.Mode != theirs.Mode:
+		return object.TreeEntry{}, false, nil
+	}
+
+	var texts [3]string
+	for i, entry := range []object.TreeEntry{base, ours, theirs} {
+		content, err := readBlob(s, entry.Hash)
+		if err != nil {
+			return object.TreeEntry{}, false, err
+		}
+		// git does not merge the lines of binary files either
+		if bytes.IndexByte(content, 0) >= 0 {
+			return object.TreeEntry{}, false, nil
+		}
+		texts[i] = string(content)
+	}
+	text, ok := diff.Merge(texts[0], texts[1], texts[2])
+	if !ok {
+		return object.TreeEntry{}, false, nil
+	}
+	hash, err := writeBlob(s, []byte(text))
+	if err != nil {
+		return object.TreeEntry{}, false, err
+	}
+	return object.TreeEntry{Mode: mode, Hash: hash}, true, nil
+}
+
+// sameEntry reports whether the entries a and b, each present or not, are
+// the same version of a path.
+func sameEntry(a object.TreeEntry, hasA bool, b object.TreeEntry, hasB bool) bool {
+	if !hasA || !hasB {
+		return hasA == hasB
+	}
+	return a.Hash == b.Hash && a.Mode == b.Mode
+}
+
+// writeTree stores the tree holding files by path, and its subtrees, in s
+// and returns its hash.
+func writeTree(s storer.EncodedObjectStorer, files map[string]object.TreeEntry) (plumbing.Hash, error) {
+	tree := &object.Tree{}
+	subtrees := make(map[string]map[string]object.TreeEntry)
+	for name, entry := range files {
+		if dir, rest, ok := strings.Cut(name, "/"); ok {
+			if subtrees[dir] == nil {
+				subtrees[dir] = make(map[string]object.TreeEntry)
+			}
+			subtrees[dir][rest] = entry
+			continue
+		}
+		entry.Name = name
+		tree.Entries = append(tree.Entries, entry)
+	}
+	for dir, subtree := range subtrees {
+		hash, err := writeTree(s, subtree)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: hash})
+	}
+	// git orders directories as if their names ended with a slash
+	sort.Slice(tree.Entries, func(i, j int) bool {
+		return sortName(tree.Entries[i]) < sortName(tree.Entries[j])
+	})
+	return storeObject(s, tree)
+}
+
+// sortName returns the name git sorts the entry of a tree by.
+func sortName(entry object.TreeEntry) string {
+	if entry.Mode == filemode.Dir {
+		return entry.Name + "/"
+	}
+	return entry.Name
+}
+
+// readBlob returns the content of the blob hash in s.
+func readBlob(s storer.EncodedObjectStorer, hash plumbing.Hash) ([]byte, error) {
+	blob, err := object.GetBlob(s, hash)
+	if err != nil {
+		return nil, err
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// writeBlob stores content as a blob in s and returns its hash.
+func writeBlob(s storer.EncodedObjectStorer, content []byte) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := w.Write(content); err != nil {
+		w.Close()
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// storeObject encodes o, a tree or a commit, into s and returns its hash.
+func storeObject(s storer.EncodedObjectStorer, o interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	if err := o.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
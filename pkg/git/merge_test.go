@@ -0,0 +1,189 @@
+package git
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestMergeTrees(t *testing.T) {
+	const (
+		lines       = "one\ntwo\nthree\nfour\nfive\n"
+		oursLines   = "ONE\ntwo\nthree\nfour\nfive\n"
+		theirsLines = "one\ntwo\nthree\nfour\nFIVE\n"
+		bothLines   = "ONE\ntwo\nthree\nfour\nFIVE\n"
+		otherLines  = "uno\ntwo\nthree\nfour\nfive\n"
+	)
+	// A file is given by its content, prefixed with "x:" when it is
+	// executable, "l:" when it is a symlink and "d:" for the entry of a
+	// directory
+	type files map[string]string
+
+	tests := []struct {
+		name      string
+		base      files
+		ours      files
+		theirs    files
+		want      files
+		conflicts bool
+	}{
+		{
+			name:   "unchanged",
+			base:   files{"a": lines},
+			ours:   files{"a": lines},
+			theirs: files{"a": lines},
+			want:   files{"a": lines},
+		},
+		{
+			name:   "changed by theirs",
+			base:   files{"a": lines, "b": "b\n"},
+			ours:   files{"a": lines, "b": "b\n"},
+			theirs: files{"a": theirsLines},
+			want:   files{"a": theirsLines},
+		},
+		{
+			name:   "changed by ours",
+			base:   files{"a": lines, "b": "b\n"},
+			ours:   files{"a": oursLines, "c": "c\n"},
+			theirs: files{"a": lines, "b": "b\n"},
+			want:   files{"a": oursLines, "c": "c\n"},
+		},
+		{
+			name:   "same change",
+			base:   files{"a": lines},
+			ours:   files{"a": oursLines, "b": "b\n"},
+			theirs: files{"a": oursLines, "b": "b\n"},
+			want:   files{"a": oursLines, "b": "b\n"},
+		},
+		{
+			name:   "different lines",
+			base:   files{"dir/a": lines},
+			ours:   files{"dir/a": oursLines},
+			theirs: files{"dir/a": theirsLines},
+			want:   files{"dir/a": bothLines},
+		},
+		{
+			name:   "different lines and mode",
+			base:   files{"a": lines},
+			ours:   files{"a": oursLines},
+			theirs: files{"a": "x:" + theirsLines},
+			want:   files{"a": "x:" + bothLines},
+		},
+		{
+			name:      "same lines",
+			base:      files{"a": lines},
+			ours:      files{"a": oursLines},
+			theirs:    files{"a": otherLines},
+			want:      files{"a": otherLines},
+			conflicts: true,
+		},
+		{
+			name:      "different modes",
+			base:      files{"a": lines},
+			ours:      files{"a": "x:" + oursLines},
+			theirs:    files{"a": "l:" + theirsLines},
+			want:      files{"a": "l:" + theirsLines},
+			conflicts: true,
+		},
+		{
+			name:      "binary",
+			base:      files{"a": "\x00" + lines},
+			ours:      files{"a": "\x00" + oursLines},
+			theirs:    files{"a": "\x00" + theirsLines},
+			want:      files{"a": "\x00" + theirsLines},
+			conflicts: true,
+		},
+		{
+			name:      "added by both",
+			ours:      files{"a": oursLines},
+			theirs:    files{"a": theirsLines},
+			want:      files{"a": theirsLines},
+			conflicts: true,
+		},
+		{
+			name:      "changed by ours, deleted by theirs",
+			base:      files{"a": lines},
+			ours:      files{"a": oursLines},
+			theirs:    files{},
+			want:      files{},
+			conflicts: true,
+		},
+		{
+			name:      "deleted by ours, changed by theirs",
+			base:      files{"a": lines},
+			ours:      files{},
+			theirs:    files{"a": theirsLines},
+			want:      files{"a": theirsLines},
+			conflicts: true,
+		},
+		{
+			name:      "file of ours in a directory of theirs",
+			base:      files{},
+			ours:      files{"a": lines},
+			theirs:    files{"a/b": lines},
+			want:      files{"a/b": lines},
+			conflicts: true,
+		},
+		{
+			name:      "file of theirs in a directory of ours",
+			base:      files{},
+			ours:      files{"a/b": lines},
+			theirs:    files{"a": lines},
+			want:      files{"a": lines},
+			conflicts: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := memory.NewStorage()
+			entries := func(files files) map[string]object.TreeEntry {
+				t.Helper()
+				result := make(map[string]object.TreeEntry)
+				for name, content := range files {
+					mode := filemode.Regular
+					switch content[:min(2, len(content))] {
+					case "x:":
+						mode, content = filemode.Executable, content[2:]
+					case "l:":
+						mode, content = filemode.Symlink, content[2:]
+					}
+					hash, err := writeBlob(s, []byte(content))
+					if err != nil {
+						t.Fatalf("writeBlob: %v", err)
+					}
+					result[name] = object.TreeEntry{Mode: mode, Hash: hash}
+				}
+				return result
+			}
+
+			merged, conflicts, err := mergeTrees(s, entries(tt.base), entries(tt.ours), entries(tt.theirs))
+			if err != nil {
+				t.Fatalf("mergeTrees: %v", err)
+			}
+			if conflicts != tt.conflicts {
+				t.Errorf("conflicts = %v, want %v", conflicts, tt.conflicts)
+			}
+			got := make(files)
+			for name, entry := range merged {
+				content, err := readBlob(s, entry.Hash)
+				if err != nil {
+					t.Fatalf("readBlob %s: %v", name, err)
+				}
+				switch entry.Mode {
+				case filemode.Executable:
+					got[name] = "x:" + string(content)
+				case filemode.Symlink:
+					got[name] = "l:" + string(content)
+				default:
+					got[name] = string(content)
+				}
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("merged files = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
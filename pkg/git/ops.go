@@ -3,99 +3,349 @@ package git
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
 	"strings"
 	"time"
 
+	gogit "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+
 	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
 	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
 )
 
+// lsRemoteTimeout bounds the time spent listing the refs of a repository.
+const lsRemoteTimeout = 30 * time.Second
+
 // Client provides Git repository validation and operations.
 type Client struct {
-	log        *logger.Logger
+	gitPath string
+	log     *logger.Logger
+
+	// httpClient probes HTTP(S) repositories instead of a client using the
+	// proxy git would use, if set
 	httpClient *http.Client
+
+	// timeout bounds the time spent listing the refs of a repository
+	timeout time.Duration
+
+	// userAgent is sent when probing HTTP(S) repositories
+	userAgent string
+}
+
+// Option customizes a Client created by NewClient.
+type Option func(*Client)
+
+// WithHTTPClient makes the client probe HTTP(S) repositories with
+// httpClient, e.g. to go through a proxy or to a test server, instead of
+// choosing the proxy git would use for each repository.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout bounds the time spent listing the refs of a repository during
+// validation (default: 30s).
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
 }
 
-// NewClient creates a new Git client.
-func NewClient(log *logger.Logger) *Client {
-	return &Client{
-		log: log,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+// WithUserAgent sets the User-Agent header sent when probing HTTP(S)
+// repositories. Servers such as GitHub only speak the smart protocol to user
+// agents starting with git/.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
 	}
 }
 
+// NewClient creates a new Git client running the git binary at gitPath, or
+// git found in PATH when gitPath is empty, customized with opts.
+func NewClient(gitPath string, log *logger.Logger, opts ...Option) *Client {
+	c := &Client{
+		gitPath:   gitPath,
+		log:       log,
+		timeout:   lsRemoteTimeout,
+		userAgent: "git/gh-mirror",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
 // ValidateRepos checks if both repositories are accessible.
 func (c *Client) ValidateRepos(ctx context.Context, cfg *config.Config) error {
 	// Validate primary repository URL
-	if err := c.validateRepoURL(ctx, cfg.PrimaryRepo); err != nil {
+	primaryRefs, err := c.validateRepoURL(ctx, cfg, cfg.PrimaryRepo, "")
+	if err != nil {
 		return fmt.Errorf("invalid primary repository URL: %w", err)
 	}
+	if primaryRefs != nil {
+		if _, ok := primaryRefs["refs/heads/"+cfg.PrimaryBranch]; !ok {
+			return config.WithKind(config.ErrBranchMissing, fmt.Errorf("primary branch %s not found in primary repository (branches: %s)",
+				cfg.PrimaryBranch, strings.Join(branchNames(primaryRefs), ", ")))
+		}
+	}
 
-	// Validate GitHub repository URL format
-	if !strings.Contains(cfg.MirrorRepo, "github.com") {
-		return fmt.Errorf("mirror repository must be a GitHub repository URL")
+	// Validate mirror repository URL format
+	if config.IsGitHubURL(cfg.MirrorRepo) {
+		owner, repo, err := parseGitHubURL(cfg.MirrorRepo)
+		if err != nil {
+			return fmt.Errorf("failed to parse GitHub repository URL: %w", err)
+		}
+		c.log.Debug("Parsed GitHub repository", "owner", owner, "repo", repo)
+	} else {
+		if _, err := mirrorPath(cfg.MirrorRepo); err != nil {
+			return fmt.Errorf("invalid mirror repository URL: %w", err)
+		}
+		c.log.Debug("Mirror is not on GitHub, GitHub specific setup is unavailable", "url", cfg.MirrorRepo)
 	}
 
-	// Extract owner and repo from GitHub URL
-	owner, repo, err := parseGitHubURL(cfg.MirrorRepo)
-	if err != nil {
-		return fmt.Errorf("failed to parse GitHub repository URL: %w", err)
+	// The mirror may be private or not created yet, so it is only checked
+	// on a best-effort basis
+	var mirrorToken string
+	check := false
+	switch {
+	case strings.HasPrefix(cfg.MirrorRepo, "https://"):
+		check = true
+		if config.IsGitHubURL(cfg.MirrorRepo) {
+			mirrorToken = cfg.GithubToken
+		}
+	case config.IsSSHURL(cfg.MirrorRepo) && cfg.ValidateSSH:
+		check = true
+	}
+	if check {
+		mirrorRefs, err := c.lsRemote(ctx, cfg, cfg.MirrorRepo, mirrorToken)
+		if err != nil {
+			c.log.Warn("Could not list mirror repository refs", "url", cfg.MirrorRepo, "error", err)
+		} else if _, ok := mirrorRefs["refs/heads/"+cfg.MirrorBranch]; !ok {
+			c.log.Info("Mirror branch does not exist yet, it will be created by the first sync", "branch", cfg.MirrorBranch)
+		}
 	}
 
-	c.log.Debug("Parsed GitHub repository", "owner", owner, "repo", repo)
 	return nil
 }
 
-// validateRepoURL checks if a Git repository URL is accessible.
-func (c *Client) validateRepoURL(ctx context.Context, repoURL string) error {
-	// For HTTP/HTTPS URLs, try to access the repository
+// ListRefs checks that repoURL is an accessible Git repository, like the
+// primary is checked by ValidateRepos, and returns its refs. The token, when
+// not empty, authenticates with GitHub. The refs are nil when the repository
+// was not listed, as for SSH URLs without cfg.ValidateSSH.
+func (c *Client) ListRefs(ctx context.Context, cfg *config.Config, repoURL, token string) (map[string]string, error) {
+	return c.validateRepoURL(ctx, cfg, repoURL, token)
+}
+
+// ProxyFor returns the proxy git connections to repoURL go through, or an
+// empty string when git uses the proxy environment variables.
+func ProxyFor(cfg *config.Config, repoURL string) string {
+	switch {
+	case cfg.Proxy != "":
+		return cfg.Proxy
+	case config.I2PHost(repoURL) != "":
+		return cfg.I2PProxy
+	case config.OnionOrigin(repoURL) != "":
+		return cfg.TorProxy
+	}
+	return ""
+}
+
+// validateRepoURL checks if a Git repository URL is accessible and returns
+// its refs. The refs are nil when the repository could not be listed.
+func (c *Client) validateRepoURL(ctx context.Context, cfg *config.Config, repoURL, token string) (map[string]string, error) {
+	// For HTTP/HTTPS URLs, request the ref advertisement to make sure it is
+	// a Git repository, and tell missing repositories, required credentials
+	// and network errors apart
 	if strings.HasPrefix(repoURL, "http://") || strings.HasPrefix(repoURL, "https://") {
-		// For GitHub URLs, we can check info/refs
-		if strings.Contains(repoURL, "github.com") {
-			checkURL := ensureGitExtension(repoURL) + "/info/refs?service=git-upload-pack"
-			return c.checkEndpoint(ctx, checkURL)
+		adv, err := c.probeSmartHTTP(ctx, cfg, repoURL)
+		switch {
+		case err == nil:
+			if adv.URL != strings.TrimSuffix(repoURL, "/") {
+				c.log.Warn("Repository URL redirects, consider using the new URL", "url", repoURL, "location", adv.URL)
+			}
+			if adv.Refs != nil {
+				return adv.Refs, nil
+			}
+		case errors.Is(err, errAuthRequired), errors.Is(err, errProbeUnavailable):
+			c.log.Debug("Listing repository refs with credentials", "url", repoURL, "reason", err)
+		default:
+			return nil, err
+		}
+
+		// The credentials are obtained from the credential helpers, and
+		// protocol v2 servers advertise their refs when listed
+		refs, err := c.lsRemote(ctx, cfg, repoURL, token)
+		if err != nil {
+			return nil, credentialError(err)
 		}
+		return refs, nil
+	}
 
-		// For other Git servers, just try a HEAD request on the base URL
-		return c.checkEndpoint(ctx, ensureGitExtension(repoURL))
+	if config.IsLocalURL(repoURL) {
+		// Workflows for self-hosted runners may name a path that only
+		// exists on the runner
+		path := strings.TrimPrefix(repoURL, "file://")
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			c.log.Info("Local repository not found on this machine, it must exist where the sync runs", "path", path)
+			return nil, nil
+		}
+		refs, err := c.lsRemote(ctx, cfg, repoURL, "")
+		if err != nil {
+			return nil, err
+		}
+		return refs, nil
 	}
 
-	// For SSH URLs, we can't easily validate, so just check the format
-	if strings.HasPrefix(repoURL, "git@") || strings.HasPrefix(repoURL, "ssh://") {
+	if config.IsSSHURL(repoURL) {
 		// Basic validation for SSH URLs
 		if !strings.Contains(repoURL, ":") && !strings.Contains(repoURL, "/") {
-			return fmt.Errorf("invalid SSH URL format")
+			return nil, fmt.Errorf("invalid SSH URL format")
 		}
-		c.log.Debug("SSH URL provided, cannot fully validate accessibility", "url", repoURL)
-		return nil
+		if !cfg.ValidateSSH {
+			c.log.Debug("SSH URL provided, use --validate-ssh to check accessibility", "url", repoURL)
+			return nil, nil
+		}
+		refs, err := c.lsRemote(ctx, cfg, repoURL, "")
+		if err != nil {
+			return nil, sshError(err)
+		}
+		return refs, nil
 	}
 
-	return fmt.Errorf("unsupported repository URL scheme")
+	return nil, fmt.Errorf("unsupported repository URL scheme")
 }
 
-// checkEndpoint makes a HEAD request to check if an endpoint is accessible.
-func (c *Client) checkEndpoint(ctx context.Context, url string) error {
-	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+// lsRemote lists the refs advertised by a repository with go-git, mapping
+// ref names to object IDs, and symbolic refs like HEAD to the IDs of their
+// targets. The transport options are those of Sync, see remoteOptions.
+func (c *Client) lsRemote(ctx context.Context, cfg *config.Config, repoURL, token string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	opts, err := c.remoteOptions(ctx, cfg, repoURL, token)
 	if err != nil {
-		return fmt.Errorf("failed to create HTTP request: %w", err)
+		return nil, err
+	}
+	remote := gogit.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{Name: "origin", URLs: []string{repoURL}})
+	advertised, err := remote.ListContext(ctx, opts.list())
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return map[string]string{}, nil
 	}
-
-	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to access repository: %w", err)
+		return nil, redact.Error(fmt.Errorf("failed to access repository: %w", err))
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("repository returned error status: %s", resp.Status)
+	refs := make(map[string]string)
+	for _, ref := range advertised {
+		if ref.Type() == plumbing.HashReference {
+			refs[ref.Name().String()] = ref.Hash().String()
+		}
+	}
+	for _, ref := range advertised {
+		if target, ok := refs[ref.Target().String()]; ok && ref.Type() == plumbing.SymbolicReference {
+			refs[ref.Name().String()] = target
+		}
 	}
 
-	return nil
+	c.log.Debug("Listed repository refs", "url", repoURL, "refs", len(refs))
+	return refs, nil
+}
+
+// tlsOrigin returns the scheme and host of an HTTPS repository URL outside
+// GitHub, or an empty string for any other URL.
+func tlsOrigin(repoURL string) string {
+	parsed, err := url.Parse(repoURL)
+	if err != nil || parsed.Scheme != "https" || config.IsGitHubURL(repoURL) {
+		return ""
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+// sshError explains common SSH failures reported by go-git.
+func sshError(err error) error {
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "knownhosts"), strings.Contains(msg, "known_hosts"):
+		return fmt.Errorf("SSH host key is unknown or has changed, add it to known_hosts first: %w", err)
+	case strings.Contains(msg, "unable to authenticate"):
+		return fmt.Errorf("SSH authentication failed, check the agent or --ssh-key: %w", err)
+	case strings.Contains(msg, "no such host"),
+		strings.Contains(msg, "connection refused"),
+		strings.Contains(msg, "i/o timeout"),
+		strings.Contains(msg, "network is unreachable"):
+		return fmt.Errorf("SSH host is unreachable: %w", err)
+	}
+	return err
+}
+
+// credentialError explains HTTPS authentication failures reported by go-git.
+func credentialError(err error) error {
+	if errors.Is(err, transport.ErrAuthenticationRequired) || errors.Is(err, transport.ErrAuthorizationFailed) {
+		return config.WithKind(config.ErrAuthFailed, fmt.Errorf("repository requires credentials, store them with a git credential helper or choose one with --credential-helper: %w", err))
+	}
+	return err
+}
+
+// branchNames returns the sorted branch names among refs.
+func branchNames(refs map[string]string) []string {
+	var names []string
+	for ref := range refs {
+		if name, ok := strings.CutPrefix(ref, "refs/heads/"); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mirrorPath returns the host and path of a mirror repository URL without
+// the .git suffix, e.g. codeberg.org/owner/repo. It is used to lay out the
+// working copies of the local sync engine.
+func mirrorPath(repoURL string) (string, error) {
+	if config.IsGitHubURL(repoURL) {
+		owner, repo, err := parseGitHubURL(repoURL)
+		if err != nil {
+			return "", err
+		}
+		return path.Join(owner, repo), nil
+	}
+
+	// scp-like syntax, e.g. git@codeberg.org:owner/repo.git
+	if !strings.Contains(repoURL, "://") {
+		userHost, repoPath, ok := strings.Cut(repoURL, ":")
+		if !ok {
+			return "", fmt.Errorf("unsupported repository URL format")
+		}
+		_, host, _ := strings.Cut(userHost, "@")
+		if host == "" {
+			host = userHost
+		}
+		repoURL = "ssh://" + host + "/" + repoPath
+	}
+
+	parsed, err := url.Parse(repoURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	repoPath := strings.Trim(strings.TrimSuffix(parsed.Path, ".git"), "/")
+	if parsed.Hostname() == "" || repoPath == "" || strings.Contains(repoPath, "..") {
+		return "", fmt.Errorf("invalid repository path: %s", repoURL)
+	}
+
+	return path.Join(parsed.Hostname(), repoPath), nil
 }
 
 // parseGitHubURL extracts the owner and repository from a GitHub URL.
@@ -138,3 +388,55 @@ func ensureGitExtension(repoURL string) string {
 	}
 	return repoURL
 }
+
+// UpdateRemoteURL points every remote of the git repository in the current
+// directory that refers to oldURL at newURL instead. SSH remotes keep using SSH.
+// It returns the names of the updated remotes.
+func (c *Client) UpdateRemoteURL(ctx context.Context, oldURL, newURL string) ([]string, error) {
+	binary, err := config.LookGit(c.gitPath)
+	if err != nil {
+		return nil, err
+	}
+	output, err := exec.CommandContext(ctx, binary, "remote", "-v").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list git remotes: %w", err)
+	}
+
+	oldOwner, oldRepo, err := parseGitHubURL(oldURL)
+	if err != nil {
+		return nil, err
+	}
+	newOwner, newRepo, err := parseGitHubURL(newURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		parts := strings.Fields(line)
+		if len(parts) < 2 || seen[parts[0]] {
+			continue
+		}
+		name, remoteURL := parts[0], parts[1]
+
+		owner, repo, err := parseGitHubURL(remoteURL)
+		if err != nil || !strings.EqualFold(owner, oldOwner) || !strings.EqualFold(repo, oldRepo) {
+			continue
+		}
+		seen[name] = true
+
+		target := ensureGitExtension(strings.TrimSuffix(newURL, ".git"))
+		if strings.HasPrefix(remoteURL, "git@github.com:") {
+			target = "git@github.com:" + newOwner + "/" + newRepo + ".git"
+		}
+
+		if err := exec.CommandContext(ctx, binary, "remote", "set-url", name, target).Run(); err != nil {
+			return updated, fmt.Errorf("failed to update remote %s: %w", name, err)
+		}
+		c.log.Info("Updated git remote to renamed mirror", "remote", name, "url", target)
+		updated = append(updated, name)
+	}
+
+	return updated, nil
+}
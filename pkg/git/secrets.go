@@ -0,0 +1,45 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+)
+
+// ErrSecretsFound is returned by Sync when cfg.ScanSecrets is set and
+// gitleaks finds credentials in primary commits that are not on the mirror.
+var ErrSecretsFound = errors.New("secrets found")
+
+// scanSecrets runs gitleaks on the commits of primaryRef that are not on the
+// mirror yet, the commits the sync would publish, as the generated workflow
+// does. Findings are redacted, since the output may end up in public logs.
+// Once pushed, secrets cannot be taken back from the mirror, so the sync is
+// refused when any are found.
+func (c *Client) scanSecrets(ctx context.Context, dir string, env []string, primaryRef string) error {
+	binary, err := exec.LookPath("gitleaks")
+	if err != nil {
+		return fmt.Errorf("gitleaks is required to scan for secrets: %w", err)
+	}
+
+	c.log.Debug("Scanning new primary commits for secrets", "ref", primaryRef)
+	cmd := exec.CommandContext(ctx, binary, "git", "--no-banner", "--redact", "--verbose",
+		"--log-opts="+primaryRef+" --not --remotes=origin", dir)
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+
+	// gitleaks exits with 1 when it finds leaks
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		c.log.Warn("Secrets found in primary commits", "findings", strings.TrimSpace(string(output)))
+		return fmt.Errorf("%w in primary commits that are not on the mirror yet, refusing to sync; see the gitleaks findings", ErrSecretsFound)
+	case err != nil:
+		return redact.Error(fmt.Errorf("gitleaks failed: %w: %s", err, strings.TrimSpace(string(output))))
+	}
+	c.log.Trace("gitleaks output", "output", string(output))
+	return nil
+}
@@ -0,0 +1,226 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+// Errors of the smart HTTP probe, see probeSmartHTTP.
+var (
+	// errNotRepository is returned when the server has no repository at the URL
+	errNotRepository = config.WithKind(config.ErrRepoNotFound, errors.New("not a git repository"))
+
+	// errAuthRequired is returned when the server requires credentials,
+	// which listing the refs obtains from the credential helpers
+	errAuthRequired = config.WithKind(config.ErrAuthFailed, errors.New("repository requires authentication"))
+
+	// errProbeUnavailable is returned when the repository cannot be probed:
+	// the server only speaks the dumb HTTP protocol, or the proxy is not
+	// supported by the HTTP client
+	errProbeUnavailable = errors.New("smart HTTP probe unavailable")
+)
+
+// uploadPackAdvertisement is the content type of smart HTTP ref advertisements.
+const uploadPackAdvertisement = "application/x-git-upload-pack-advertisement"
+
+// advertisement is the result of a smart HTTP probe.
+type advertisement struct {
+	// Refs maps ref names to object IDs. It is nil when the server answered
+	// with protocol v2, which advertises capabilities instead of refs.
+	Refs map[string]string
+
+	// URL is the repository URL after redirects
+	URL string
+}
+
+// probeSmartHTTP requests the ref advertisement of an HTTP(S) repository
+// like git does when fetching, through the proxy git would use unless the
+// client has its own HTTP client, and parses its pkt-lines. Failures are
+// reported as errNotRepository, errAuthRequired or errProbeUnavailable, or
+// as network errors.
+func (c *Client) probeSmartHTTP(ctx context.Context, cfg *config.Config, repoURL string) (*advertisement, error) {
+	client := c.httpClient
+	if client == nil {
+		proxy, err := probeProxy(cfg, repoURL)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig, err := probeTLS(cfg, repoURL)
+		if err != nil {
+			return nil, err
+		}
+		client = &http.Client{Transport: &http.Transport{Proxy: proxy, TLSClientConfig: tlsConfig}}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	base := strings.TrimSuffix(repoURL, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/info/refs?service=git-upload-pack", nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository URL: %w", err)
+	}
+	// Servers such as GitHub only speak the smart protocol to git clients
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("network error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// handled below
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("%w (HTTP %s)", errAuthRequired, resp.Status)
+	case http.StatusNotFound, http.StatusGone:
+		return nil, fmt.Errorf("%w (HTTP %s)", errNotRepository, resp.Status)
+	default:
+		return nil, fmt.Errorf("unexpected response from repository server: HTTP %s", resp.Status)
+	}
+	// Dumb HTTP servers serve info/refs as a plain file, web pages are
+	// served for URLs that are no repository
+	contentType := resp.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "text/html") {
+		return nil, fmt.Errorf("%w: server answered with a web page", errNotRepository)
+	}
+	if contentType != uploadPackAdvertisement {
+		return nil, fmt.Errorf("%w: server answered with %q", errProbeUnavailable, contentType)
+	}
+
+	refs, err := parseAdvertisement(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Redirects are followed; the final request reveals where the repository lives
+	final := *resp.Request.URL
+	final.RawQuery = ""
+	final.Path = strings.TrimSuffix(final.Path, "/info/refs")
+	final.RawPath = ""
+
+	c.log.Debug("Probed repository over smart HTTP", "url", repoURL, "refs", len(refs))
+	return &advertisement{Refs: refs, URL: final.String()}, nil
+}
+
+// probeTLS returns the TLS configuration for probing repoURL, matching the
+// TLS options of remoteOptions, or nil for the defaults.
+func probeTLS(cfg *config.Config, repoURL string) (*tls.Config, error) {
+	if tlsOrigin(repoURL) == "" {
+		return nil, nil
+	}
+	switch {
+	case cfg.InsecureSkipVerify:
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	case cfg.CACert != "":
+		pool, err := config.CertPool(cfg.CACert)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{RootCAs: pool}, nil
+	}
+	return nil, nil
+}
+
+// probeProxy returns the proxy selection for probing repoURL, matching the
+// proxy remoteOptions configures for git connections.
+func probeProxy(cfg *config.Config, repoURL string) (func(*http.Request) (*url.URL, error), error) {
+	proxy := ProxyFor(cfg, repoURL)
+	if proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return http.ProxyURL(proxyURL), nil
+	}
+	return nil, fmt.Errorf("%w: %s proxies are not supported", errProbeUnavailable, proxyURL.Scheme)
+}
+
+// parseAdvertisement parses a smart HTTP ref advertisement: an optional
+// service announcement followed by a flush packet, then one pkt-line per ref
+// up to the next flush packet. The first ref carries the capabilities after a
+// NUL byte, and empty repositories advertise a capabilities^{} placeholder.
+func parseAdvertisement(r io.Reader) (map[string]string, error) {
+	br := bufio.NewReader(r)
+
+	line, err := readPktLine(br)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ref advertisement: %w", err)
+	}
+	if bytes.HasPrefix(line, []byte("# service=")) {
+		if line, err = readPktLine(br); err != nil || line != nil {
+			return nil, fmt.Errorf("invalid ref advertisement: missing flush after service announcement")
+		}
+		if line, err = readPktLine(br); err != nil {
+			return nil, fmt.Errorf("invalid ref advertisement: %w", err)
+		}
+	}
+
+	refs := make(map[string]string)
+	for ; line != nil; line, err = readPktLine(br) {
+		text := strings.TrimSuffix(string(line), "\n")
+		switch {
+		case strings.HasPrefix(text, "ERR "):
+			return nil, fmt.Errorf("repository server error: %s", strings.TrimPrefix(text, "ERR "))
+		case text == "version 2":
+			// Capabilities follow instead of refs
+			return nil, nil
+		case text == "version 1":
+			continue
+		}
+
+		text, _, _ = strings.Cut(text, "\x00")
+		oid, ref, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid ref advertisement line: %q", text)
+		}
+		if ref != "capabilities^{}" {
+			refs[ref] = oid
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid ref advertisement: %w", err)
+	}
+
+	return refs, nil
+}
+
+// readPktLine reads one pkt-line from r: a four digit hexadecimal length,
+// which includes itself, followed by the payload. Flush and other special
+// packets shorter than four bytes are returned as nil.
+func readPktLine(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length, err := strconv.ParseUint(string(header[:]), 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pkt-line length %q", header[:])
+	}
+	if length < 4 {
+		return nil, nil
+	}
+
+	payload := make([]byte, length-4)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
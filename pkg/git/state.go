@@ -0,0 +1,264 @@
+package git
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+)
+
+// stateFile is the name of the sync state database in the state directory.
+const stateFile = "state.db"
+
+// stateLockTimeout bounds the wait for another process holding the state
+// database, like run --watch while serve records a sync.
+const stateLockTimeout = 30 * time.Second
+
+// Buckets of the state database.
+var (
+	// mirrorsBucket maps mirror repository URLs to their MirrorState
+	mirrorsBucket = []byte("mirrors")
+
+	// runBucket holds the RunState of the batch in progress under runKey
+	runBucket = []byte("run")
+	runKey    = []byte("current")
+)
+
+// MirrorState records the outcome of the local syncs of one mirror.
+type MirrorState struct {
+	// PrimaryRepo and PrimaryBranch are what the mirror was last synced from
+	PrimaryRepo   string `json:"primary_repo,omitempty"`
+	PrimaryBranch string `json:"primary_branch,omitempty"`
+
+	LastAttempt time.Time `json:"last_attempt"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	SyncedSHA   string    `json:"synced_sha,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+
+	// Failures counts the consecutive failed syncs
+	Failures int `json:"failures,omitempty"`
+
+	// LastAlert is when an alert about the failures was last sent, zero
+	// when none was sent since the mirror last synced
+	LastAlert time.Time `json:"last_alert,omitzero"`
+}
+
+// SyncState is the persisted state of the local sync engine, keyed by
+// mirror repository URL. It is kept in a bbolt database in the state
+// directory, which is only held open for the duration of each transaction,
+// so that several processes, like run --watch and serve, can share it. The
+// state of each mirror is updated in a transaction of its own, from the
+// state stored rather than the one loaded, so that no process overwrites
+// what another recorded about other mirrors.
+type SyncState struct {
+	path string
+
+	// Mirrors are the states of the mirrors as loaded and updated by this
+	// process
+	Mirrors map[string]*MirrorState `json:"mirrors"`
+
+	// Run is the batch of syncs in progress, left behind when it was
+	// interrupted
+	Run *RunState `json:"run,omitempty"`
+}
+
+// RunState records a batch of syncs while it is in progress, so an
+// interrupted batch can be resumed.
+type RunState struct {
+	StartedAt time.Time `json:"started_at"`
+	Mirrors   []string  `json:"mirrors"`
+}
+
+// LoadSyncState reads the sync state kept in stateDir. An empty state is
+// returned when no sync has been recorded yet.
+func LoadSyncState(stateDir string) (*SyncState, error) {
+	state := &SyncState{
+		path:    filepath.Join(stateDir, stateFile),
+		Mirrors: make(map[string]*MirrorState),
+	}
+	if _, err := os.Stat(state.path); errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+
+	err := state.view(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(mirrorsBucket); bucket != nil {
+			err := bucket.ForEach(func(mirror, data []byte) error {
+				var ms MirrorState
+				if err := json.Unmarshal(data, &ms); err != nil {
+					return fmt.Errorf("failed to parse the state of %s: %w", mirror, err)
+				}
+				state.Mirrors[string(mirror)] = &ms
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if bucket := tx.Bucket(runBucket); bucket != nil {
+			if data := bucket.Get(runKey); data != nil {
+				state.Run = &RunState{}
+				if err := json.Unmarshal(data, state.Run); err != nil {
+					return fmt.Errorf("failed to parse the run in progress: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state %s: %w", state.path, err)
+	}
+	return state, nil
+}
+
+// Record updates the state of the mirror of cfg with the outcome of a sync
+// and stores it. The updated state is returned even when it could not be
+// stored.
+func (s *SyncState) Record(cfg *config.Config, result *SyncResult, syncErr error) (*MirrorState, error) {
+	return s.Update(cfg.MirrorRepo, func(ms *MirrorState) {
+		ms.PrimaryRepo = cfg.PrimaryRepo
+		ms.PrimaryBranch = cfg.PrimaryBranch
+		ms.LastAttempt = time.Now().UTC()
+		if syncErr != nil {
+			ms.LastError = redact.String(syncErr.Error())
+			ms.Failures++
+			return
+		}
+
+		ms.LastSuccess = ms.LastAttempt
+		ms.SyncedSHA = result.SHA
+		ms.LastError = ""
+		ms.Failures = 0
+	})
+}
+
+// Update applies fn to the stored state of mirror and stores the result, in
+// a single transaction. The updated state is returned even when it could
+// not be stored, applied to the state loaded instead.
+func (s *SyncState) Update(mirror string, fn func(*MirrorState)) (*MirrorState, error) {
+	var updated *MirrorState
+	err := s.update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(mirrorsBucket)
+		if err != nil {
+			return err
+		}
+		ms := &MirrorState{}
+		if data := bucket.Get([]byte(mirror)); data != nil {
+			if err := json.Unmarshal(data, ms); err != nil {
+				return fmt.Errorf("failed to parse the state of %s: %w", mirror, err)
+			}
+		}
+		fn(ms)
+
+		data, err := json.Marshal(ms)
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte(mirror), data); err != nil {
+			return err
+		}
+		updated = ms
+		return nil
+	})
+	if err != nil {
+		updated = s.Mirrors[mirror]
+		if updated == nil {
+			updated = &MirrorState{}
+		}
+		fn(updated)
+	}
+	s.Mirrors[mirror] = updated
+	if err != nil {
+		return updated, fmt.Errorf("failed to store the sync state of %s: %w", mirror, err)
+	}
+	return updated, nil
+}
+
+// Unchanged reports whether the mirror of cfg was last synced successfully
+// from the same primary branch when it was at head, so syncing it again
+// would push nothing new.
+func (s *SyncState) Unchanged(cfg *config.Config, head string) bool {
+	ms := s.Mirrors[cfg.MirrorRepo]
+	return ms != nil && head != "" && ms.LastError == "" && ms.SyncedSHA == head &&
+		ms.PrimaryRepo == cfg.PrimaryRepo && ms.PrimaryBranch == cfg.PrimaryBranch
+}
+
+// BeginRun records the start of a batch syncing mirrors. With resume, a
+// batch left behind by an interrupted run is continued instead, and true is
+// returned.
+func (s *SyncState) BeginRun(mirrors []string, resume bool) (bool, error) {
+	if resume && s.Run != nil {
+		return true, nil
+	}
+	s.Run = &RunState{
+		StartedAt: time.Now().UTC(),
+		Mirrors:   append([]string(nil), mirrors...),
+	}
+	data, err := json.Marshal(s.Run)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode the run in progress: %w", err)
+	}
+	err = s.update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(runBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(runKey, data)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to store the run in progress: %w", err)
+	}
+	return false, nil
+}
+
+// Done reports whether mirror was synced successfully since the batch in
+// progress started.
+func (s *SyncState) Done(mirror string) bool {
+	ms := s.Mirrors[mirror]
+	return s.Run != nil && ms != nil && ms.LastError == "" && !ms.LastSuccess.Before(s.Run.StartedAt)
+}
+
+// EndRun records that the batch in progress completed.
+func (s *SyncState) EndRun() error {
+	s.Run = nil
+	err := s.update(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(runBucket); bucket != nil {
+			return bucket.Delete(runKey)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clear the run in progress: %w", err)
+	}
+	return nil
+}
+
+// view runs fn in a read-only transaction of the state database.
+func (s *SyncState) view(fn func(*bolt.Tx) error) error {
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: stateLockTimeout, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+// update runs fn in a read-write transaction of the state database, which
+// is created when missing.
+func (s *SyncState) update(fn func(*bolt.Tx) error) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: stateLockTimeout})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
@@ -0,0 +1,604 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/events"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+)
+
+// SyncResult describes a completed mirror sync.
+type SyncResult struct {
+	// SHA is the primary commit that was synced
+	SHA string
+
+	// Updated reports whether the mirror branch was changed
+	Updated bool
+
+	// Head is the commit of the mirror branch after the sync, which differs
+	// from SHA when the primary was merged into the mirror branch
+	Head string
+
+	// Fetched is the growth of the object store of the working copy in
+	// bytes, an estimate of the data fetched that is low when it was repacked
+	Fetched int64
+}
+
+// Sync performs the mirror sync of the generated workflow locally with
+// go-git: the mirror and the primary are fetched into a working copy of the
+// mirror below cfg.WorkDir, which is created by the first sync, and the
+// primary branch is reset or merged onto the mirror branch and pushed to each
+// of cfg.MirrorBranches. With cfg.PushMirror, all refs of the primary are
+// replicated from a bare mirror clone instead, see pushMirror. The token,
+// when not empty, authenticates HTTPS pushes to GitHub. Only
+// cfg.RequireSigned and cfg.ScanSecrets run external programs, git with gpg
+// or ssh-keygen and gitleaks.
+func (c *Client) Sync(ctx context.Context, cfg *config.Config, token string) (*SyncResult, error) {
+	repoPath, err := mirrorPath(cfg.MirrorRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mirror repository URL: %w", err)
+	}
+	dir := filepath.Join(cfg.WorkDir, filepath.FromSlash(repoPath))
+
+	if cfg.PushMirror {
+		return c.pushMirror(ctx, cfg, dir+".git", token)
+	}
+
+	objects := objectBytes(dir)
+	wc, err := c.prepareWorkingCopy(ctx, cfg, dir, token)
+	if err != nil {
+		return nil, err
+	}
+	fetched := max(objectBytes(dir)-objects, 0)
+
+	primaryRef := plumbing.NewRemoteReferenceName("primary", cfg.PrimaryBranch)
+	mirrorRef := plumbing.NewRemoteReferenceName("origin", cfg.MirrorBranch)
+	primary, err := wc.Reference(primaryRef, true)
+	if err != nil {
+		return nil, config.WithKind(config.ErrBranchMissing, fmt.Errorf("primary branch %s not found in primary repository", cfg.PrimaryBranch))
+	}
+	if cfg.RequireSigned {
+		if err := c.verifySignatures(ctx, cfg, dir, primaryRef.String()); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ScanSecrets {
+		if err := c.scanSecrets(ctx, dir, gitEnv(cfg), primaryRef.String()); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ForceSync && cfg.DetectsRewrites() {
+		if err := c.checkRewrite(ctx, cfg, wc, primary.Hash(), mirrorRef); err != nil {
+			return nil, err
+		}
+	}
+	var before plumbing.Hash
+	if ref, err := wc.Reference(mirrorRef, true); err == nil {
+		before = ref.Hash()
+	}
+
+	after := primary.Hash()
+	if cfg.ForceSync || before.IsZero() {
+		// Force-apply all changes from primary, overriding any conflicts
+		c.log.Debug("Resetting mirror branch to primary", "branch", cfg.MirrorBranch)
+	} else {
+		c.log.Debug("Merging primary into mirror branch", "branch", cfg.MirrorBranch)
+		if after, err = c.merge(cfg, wc, before, primary.Hash()); err != nil {
+			return nil, err
+		}
+	}
+	branchRef := plumbing.NewBranchReferenceName(cfg.MirrorBranch)
+	if err := wc.Storer.SetReference(plumbing.NewHashReference(branchRef, after)); err != nil {
+		return nil, fmt.Errorf("failed to update mirror branch %s: %w", cfg.MirrorBranch, err)
+	}
+
+	// Push changes back to each mirror branch that is not up to date
+	var targets, refspecs []string
+	for _, branch := range cfg.MirrorBranches {
+		if ref, err := wc.Reference(plumbing.NewRemoteReferenceName("origin", branch), true); err == nil && ref.Hash() == after {
+			continue
+		}
+		target := cfg.MirrorBranch + ":" + branch
+		refspec := branchRef.String() + ":" + plumbing.NewBranchReferenceName(branch).String()
+		if cfg.ForceSync {
+			target, refspec = "+"+target, "+"+refspec
+		}
+		targets, refspecs = append(targets, target), append(refspecs, refspec)
+	}
+	if len(refspecs) > 0 {
+		if err := c.push(ctx, cfg, wc.Repository, wc.origin.push("origin", refspecs...), strings.Join(targets, " ")); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.ForceSync && cfg.DetectsRewrites() {
+		// Record the synced commit on the mirror for the next rewrite check
+		refspec := "+" + branchRef.String() + ":" + syncedRef(cfg.MirrorBranch)
+		target := "+" + cfg.MirrorBranch + ":" + syncedRef(cfg.MirrorBranch)
+		if err := c.push(ctx, cfg, wc.Repository, wc.origin.push("origin", refspec), target); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.pushAdditionalRefs(ctx, cfg, wc); err != nil {
+		return nil, err
+	}
+
+	sha := primary.Hash().String()
+	c.log.Info("Synced primary commit", "sha", sha, "mirror_repo", cfg.MirrorRepo, "updated", after != before)
+	return &SyncResult{SHA: sha, Updated: after != before, Head: after.String(), Fetched: fetched}, nil
+}
+
+// merge merges the primary commit into the mirror commit and returns the
+// commit of the result, which is one of them when the other is its
+// ancestor. Otherwise a merge commit is made, taking the primary's version
+// of the files whose changes conflict, see mergeTrees. Histories without a common
+// commit, such as of a filtered mirror, are not merged.
+func (c *Client) merge(cfg *config.Config, wc *workingCopy, mirror, primary plumbing.Hash) (plumbing.Hash, error) {
+	ours, err := wc.CommitObject(mirror)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	theirs, err := wc.CommitObject(primary)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if upToDate, err := theirs.IsAncestor(ours); err != nil || upToDate {
+		return mirror, err
+	}
+	if fastForward, err := ours.IsAncestor(theirs); err != nil || fastForward {
+		return primary, err
+	}
+
+	bases, err := ours.MergeBase(theirs)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if len(bases) == 0 {
+		return plumbing.ZeroHash, fmt.Errorf("mirror branch %s shares no history with primary branch %s, refusing to merge unrelated histories", cfg.MirrorBranch, cfg.PrimaryBranch)
+	}
+	var files [3]map[string]object.TreeEntry
+	for i, commit := range []*object.Commit{bases[0], ours, theirs} {
+		tree, err := commit.Tree()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if files[i], err = treeFiles(tree); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	merged, conflicts, err := mergeTrees(wc.Storer, files[0], files[1], files[2])
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	message := "Merge remote-tracking branch 'primary/" + cfg.PrimaryBranch + "'\n"
+	if conflicts {
+		// If both changed the same files, prefer the primary repository's changes
+		c.log.Warn("Merge conflict detected, preferring primary repository's changes", "branch", cfg.MirrorBranch)
+		message = "Merge primary repository, preferring primary changes in conflicts\n"
+	}
+	tree, err := writeTree(wc.Storer, merged)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to write merged tree: %w", err)
+	}
+	sig := signature()
+	return storeObject(wc.Storer, &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      message,
+		TreeHash:     tree,
+		ParentHashes: []plumbing.Hash{mirror, primary},
+	})
+}
+
+// objectBytes returns the size of the object store of the repository in dir,
+// a working copy or a bare repository, or 0 when it does not exist yet.
+func objectBytes(dir string) int64 {
+	objects := filepath.Join(dir, gogit.GitDirName, "objects")
+	if _, err := os.Stat(objects); err != nil {
+		objects = filepath.Join(dir, "objects")
+	}
+
+	var size int64
+	_ = filepath.WalkDir(objects, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// syncedRef returns the ref on the mirror recording the primary commit that
+// was last synced to branch, shared with the generated workflow.
+func syncedRef(branch string) string {
+	return "refs/gh-mirror/synced/" + branch
+}
+
+// ErrUnsigned is returned by Sync when --require-signed is set and a primary
+// commit is not signed by an allowed signer.
+var ErrUnsigned = errors.New("not signed by an allowed signer")
+
+// RewriteError reports that the primary branch no longer contains the commit
+// synced last, and that the rewrite policy refused to overwrite the mirror.
+type RewriteError struct {
+	// Branch is the primary branch that was rewritten
+	Branch string
+
+	// Synced is the commit synced last, Head the current primary commit
+	Synced string
+	Head   string
+}
+
+// Error implements error.
+func (e *RewriteError) Error() string {
+	return fmt.Sprintf("primary branch %s was rewritten and no longer contains the last synced commit %s, refusing to overwrite the mirror", e.Branch, e.Synced)
+}
+
+// checkRewrite applies the rewrite policy when the primary commit no longer
+// contains the commit recorded on the mirror by the previous sync: a
+// *RewriteError is returned, or with the backup policy the mirror branch at
+// mirrorRef is kept in a backup branch before it is overwritten.
+func (c *Client) checkRewrite(ctx context.Context, cfg *config.Config, wc *workingCopy, primary plumbing.Hash, mirrorRef plumbing.ReferenceName) error {
+	const lastSynced = "refs/gh-mirror/synced"
+
+	// Mirrors synced before the policy was enabled have no record yet
+	if err := fetch(ctx, wc.Repository, wc.origin.fetch("origin", "+"+syncedRef(cfg.MirrorBranch)+":"+lastSynced)); err != nil {
+		c.log.Debug("No synced commit recorded on the mirror", "branch", cfg.MirrorBranch, "error", err)
+		return nil
+	}
+
+	ref, err := wc.Reference(lastSynced, true)
+	if err != nil {
+		return err
+	}
+	synced, err := wc.CommitObject(ref.Hash())
+	if err != nil {
+		return err
+	}
+	head, err := wc.CommitObject(primary)
+	if err != nil {
+		return err
+	}
+	if contained, err := synced.IsAncestor(head); err != nil || contained {
+		return err
+	}
+	c.log.Warn("Primary branch history was rewritten", "branch", cfg.PrimaryBranch, "synced", synced.Hash.String(), "head", head.Hash.String(), "policy", cfg.RewritePolicy)
+
+	if cfg.RewritePolicy != config.RewritePolicyBackup {
+		return &RewriteError{Branch: cfg.PrimaryBranch, Synced: synced.Hash.String(), Head: head.Hash.String()}
+	}
+
+	backup := "gh-mirror/backup/" + cfg.MirrorBranch + "-" + time.Now().UTC().Format("20060102150405")
+	refspec := mirrorRef.String() + ":refs/heads/" + backup
+	if err := c.push(ctx, cfg, wc.Repository, wc.origin.push("origin", refspec), refspec); err != nil {
+		return fmt.Errorf("failed to back up mirror branch %s: %w", cfg.MirrorBranch, err)
+	}
+	c.log.Info("Kept the previous mirror history in a backup branch", "branch", backup)
+	return nil
+}
+
+// verifySignatures checks that every commit of primaryRef that is not on
+// the mirror yet is signed by one of the allowed SSH signers or GPG keys,
+// which go-git cannot verify, so git runs in the working copy in dir. git
+// and gpg use a temporary keyring holding only the GPG keys of
+// cfg.SigningKeysFile, if any, so neither the keys trusted by the user's
+// keyring nor those of an earlier verification are trusted.
+func (c *Client) verifySignatures(ctx context.Context, cfg *config.Config, dir string, primaryRef string) error {
+	gnupgHome, err := os.MkdirTemp("", "gh-mirror-gnupg-")
+	if err != nil {
+		return fmt.Errorf("failed to create GPG keyring: %w", err)
+	}
+	defer os.RemoveAll(gnupgHome)
+	env := append(gitEnv(cfg), "GNUPGHOME="+gnupgHome)
+
+	if cfg.SigningKeysFile != "" {
+		cmd := exec.CommandContext(ctx, "gpg", "--batch", "--quiet", "--import", cfg.SigningKeysFile)
+		cmd.Env = env
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to import signing keys: %w: %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	commits, err := c.git(ctx, dir, env, "rev-list", primaryRef, "--not", "--remotes=origin")
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, commit := range strings.Fields(commits) {
+		if _, err := c.git(ctx, dir, env, "verify-commit", commit); err != nil {
+			return fmt.Errorf("commit %s is %w, refusing to sync: %w", commit, ErrUnsigned, err)
+		}
+		count++
+	}
+
+	c.log.Debug("Verified primary commit signatures", "commits", count)
+	return nil
+}
+
+// pushMirror replicates all refs of the primary to the mirror like git push
+// --mirror, deleting mirror refs that are gone from the primary. The primary
+// is kept as a bare mirror clone in dir, which is updated on later syncs.
+func (c *Client) pushMirror(ctx context.Context, cfg *config.Config, dir, token string) (*SyncResult, error) {
+	objects := objectBytes(dir)
+	repo, err := gogit.PlainOpen(dir)
+	if errors.Is(err, gogit.ErrRepositoryNotExists) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create working directory: %w", err)
+		}
+		c.log.Info("Cloning primary repository", "url", cfg.PrimaryRepo, "dir", dir)
+		repo, err = gogit.PlainInit(dir, true)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mirror clone %s: %w", dir, err)
+	}
+
+	mirrorURL := ensureGitExtension(cfg.MirrorRepo)
+	if err := setRemote(repo, "origin", cfg.PrimaryRepo, "+refs/*:refs/*"); err != nil {
+		return nil, err
+	}
+	if err := setRemote(repo, "mirror", mirrorURL); err != nil {
+		return nil, err
+	}
+	primaryOpts, err := c.remoteOptions(ctx, cfg, cfg.PrimaryRepo, "")
+	if err != nil {
+		return nil, err
+	}
+	mirrorOpts, err := c.remoteOptions(ctx, cfg, mirrorURL, token)
+	if err != nil {
+		return nil, err
+	}
+
+	c.log.Debug("Fetching primary repository", "url", cfg.PrimaryRepo)
+	if err := fetch(ctx, repo, primaryOpts.fetch("origin")); err != nil {
+		return nil, credentialError(redact.Error(fmt.Errorf("failed to fetch primary repository: %w", err)))
+	}
+	fetched := max(objectBytes(dir)-objects, 0)
+
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(cfg.PrimaryBranch), true)
+	if err != nil {
+		return nil, config.WithKind(config.ErrBranchMissing, fmt.Errorf("primary branch %s not found in primary repository", cfg.PrimaryBranch))
+	}
+	sha := ref.Hash().String()
+
+	// go-git prunes every ref with a forced refspec, so the refs gone from
+	// the primary are deleted explicitly instead
+	refspecs := []string{"+refs/*:refs/*"}
+	remote, err := repo.Remote("mirror")
+	if err != nil {
+		return nil, err
+	}
+	mirrorRefs, err := remote.ListContext(ctx, mirrorOpts.list())
+	if err != nil && !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil, credentialError(redact.Error(fmt.Errorf("failed to list mirror repository refs: %w", err)))
+	}
+	for _, ref := range mirrorRefs {
+		if ref.Type() != plumbing.HashReference || !strings.HasPrefix(ref.Name().String(), "refs/") {
+			continue
+		}
+		if _, err := repo.Reference(ref.Name(), false); errors.Is(err, plumbing.ErrReferenceNotFound) {
+			refspecs = append(refspecs, ":"+ref.Name().String())
+		}
+	}
+
+	err = repo.PushContext(ctx, mirrorOpts.push("mirror", refspecs...))
+	updated := !errors.Is(err, gogit.NoErrAlreadyUpToDate)
+	if !updated {
+		err = nil
+	}
+	err = redact.Error(err)
+	c.recordPush(cfg, "--mirror", err)
+	if err != nil {
+		return nil, credentialError(fmt.Errorf("failed to push to the mirror: %w", err))
+	}
+
+	c.log.Info("Synced primary commit", "sha", sha, "mirror_repo", cfg.MirrorRepo, "updated", updated)
+	return &SyncResult{SHA: sha, Updated: updated, Head: sha, Fetched: fetched}, nil
+}
+
+// workingCopy is the working copy of a mirror, with the transport options
+// of its origin remote, the mirror, and its primary remote.
+type workingCopy struct {
+	*gogit.Repository
+
+	origin, primary *remoteOptions
+}
+
+// openWorkingCopy opens the working copy of the mirror in dir, or with create
+// initializes it when missing, and points its origin and primary remotes at
+// the mirror and primary repositories of cfg. The token, when not empty,
+// authenticates with a mirror on GitHub.
+func (c *Client) openWorkingCopy(ctx context.Context, cfg *config.Config, dir, token string, create bool) (*workingCopy, error) {
+	mirrorURL := ensureGitExtension(cfg.MirrorRepo)
+
+	repo, err := gogit.PlainOpen(dir)
+	if errors.Is(err, gogit.ErrRepositoryNotExists) && create {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create working directory: %w", err)
+		}
+		c.log.Info("Cloning mirror repository", "url", mirrorURL, "dir", dir)
+		repo, err = gogit.PlainInit(dir, false)
+	}
+	if errors.Is(err, gogit.ErrRepositoryNotExists) {
+		return nil, fmt.Errorf("no working copy of the mirror in %s", dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open working copy %s: %w", dir, err)
+	}
+
+	if err := setRemote(repo, "origin", mirrorURL, "+refs/heads/*:refs/remotes/origin/*"); err != nil {
+		return nil, err
+	}
+	if err := setRemote(repo, "primary", cfg.PrimaryRepo, "+refs/heads/*:refs/remotes/primary/*"); err != nil {
+		return nil, err
+	}
+	wc := &workingCopy{Repository: repo}
+	if wc.origin, err = c.remoteOptions(ctx, cfg, mirrorURL, token); err != nil {
+		return nil, err
+	}
+	if wc.primary, err = c.remoteOptions(ctx, cfg, cfg.PrimaryRepo, ""); err != nil {
+		return nil, err
+	}
+	return wc, nil
+}
+
+// prepareWorkingCopy opens the working copy of the mirror in dir, created by
+// the first sync, and fetches the branches of the mirror and the primary.
+func (c *Client) prepareWorkingCopy(ctx context.Context, cfg *config.Config, dir, token string) (*workingCopy, error) {
+	wc, err := c.openWorkingCopy(ctx, cfg, dir, token, true)
+	if err != nil {
+		return nil, err
+	}
+
+	c.log.Debug("Fetching mirror repository", "url", cfg.MirrorRepo)
+	if err := fetch(ctx, wc.Repository, wc.origin.fetch("origin")); err != nil {
+		return nil, credentialError(redact.Error(fmt.Errorf("failed to fetch mirror repository: %w", err)))
+	}
+	c.log.Debug("Fetching primary repository", "url", cfg.PrimaryRepo)
+	if err := fetch(ctx, wc.Repository, wc.primary.fetch("primary")); err != nil {
+		return nil, credentialError(redact.Error(fmt.Errorf("failed to fetch primary repository: %w", err)))
+	}
+	return wc, nil
+}
+
+// setRemote adds the remote name fetching url with the refspecs fetch, or
+// points it at url when it exists.
+func setRemote(repo *gogit.Repository, name, url string, fetch ...string) error {
+	cfg, err := repo.Config()
+	if err != nil {
+		return fmt.Errorf("failed to read repository configuration: %w", err)
+	}
+	cfg.Remotes[name] = &gitconfig.RemoteConfig{Name: name, URLs: []string{url}, Fetch: toRefSpecs(fetch)}
+	if err := repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("failed to configure remote %s: %w", name, err)
+	}
+	return nil
+}
+
+// fetch fetches from a remote of repo with opts. Remotes without new objects
+// and empty remotes, like a mirror that was just created, are no error.
+func fetch(ctx context.Context, repo *gogit.Repository, opts *gogit.FetchOptions) error {
+	err := repo.FetchContext(ctx, opts)
+	if errors.Is(err, gogit.NoErrAlreadyUpToDate) || errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil
+	}
+	return err
+}
+
+// pushAdditionalRefs mirrors git notes and the configured refspecs.
+func (c *Client) pushAdditionalRefs(ctx context.Context, cfg *config.Config, wc *workingCopy) error {
+	refspecs := cfg.Refspecs
+	if cfg.SyncNotes {
+		refspecs = append([]config.Refspec{{Source: "refs/notes/*", Destination: "refs/notes/*"}}, refspecs...)
+	}
+
+	for _, refspec := range refspecs {
+		if err := fetch(ctx, wc.Repository, wc.primary.fetch("primary", "+"+refspec.Source+":"+refspec.Destination)); err != nil {
+			return redact.Error(fmt.Errorf("failed to fetch %s from primary repository: %w", refspec.Source, err))
+		}
+		push := refspec.Destination + ":" + refspec.Destination
+		if refspec.Force || cfg.ForceSync {
+			push = "+" + push
+		}
+		if err := c.push(ctx, cfg, wc.Repository, wc.origin.push("origin", push), push); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// push pushes to a remote of repo with opts and records the push of target,
+// the refs pushed, in the audit log and, once done, the events file of cfg.
+// Refs that are up to date already are no error.
+func (c *Client) push(ctx context.Context, cfg *config.Config, repo *gogit.Repository, opts *gogit.PushOptions, target string) error {
+	err := repo.PushContext(ctx, opts)
+	if errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		err = nil
+	}
+	if err != nil {
+		err = credentialError(redact.Error(fmt.Errorf("failed to push %s: %w", target, err)))
+	}
+	c.recordPush(cfg, target, err)
+	if err == nil {
+		event := events.Event{Type: events.TypePushed, Repo: cfg.MirrorRepo, Primary: cfg.PrimaryRepo, Ref: target}
+		if eventErr := events.Emit(cfg.EventsFile, event); eventErr != nil {
+			c.log.Warn("Could not write event", "event", event.Type, "error", eventErr)
+		}
+	}
+	return err
+}
+
+// recordPush records a push of target to the mirror of cfg in its audit log.
+// Pushes to GitHub use the GitHub credentials; other mirrors are pushed to
+// with the credentials of the credential helpers, which are not known.
+func (c *Client) recordPush(cfg *config.Config, target string, err error) {
+	entry := audit.Entry{Operation: audit.OpPush, Repo: cfg.MirrorRepo, Target: target}
+	if config.IsGitHubURL(cfg.MirrorRepo) {
+		entry.Credential = audit.Credential(cfg.GithubToken, cfg.AppID)
+	}
+	if auditErr := audit.Record(cfg.AuditLog, entry, err); auditErr != nil {
+		c.log.Warn("Could not record push in the audit log", "error", auditErr)
+	}
+}
+
+// git runs a git command in dir and returns its trimmed standard output.
+// The error includes the output of git when the command fails.
+func (c *Client) git(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	c.log.Debug("Running git", "args", args, "dir", dir)
+
+	binary, err := config.LookGit(c.gitPath)
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", redact.Error(fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String())))
+	}
+	c.log.Trace("git output", "command", args[0], "stdout", string(output), "stderr", stderr.String())
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// gitEnv returns the environment of the programs inspecting the working copy
+// of a mirror, git verifying signatures and gitleaks scanning for secrets.
+// The allowed SSH signers are passed through the environment, and prompts
+// are disabled.
+func gitEnv(cfg *config.Config) []string {
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if cfg.AllowedSignersFile != "" {
+		// Git runs in the working copy, so relative paths would not resolve
+		signers, err := filepath.Abs(cfg.AllowedSignersFile)
+		if err != nil {
+			signers = cfg.AllowedSignersFile
+		}
+		env = append(env, "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=gpg.ssh.allowedSignersFile", "GIT_CONFIG_VALUE_0="+signers)
+	}
+	return env
+}
@@ -0,0 +1,138 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// GitHubAPI is the part of the GitHub API that Client uses, grouped into
+// services like in go-github, whose services implement them. NewClient talks
+// to GitHub; NewClientWithAPI takes another implementation, such as the
+// in-memory fake of package githubtest.
+type GitHubAPI interface {
+	Repositories() RepositoriesAPI
+	Git() GitAPI
+	Actions() ActionsAPI
+	Issues() IssuesAPI
+	PullRequests() PullRequestsAPI
+	Users() UsersAPI
+
+	// BaseURL returns the URL of the REST API, ending in a slash
+	BaseURL() string
+
+	// GraphQL runs a GraphQL query and decodes the response body, with its
+	// data and errors, into v
+	GraphQL(ctx context.Context, query string, variables map[string]interface{}, v interface{}) (*github.Response, error)
+}
+
+// RepositoriesAPI covers repository metadata, contents, deploy keys,
+// environments, branch protection and commit statuses.
+type RepositoriesAPI interface {
+	Get(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error)
+	Create(ctx context.Context, org string, repo *github.Repository) (*github.Repository, *github.Response, error)
+	Edit(ctx context.Context, owner, repo string, repository *github.Repository) (*github.Repository, *github.Response, error)
+	ReplaceAllTopics(ctx context.Context, owner, repo string, topics []string) ([]string, *github.Response, error)
+	ListByOrg(ctx context.Context, org string, opts *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error)
+
+	GetContents(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentGetOptions) (*github.RepositoryContent, []*github.RepositoryContent, *github.Response, error)
+	GetReadme(ctx context.Context, owner, repo string, opts *github.RepositoryContentGetOptions) (*github.RepositoryContent, *github.Response, error)
+	CreateFile(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentFileOptions) (*github.RepositoryContentResponse, *github.Response, error)
+	DeleteFile(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentFileOptions) (*github.RepositoryContentResponse, *github.Response, error)
+
+	ListKeys(ctx context.Context, owner string, repo string, opts *github.ListOptions) ([]*github.Key, *github.Response, error)
+	CreateKey(ctx context.Context, owner string, repo string, key *github.Key) (*github.Key, *github.Response, error)
+	DeleteKey(ctx context.Context, owner string, repo string, id int64) (*github.Response, error)
+
+	GetEnvironment(ctx context.Context, owner, repo, name string) (*github.Environment, *github.Response, error)
+	CreateUpdateEnvironment(ctx context.Context, owner, repo, name string, environment *github.CreateUpdateEnvironment) (*github.Environment, *github.Response, error)
+
+	GetBranchProtection(ctx context.Context, owner, repo, branch string) (*github.Protection, *github.Response, error)
+	UpdatePullRequestReviewEnforcement(ctx context.Context, owner, repo, branch string, patch *github.PullRequestReviewsEnforcementUpdate) (*github.PullRequestReviewsEnforcement, *github.Response, error)
+	AddAppRestrictions(ctx context.Context, owner, repo, branch string, apps []string) ([]*github.App, *github.Response, error)
+
+	ListStatuses(ctx context.Context, owner, repo, ref string, opts *github.ListOptions) ([]*github.RepoStatus, *github.Response, error)
+	CreateStatus(ctx context.Context, owner, repo, ref string, status *github.RepoStatus) (*github.RepoStatus, *github.Response, error)
+}
+
+// GitAPI covers the Git data API, used to commit several files at once.
+type GitAPI interface {
+	GetRef(ctx context.Context, owner string, repo string, ref string) (*github.Reference, *github.Response, error)
+	CreateRef(ctx context.Context, owner string, repo string, ref *github.Reference) (*github.Reference, *github.Response, error)
+	UpdateRef(ctx context.Context, owner string, repo string, ref *github.Reference, force bool) (*github.Reference, *github.Response, error)
+	GetCommit(ctx context.Context, owner string, repo string, sha string) (*github.Commit, *github.Response, error)
+	CreateCommit(ctx context.Context, owner string, repo string, commit *github.Commit, opts *github.CreateCommitOptions) (*github.Commit, *github.Response, error)
+	CreateTree(ctx context.Context, owner string, repo string, baseTree string, entries []*github.TreeEntry) (*github.Tree, *github.Response, error)
+}
+
+// ActionsAPI covers workflow runs and Actions secrets.
+type ActionsAPI interface {
+	ListWorkflowRunsByFileName(ctx context.Context, owner, repo, workflowFileName string, opts *github.ListWorkflowRunsOptions) (*github.WorkflowRuns, *github.Response, error)
+	GetWorkflowRunByID(ctx context.Context, owner, repo string, runID int64) (*github.WorkflowRun, *github.Response, error)
+	ListWorkflowJobs(ctx context.Context, owner, repo string, runID int64, opts *github.ListWorkflowJobsOptions) (*github.Jobs, *github.Response, error)
+	GetWorkflowJobLogs(ctx context.Context, owner, repo string, jobID int64, maxRedirects int) (*url.URL, *github.Response, error)
+	CreateWorkflowDispatchEventByFileName(ctx context.Context, owner, repo, workflowFileName string, event github.CreateWorkflowDispatchEventRequest) (*github.Response, error)
+
+	GetRepoPublicKey(ctx context.Context, owner, repo string) (*github.PublicKey, *github.Response, error)
+	GetRepoSecret(ctx context.Context, owner, repo, name string) (*github.Secret, *github.Response, error)
+	CreateOrUpdateRepoSecret(ctx context.Context, owner, repo string, eSecret *github.EncryptedSecret) (*github.Response, error)
+	DeleteRepoSecret(ctx context.Context, owner, repo, name string) (*github.Response, error)
+
+	GetEnvPublicKey(ctx context.Context, repoID int, env string) (*github.PublicKey, *github.Response, error)
+	GetEnvSecret(ctx context.Context, repoID int, env, secretName string) (*github.Secret, *github.Response, error)
+	CreateOrUpdateEnvSecret(ctx context.Context, repoID int, env string, eSecret *github.EncryptedSecret) (*github.Response, error)
+	DeleteEnvSecret(ctx context.Context, repoID int, env, secretName string) (*github.Response, error)
+}
+
+// IssuesAPI covers the issues opened about rewritten primaries.
+type IssuesAPI interface {
+	ListByRepo(ctx context.Context, owner string, repo string, opts *github.IssueListByRepoOptions) ([]*github.Issue, *github.Response, error)
+	Create(ctx context.Context, owner string, repo string, issue *github.IssueRequest) (*github.Issue, *github.Response, error)
+}
+
+// PullRequestsAPI covers the pull requests proposing the workflow.
+type PullRequestsAPI interface {
+	List(ctx context.Context, owner string, repo string, opts *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error)
+	Create(ctx context.Context, owner string, repo string, pull *github.NewPullRequest) (*github.PullRequest, *github.Response, error)
+}
+
+// UsersAPI covers looking up the authenticated user.
+type UsersAPI interface {
+	Get(ctx context.Context, user string) (*github.User, *github.Response, error)
+}
+
+// restAPI is the GitHubAPI of a go-github client.
+type restAPI struct {
+	client *github.Client
+}
+
+// NewAPI returns the GitHubAPI of a go-github client.
+func NewAPI(client *github.Client) GitHubAPI {
+	return restAPI{client: client}
+}
+
+func (a restAPI) Repositories() RepositoriesAPI { return a.client.Repositories }
+func (a restAPI) Git() GitAPI                   { return a.client.Git }
+func (a restAPI) Actions() ActionsAPI           { return a.client.Actions }
+func (a restAPI) Issues() IssuesAPI             { return a.client.Issues }
+func (a restAPI) PullRequests() PullRequestsAPI { return a.client.PullRequests }
+func (a restAPI) Users() UsersAPI               { return a.client.Users }
+func (a restAPI) BaseURL() string               { return a.client.BaseURL.String() }
+
+// GraphQL implements GitHubAPI.
+func (a restAPI) GraphQL(ctx context.Context, query string, variables map[string]interface{}, v interface{}) (*github.Response, error) {
+	// GitHub Enterprise Server serves GraphQL at /api/graphql, beside the
+	// REST API at /api/v3/
+	endpoint := "graphql"
+	if strings.HasSuffix(a.client.BaseURL.Path, "/api/v3/") {
+		endpoint = "../graphql"
+	}
+	req, err := a.client.NewRequest("POST", endpoint, &graphqlRequest{Query: query, Variables: variables})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build GraphQL request: %w", err)
+	}
+	return a.client.Do(ctx, req, v)
+}
@@ -0,0 +1,163 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// appJWTLifetime is the validity of app JWTs; GitHub allows at most ten minutes.
+	appJWTLifetime = 9 * time.Minute
+	// appClockSkew backdates JWTs to tolerate clock drift between us and GitHub.
+	appClockSkew = 60 * time.Second
+)
+
+// appJWTSource mints JSON Web Tokens identifying a GitHub App.
+type appJWTSource struct {
+	appID int64
+	key   *rsa.PrivateKey
+}
+
+// Token implements oauth2.TokenSource.
+func (s *appJWTSource) Token() (*oauth2.Token, error) {
+	now := time.Now()
+	expiry := now.Add(appJWTLifetime)
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return nil, err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-appClockSkew).Unix(),
+		"exp": expiry.Unix(),
+		"iss": strconv.FormatInt(s.appID, 10),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign app JWT: %w", err)
+	}
+
+	return &oauth2.Token{
+		AccessToken: signingInput + "." + base64.RawURLEncoding.EncodeToString(signature),
+		TokenType:   "Bearer",
+		Expiry:      expiry,
+	}, nil
+}
+
+// installationTokenSource mints installation access tokens for a GitHub App installation.
+type installationTokenSource struct {
+	ctx            context.Context
+	appClient      *github.Client
+	installationID int64
+
+	mu          sync.Mutex
+	permissions *github.InstallationPermissions
+}
+
+// Token implements oauth2.TokenSource.
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	token, _, err := s.appClient.Apps.CreateInstallationToken(s.ctx, s.installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create installation token: %w", err)
+	}
+
+	s.mu.Lock()
+	s.permissions = token.Permissions
+	s.mu.Unlock()
+
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		TokenType:   "token",
+		Expiry:      token.GetExpiresAt().Time,
+	}, nil
+}
+
+// Permissions returns the permissions granted to the most recently minted token.
+func (s *installationTokenSource) Permissions() *github.InstallationPermissions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.permissions
+}
+
+// newAppTokenSource creates a token source authenticating as a GitHub App installation.
+// When installationID is zero, the installation for owner/repo, or for the
+// owner organization when repo is empty, is looked up.
+func newAppTokenSource(ctx context.Context, appID, installationID int64, keyPath, owner, repo string, o *options) (*installationTokenSource, error) {
+	key, err := loadAppPrivateKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	jwtSource := oauth2.ReuseTokenSource(nil, &appJWTSource{appID: appID, key: key})
+	appClient, err := o.newGitHubClient(oauth2.NewClient(ctx, jwtSource))
+	if err != nil {
+		return nil, err
+	}
+
+	if installationID == 0 {
+		var installation *github.Installation
+		if repo == "" {
+			installation, _, err = appClient.Apps.FindOrganizationInstallation(ctx, owner)
+		} else {
+			installation, _, err = appClient.Apps.FindRepositoryInstallation(ctx, owner, repo)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to find app installation for %s: %w", path.Join(owner, repo), err)
+		}
+		installationID = installation.GetID()
+	}
+
+	return &installationTokenSource{
+		ctx:            ctx,
+		appClient:      appClient,
+		installationID: installationID,
+	}, nil
+}
+
+// loadAppPrivateKey reads a PEM encoded RSA private key from path.
+func loadAppPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read app private key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("app private key %s is not PEM encoded", path)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse app private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("app private key must be an RSA key")
+	}
+	return key, nil
+}
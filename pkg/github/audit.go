@@ -0,0 +1,25 @@
+package github
+
+import (
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+)
+
+// audit records a change made to the mirror repository, or the failure to
+// make it, in the audit log of the configuration. The change itself is not
+// undone when the log cannot be written.
+func (c *Client) audit(operation, target, detail string, err error) {
+	repo := c.owner
+	if c.repo != "" {
+		repo += "/" + c.repo
+	}
+	entry := audit.Entry{
+		Operation:  operation,
+		Repo:       repo,
+		Target:     target,
+		Detail:     detail,
+		Credential: audit.Credential(c.cfg.GithubToken, c.cfg.AppID),
+	}
+	if auditErr := audit.Record(c.cfg.AuditLog, entry, err); auditErr != nil {
+		c.log.Warn("Could not record change in the audit log", "operation", operation, "error", auditErr)
+	}
+}
@@ -0,0 +1,147 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+)
+
+const (
+	bannerStart = "<!-- gh-mirror:banner:start -->"
+	bannerEnd   = "<!-- gh-mirror:banner:end -->"
+)
+
+// bannerReadme returns the mirror README with the mirror notice inserted or
+// refreshed, or nil when the README is already up to date or cannot carry
+// the notice.
+func (c *Client) bannerReadme(ctx context.Context, ref string) (*fileChange, error) {
+	var readme *github.RepositoryContent
+	err := c.withRetry(ctx, "get README", func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		readme, resp, err = c.api.Repositories().GetReadme(ctx, c.owner, c.repo, &github.RepositoryContentGetOptions{Ref: ref})
+		return resp, err
+	})
+
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+		return &fileChange{Path: "README.md", Content: c.banner()}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get README: %w", err)
+	}
+
+	// HTML comment markers only work in Markdown
+	switch strings.ToLower(path.Ext(readme.GetPath())) {
+	case ".md", ".markdown", "":
+	default:
+		c.log.Warn("README is not Markdown, skipping mirror notice", "path", readme.GetPath())
+		return nil, nil
+	}
+
+	content, err := readme.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode README: %w", err)
+	}
+
+	updated := InsertBanner(content, c.banner())
+	if updated == content {
+		c.log.Debug("README mirror notice is up to date", "path", readme.GetPath())
+		return nil, nil
+	}
+
+	return &fileChange{Path: readme.GetPath(), Content: updated, Previous: content}, nil
+}
+
+// banner returns the mirror notice, including its markers.
+func (c *Client) banner() string {
+	primary := WebURL(c.cfg.PrimaryRepo)
+	if primary == "" {
+		// The primary is not published, so there is nowhere to point to
+		return bannerStart + "\n" +
+			"> **Note**\n" +
+			"> This repository is a read-only mirror, changes made here are overwritten by the next sync.\n" +
+			bannerEnd + "\n"
+	}
+	return bannerStart + "\n" +
+		"> **Note**\n" +
+		"> This repository is a read-only mirror of [" + primary + "](" + primary + ").\n" +
+		"> Please open issues and pull requests there instead.\n" +
+		bannerEnd + "\n"
+}
+
+// InsertBanner replaces the marked notice in content with banner, or prepends
+// banner when content has no notice yet.
+func InsertBanner(content, banner string) string {
+	start := strings.Index(content, bannerStart)
+	end := strings.Index(content, bannerEnd)
+	if start >= 0 && end > start {
+		rest := strings.TrimPrefix(content[end+len(bannerEnd):], "\n")
+		return content[:start] + banner + rest
+	}
+	return banner + "\n" + content
+}
+
+// RemoveBanner removes the marked notice, as inserted by InsertBanner, from
+// content.
+func RemoveBanner(content string) string {
+	start := strings.Index(content, bannerStart)
+	end := strings.Index(content, bannerEnd)
+	if start < 0 || end < start {
+		return content
+	}
+	rest := strings.TrimPrefix(content[end+len(bannerEnd):], "\n")
+	if start == 0 {
+		// InsertBanner separates a prepended notice with a blank line
+		rest = strings.TrimPrefix(rest, "\n")
+	}
+	return content[:start] + rest
+}
+
+// RemoveReadmeBanner removes the mirror notice from the mirror README, and
+// deletes the README when it only held the notice. It reports whether the
+// README had a notice.
+func (c *Client) RemoveReadmeBanner(ctx context.Context) (bool, error) {
+	var readme *github.RepositoryContent
+	err := c.withRetry(ctx, "get README", func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		readme, resp, err = c.api.Repositories().GetReadme(ctx, c.owner, c.repo, nil)
+		return resp, err
+	})
+
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get README: %w", err)
+	}
+
+	content, err := readme.GetContent()
+	if err != nil {
+		return false, fmt.Errorf("failed to decode README: %w", err)
+	}
+	updated := RemoveBanner(content)
+	if updated == content {
+		return false, nil
+	}
+
+	const message = "Remove mirror notice"
+	if strings.TrimSpace(updated) == "" {
+		err = c.deleteFile(ctx, readme.GetPath(), readme.GetSHA(), message)
+	} else {
+		_, err = c.putFile(ctx, "", message, fileChange{Path: readme.GetPath(), Content: updated, Previous: content})
+	}
+	if err != nil {
+		return false, err
+	}
+
+	c.log.Info("Removed mirror notice", "owner", c.owner, "repo", c.repo, "path", readme.GetPath())
+	return true, nil
+}
@@ -0,0 +1,150 @@
+package github
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+)
+
+// cacheablePath matches API paths for repository metadata and file contents.
+var cacheablePath = regexp.MustCompile(`^/repos/[^/]+/[^/]+(/contents/.*|/readme)?$`)
+
+// cacheEntry is a cached API response stored on disk.
+type cacheEntry struct {
+	ETag   string      `json:"etag"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// etagTransport caches GET responses for repository metadata and contents on
+// disk and revalidates them with conditional requests. GitHub does not count
+// 304 Not Modified responses against the rate limit.
+type etagTransport struct {
+	base http.RoundTripper
+	dir  string
+	// namespace separates cache entries of different credentials
+	namespace string
+	log       *logger.Logger
+}
+
+// newETagTransport wraps base with an on-disk ETag cache in dir.
+func newETagTransport(base http.RoundTripper, dir, namespace string, log *logger.Logger) *etagTransport {
+	return &etagTransport{base: base, dir: dir, namespace: namespace, log: log}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *etagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || !cacheablePath.MatchString(req.URL.Path) {
+		return t.base.RoundTrip(req)
+	}
+
+	path := t.entryPath(req)
+	entry := t.load(path)
+	if entry != nil {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		t.log.Debug("GitHub API cache hit", "url", req.URL.String())
+		resp.Body.Close()
+		return entry.response(req, resp), nil
+	}
+
+	if resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "" {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		t.store(path, &cacheEntry{
+			ETag:   resp.Header.Get("ETag"),
+			Status: resp.StatusCode,
+			Header: resp.Header.Clone(),
+			Body:   body,
+		})
+	}
+
+	return resp, nil
+}
+
+// entryPath returns the cache file for a request.
+func (t *etagTransport) entryPath(req *http.Request) string {
+	sum := sha256.Sum256([]byte(t.namespace + "\x00" + req.Header.Get("Accept") + "\x00" + req.URL.String()))
+	return filepath.Join(t.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load reads a cache entry, returning nil when it is missing or unreadable.
+func (t *etagTransport) load(path string) *cacheEntry {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.ETag == "" {
+		return nil
+	}
+	return &entry
+}
+
+// store writes a cache entry. Failures only disable caching for the entry.
+func (t *etagTransport) store(path string, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = os.MkdirAll(t.dir, 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0600)
+	}
+	if err != nil {
+		t.log.Debug("Failed to write GitHub API cache entry", "error", err)
+	}
+}
+
+// response rebuilds a cached response, keeping the fresh rate limit headers
+// of the 304 response.
+func (e *cacheEntry) response(req *http.Request, notModified *http.Response) *http.Response {
+	header := e.Header.Clone()
+	for key, values := range notModified.Header {
+		header[key] = values
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// cacheNamespace derives a cache namespace from the configured credentials,
+// so that responses visible to one token are never served to another.
+func cacheNamespace(token string, appID, installationID int64) string {
+	key := "token:" + token
+	if appID != 0 {
+		key = fmt.Sprintf("app:%d:%d", appID, installationID)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
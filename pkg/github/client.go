@@ -3,6 +3,7 @@ package github
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -11,8 +12,10 @@ import (
 	"github.com/google/go-github/v61/github"
 	"golang.org/x/oauth2"
 
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
 	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
 	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/provenance"
 )
 
 const (
@@ -21,93 +24,381 @@ const (
 
 // Client provides GitHub API functionality.
 type Client struct {
-	client *github.Client
-	log    *logger.Logger
-	cfg    *config.Config
-	owner  string
-	repo   string
+	api   GitHubAPI
+	log   *logger.Logger
+	cfg   *config.Config
+	owner string
+	repo  string
+
+	// appTokens is set when authenticating as a GitHub App installation
+	appTokens *installationTokenSource
+}
+
+// NewClient creates a new GitHub API client for the configured mirror
+// repository. Its requests are customized with opts.
+func NewClient(ctx context.Context, cfg *config.Config, log *logger.Logger, opts ...Option) (*Client, error) {
+	// Parse owner and repo from mirror URL
+	owner, repo, err := parseGitHubURL(cfg.MirrorRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub repository URL: %w", err)
+	}
+
+	return newClient(ctx, cfg, log, owner, repo, newOptions(opts))
 }
 
-// NewClient creates a new GitHub API client.
-func NewClient(ctx context.Context, cfg *config.Config, log *logger.Logger) (*Client, error) {
+// NewClientWithAPI creates a client for the configured mirror repository that
+// makes its requests through api, such as the fake of package githubtest,
+// instead of to GitHub. Git pushes use the configured token.
+func NewClientWithAPI(cfg *config.Config, log *logger.Logger, api GitHubAPI) (*Client, error) {
+	owner, repo, err := parseGitHubURL(cfg.MirrorRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub repository URL: %w", err)
+	}
+
+	return &Client{
+		api:   kindAPI{api},
+		log:   log,
+		cfg:   cfg,
+		owner: owner,
+		repo:  repo,
+	}, nil
+}
+
+// NewOrgClient creates a GitHub API client for operations on an organization
+// rather than on the configured mirror repository. Its requests are
+// customized with opts.
+func NewOrgClient(ctx context.Context, cfg *config.Config, log *logger.Logger, org string, opts ...Option) (*Client, error) {
+	return newClient(ctx, cfg, log, org, "", newOptions(opts))
+}
+
+// newClient creates a GitHub API client bound to owner and, unless empty, repo.
+func newClient(ctx context.Context, cfg *config.Config, log *logger.Logger, owner, repo string, o *options) (*Client, error) {
 	var httpClient *http.Client
+	var appTokens *installationTokenSource
+	var err error
+
+	// Authenticated clients send their requests with the client in ctx
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, o.httpClient)
 
-	// Create authenticated client if token is available
-	if cfg.GithubToken != "" {
+	// Prefer GitHub App authentication, then a token, then anonymous access
+	switch {
+	case cfg.AppID != 0:
+		appTokens, err = newAppTokenSource(ctx, cfg.AppID, cfg.AppInstallationID, cfg.AppPrivateKey, owner, repo, o)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up GitHub App authentication: %w", err)
+		}
+		httpClient = oauth2.NewClient(ctx, oauth2.ReuseTokenSource(nil, appTokens))
+		log.Debug("Created GitHub App authenticated GitHub client", "app_id", cfg.AppID, "installation_id", appTokens.installationID)
+	case cfg.GithubToken != "":
 		ts := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: cfg.GithubToken},
 		)
 		httpClient = oauth2.NewClient(ctx, ts)
 		log.Debug("Created authenticated GitHub client")
-	} else {
-		httpClient = http.DefaultClient
+	default:
+		httpClient = o.httpClient
 		log.Debug("Created unauthenticated GitHub client")
 	}
 
-	// Create GitHub client
-	client := github.NewClient(httpClient)
+	// Retry requests rejected by rate limits instead of aborting
+	var transport http.RoundTripper = newRateLimitTransport(o.wrapTransport(httpClient.Transport), log, cfg.RateLimitWait)
 
-	// Parse owner and repo from mirror URL
-	owner, repo, err := parseGitHubURL(cfg.MirrorRepo)
+	// Revalidate cached metadata and contents instead of refetching them
+	if cfg.CacheDir != "" {
+		installationID := cfg.AppInstallationID
+		if appTokens != nil {
+			installationID = appTokens.installationID
+		}
+		namespace := cacheNamespace(cfg.GithubToken, cfg.AppID, installationID)
+		transport = newETagTransport(transport, cfg.CacheDir, namespace, log)
+	}
+	httpClient = &http.Client{Transport: transport, Timeout: o.timeout}
+
+	// Create GitHub client
+	client, err := o.newGitHubClient(httpClient)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse GitHub repository URL: %w", err)
+		return nil, err
 	}
 
 	return &Client{
-		client: client,
-		log:    log,
-		cfg:    cfg,
-		owner:  owner,
-		repo:   repo,
+		api:   kindAPI{NewAPI(client)},
+		log:   log,
+		cfg:   cfg,
+		owner: owner,
+		repo:  repo,
+
+		appTokens: appTokens,
 	}, nil
 }
 
-// SetupWorkflow creates or updates the workflow file in the repository.
-func (c *Client) SetupWorkflow(ctx context.Context, workflowContent string) error {
+// RepositoryExists reports whether the mirror repository exists.
+func (c *Client) RepositoryExists(ctx context.Context) (bool, error) {
+	_, resp, err := c.api.Repositories().Get(ctx, c.owner, c.repo)
+	if err == nil {
+		return true, nil
+	}
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to look up mirror repository: %w", err)
+}
+
+// EnsureRepository checks that the mirror repository exists and creates it
+// when it is missing and creation was requested in the configuration.
+func (c *Client) EnsureRepository(ctx context.Context) error {
+	repository, resp, err := c.api.Repositories().Get(ctx, c.owner, c.repo)
+	if err == nil {
+		c.followRename(repository)
+		c.log.Debug("Mirror repository exists", "owner", c.owner, "repo", c.repo)
+		return nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to look up mirror repository: %w", err)
+	}
+	if !c.cfg.CreateMissing {
+		return config.WithKind(config.ErrRepoNotFound,
+			fmt.Errorf("mirror repository %s/%s does not exist (use --create-missing to create it)", c.owner, c.repo))
+	}
+
+	// Repositories of organizations are created in the organization, and
+	// those of the authenticated user without one. The owner is looked up
+	// by name first, since app installation tokens may not look up the
+	// authenticated user.
+	org := c.owner
+	owner, _, err := c.api.Users().Get(ctx, c.owner)
+	if err != nil {
+		return fmt.Errorf("failed to look up mirror owner %s: %w", c.owner, err)
+	}
+	if owner.GetType() != "Organization" {
+		user, _, err := c.api.Users().Get(ctx, "")
+		if err != nil {
+			return fmt.Errorf("failed to look up authenticated user: %w", err)
+		}
+		if !strings.EqualFold(user.GetLogin(), c.owner) {
+			return fmt.Errorf("mirror owner %s is neither an organization nor the authenticated user %s, so the repository cannot be created", c.owner, user.GetLogin())
+		}
+		org = ""
+	}
+
+	description := "Mirror of " + c.cfg.PrimaryRepo
+	_, _, err = c.api.Repositories().Create(ctx, org, &github.Repository{
+		Name:        &c.repo,
+		Description: &description,
+		Private:     &c.cfg.PrivateMirror,
+	})
+	c.audit(audit.OpCreateRepository, "", "", err)
+	if err != nil {
+		return fmt.Errorf("failed to create mirror repository: %w", err)
+	}
+
+	c.log.Info("Created mirror repository", "owner", c.owner, "repo", c.repo, "private", c.cfg.PrivateMirror)
+	return nil
+}
+
+// SetupWorkflow creates or updates the workflow file in the repository and
+// returns the SHA of the commit made. No commit is made, and an empty SHA is
+// returned, when the installed workflow is already up to date.
+func (c *Client) SetupWorkflow(ctx context.Context, workflowContent string) (string, error) {
 	c.log.Info("Setting up workflow in repository", "owner", c.owner, "repo", c.repo, "path", workflowPath)
 
+	sha, err := c.putWorkflow(ctx, workflowContent, "")
+	if err != nil {
+		return "", err
+	}
+
+	if sha != "" {
+		c.log.Info("Workflow file successfully created/updated", "sha", sha)
+	} else {
+		c.log.Info("Workflow file is up to date, nothing to commit")
+	}
+	return sha, nil
+}
+
+// PlannedFile is a file that SetupWorkflow would write to the mirror repository.
+type PlannedFile struct {
+	Path     string `json:"path"`
+	Previous string `json:"previous,omitempty"`
+	Content  string `json:"content"`
+}
+
+// WorkflowPlan describes the commit SetupWorkflow would make.
+type WorkflowPlan struct {
+	Message string        `json:"message"`
+	Files   []PlannedFile `json:"files,omitempty"`
+}
+
+// PlanWorkflow returns the commit SetupWorkflow would make without writing
+// anything. The plan has no files when the mirror is already up to date.
+func (c *Client) PlanWorkflow(ctx context.Context, workflowContent string) (*WorkflowPlan, error) {
+	commitMsg, files, err := c.workflowChanges(ctx, workflowContent, "")
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &WorkflowPlan{Message: commitMsg}
+	for _, file := range files {
+		plan.Files = append(plan.Files, PlannedFile{Path: file.Path, Previous: file.Previous, Content: file.Content})
+	}
+	return plan, nil
+}
+
+// ApplyWorkflowPlan makes the commit described by plan, which PlanWorkflow
+// returned earlier, and returns its SHA. It fails without writing anything
+// when a planned file changed on the mirror since the plan was made.
+func (c *Client) ApplyWorkflowPlan(ctx context.Context, plan *WorkflowPlan) (string, error) {
+	if len(plan.Files) == 0 {
+		return "", nil
+	}
+
+	files := make([]fileChange, 0, len(plan.Files))
+	for _, file := range plan.Files {
+		current, err := c.getFile(ctx, file.Path, "")
+		if err != nil {
+			return "", fmt.Errorf("failed to check for existing %s: %w", file.Path, err)
+		}
+		content := ""
+		if current != nil {
+			if content, err = current.GetContent(); err != nil {
+				return "", fmt.Errorf("failed to decode existing %s: %w", file.Path, err)
+			}
+		}
+		if content != file.Previous {
+			return "", fmt.Errorf("%s changed on the mirror since the plan was made, plan again", file.Path)
+		}
+		files = append(files, fileChange{Path: file.Path, Content: file.Content, Previous: file.Previous})
+	}
+
+	sha, err := c.commitFiles(ctx, "", plan.Message, files)
+	if err != nil {
+		return "", fmt.Errorf("failed to create/update workflow file: %w", err)
+	}
+	c.log.Info("Planned files committed", "owner", c.owner, "repo", c.repo, "sha", sha)
+	return sha, nil
+}
+
+// putWorkflow creates or updates the workflow file on branch, or on the
+// default branch when branch is empty. The README mirror notice is committed
+// along with it when enabled. Files whose content is unchanged are skipped;
+// the SHA of the commit is returned, or an empty string when nothing was
+// committed.
+func (c *Client) putWorkflow(ctx context.Context, workflowContent, branch string) (string, error) {
+	commitMsg, files, err := c.workflowChanges(ctx, workflowContent, branch)
+	if err != nil {
+		return "", err
+	}
+
+	if len(files) == 0 {
+		return "", nil
+	}
+
+	sha, err := c.commitFiles(ctx, branch, commitMsg, files)
+	if err != nil {
+		return "", fmt.Errorf("failed to create/update workflow file: %w", err)
+	}
+
+	return sha, nil
+}
+
+// workflowChanges returns the commit message and the files that differ from
+// branch for installing the workflow.
+func (c *Client) workflowChanges(ctx context.Context, workflowContent, branch string) (string, []fileChange, error) {
 	// Check if the file already exists
-	fileContent, _, resp, err := c.client.Repositories.GetContents(
-		ctx,
-		c.owner,
-		c.repo,
-		workflowPath,
-		&github.RepositoryContentGetOptions{},
-	)
+	fileContent, err := c.getFile(ctx, workflowPath, branch)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to check for existing workflow file: %w", err)
+	}
 
 	// Create a commit message based on whether we're creating or updating
 	commitMsg := "Add repository sync workflow"
-	var sha *string
+	var files []fileChange
 
-	if err == nil && resp.StatusCode == http.StatusOK && fileContent != nil {
-		// File exists, we'll update it
+	if fileContent != nil {
 		commitMsg = "Update repository sync workflow"
-		sha = fileContent.SHA
-		c.log.Debug("Updating existing workflow file", "sha", *sha)
-	} else if resp != nil && resp.StatusCode != http.StatusNotFound {
-		// Unexpected error
-		return fmt.Errorf("failed to check for existing workflow file: %w", err)
-	}
-
-	// Create or update the file
-	_, _, err = c.client.Repositories.CreateFile(
-		ctx,
-		c.owner,
-		c.repo,
-		workflowPath,
-		&github.RepositoryContentFileOptions{
-			Message: &commitMsg,
-			Content: []byte(workflowContent),
-			SHA:     sha,
-		},
-	)
+		existing, err := fileContent.GetContent()
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to decode existing workflow file: %w", err)
+		}
+		if provenance.Equal(existing, workflowContent) {
+			c.log.Debug("Existing workflow file is identical", "sha", fileContent.GetSHA())
+		} else {
+			files = append(files, fileChange{Path: workflowPath, Content: workflowContent, Previous: existing})
+		}
+	} else {
+		files = append(files, fileChange{Path: workflowPath, Content: workflowContent})
+	}
+
+	if c.cfg.ReadmeBanner {
+		readme, err := c.bannerReadme(ctx, branch)
+		if err != nil {
+			return "", nil, err
+		}
+		if readme != nil {
+			if len(files) == 0 {
+				commitMsg = "Update mirror notice"
+			} else {
+				commitMsg += " and mirror notice"
+			}
+			files = append(files, *readme)
+		}
+	}
 
+	return commitMsg, files, nil
+}
+
+// GetWorkflow returns the content of the workflow file currently installed in the
+// repository. The boolean result reports whether the file exists.
+func (c *Client) GetWorkflow(ctx context.Context) (string, bool, error) {
+	fileContent, err := c.getFile(ctx, workflowPath, "")
 	if err != nil {
-		return fmt.Errorf("failed to create/update workflow file: %w", err)
+		return "", false, fmt.Errorf("failed to fetch existing workflow file: %w", err)
+	}
+	if fileContent == nil {
+		return "", false, nil
 	}
 
-	c.log.Info("Workflow file successfully created/updated")
-	return nil
+	content, err := fileContent.GetContent()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to decode existing workflow file: %w", err)
+	}
+
+	return content, true, nil
+}
+
+// getFile fetches a file from ref in the mirror repository, or from the
+// default branch when ref is empty. A nil content and nil error are returned
+// when the file does not exist.
+func (c *Client) getFile(ctx context.Context, path, ref string) (*github.RepositoryContent, error) {
+	var fileContent *github.RepositoryContent
+	err := c.withRetry(ctx, "get "+path, func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		fileContent, _, resp, err = c.api.Repositories().GetContents(
+			ctx,
+			c.owner,
+			c.repo,
+			path,
+			&github.RepositoryContentGetOptions{Ref: ref},
+		)
+		return resp, err
+	})
+
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fileContent == nil {
+		return nil, fmt.Errorf("%s is not a file", path)
+	}
+
+	return fileContent, nil
+}
+
+// WorkflowPath returns the repository path where the workflow file is installed.
+func (c *Client) WorkflowPath() string {
+	return workflowPath
 }
 
 // parseGitHubURL extracts the owner and repository from a GitHub URL.
@@ -138,3 +429,18 @@ func parseGitHubURL(githubURL string) (string, string, error) {
 
 	return "", "", fmt.Errorf("unsupported GitHub URL format")
 }
+
+// GitToken returns a token that authorizes git pushes to the mirror
+// repository: a fresh installation token with GitHub App authentication,
+// the configured token otherwise.
+func (c *Client) GitToken() (string, error) {
+	if c.appTokens == nil {
+		return c.cfg.GithubToken, nil
+	}
+
+	token, err := c.appTokens.Token()
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
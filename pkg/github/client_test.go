@@ -0,0 +1,165 @@
+package github_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github/githubtest"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/provenance"
+)
+
+// newTestClient returns a client of the mirror go-i2p/reseed-tools in a
+// fake holding it.
+func newTestClient(t *testing.T) (*github.Client, *githubtest.Fake) {
+	t.Helper()
+	fake := githubtest.New()
+	fake.AddRepo("go-i2p", "reseed-tools")
+
+	cfg := &config.Config{
+		PrimaryRepo: "https://i2pgit.org/go-i2p/reseed-tools.git",
+		MirrorRepo:  "https://github.com/go-i2p/reseed-tools",
+	}
+	client, err := github.NewClientWithAPI(cfg, logger.New(), fake)
+	if err != nil {
+		t.Fatalf("NewClientWithAPI: %v", err)
+	}
+	return client, fake
+}
+
+// stamp returns workflowYAML with a provenance generated at generated.
+func stamp(workflowYAML string, generated time.Time) string {
+	return provenance.Stamp(workflowYAML, provenance.Provenance{Tool: "(devel)", Config: provenance.Hash([]byte("options")), Generated: generated})
+}
+
+func TestSetupWorkflow(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newTestClient(t)
+	repo := fake.Repo("go-i2p", "reseed-tools")
+	generated := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	first := stamp("name: Sync\n", generated)
+	sha, err := client.SetupWorkflow(ctx, first)
+	if err != nil {
+		t.Fatalf("SetupWorkflow: %v", err)
+	}
+	if sha == "" || repo.Head("main") != sha {
+		t.Fatalf("SetupWorkflow returned commit %q, head of main is %q", sha, repo.Head("main"))
+	}
+	if content, ok := repo.File("", client.WorkflowPath()); !ok || content != first {
+		t.Fatalf("installed workflow = %q, %v; want %q", content, ok, first)
+	}
+
+	tests := []struct {
+		name     string
+		workflow string
+		commit   bool
+	}{
+		{"unchanged", first, false},
+		{"regenerated", stamp("name: Sync\n", generated.Add(time.Hour)), false},
+		{"changed", stamp("name: Mirror\n", generated), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head := repo.Head("main")
+			installed, _ := repo.File("", client.WorkflowPath())
+
+			sha, err := client.SetupWorkflow(ctx, tt.workflow)
+			if err != nil {
+				t.Fatalf("SetupWorkflow: %v", err)
+			}
+			content, _ := repo.File("", client.WorkflowPath())
+			if !tt.commit {
+				if sha != "" || repo.Head("main") != head {
+					t.Errorf("SetupWorkflow committed %q, want no commit", sha)
+				}
+				if content != installed {
+					t.Errorf("installed workflow changed to %q", content)
+				}
+				return
+			}
+			if sha == "" || repo.Head("main") != sha {
+				t.Errorf("SetupWorkflow returned commit %q, head of main is %q", sha, repo.Head("main"))
+			}
+			if content != tt.workflow {
+				t.Errorf("installed workflow = %q, want %q", content, tt.workflow)
+			}
+		})
+	}
+}
+
+func TestSetupDeployKey(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newTestClient(t)
+	repo := fake.Repo("go-i2p", "reseed-tools")
+
+	if err := client.SetupDeployKey(ctx, "MIRROR_DEPLOY_KEY"); err != nil {
+		t.Fatalf("SetupDeployKey: %v", err)
+	}
+	if len(repo.Keys) != 1 {
+		t.Fatalf("got %d deploy keys, want 1", len(repo.Keys))
+	}
+	if _, ok := repo.Secrets["MIRROR_DEPLOY_KEY"]; !ok {
+		t.Fatal("secret MIRROR_DEPLOY_KEY not stored")
+	}
+	original := repo.Keys[0].GetID()
+
+	// A secret that cannot be stored keeps the key in use
+	if err := client.SetupDeployKey(ctx, "INVALID NAME"); err == nil {
+		t.Fatal("SetupDeployKey with an invalid secret name succeeded")
+	}
+	if len(repo.Keys) != 1 || repo.Keys[0].GetID() != original {
+		t.Fatalf("deploy keys after a failed rotation = %v, want only key %d", repo.Keys, original)
+	}
+
+	if err := client.SetupDeployKey(ctx, "MIRROR_DEPLOY_KEY"); err != nil {
+		t.Fatalf("SetupDeployKey: %v", err)
+	}
+	if len(repo.Keys) != 1 || repo.Keys[0].GetID() == original {
+		t.Fatalf("deploy keys after rotation = %v, want one new key", repo.Keys)
+	}
+}
+
+func TestEnsureRepository(t *testing.T) {
+	tests := []struct {
+		name    string
+		owner   string
+		created bool
+	}{
+		{"organization", "go-i2p", true},
+		{"authenticated user", "gh-mirror-test", true},
+		{"other user", "someone-else", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := githubtest.New()
+			fake.Organizations = []string{"go-i2p"}
+			cfg := &config.Config{
+				PrimaryRepo:   "https://i2pgit.org/go-i2p/reseed-tools.git",
+				MirrorRepo:    "https://github.com/" + tt.owner + "/reseed-tools",
+				CreateMissing: true,
+			}
+			client, err := github.NewClientWithAPI(cfg, logger.New(), fake)
+			if err != nil {
+				t.Fatalf("NewClientWithAPI: %v", err)
+			}
+
+			err = client.EnsureRepository(context.Background())
+			if tt.created && err != nil {
+				t.Fatalf("EnsureRepository: %v", err)
+			}
+			if !tt.created && err == nil {
+				t.Fatal("EnsureRepository succeeded, want an error")
+			}
+			if repo := fake.Repo(tt.owner, "reseed-tools"); (repo != nil) != tt.created {
+				t.Errorf("repository %s/reseed-tools created = %v, want %v", tt.owner, repo != nil, tt.created)
+			}
+			if !tt.created && fake.Repo(fake.Login, "reseed-tools") != nil {
+				t.Errorf("repository created for the authenticated user %s instead", fake.Login)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/events"
+)
+
+// fileChange is a file to be written to the mirror repository.
+type fileChange struct {
+	Path    string
+	Content string
+
+	// Previous is the content being replaced, empty for new files
+	Previous string
+}
+
+// commitFiles writes files to branch, or to the default branch when branch is
+// empty. Multiple files are committed atomically through the Git data API;
+// a single file, or any file in an empty repository, goes through the
+// contents API instead. The SHA of the last commit made is returned.
+func (c *Client) commitFiles(ctx context.Context, branch, message string, files []fileChange) (string, error) {
+	if len(files) == 1 {
+		return c.putFile(ctx, branch, message, files[0])
+	}
+
+	if branch == "" {
+		repository, _, err := c.api.Repositories().Get(ctx, c.owner, c.repo)
+		if err != nil {
+			return "", fmt.Errorf("failed to look up mirror repository: %w", err)
+		}
+		branch = repository.GetDefaultBranch()
+	}
+
+	ref, _, err := c.api.Git().GetRef(ctx, c.owner, c.repo, "refs/heads/"+branch)
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && (errResp.Response.StatusCode == http.StatusNotFound || errResp.Response.StatusCode == http.StatusConflict) {
+		// Empty repositories have no commit to build a tree on
+		c.log.Debug("Branch has no commits, writing files individually", "branch", branch)
+		var sha string
+		for _, file := range files {
+			if sha, err = c.putFile(ctx, branch, message, file); err != nil {
+				return "", err
+			}
+		}
+		return sha, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up branch %s: %w", branch, err)
+	}
+
+	parent, _, err := c.api.Git().GetCommit(ctx, c.owner, c.repo, ref.GetObject().GetSHA())
+	if err != nil {
+		return "", fmt.Errorf("failed to get head commit of %s: %w", branch, err)
+	}
+
+	entries := make([]*github.TreeEntry, 0, len(files))
+	for _, file := range files {
+		entries = append(entries, &github.TreeEntry{
+			Path:    github.String(file.Path),
+			Mode:    github.String("100644"),
+			Type:    github.String("blob"),
+			Content: github.String(file.Content),
+		})
+	}
+	tree, _, err := c.api.Git().CreateTree(ctx, c.owner, c.repo, parent.GetTree().GetSHA(), entries)
+	if err != nil {
+		return "", fmt.Errorf("failed to create tree: %w", err)
+	}
+
+	commit, _, err := c.api.Git().CreateCommit(ctx, c.owner, c.repo, &github.Commit{
+		Message: &message,
+		Tree:    tree,
+		Parents: []*github.Commit{{SHA: parent.SHA}},
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create commit: %w", err)
+	}
+
+	ref.Object.SHA = commit.SHA
+	_, _, err = c.api.Git().UpdateRef(ctx, c.owner, c.repo, ref, false)
+	c.audit(audit.OpCommit, strings.Join(paths(files), ", "), branch+" "+commit.GetSHA(), err)
+	if err != nil {
+		return "", fmt.Errorf("failed to update branch %s: %w", branch, err)
+	}
+	c.emit(events.Event{Type: events.TypeCommitCreated, Path: strings.Join(paths(files), ", "), Ref: branch, Commit: commit.GetSHA()})
+
+	c.log.Debug("Committed files", "branch", branch, "sha", commit.GetSHA(), "files", len(files))
+	return commit.GetSHA(), nil
+}
+
+// paths returns the paths of files.
+func paths(files []fileChange) []string {
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Path)
+	}
+	return names
+}
+
+// putFile creates or updates a single file through the contents API and
+// returns the SHA of the commit made.
+func (c *Client) putFile(ctx context.Context, branch, message string, file fileChange) (string, error) {
+	existing, err := c.getFile(ctx, file.Path, branch)
+	if err != nil {
+		return "", fmt.Errorf("failed to check for existing %s: %w", file.Path, err)
+	}
+
+	opts := &github.RepositoryContentFileOptions{
+		Message: &message,
+		Content: []byte(file.Content),
+	}
+	if existing != nil {
+		opts.SHA = existing.SHA
+		c.log.Debug("Updating existing file", "path", file.Path, "sha", existing.GetSHA())
+	}
+	if branch != "" {
+		opts.Branch = &branch
+	}
+
+	var content *github.RepositoryContentResponse
+	err = c.withRetry(ctx, "create/update "+file.Path, func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		content, resp, err = c.api.Repositories().CreateFile(ctx, c.owner, c.repo, file.Path, opts)
+		return resp, err
+	})
+	detail := branch
+	if err == nil {
+		detail = strings.TrimSpace(branch + " " + content.Commit.GetSHA())
+	}
+	c.audit(audit.OpCommit, file.Path, detail, err)
+	if err != nil {
+		return "", fmt.Errorf("failed to create/update %s: %w", file.Path, err)
+	}
+	c.emit(events.Event{Type: events.TypeCommitCreated, Path: file.Path, Ref: branch, Commit: content.Commit.GetSHA()})
+
+	return content.Commit.GetSHA(), nil
+}
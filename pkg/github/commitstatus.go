@@ -0,0 +1,51 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v61/github"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+// EnsureCommitStatus marks the mirror commit sha with a successful commit
+// status of context config.CommitStatusContext, unless the commit already
+// has one. The status links to targetURL when it is not empty. It reports
+// whether a status was created.
+func (c *Client) EnsureCommitStatus(ctx context.Context, sha, description, targetURL string) (bool, error) {
+	var statuses []*github.RepoStatus
+	err := c.withRetry(ctx, "list commit statuses", func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		statuses, resp, err = c.api.Repositories().ListStatuses(ctx, c.owner, c.repo, sha, &github.ListOptions{PerPage: 100})
+		return resp, err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list commit statuses: %w", err)
+	}
+	for _, status := range statuses {
+		if status.GetContext() == config.CommitStatusContext {
+			c.log.Debug("Commit status already set", "sha", sha, "description", status.GetDescription())
+			return false, nil
+		}
+	}
+
+	status := &github.RepoStatus{
+		State:       github.String("success"),
+		Context:     github.String(config.CommitStatusContext),
+		Description: github.String(description),
+	}
+	if targetURL != "" {
+		status.TargetURL = github.String(targetURL)
+	}
+	_, _, err = c.api.Repositories().CreateStatus(ctx, c.owner, c.repo, sha, status)
+	c.audit(audit.OpSetCommitStatus, sha, description, err)
+	if err != nil {
+		return false, fmt.Errorf("failed to create commit status: %w", err)
+	}
+
+	c.log.Info("Set commit status", "sha", sha, "description", description)
+	return true, nil
+}
@@ -0,0 +1,105 @@
+package github
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+	"golang.org/x/crypto/ssh"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+)
+
+// deployKeyTitle prefixes the titles of deploy keys created by this tool.
+const deployKeyTitle = "gh-mirror sync"
+
+// SetupDeployKey generates an ed25519 keypair, registers the public key as a
+// write-enabled deploy key on the mirror and stores the private key in the
+// Actions secret secretName. Deploy keys previously created by this tool are
+// removed, so running it again rotates the key. They are only removed once
+// the new key and secret are in place, so a failure leaves the mirror with
+// the key it had.
+func (c *Client) SetupDeployKey(ctx context.Context, secretName string) error {
+	publicKey, privateKey, err := generateDeployKey()
+	if err != nil {
+		return err
+	}
+
+	title := deployKeyTitle + " (" + secretName + ")"
+	key, _, err := c.api.Repositories().CreateKey(ctx, c.owner, c.repo, &github.Key{
+		Title:    &title,
+		Key:      &publicKey,
+		ReadOnly: github.Bool(false),
+	})
+	c.audit(audit.OpAddDeployKey, title, strconv.FormatInt(key.GetID(), 10), err)
+	if err != nil {
+		return fmt.Errorf("failed to create deploy key: %w", err)
+	}
+	c.log.Info("Created deploy key", "id", key.GetID(), "title", title)
+
+	if err := c.SetSecret(ctx, secretName, privateKey); err != nil {
+		// The private key is lost, the key would only be a stale grant
+		_, deleteErr := c.api.Repositories().DeleteKey(ctx, c.owner, c.repo, key.GetID())
+		c.audit(audit.OpDeleteDeployKey, title, strconv.FormatInt(key.GetID(), 10), deleteErr)
+		if deleteErr != nil {
+			c.log.Warn("Could not delete the new deploy key", "id", key.GetID(), "error", deleteErr)
+		}
+		return err
+	}
+
+	return c.removeDeployKeys(ctx, key.GetID())
+}
+
+// RemoveDeployKeys deletes the deploy keys created by this tool.
+func (c *Client) RemoveDeployKeys(ctx context.Context) error {
+	return c.removeDeployKeys(ctx, 0)
+}
+
+// removeDeployKeys deletes the deploy keys created by this tool, except the
+// key with the ID keep.
+func (c *Client) removeDeployKeys(ctx context.Context, keep int64) error {
+	keys, _, err := c.api.Repositories().ListKeys(ctx, c.owner, c.repo, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return fmt.Errorf("failed to list deploy keys: %w", err)
+	}
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key.GetTitle(), deployKeyTitle) || key.GetID() == keep {
+			continue
+		}
+		_, err := c.api.Repositories().DeleteKey(ctx, c.owner, c.repo, key.GetID())
+		c.audit(audit.OpDeleteDeployKey, key.GetTitle(), strconv.FormatInt(key.GetID(), 10), err)
+		if err != nil {
+			return fmt.Errorf("failed to delete deploy key %d: %w", key.GetID(), err)
+		}
+		c.log.Info("Removed deploy key", "id", key.GetID(), "title", key.GetTitle())
+	}
+
+	return nil
+}
+
+// generateDeployKey returns a new ed25519 keypair as an authorized_keys line
+// and an OpenSSH private key.
+func generateDeployKey() (string, string, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(private, deployKeyTitle)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublic))), string(pem.EncodeToMemory(block)), nil
+}
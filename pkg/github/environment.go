@@ -0,0 +1,38 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/v61/github"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+)
+
+// EnsureEnvironment creates the configured deployment environment on the
+// mirror unless it already exists. Existing environments, including their
+// protection rules, are left untouched.
+func (c *Client) EnsureEnvironment(ctx context.Context) error {
+	name := c.cfg.Environment
+
+	_, _, err := c.api.Repositories().GetEnvironment(ctx, c.owner, c.repo, name)
+	if err == nil {
+		c.log.Debug("Environment exists", "environment", name)
+		return nil
+	}
+	var errResp *github.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to look up environment %s: %w", name, err)
+	}
+
+	_, _, err = c.api.Repositories().CreateUpdateEnvironment(ctx, c.owner, c.repo, name, &github.CreateUpdateEnvironment{})
+	c.audit(audit.OpCreateEnvironment, name, "", err)
+	if err != nil {
+		return fmt.Errorf("failed to create environment %s: %w", name, err)
+	}
+
+	c.log.Info("Created environment, configure its protection rules in the repository settings", "environment", name)
+	return nil
+}
@@ -0,0 +1,316 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+// errorKind marks err with the kind of failure of a GitHub API request, see
+// config.WithKind. Errors of other kinds are returned unchanged.
+func errorKind(err error) error {
+	var rateErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	var errResp *github.ErrorResponse
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &rateErr), errors.As(err, &abuseErr):
+		return config.WithKind(config.ErrRateLimited, err)
+	case errors.As(err, &errResp) && errResp.Response != nil:
+		switch errResp.Response.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return config.WithKind(config.ErrAuthFailed, err)
+		case http.StatusTooManyRequests:
+			return config.WithKind(config.ErrRateLimited, err)
+		}
+	}
+	return err
+}
+
+// notFoundKind is errorKind for requests on a single resource, marking
+// errors of missing resources with kind.
+func notFoundKind(kind, err error) error {
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound {
+		return config.WithKind(kind, err)
+	}
+	return errorKind(err)
+}
+
+// kindAPI marks the errors returned by a GitHubAPI with their kind, so that
+// the errors of every request made by Client can be told apart with
+// errors.Is. Lookups of repositories and branches that do not exist fail
+// with config.ErrRepoNotFound and config.ErrBranchMissing.
+type kindAPI struct {
+	api GitHubAPI
+}
+
+func (k kindAPI) Repositories() RepositoriesAPI { return kindRepositories{k.api.Repositories()} }
+func (k kindAPI) Git() GitAPI                   { return kindGit{k.api.Git()} }
+func (k kindAPI) Actions() ActionsAPI           { return kindActions{k.api.Actions()} }
+func (k kindAPI) Issues() IssuesAPI             { return kindIssues{k.api.Issues()} }
+func (k kindAPI) PullRequests() PullRequestsAPI { return kindPullRequests{k.api.PullRequests()} }
+func (k kindAPI) Users() UsersAPI               { return kindUsers{k.api.Users()} }
+func (k kindAPI) BaseURL() string               { return k.api.BaseURL() }
+
+// GraphQL implements GitHubAPI.
+func (k kindAPI) GraphQL(ctx context.Context, query string, variables map[string]interface{}, v interface{}) (*github.Response, error) {
+	resp, err := k.api.GraphQL(ctx, query, variables, v)
+	return resp, errorKind(err)
+}
+
+// kindRepositories marks the errors of a RepositoriesAPI.
+type kindRepositories struct {
+	api RepositoriesAPI
+}
+
+func (k kindRepositories) Get(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error) {
+	v, resp, err := k.api.Get(ctx, owner, repo)
+	return v, resp, notFoundKind(config.ErrRepoNotFound, err)
+}
+
+func (k kindRepositories) Create(ctx context.Context, org string, repo *github.Repository) (*github.Repository, *github.Response, error) {
+	v, resp, err := k.api.Create(ctx, org, repo)
+	return v, resp, errorKind(err)
+}
+
+func (k kindRepositories) Edit(ctx context.Context, owner, repo string, repository *github.Repository) (*github.Repository, *github.Response, error) {
+	v, resp, err := k.api.Edit(ctx, owner, repo, repository)
+	return v, resp, errorKind(err)
+}
+
+func (k kindRepositories) ReplaceAllTopics(ctx context.Context, owner, repo string, topics []string) ([]string, *github.Response, error) {
+	v, resp, err := k.api.ReplaceAllTopics(ctx, owner, repo, topics)
+	return v, resp, errorKind(err)
+}
+
+func (k kindRepositories) ListByOrg(ctx context.Context, org string, opts *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+	v, resp, err := k.api.ListByOrg(ctx, org, opts)
+	return v, resp, errorKind(err)
+}
+
+func (k kindRepositories) GetContents(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentGetOptions) (*github.RepositoryContent, []*github.RepositoryContent, *github.Response, error) {
+	file, dir, resp, err := k.api.GetContents(ctx, owner, repo, path, opts)
+	return file, dir, resp, errorKind(err)
+}
+
+func (k kindRepositories) GetReadme(ctx context.Context, owner, repo string, opts *github.RepositoryContentGetOptions) (*github.RepositoryContent, *github.Response, error) {
+	v, resp, err := k.api.GetReadme(ctx, owner, repo, opts)
+	return v, resp, errorKind(err)
+}
+
+func (k kindRepositories) CreateFile(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentFileOptions) (*github.RepositoryContentResponse, *github.Response, error) {
+	v, resp, err := k.api.CreateFile(ctx, owner, repo, path, opts)
+	return v, resp, errorKind(err)
+}
+
+func (k kindRepositories) DeleteFile(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentFileOptions) (*github.RepositoryContentResponse, *github.Response, error) {
+	v, resp, err := k.api.DeleteFile(ctx, owner, repo, path, opts)
+	return v, resp, errorKind(err)
+}
+
+func (k kindRepositories) ListKeys(ctx context.Context, owner string, repo string, opts *github.ListOptions) ([]*github.Key, *github.Response, error) {
+	v, resp, err := k.api.ListKeys(ctx, owner, repo, opts)
+	return v, resp, errorKind(err)
+}
+
+func (k kindRepositories) CreateKey(ctx context.Context, owner string, repo string, key *github.Key) (*github.Key, *github.Response, error) {
+	v, resp, err := k.api.CreateKey(ctx, owner, repo, key)
+	return v, resp, errorKind(err)
+}
+
+func (k kindRepositories) DeleteKey(ctx context.Context, owner string, repo string, id int64) (*github.Response, error) {
+	resp, err := k.api.DeleteKey(ctx, owner, repo, id)
+	return resp, errorKind(err)
+}
+
+func (k kindRepositories) GetEnvironment(ctx context.Context, owner, repo, name string) (*github.Environment, *github.Response, error) {
+	v, resp, err := k.api.GetEnvironment(ctx, owner, repo, name)
+	return v, resp, errorKind(err)
+}
+
+func (k kindRepositories) CreateUpdateEnvironment(ctx context.Context, owner, repo, name string, environment *github.CreateUpdateEnvironment) (*github.Environment, *github.Response, error) {
+	v, resp, err := k.api.CreateUpdateEnvironment(ctx, owner, repo, name, environment)
+	return v, resp, errorKind(err)
+}
+
+func (k kindRepositories) GetBranchProtection(ctx context.Context, owner, repo, branch string) (*github.Protection, *github.Response, error) {
+	v, resp, err := k.api.GetBranchProtection(ctx, owner, repo, branch)
+	return v, resp, errorKind(err)
+}
+
+func (k kindRepositories) UpdatePullRequestReviewEnforcement(ctx context.Context, owner, repo, branch string, patch *github.PullRequestReviewsEnforcementUpdate) (*github.PullRequestReviewsEnforcement, *github.Response, error) {
+	v, resp, err := k.api.UpdatePullRequestReviewEnforcement(ctx, owner, repo, branch, patch)
+	return v, resp, errorKind(err)
+}
+
+func (k kindRepositories) AddAppRestrictions(ctx context.Context, owner, repo, branch string, apps []string) ([]*github.App, *github.Response, error) {
+	v, resp, err := k.api.AddAppRestrictions(ctx, owner, repo, branch, apps)
+	return v, resp, errorKind(err)
+}
+
+func (k kindRepositories) ListStatuses(ctx context.Context, owner, repo, ref string, opts *github.ListOptions) ([]*github.RepoStatus, *github.Response, error) {
+	v, resp, err := k.api.ListStatuses(ctx, owner, repo, ref, opts)
+	return v, resp, errorKind(err)
+}
+
+func (k kindRepositories) CreateStatus(ctx context.Context, owner, repo, ref string, status *github.RepoStatus) (*github.RepoStatus, *github.Response, error) {
+	v, resp, err := k.api.CreateStatus(ctx, owner, repo, ref, status)
+	return v, resp, errorKind(err)
+}
+
+// kindGit marks the errors of a GitAPI.
+type kindGit struct {
+	api GitAPI
+}
+
+func (k kindGit) GetRef(ctx context.Context, owner string, repo string, ref string) (*github.Reference, *github.Response, error) {
+	v, resp, err := k.api.GetRef(ctx, owner, repo, ref)
+	if strings.HasPrefix(ref, "refs/heads/") {
+		return v, resp, notFoundKind(config.ErrBranchMissing, err)
+	}
+	return v, resp, errorKind(err)
+}
+
+func (k kindGit) CreateRef(ctx context.Context, owner string, repo string, ref *github.Reference) (*github.Reference, *github.Response, error) {
+	v, resp, err := k.api.CreateRef(ctx, owner, repo, ref)
+	return v, resp, errorKind(err)
+}
+
+func (k kindGit) UpdateRef(ctx context.Context, owner string, repo string, ref *github.Reference, force bool) (*github.Reference, *github.Response, error) {
+	v, resp, err := k.api.UpdateRef(ctx, owner, repo, ref, force)
+	return v, resp, errorKind(err)
+}
+
+func (k kindGit) GetCommit(ctx context.Context, owner string, repo string, sha string) (*github.Commit, *github.Response, error) {
+	v, resp, err := k.api.GetCommit(ctx, owner, repo, sha)
+	return v, resp, errorKind(err)
+}
+
+func (k kindGit) CreateCommit(ctx context.Context, owner string, repo string, commit *github.Commit, opts *github.CreateCommitOptions) (*github.Commit, *github.Response, error) {
+	v, resp, err := k.api.CreateCommit(ctx, owner, repo, commit, opts)
+	return v, resp, errorKind(err)
+}
+
+func (k kindGit) CreateTree(ctx context.Context, owner string, repo string, baseTree string, entries []*github.TreeEntry) (*github.Tree, *github.Response, error) {
+	v, resp, err := k.api.CreateTree(ctx, owner, repo, baseTree, entries)
+	return v, resp, errorKind(err)
+}
+
+// kindActions marks the errors of a ActionsAPI.
+type kindActions struct {
+	api ActionsAPI
+}
+
+func (k kindActions) ListWorkflowRunsByFileName(ctx context.Context, owner, repo, workflowFileName string, opts *github.ListWorkflowRunsOptions) (*github.WorkflowRuns, *github.Response, error) {
+	v, resp, err := k.api.ListWorkflowRunsByFileName(ctx, owner, repo, workflowFileName, opts)
+	return v, resp, errorKind(err)
+}
+
+func (k kindActions) GetWorkflowRunByID(ctx context.Context, owner, repo string, runID int64) (*github.WorkflowRun, *github.Response, error) {
+	v, resp, err := k.api.GetWorkflowRunByID(ctx, owner, repo, runID)
+	return v, resp, errorKind(err)
+}
+
+func (k kindActions) ListWorkflowJobs(ctx context.Context, owner, repo string, runID int64, opts *github.ListWorkflowJobsOptions) (*github.Jobs, *github.Response, error) {
+	v, resp, err := k.api.ListWorkflowJobs(ctx, owner, repo, runID, opts)
+	return v, resp, errorKind(err)
+}
+
+func (k kindActions) GetWorkflowJobLogs(ctx context.Context, owner, repo string, jobID int64, maxRedirects int) (*url.URL, *github.Response, error) {
+	v, resp, err := k.api.GetWorkflowJobLogs(ctx, owner, repo, jobID, maxRedirects)
+	return v, resp, errorKind(err)
+}
+
+func (k kindActions) CreateWorkflowDispatchEventByFileName(ctx context.Context, owner, repo, workflowFileName string, event github.CreateWorkflowDispatchEventRequest) (*github.Response, error) {
+	resp, err := k.api.CreateWorkflowDispatchEventByFileName(ctx, owner, repo, workflowFileName, event)
+	return resp, errorKind(err)
+}
+
+func (k kindActions) GetRepoPublicKey(ctx context.Context, owner, repo string) (*github.PublicKey, *github.Response, error) {
+	v, resp, err := k.api.GetRepoPublicKey(ctx, owner, repo)
+	return v, resp, errorKind(err)
+}
+
+func (k kindActions) GetRepoSecret(ctx context.Context, owner, repo, name string) (*github.Secret, *github.Response, error) {
+	v, resp, err := k.api.GetRepoSecret(ctx, owner, repo, name)
+	return v, resp, errorKind(err)
+}
+
+func (k kindActions) CreateOrUpdateRepoSecret(ctx context.Context, owner, repo string, eSecret *github.EncryptedSecret) (*github.Response, error) {
+	resp, err := k.api.CreateOrUpdateRepoSecret(ctx, owner, repo, eSecret)
+	return resp, errorKind(err)
+}
+
+func (k kindActions) DeleteRepoSecret(ctx context.Context, owner, repo, name string) (*github.Response, error) {
+	resp, err := k.api.DeleteRepoSecret(ctx, owner, repo, name)
+	return resp, errorKind(err)
+}
+
+func (k kindActions) GetEnvPublicKey(ctx context.Context, repoID int, env string) (*github.PublicKey, *github.Response, error) {
+	v, resp, err := k.api.GetEnvPublicKey(ctx, repoID, env)
+	return v, resp, errorKind(err)
+}
+
+func (k kindActions) GetEnvSecret(ctx context.Context, repoID int, env, secretName string) (*github.Secret, *github.Response, error) {
+	v, resp, err := k.api.GetEnvSecret(ctx, repoID, env, secretName)
+	return v, resp, errorKind(err)
+}
+
+func (k kindActions) CreateOrUpdateEnvSecret(ctx context.Context, repoID int, env string, eSecret *github.EncryptedSecret) (*github.Response, error) {
+	resp, err := k.api.CreateOrUpdateEnvSecret(ctx, repoID, env, eSecret)
+	return resp, errorKind(err)
+}
+
+func (k kindActions) DeleteEnvSecret(ctx context.Context, repoID int, env, secretName string) (*github.Response, error) {
+	resp, err := k.api.DeleteEnvSecret(ctx, repoID, env, secretName)
+	return resp, errorKind(err)
+}
+
+// kindIssues marks the errors of a IssuesAPI.
+type kindIssues struct {
+	api IssuesAPI
+}
+
+func (k kindIssues) ListByRepo(ctx context.Context, owner string, repo string, opts *github.IssueListByRepoOptions) ([]*github.Issue, *github.Response, error) {
+	v, resp, err := k.api.ListByRepo(ctx, owner, repo, opts)
+	return v, resp, errorKind(err)
+}
+
+func (k kindIssues) Create(ctx context.Context, owner string, repo string, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	v, resp, err := k.api.Create(ctx, owner, repo, issue)
+	return v, resp, errorKind(err)
+}
+
+// kindPullRequests marks the errors of a PullRequestsAPI.
+type kindPullRequests struct {
+	api PullRequestsAPI
+}
+
+func (k kindPullRequests) List(ctx context.Context, owner string, repo string, opts *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error) {
+	v, resp, err := k.api.List(ctx, owner, repo, opts)
+	return v, resp, errorKind(err)
+}
+
+func (k kindPullRequests) Create(ctx context.Context, owner string, repo string, pull *github.NewPullRequest) (*github.PullRequest, *github.Response, error) {
+	v, resp, err := k.api.Create(ctx, owner, repo, pull)
+	return v, resp, errorKind(err)
+}
+
+// kindUsers marks the errors of a UsersAPI.
+type kindUsers struct {
+	api UsersAPI
+}
+
+func (k kindUsers) Get(ctx context.Context, user string) (*github.User, *github.Response, error) {
+	v, resp, err := k.api.Get(ctx, user)
+	return v, resp, errorKind(err)
+}
@@ -0,0 +1,14 @@
+package github
+
+import (
+	"i2pgit.org/go-i2p/go-github-sync/pkg/events"
+)
+
+// emit appends event about the mirror repository to the events file of the
+// configuration. A failure to write it is only logged.
+func (c *Client) emit(event events.Event) {
+	event.Repo, event.Primary = c.cfg.MirrorRepo, c.cfg.PrimaryRepo
+	if err := events.Emit(c.cfg.EventsFile, event); err != nil {
+		c.log.Warn("Could not write event", "event", event.Type, "error", err)
+	}
+}
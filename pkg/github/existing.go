@@ -0,0 +1,143 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+)
+
+const workflowsDir = ".github/workflows"
+
+// legacyWorkflowNames are file names under which earlier versions of this tool
+// or its default --output installed the sync workflow.
+var legacyWorkflowNames = map[string]bool{
+	"sync.yaml":        true,
+	"sync.yml":         true,
+	"sync-mirror.yaml": true,
+}
+
+// syncMarkers are content fragments identifying workflows that sync a mirror.
+var syncMarkers = []string{
+	"generated by go-github-sync",
+	"git remote add primary",
+	"git push --mirror",
+	"repo-sync/",
+	"wei/git-sync",
+}
+
+// ExistingWorkflow describes a workflow on the mirror that appears to compete
+// with the generated sync workflow.
+type ExistingWorkflow struct {
+	Path   string
+	SHA    string
+	Reason string
+}
+
+// FindSyncWorkflows lists workflows on the mirror, other than the one managed
+// by this tool, that look like repository sync or mirror jobs.
+func (c *Client) FindSyncWorkflows(ctx context.Context) ([]ExistingWorkflow, error) {
+	var entries []*github.RepositoryContent
+	err := c.withRetry(ctx, "list workflows", func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		_, entries, resp, err = c.api.Repositories().GetContents(ctx, c.owner, c.repo, workflowsDir, nil)
+		return resp, err
+	})
+
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", workflowsDir, err)
+	}
+
+	var found []ExistingWorkflow
+	for _, entry := range entries {
+		name := entry.GetName()
+		if entry.GetType() != "file" || entry.GetPath() == workflowPath {
+			continue
+		}
+		if ext := path.Ext(name); ext != ".yml" && ext != ".yaml" {
+			continue
+		}
+
+		if legacyWorkflowNames[name] {
+			found = append(found, ExistingWorkflow{Path: entry.GetPath(), SHA: entry.GetSHA(), Reason: "legacy sync workflow file name"})
+			continue
+		}
+
+		file, err := c.getFile(ctx, entry.GetPath(), "")
+		if err != nil || file == nil {
+			c.log.Debug("Could not inspect workflow", "path", entry.GetPath(), "error", err)
+			continue
+		}
+		content, err := file.GetContent()
+		if err != nil {
+			continue
+		}
+		lower := strings.ToLower(content)
+		for _, marker := range syncMarkers {
+			if strings.Contains(lower, marker) {
+				found = append(found, ExistingWorkflow{Path: entry.GetPath(), SHA: file.GetSHA(), Reason: "contains " + marker})
+				break
+			}
+		}
+	}
+
+	return found, nil
+}
+
+// RemoveWorkflow deletes a workflow file from the mirror repository.
+func (c *Client) RemoveWorkflow(ctx context.Context, existing ExistingWorkflow) error {
+	message := "Remove superseded sync workflow " + path.Base(existing.Path)
+	if err := c.deleteFile(ctx, existing.Path, existing.SHA, message); err != nil {
+		return err
+	}
+
+	c.log.Info("Removed superseded sync workflow", "path", existing.Path)
+	return nil
+}
+
+// RemoveSyncWorkflow deletes the workflow installed by SetupWorkflow from the
+// mirror repository. The result reports whether it was installed.
+func (c *Client) RemoveSyncWorkflow(ctx context.Context) (bool, error) {
+	file, err := c.getFile(ctx, workflowPath, "")
+	if err != nil {
+		return false, fmt.Errorf("failed to check for existing workflow file: %w", err)
+	}
+	if file == nil {
+		return false, nil
+	}
+
+	if err := c.deleteFile(ctx, workflowPath, file.GetSHA(), "Remove repository sync workflow"); err != nil {
+		return false, err
+	}
+
+	c.log.Info("Removed sync workflow", "owner", c.owner, "repo", c.repo, "path", workflowPath)
+	return true, nil
+}
+
+// deleteFile deletes the file at filePath with blob SHA sha from the default branch.
+func (c *Client) deleteFile(ctx context.Context, filePath, sha, message string) error {
+	err := c.withRetry(ctx, "delete "+filePath, func() (*github.Response, error) {
+		_, resp, err := c.api.Repositories().DeleteFile(ctx, c.owner, c.repo, filePath,
+			&github.RepositoryContentFileOptions{
+				Message: &message,
+				SHA:     &sha,
+			})
+		return resp, err
+	})
+	c.audit(audit.OpDeleteFile, filePath, message, err)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", filePath, err)
+	}
+	return nil
+}
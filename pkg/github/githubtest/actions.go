@@ -0,0 +1,333 @@
+package githubtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// publicKey is the Actions secrets public key of every repository and
+// environment of a Fake.
+const publicKey = "AwWGtGMxdeLtRnYoxdtYSTXa2BE1rqTsQ+RyKdRqdQQ="
+
+// actions is the ActionsAPI of a Fake.
+type actions struct{ f *Fake }
+
+func (s actions) ListWorkflowRunsByFileName(ctx context.Context, owner, repo, workflowFileName string, opts *github.ListWorkflowRunsOptions) (*github.WorkflowRuns, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	runs := &github.WorkflowRuns{WorkflowRuns: []*github.WorkflowRun{}}
+	for _, run := range r.Runs {
+		if opts != nil && opts.Event != "" && run.GetEvent() != opts.Event {
+			continue
+		}
+		runs.WorkflowRuns = append(runs.WorkflowRuns, run)
+	}
+	runs.TotalCount = github.Int(len(runs.WorkflowRuns))
+	return runs, resp, nil
+}
+
+func (s actions) GetWorkflowRunByID(ctx context.Context, owner, repo string, runID int64) (*github.WorkflowRun, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	for _, run := range r.Runs {
+		if run.GetID() == runID {
+			return run, resp, nil
+		}
+	}
+	resp, err = notFound("workflow run %d not found", runID)
+	return nil, resp, err
+}
+
+func (s actions) ListWorkflowJobs(ctx context.Context, owner, repo string, runID int64, opts *github.ListWorkflowJobsOptions) (*github.Jobs, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	_, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	return &github.Jobs{TotalCount: github.Int(0)}, resp, nil
+}
+
+func (s actions) GetWorkflowJobLogs(ctx context.Context, owner, repo string, jobID int64, maxRedirects int) (*url.URL, *github.Response, error) {
+	return nil, nil, ErrUnsupported
+}
+
+func (s actions) CreateWorkflowDispatchEventByFileName(ctx context.Context, owner, repo, workflowFileName string, event github.CreateWorkflowDispatchEventRequest) (*github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return resp, err
+	}
+	sha, ok := r.resolve(event.Ref)
+	if !ok {
+		return apiError(http.StatusUnprocessableEntity, "No ref found for: %s", event.Ref)
+	}
+	if _, ok := r.files(sha)[path.Join(".github/workflows", workflowFileName)]; !ok {
+		return notFound("workflow %s not found", workflowFileName)
+	}
+
+	r.Dispatches = append(r.Dispatches, event.Ref)
+	resp.StatusCode = http.StatusNoContent
+	return resp, nil
+}
+
+func (s actions) GetRepoPublicKey(ctx context.Context, owner, repo string) (*github.PublicKey, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	_, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	return &github.PublicKey{KeyID: github.String("1"), Key: github.String(publicKey)}, resp, nil
+}
+
+func (s actions) GetRepoSecret(ctx context.Context, owner, repo, name string) (*github.Secret, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	return secret(r, name)
+}
+
+func (s actions) CreateOrUpdateRepoSecret(ctx context.Context, owner, repo string, eSecret *github.EncryptedSecret) (*github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return resp, err
+	}
+	r.Secrets[eSecret.Name] = &github.Secret{Name: eSecret.Name}
+	return resp, nil
+}
+
+func (s actions) DeleteRepoSecret(ctx context.Context, owner, repo, name string) (*github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return resp, err
+	}
+	return deleteSecret(r, name, resp)
+}
+
+func (s actions) GetEnvPublicKey(ctx context.Context, repoID int, env string) (*github.PublicKey, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	_, resp, err := s.f.environment(repoID, env)
+	if err != nil {
+		return nil, resp, err
+	}
+	return &github.PublicKey{KeyID: github.String("1"), Key: github.String(publicKey)}, resp, nil
+}
+
+func (s actions) GetEnvSecret(ctx context.Context, repoID int, env, secretName string) (*github.Secret, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.environment(repoID, env)
+	if err != nil {
+		return nil, resp, err
+	}
+	return secret(r, env+"/"+secretName)
+}
+
+func (s actions) CreateOrUpdateEnvSecret(ctx context.Context, repoID int, env string, eSecret *github.EncryptedSecret) (*github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.environment(repoID, env)
+	if err != nil {
+		return resp, err
+	}
+	r.Secrets[env+"/"+eSecret.Name] = &github.Secret{Name: eSecret.Name}
+	return resp, nil
+}
+
+func (s actions) DeleteEnvSecret(ctx context.Context, repoID int, env, secretName string) (*github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.environment(repoID, env)
+	if err != nil {
+		return resp, err
+	}
+	return deleteSecret(r, env+"/"+secretName, resp)
+}
+
+// environment returns the repository with id, or a not found error when it
+// or its environment env does not exist.
+func (f *Fake) environment(repoID int, env string) (*Repo, *github.Response, error) {
+	r, resp, err := f.repoByID(repoID)
+	if err != nil {
+		return nil, resp, err
+	}
+	if !r.Environments[env] {
+		resp, err := notFound("environment %s not found", env)
+		return nil, resp, err
+	}
+	return r, resp, nil
+}
+
+// secret returns the secret of r stored under key.
+func secret(r *Repo, key string) (*github.Secret, *github.Response, error) {
+	s, ok := r.Secrets[key]
+	if !ok {
+		resp, err := notFound("secret %s not found", key)
+		return nil, resp, err
+	}
+	return s, ok200(), nil
+}
+
+// deleteSecret deletes the secret of r stored under key.
+func deleteSecret(r *Repo, key string, resp *github.Response) (*github.Response, error) {
+	if _, ok := r.Secrets[key]; !ok {
+		return notFound("secret %s not found", key)
+	}
+	delete(r.Secrets, key)
+	resp.StatusCode = http.StatusNoContent
+	return resp, nil
+}
+
+// issues is the IssuesAPI of a Fake.
+type issues struct{ f *Fake }
+
+func (s issues) ListByRepo(ctx context.Context, owner string, repo string, opts *github.IssueListByRepoOptions) ([]*github.Issue, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	state := "open"
+	if opts != nil && opts.State != "" {
+		state = opts.State
+	}
+	var list []*github.Issue
+	for _, issue := range r.Issues {
+		if state == "all" || issue.GetState() == state {
+			list = append(list, issue)
+		}
+	}
+	return list, resp, nil
+}
+
+func (s issues) Create(ctx context.Context, owner string, repo string, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	number := r.nextNumber()
+	created := &github.Issue{
+		ID:      github.Int64(s.f.newID()),
+		Number:  github.Int(number),
+		State:   github.String("open"),
+		Title:   github.String(issue.GetTitle()),
+		Body:    github.String(issue.GetBody()),
+		HTMLURL: github.String(fmt.Sprintf("https://github.com/%s/%s/issues/%d", r.Owner, r.Name, number)),
+	}
+	r.Issues = append(r.Issues, created)
+	resp.StatusCode = http.StatusCreated
+	return created, resp, nil
+}
+
+// pullRequests is the PullRequestsAPI of a Fake.
+type pullRequests struct{ f *Fake }
+
+func (s pullRequests) List(ctx context.Context, owner string, repo string, opts *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	if opts == nil {
+		opts = &github.PullRequestListOptions{}
+	}
+	state := opts.State
+	if state == "" {
+		state = "open"
+	}
+	var list []*github.PullRequest
+	for _, pr := range r.PullRequests {
+		if state != "all" && pr.GetState() != state {
+			continue
+		}
+		if opts.Head != "" && pr.GetHead().GetLabel() != opts.Head {
+			continue
+		}
+		if opts.Base != "" && pr.GetBase().GetRef() != opts.Base {
+			continue
+		}
+		list = append(list, pr)
+	}
+	return list, resp, nil
+}
+
+func (s pullRequests) Create(ctx context.Context, owner string, repo string, pull *github.NewPullRequest) (*github.PullRequest, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	head := strings.TrimPrefix(pull.GetHead(), r.Owner+":")
+	for _, branch := range []string{head, pull.GetBase()} {
+		if _, ok := r.refs["refs/heads/"+branch]; !ok {
+			resp, err := apiError(http.StatusUnprocessableEntity, "branch %s not found", branch)
+			return nil, resp, err
+		}
+	}
+
+	number := r.nextNumber()
+	created := &github.PullRequest{
+		ID:      github.Int64(s.f.newID()),
+		Number:  github.Int(number),
+		State:   github.String("open"),
+		Title:   github.String(pull.GetTitle()),
+		Body:    github.String(pull.GetBody()),
+		HTMLURL: github.String(fmt.Sprintf("https://github.com/%s/%s/pull/%d", r.Owner, r.Name, number)),
+		Head:    &github.PullRequestBranch{Label: github.String(r.Owner + ":" + head), Ref: github.String(head)},
+		Base:    &github.PullRequestBranch{Label: github.String(r.Owner + ":" + pull.GetBase()), Ref: github.String(pull.GetBase())},
+	}
+	r.PullRequests = append(r.PullRequests, created)
+	resp.StatusCode = http.StatusCreated
+	return created, resp, nil
+}
+
+// nextNumber returns the number of the next issue or pull request of r,
+// which share one sequence like on GitHub.
+func (r *Repo) nextNumber() int {
+	return len(r.Issues) + len(r.PullRequests) + 1
+}
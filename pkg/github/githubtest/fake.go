@@ -0,0 +1,228 @@
+// Package githubtest provides an in-memory fake of the GitHub API, so code
+// using package github can be exercised without network access:
+//
+//	fake := githubtest.New()
+//	fake.AddRepo("go-i2p", "reseed-tools")
+//
+//	client, err := github.NewClientWithAPI(cfg, log, fake)
+//	sha, err := client.SetupWorkflow(ctx, workflowYAML)
+//	content, ok := fake.Repo("go-i2p", "reseed-tools").File("", ".github/workflows/sync-mirror.yml")
+//
+// The fake keeps repositories with their files, branches and commits, deploy
+// keys, environments, secrets, issues, pull requests, workflow runs and commit
+// statuses.
+// Branch protection changes, job logs and GraphQL queries are not supported
+// and fail with ErrUnsupported.
+package githubtest
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v61/github"
+
+	ghsync "i2pgit.org/go-i2p/go-github-sync/pkg/github"
+)
+
+// ErrUnsupported is returned by the operations the fake does not implement.
+var ErrUnsupported = errors.New("operation not supported by the GitHub API fake")
+
+// Fake is an in-memory GitHubAPI. It is safe for concurrent use.
+type Fake struct {
+	// Login is the login of the authenticated user
+	Login string
+
+	// Organizations are the logins of the accounts that are organizations,
+	// in which repositories can be created; any other account is a user
+	Organizations []string
+
+	// Scopes are reported in the X-OAuth-Scopes header of repository
+	// lookups, like for classic tokens; nil leaves the header out, like
+	// for fine-grained tokens
+	Scopes []string
+
+	mu     sync.Mutex
+	repos  map[string]*Repo
+	nextID int64
+	serial int
+}
+
+var _ ghsync.GitHubAPI = (*Fake)(nil)
+
+// New returns an empty fake, authenticated as a user with a classic token
+// granting the repo and workflow scopes.
+func New() *Fake {
+	return &Fake{
+		Login:  "gh-mirror-test",
+		Scopes: []string{"repo", "workflow"},
+		repos:  make(map[string]*Repo),
+	}
+}
+
+// AddRepo creates an empty repository, with main as default branch and push
+// and admin permissions, and returns it. An existing repository is returned
+// unchanged.
+func (f *Fake) AddRepo(owner, name string) *Repo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.addRepo(owner, name)
+}
+
+// Repo returns the repository owner/name, or nil when it does not exist.
+func (f *Fake) Repo(owner, name string) *Repo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.repos[repoKey(owner, name)]
+}
+
+// addRepo creates the repository owner/name unless it exists.
+func (f *Fake) addRepo(owner, name string) *Repo {
+	if r, ok := f.repos[repoKey(owner, name)]; ok {
+		return r
+	}
+
+	r := &Repo{
+		Owner:         owner,
+		Name:          name,
+		ID:            f.newID(),
+		DefaultBranch: "main",
+		Permissions:   map[string]bool{"pull": true, "push": true, "admin": true},
+		Environments:  make(map[string]bool),
+		Secrets:       make(map[string]*github.Secret),
+		Protection:    make(map[string]*github.Protection),
+
+		fake:    f,
+		refs:    make(map[string]string),
+		commits: make(map[string]*commit),
+		trees:   map[string]map[string]string{emptyTree: {}},
+	}
+	f.repos[repoKey(owner, name)] = r
+	return r
+}
+
+// repo returns the repository owner/name, or a not found error.
+func (f *Fake) repo(owner, name string) (*Repo, *github.Response, error) {
+	r, ok := f.repos[repoKey(owner, name)]
+	if !ok {
+		resp, err := notFound("repository %s/%s not found", owner, name)
+		return nil, resp, err
+	}
+	return r, ok200(), nil
+}
+
+// repoByID returns the repository with id, or a not found error.
+func (f *Fake) repoByID(id int) (*Repo, *github.Response, error) {
+	for _, r := range f.repos {
+		if r.ID == int64(id) {
+			return r, ok200(), nil
+		}
+	}
+	resp, err := notFound("repository %d not found", id)
+	return nil, resp, err
+}
+
+// newID returns an ID not used before.
+func (f *Fake) newID() int64 {
+	f.nextID++
+	return f.nextID
+}
+
+// newSHA returns a SHA not used before, derived from parts.
+func (f *Fake) newSHA(parts ...string) string {
+	f.serial++
+	return hashOf(fmt.Sprint(f.serial), strings.Join(parts, "\x00"))
+}
+
+// Repositories implements GitHubAPI.
+func (f *Fake) Repositories() ghsync.RepositoriesAPI { return repositories{f} }
+
+// Git implements GitHubAPI.
+func (f *Fake) Git() ghsync.GitAPI { return gitData{f} }
+
+// Actions implements GitHubAPI.
+func (f *Fake) Actions() ghsync.ActionsAPI { return actions{f} }
+
+// Issues implements GitHubAPI.
+func (f *Fake) Issues() ghsync.IssuesAPI { return issues{f} }
+
+// PullRequests implements GitHubAPI.
+func (f *Fake) PullRequests() ghsync.PullRequestsAPI { return pullRequests{f} }
+
+// Users implements GitHubAPI.
+func (f *Fake) Users() ghsync.UsersAPI { return users{f} }
+
+// BaseURL implements GitHubAPI.
+func (f *Fake) BaseURL() string { return "https://api.github.com/" }
+
+// GraphQL implements GitHubAPI. Queries are not supported.
+func (f *Fake) GraphQL(ctx context.Context, query string, variables map[string]interface{}, v interface{}) (*github.Response, error) {
+	return nil, ErrUnsupported
+}
+
+// users is the UsersAPI of a Fake.
+type users struct{ f *Fake }
+
+func (s users) Get(ctx context.Context, user string) (*github.User, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	if user == "" {
+		user = s.f.Login
+	}
+	typ := "User"
+	if s.f.isOrganization(user) {
+		typ = "Organization"
+	}
+	return &github.User{Login: github.String(user), Type: github.String(typ)}, ok200(), nil
+}
+
+// isOrganization reports whether the account login is one of the
+// organizations of f.
+func (f *Fake) isOrganization(login string) bool {
+	for _, org := range f.Organizations {
+		if strings.EqualFold(org, login) {
+			return true
+		}
+	}
+	return false
+}
+
+// ok200 returns the response of a successful request.
+func ok200() *github.Response {
+	return &github.Response{Response: &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}}
+}
+
+// apiError returns the response and error of a request failing with status.
+func apiError(status int, format string, args ...interface{}) (*github.Response, error) {
+	resp := &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     http.Header{},
+		Request:    &http.Request{Method: "FAKE", URL: &url.URL{Scheme: "https", Host: "api.github.com"}},
+	}
+	return &github.Response{Response: resp}, &github.ErrorResponse{Response: resp, Message: fmt.Sprintf(format, args...)}
+}
+
+// notFound returns the response and error of a request for a missing resource.
+func notFound(format string, args ...interface{}) (*github.Response, error) {
+	return apiError(http.StatusNotFound, format, args...)
+}
+
+// repoKey returns the key of owner/name in Fake.repos; GitHub names are case
+// insensitive.
+func repoKey(owner, name string) string {
+	return strings.ToLower(owner + "/" + name)
+}
+
+// hashOf returns a hex SHA-1 of parts.
+func hashOf(parts ...string) string {
+	sum := sha1.Sum([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
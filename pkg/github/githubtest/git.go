@@ -0,0 +1,222 @@
+package githubtest
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// gitData is the GitAPI of a Fake.
+type gitData struct{ f *Fake }
+
+func (s gitData) GetRef(ctx context.Context, owner string, repo string, ref string) (*github.Reference, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	// GitHub rejects ref lookups in repositories without commits
+	if len(r.refs) == 0 {
+		resp, err := apiError(http.StatusConflict, "Git Repository is empty.")
+		return nil, resp, err
+	}
+
+	ref = "refs/" + strings.TrimPrefix(ref, "refs/")
+	sha, ok := r.refs[ref]
+	if !ok {
+		resp, err := notFound("ref %s not found", ref)
+		return nil, resp, err
+	}
+	return reference(ref, sha), resp, nil
+}
+
+func (s gitData) CreateRef(ctx context.Context, owner string, repo string, ref *github.Reference) (*github.Reference, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	name := "refs/" + strings.TrimPrefix(ref.GetRef(), "refs/")
+	sha := ref.GetObject().GetSHA()
+	if _, ok := r.refs[name]; ok {
+		resp, err := apiError(http.StatusUnprocessableEntity, "Reference already exists")
+		return nil, resp, err
+	}
+	if _, ok := r.commits[sha]; !ok {
+		resp, err := apiError(http.StatusUnprocessableEntity, "Object does not exist")
+		return nil, resp, err
+	}
+
+	r.refs[name] = sha
+	return reference(name, sha), resp, nil
+}
+
+func (s gitData) UpdateRef(ctx context.Context, owner string, repo string, ref *github.Reference, force bool) (*github.Reference, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	name := "refs/" + strings.TrimPrefix(ref.GetRef(), "refs/")
+	sha := ref.GetObject().GetSHA()
+	current, ok := r.refs[name]
+	if !ok {
+		resp, err := apiError(http.StatusUnprocessableEntity, "Reference does not exist")
+		return nil, resp, err
+	}
+	if _, ok := r.commits[sha]; !ok {
+		resp, err := apiError(http.StatusUnprocessableEntity, "Object does not exist")
+		return nil, resp, err
+	}
+	if !force && !r.descends(sha, current) {
+		resp, err := apiError(http.StatusUnprocessableEntity, "Update is not a fast forward")
+		return nil, resp, err
+	}
+
+	r.refs[name] = sha
+	return reference(name, sha), resp, nil
+}
+
+func (s gitData) GetCommit(ctx context.Context, owner string, repo string, sha string) (*github.Commit, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	c, ok := r.commits[sha]
+	if !ok {
+		resp, err := notFound("commit %s not found", sha)
+		return nil, resp, err
+	}
+
+	commit := &github.Commit{
+		SHA:     github.String(sha),
+		Message: github.String(c.message),
+		Tree:    &github.Tree{SHA: github.String(c.tree)},
+	}
+	if c.parent != "" {
+		commit.Parents = []*github.Commit{{SHA: github.String(c.parent)}}
+	}
+	return commit, resp, nil
+}
+
+func (s gitData) CreateCommit(ctx context.Context, owner string, repo string, newCommit *github.Commit, opts *github.CreateCommitOptions) (*github.Commit, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	tree := newCommit.GetTree().GetSHA()
+	if _, ok := r.trees[tree]; !ok {
+		resp, err := apiError(http.StatusUnprocessableEntity, "Tree SHA does not exist")
+		return nil, resp, err
+	}
+	var parent string
+	if len(newCommit.Parents) > 0 {
+		parent = newCommit.Parents[0].GetSHA()
+		if _, ok := r.commits[parent]; !ok {
+			resp, err := apiError(http.StatusUnprocessableEntity, "Parent SHA does not exist")
+			return nil, resp, err
+		}
+	}
+
+	sha := s.f.newSHA(tree, parent, newCommit.GetMessage())
+	r.commits[sha] = &commit{message: newCommit.GetMessage(), tree: tree, parent: parent}
+	return &github.Commit{
+		SHA:     github.String(sha),
+		Message: github.String(newCommit.GetMessage()),
+		Tree:    &github.Tree{SHA: github.String(tree)},
+		Parents: newCommit.Parents,
+	}, resp, nil
+}
+
+func (s gitData) CreateTree(ctx context.Context, owner string, repo string, baseTree string, entries []*github.TreeEntry) (*github.Tree, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	files := make(map[string]string)
+	if baseTree != "" {
+		base, ok := r.trees[baseTree]
+		if !ok {
+			resp, err := apiError(http.StatusUnprocessableEntity, "base_tree is not a valid tree")
+			return nil, resp, err
+		}
+		for name, content := range base {
+			files[name] = content
+		}
+	}
+	for _, entry := range entries {
+		switch {
+		case entry.Content != nil:
+			files[entry.GetPath()] = entry.GetContent()
+		case entry.SHA == nil:
+			// Entries without content or SHA delete the file
+			delete(files, entry.GetPath())
+		default:
+			// Blobs are only known by the content of the files holding them
+			resp, err := apiError(http.StatusUnprocessableEntity, "tree entries by SHA are not supported by the fake")
+			return nil, resp, err
+		}
+	}
+
+	sha := r.tree(files)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tree := &github.Tree{SHA: github.String(sha)}
+	for _, name := range names {
+		tree.Entries = append(tree.Entries, &github.TreeEntry{
+			Path: github.String(name),
+			Mode: github.String("100644"),
+			Type: github.String("blob"),
+			SHA:  github.String(blobSHA(files[name])),
+			Size: github.Int(len(files[name])),
+		})
+	}
+	return tree, resp, nil
+}
+
+// descends reports whether the commit with sha is ancestor or one of its
+// descendants.
+func (r *Repo) descends(sha, ancestor string) bool {
+	for sha != "" {
+		if sha == ancestor {
+			return true
+		}
+		c, ok := r.commits[sha]
+		if !ok {
+			return false
+		}
+		sha = c.parent
+	}
+	return false
+}
+
+// reference returns the API representation of ref pointing at the commit sha.
+func reference(ref, sha string) *github.Reference {
+	return &github.Reference{
+		Ref:    github.String(ref),
+		Object: &github.GitObject{Type: github.String("commit"), SHA: github.String(sha)},
+	}
+}
@@ -0,0 +1,567 @@
+package githubtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// emptyTree is the SHA of the tree without files.
+const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// Repo is a repository of a Fake. Its fields may be set to prepare a test and
+// read to check its outcome, between calls to the fake.
+type Repo struct {
+	Owner         string
+	Name          string
+	ID            int64
+	DefaultBranch string
+	Private       bool
+	Description   string
+	Homepage      string
+	Topics        []string
+
+	// Permissions of the authenticated user, as reported by repository lookups
+	Permissions map[string]bool
+
+	Keys         []*github.Key
+	Environments map[string]bool
+
+	// Secrets by name, prefixed with the environment and a slash for
+	// environment secrets
+	Secrets map[string]*github.Secret
+
+	// Protection of branches by name; unprotected branches are missing
+	Protection map[string]*github.Protection
+
+	Issues       []*github.Issue
+	PullRequests []*github.PullRequest
+
+	// Runs are the workflow runs listed for any workflow file, newest first
+	Runs []*github.WorkflowRun
+
+	// Dispatches are the refs workflow_dispatch events were created for
+	Dispatches []string
+
+	// Statuses are the commit statuses by commit SHA, newest first; the
+	// commits need not exist in the fake
+	Statuses map[string][]*github.RepoStatus
+
+	fake    *Fake
+	refs    map[string]string
+	commits map[string]*commit
+	trees   map[string]map[string]string
+}
+
+// commit is a commit of a Repo.
+type commit struct {
+	message string
+	tree    string
+	parent  string
+}
+
+// WriteFile commits content to the file at filePath on branch, or on the
+// default branch when branch is empty, creating the branch when needed, and
+// returns the SHA of the commit.
+func (r *Repo) WriteFile(branch, filePath, content string) string {
+	r.fake.mu.Lock()
+	defer r.fake.mu.Unlock()
+
+	if branch == "" {
+		branch = r.DefaultBranch
+	}
+	files := r.files(r.refs["refs/heads/"+branch])
+	files[filePath] = content
+	return r.commit(branch, "Update "+filePath, files)
+}
+
+// File returns the content of the file at filePath on branch, or on the
+// default branch when branch is empty. The boolean result reports whether
+// the file exists.
+func (r *Repo) File(branch, filePath string) (string, bool) {
+	r.fake.mu.Lock()
+	defer r.fake.mu.Unlock()
+
+	if branch == "" {
+		branch = r.DefaultBranch
+	}
+	content, ok := r.files(r.refs["refs/heads/"+branch])[filePath]
+	return content, ok
+}
+
+// Head returns the SHA of the head commit of branch, or an empty string when
+// the branch does not exist.
+func (r *Repo) Head(branch string) string {
+	r.fake.mu.Lock()
+	defer r.fake.mu.Unlock()
+	return r.refs["refs/heads/"+branch]
+}
+
+// Message returns the message of the commit with sha.
+func (r *Repo) Message(sha string) string {
+	r.fake.mu.Lock()
+	defer r.fake.mu.Unlock()
+
+	if c, ok := r.commits[sha]; ok {
+		return c.message
+	}
+	return ""
+}
+
+// files returns a copy of the files of the commit with sha, empty for an
+// unknown commit.
+func (r *Repo) files(sha string) map[string]string {
+	files := make(map[string]string)
+	if c, ok := r.commits[sha]; ok {
+		for name, content := range r.trees[c.tree] {
+			files[name] = content
+		}
+	}
+	return files
+}
+
+// commit stores files as a new commit on branch and returns its SHA.
+func (r *Repo) commit(branch, message string, files map[string]string) string {
+	tree := r.tree(files)
+	parent := r.refs["refs/heads/"+branch]
+	sha := r.fake.newSHA(tree, parent, message)
+	r.commits[sha] = &commit{message: message, tree: tree, parent: parent}
+	r.refs["refs/heads/"+branch] = sha
+	return sha
+}
+
+// tree stores files as a tree and returns its SHA.
+func (r *Repo) tree(files map[string]string) string {
+	if len(files) == 0 {
+		return emptyTree
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		parts = append(parts, name, blobSHA(files[name]))
+	}
+	sha := hashOf(parts...)
+	r.trees[sha] = files
+	return sha
+}
+
+// resolve returns the commit SHA that ref, a branch name or commit SHA,
+// points at; an empty ref means the default branch.
+func (r *Repo) resolve(ref string) (string, bool) {
+	if ref == "" {
+		ref = r.DefaultBranch
+	}
+	if sha, ok := r.refs["refs/heads/"+strings.TrimPrefix(ref, "refs/heads/")]; ok {
+		return sha, true
+	}
+	if _, ok := r.commits[ref]; ok {
+		return ref, true
+	}
+	return "", false
+}
+
+// repository returns the API representation of r.
+func (r *Repo) repository() *github.Repository {
+	permissions := make(map[string]bool, len(r.Permissions))
+	for name, allowed := range r.Permissions {
+		permissions[name] = allowed
+	}
+	return &github.Repository{
+		ID:            github.Int64(r.ID),
+		Name:          github.String(r.Name),
+		FullName:      github.String(r.Owner + "/" + r.Name),
+		Owner:         &github.User{Login: github.String(r.Owner)},
+		DefaultBranch: github.String(r.DefaultBranch),
+		Private:       github.Bool(r.Private),
+		Description:   github.String(r.Description),
+		Homepage:      github.String(r.Homepage),
+		Topics:        append([]string(nil), r.Topics...),
+		Permissions:   permissions,
+		HTMLURL:       github.String("https://github.com/" + r.Owner + "/" + r.Name),
+		CloneURL:      github.String("https://github.com/" + r.Owner + "/" + r.Name + ".git"),
+	}
+}
+
+// content returns the API representation of the file at filePath.
+func (r *Repo) content(filePath, content string) *github.RepositoryContent {
+	return &github.RepositoryContent{
+		Type:    github.String("file"),
+		Name:    github.String(path.Base(filePath)),
+		Path:    github.String(filePath),
+		Size:    github.Int(len(content)),
+		SHA:     github.String(blobSHA(content)),
+		Content: github.String(content),
+	}
+}
+
+// repositories is the RepositoriesAPI of a Fake.
+type repositories struct{ f *Fake }
+
+func (s repositories) Get(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	if s.f.Scopes != nil {
+		resp.Header.Set("X-OAuth-Scopes", strings.Join(s.f.Scopes, ", "))
+	}
+	return r.repository(), resp, nil
+}
+
+func (s repositories) Create(ctx context.Context, org string, repo *github.Repository) (*github.Repository, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	owner := org
+	if owner == "" {
+		owner = s.f.Login
+	} else if !s.f.isOrganization(org) {
+		resp, err := notFound("organization %s not found", org)
+		return nil, resp, err
+	}
+	if _, ok := s.f.repos[repoKey(owner, repo.GetName())]; ok {
+		resp, err := apiError(http.StatusUnprocessableEntity, "name already exists on this account")
+		return nil, resp, err
+	}
+
+	r := s.f.addRepo(owner, repo.GetName())
+	r.Private = repo.GetPrivate()
+	r.Description = repo.GetDescription()
+	r.Homepage = repo.GetHomepage()
+	return r.repository(), ok200(), nil
+}
+
+func (s repositories) Edit(ctx context.Context, owner, repo string, repository *github.Repository) (*github.Repository, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	if repository.Description != nil {
+		r.Description = repository.GetDescription()
+	}
+	if repository.Homepage != nil {
+		r.Homepage = repository.GetHomepage()
+	}
+	if repository.Private != nil {
+		r.Private = repository.GetPrivate()
+	}
+	if repository.DefaultBranch != nil {
+		r.DefaultBranch = repository.GetDefaultBranch()
+	}
+	return r.repository(), resp, nil
+}
+
+func (s repositories) ReplaceAllTopics(ctx context.Context, owner, repo string, topics []string) ([]string, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	r.Topics = append([]string(nil), topics...)
+	return topics, resp, nil
+}
+
+func (s repositories) ListByOrg(ctx context.Context, org string, opts *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	var list []*github.Repository
+	for _, r := range s.f.repos {
+		if strings.EqualFold(r.Owner, org) {
+			list = append(list, r.repository())
+		}
+	}
+	if list == nil {
+		resp, err := notFound("organization %s not found", org)
+		return nil, resp, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].GetName() < list[j].GetName() })
+	return list, ok200(), nil
+}
+
+func (s repositories) GetContents(ctx context.Context, owner, repo, filePath string, opts *github.RepositoryContentGetOptions) (*github.RepositoryContent, []*github.RepositoryContent, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, nil, resp, err
+	}
+	var ref string
+	if opts != nil {
+		ref = opts.Ref
+	}
+	sha, ok := r.resolve(ref)
+	if !ok {
+		resp, err := notFound("no commit found for the ref %s", ref)
+		return nil, nil, resp, err
+	}
+
+	files := r.files(sha)
+	filePath = strings.Trim(filePath, "/")
+	if content, ok := files[filePath]; ok {
+		return r.content(filePath, content), nil, resp, nil
+	}
+
+	// List the direct children of a directory
+	prefix := filePath + "/"
+	if filePath == "" {
+		prefix = ""
+	}
+	dirs := make(map[string]bool)
+	var entries []*github.RepositoryContent
+	for name, content := range files {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		child, _, nested := strings.Cut(strings.TrimPrefix(name, prefix), "/")
+		if !nested {
+			entries = append(entries, r.content(name, content))
+		} else if !dirs[child] {
+			dirs[child] = true
+			entries = append(entries, &github.RepositoryContent{
+				Type: github.String("dir"),
+				Name: github.String(child),
+				Path: github.String(prefix + child),
+			})
+		}
+	}
+	if entries == nil {
+		resp, err := notFound("%s not found", filePath)
+		return nil, nil, resp, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].GetPath() < entries[j].GetPath() })
+	return nil, entries, resp, nil
+}
+
+func (s repositories) GetReadme(ctx context.Context, owner, repo string, opts *github.RepositoryContentGetOptions) (*github.RepositoryContent, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	var ref string
+	if opts != nil {
+		ref = opts.Ref
+	}
+	sha, _ := r.resolve(ref)
+
+	files := r.files(sha)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !strings.Contains(name, "/") && strings.HasPrefix(strings.ToLower(name), "readme") {
+			return r.content(name, files[name]), resp, nil
+		}
+	}
+	resp, err = notFound("README not found")
+	return nil, resp, err
+}
+
+func (s repositories) CreateFile(ctx context.Context, owner, repo, filePath string, opts *github.RepositoryContentFileOptions) (*github.RepositoryContentResponse, *github.Response, error) {
+	return s.writeFile(owner, repo, filePath, opts, false)
+}
+
+func (s repositories) DeleteFile(ctx context.Context, owner, repo, filePath string, opts *github.RepositoryContentFileOptions) (*github.RepositoryContentResponse, *github.Response, error) {
+	return s.writeFile(owner, repo, filePath, opts, true)
+}
+
+// writeFile commits the content of opts, or the removal of the file, to
+// filePath. Like GitHub, replacing or deleting a file requires its blob SHA.
+func (s repositories) writeFile(owner, repo, filePath string, opts *github.RepositoryContentFileOptions, remove bool) (*github.RepositoryContentResponse, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	branch := r.DefaultBranch
+	if opts.Branch != nil {
+		branch = opts.GetBranch()
+	}
+
+	files := r.files(r.refs["refs/heads/"+branch])
+	existing, exists := files[filePath]
+	switch {
+	case remove && !exists:
+		resp, err := notFound("%s not found", filePath)
+		return nil, resp, err
+	case exists && opts.SHA == nil:
+		resp, err := apiError(http.StatusUnprocessableEntity, "\"sha\" wasn't supplied")
+		return nil, resp, err
+	case exists && opts.GetSHA() != blobSHA(existing):
+		resp, err := apiError(http.StatusConflict, "%s does not match %s", filePath, opts.GetSHA())
+		return nil, resp, err
+	}
+
+	var content *github.RepositoryContent
+	if remove {
+		delete(files, filePath)
+	} else {
+		files[filePath] = string(opts.Content)
+		content = r.content(filePath, string(opts.Content))
+	}
+	sha := r.commit(branch, opts.GetMessage(), files)
+
+	return &github.RepositoryContentResponse{
+		Content: content,
+		Commit:  github.Commit{SHA: github.String(sha), Message: github.String(opts.GetMessage())},
+	}, resp, nil
+}
+
+func (s repositories) ListKeys(ctx context.Context, owner string, repo string, opts *github.ListOptions) ([]*github.Key, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	return append([]*github.Key(nil), r.Keys...), resp, nil
+}
+
+func (s repositories) CreateKey(ctx context.Context, owner string, repo string, key *github.Key) (*github.Key, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	created := *key
+	created.ID = github.Int64(s.f.newID())
+	r.Keys = append(r.Keys, &created)
+	return &created, resp, nil
+}
+
+func (s repositories) DeleteKey(ctx context.Context, owner string, repo string, id int64) (*github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return resp, err
+	}
+	for i, key := range r.Keys {
+		if key.GetID() == id {
+			r.Keys = append(r.Keys[:i], r.Keys[i+1:]...)
+			return resp, nil
+		}
+	}
+	return notFound("key %d not found", id)
+}
+
+func (s repositories) GetEnvironment(ctx context.Context, owner, repo, name string) (*github.Environment, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	if !r.Environments[name] {
+		resp, err := notFound("environment %s not found", name)
+		return nil, resp, err
+	}
+	return &github.Environment{Name: github.String(name)}, resp, nil
+}
+
+func (s repositories) CreateUpdateEnvironment(ctx context.Context, owner, repo, name string, environment *github.CreateUpdateEnvironment) (*github.Environment, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	r.Environments[name] = true
+	return &github.Environment{Name: github.String(name)}, resp, nil
+}
+
+func (s repositories) GetBranchProtection(ctx context.Context, owner, repo, branch string) (*github.Protection, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	protection, ok := r.Protection[branch]
+	if !ok {
+		resp, err := notFound("Branch not protected")
+		return nil, resp, err
+	}
+	return protection, resp, nil
+}
+
+func (s repositories) UpdatePullRequestReviewEnforcement(ctx context.Context, owner, repo, branch string, patch *github.PullRequestReviewsEnforcementUpdate) (*github.PullRequestReviewsEnforcement, *github.Response, error) {
+	return nil, nil, ErrUnsupported
+}
+
+func (s repositories) AddAppRestrictions(ctx context.Context, owner, repo, branch string, apps []string) ([]*github.App, *github.Response, error) {
+	return nil, nil, ErrUnsupported
+}
+
+func (s repositories) ListStatuses(ctx context.Context, owner, repo, ref string, opts *github.ListOptions) ([]*github.RepoStatus, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	if sha, ok := r.resolve(ref); ok {
+		ref = sha
+	}
+	return append([]*github.RepoStatus(nil), r.Statuses[ref]...), resp, nil
+}
+
+func (s repositories) CreateStatus(ctx context.Context, owner, repo, ref string, status *github.RepoStatus) (*github.RepoStatus, *github.Response, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	r, resp, err := s.f.repo(owner, repo)
+	if err != nil {
+		return nil, resp, err
+	}
+	if sha, ok := r.resolve(ref); ok {
+		ref = sha
+	}
+	created := *status
+	created.ID = github.Int64(s.f.newID())
+	if r.Statuses == nil {
+		r.Statuses = make(map[string][]*github.RepoStatus)
+	}
+	r.Statuses[ref] = append([]*github.RepoStatus{&created}, r.Statuses[ref]...)
+	return &created, resp, nil
+}
+
+// blobSHA returns the git blob SHA of content, as reported for files by GitHub.
+func blobSHA(content string) string {
+	return hashOf(fmt.Sprintf("blob %d\x00%s", len(content), content))
+}
@@ -0,0 +1,156 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// graphqlBatchSize is the number of repositories looked up per GraphQL query.
+// It keeps each query well below the node limits of the GitHub GraphQL API.
+const graphqlBatchSize = 25
+
+// RepoMetadata is the setup-relevant state of a repository, fetched in bulk.
+type RepoMetadata struct {
+	Name          string
+	DefaultBranch string
+
+	// Workflow is the installed sync workflow on the default branch,
+	// empty when HasWorkflow is false
+	Workflow    string
+	HasWorkflow bool
+
+	// ProtectionPatterns are the branch protection rule patterns of the
+	// repository; they are only readable with admin access
+	ProtectionPatterns []string
+}
+
+// Protects reports whether a branch protection rule applies to branch.
+func (m *RepoMetadata) Protects(branch string) bool {
+	for _, pattern := range m.ProtectionPatterns {
+		if ok, _ := path.Match(pattern, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// graphqlRequest is the body of a GraphQL API request.
+type graphqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// graphqlRepository is the repository shape selected by repositoryFields.
+type graphqlRepository struct {
+	Name             string `json:"name"`
+	DefaultBranchRef *struct {
+		Name string `json:"name"`
+	} `json:"defaultBranchRef"`
+	Workflow *struct {
+		Text *string `json:"text"`
+	} `json:"workflow"`
+	BranchProtectionRules *struct {
+		Nodes []struct {
+			Pattern string `json:"pattern"`
+		} `json:"nodes"`
+	} `json:"branchProtectionRules"`
+}
+
+// graphqlResponse is the body of a GraphQL API response.
+type graphqlResponse struct {
+	Data   map[string]*graphqlRepository `json:"data"`
+	Errors []struct {
+		Message string        `json:"message"`
+		Path    []interface{} `json:"path"`
+	} `json:"errors"`
+}
+
+// repositoryFields selects the fields of a repository needed for setup.
+const repositoryFields = `name
+    defaultBranchRef { name }
+    workflow: object(expression: $workflow) { ... on Blob { text } }
+    branchProtectionRules(first: 100) { nodes { pattern } }`
+
+// RepositoryMetadata looks up the default branch, installed sync workflow and
+// branch protection rules of the named repositories of the client's owner.
+// The lookups are batched into a few GraphQL queries instead of several REST
+// calls per repository. Repositories that cannot be read are left out of the
+// result.
+func (c *Client) RepositoryMetadata(ctx context.Context, names []string) (map[string]*RepoMetadata, error) {
+	result := make(map[string]*RepoMetadata, len(names))
+
+	for start := 0; start < len(names); start += graphqlBatchSize {
+		end := start + graphqlBatchSize
+		if end > len(names) {
+			end = len(names)
+		}
+		if err := c.repositoryMetadataBatch(ctx, names[start:end], result); err != nil {
+			return nil, err
+		}
+	}
+
+	c.log.Debug("Fetched repository metadata through GraphQL", "owner", c.owner, "repos", len(result))
+	return result, nil
+}
+
+// repositoryMetadataBatch runs a single GraphQL query for names and adds the
+// repositories found to result.
+func (c *Client) repositoryMetadataBatch(ctx context.Context, names []string, result map[string]*RepoMetadata) error {
+	var query strings.Builder
+	variables := map[string]interface{}{
+		"owner":    c.owner,
+		"workflow": "HEAD:" + workflowPath,
+	}
+
+	query.WriteString("query($owner: String!, $workflow: String!")
+	for i := range names {
+		fmt.Fprintf(&query, ", $n%d: String!", i)
+		variables[fmt.Sprintf("n%d", i)] = names[i]
+	}
+	query.WriteString(") {\n")
+	for i := range names {
+		fmt.Fprintf(&query, "  r%d: repository(owner: $owner, name: $n%d) {\n    %s\n  }\n", i, i, repositoryFields)
+	}
+	query.WriteString("}\n")
+
+	var resp graphqlResponse
+	err := c.withRetry(ctx, "query repository metadata", func() (*github.Response, error) {
+		return c.api.GraphQL(ctx, query.String(), variables, &resp)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query repository metadata: %w", err)
+	}
+
+	// Missing repositories and unreadable protection rules are reported as
+	// errors alongside the data that could be read
+	for _, e := range resp.Errors {
+		c.log.Debug("GraphQL query reported an error", "path", e.Path, "error", e.Message)
+	}
+
+	for i := range names {
+		repo := resp.Data[fmt.Sprintf("r%d", i)]
+		if repo == nil {
+			continue
+		}
+
+		meta := &RepoMetadata{Name: repo.Name}
+		if repo.DefaultBranchRef != nil {
+			meta.DefaultBranch = repo.DefaultBranchRef.Name
+		}
+		if repo.Workflow != nil && repo.Workflow.Text != nil {
+			meta.Workflow, meta.HasWorkflow = *repo.Workflow.Text, true
+		}
+		if repo.BranchProtectionRules != nil {
+			for _, rule := range repo.BranchProtectionRules.Nodes {
+				meta.ProtectionPatterns = append(meta.ProtectionPatterns, rule.Pattern)
+			}
+		}
+		result[names[i]] = meta
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v61/github"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+)
+
+// EnsureIssue opens an issue with title and body on the mirror repository,
+// unless an open issue with the same title exists, which is returned instead.
+// The boolean result reports whether a new issue was opened.
+func (c *Client) EnsureIssue(ctx context.Context, title, body string) (*github.Issue, bool, error) {
+	opts := &github.IssueListByRepoOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		var issues []*github.Issue
+		var resp *github.Response
+		err := c.withRetry(ctx, "list issues", func() (*github.Response, error) {
+			var err error
+			issues, resp, err = c.api.Issues().ListByRepo(ctx, c.owner, c.repo, opts)
+			return resp, err
+		})
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to list issues: %w", err)
+		}
+
+		for _, issue := range issues {
+			if !issue.IsPullRequest() && issue.GetTitle() == title {
+				c.log.Debug("Issue already open", "url", issue.GetHTMLURL())
+				return issue, false, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	issue, _, err := c.api.Issues().Create(ctx, c.owner, c.repo, &github.IssueRequest{
+		Title: &title,
+		Body:  &body,
+	})
+	c.audit(audit.OpOpenIssue, title, issue.GetHTMLURL(), err)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create issue: %w", err)
+	}
+
+	c.log.Info("Opened issue", "url", issue.GetHTMLURL())
+	return issue, true, nil
+}
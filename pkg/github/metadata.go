@@ -0,0 +1,82 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+// SetMetadata updates the mirror repository description, homepage and topics
+// so that visitors are pointed at the primary repository.
+func (c *Client) SetMetadata(ctx context.Context) error {
+	description := c.cfg.MirrorDescription
+	if description == "" {
+		description = "Read-only mirror of " + c.cfg.PrimaryRepo
+	}
+	homepage := c.cfg.MirrorHomepage
+	if homepage == "" {
+		homepage = WebURL(c.cfg.PrimaryRepo)
+	}
+
+	c.log.Info("Setting mirror repository metadata",
+		"owner", c.owner,
+		"repo", c.repo,
+		"description", description,
+		"homepage", homepage,
+		"topics", c.cfg.MirrorTopics)
+
+	_, _, err := c.api.Repositories().Edit(ctx, c.owner, c.repo, &github.Repository{
+		Description: &description,
+		Homepage:    &homepage,
+	})
+	c.audit(audit.OpEditRepository, "description, homepage", description, err)
+	if err != nil {
+		return fmt.Errorf("failed to update repository description and homepage: %w", err)
+	}
+
+	// An empty topic list is sent as-is so that existing topics are cleared
+	topics := c.cfg.MirrorTopics
+	if topics == nil {
+		topics = []string{}
+	}
+	_, _, err = c.api.Repositories().ReplaceAllTopics(ctx, c.owner, c.repo, topics)
+	c.audit(audit.OpEditRepository, "topics", strings.Join(topics, ", "), err)
+	if err != nil {
+		return fmt.Errorf("failed to update repository topics: %w", err)
+	}
+
+	return nil
+}
+
+// WebURL converts a Git clone URL into the URL of the repository's web page.
+// Local repositories have no web page, so an empty string is returned for
+// file:// URLs.
+func WebURL(repoURL string) string {
+	if config.IsLocalURL(repoURL) {
+		return ""
+	}
+	webURL := strings.TrimSuffix(repoURL, ".git")
+
+	switch {
+	case strings.HasPrefix(webURL, "ssh://"):
+		// ssh://git@host:port/path -> https://host/path
+		rest := strings.TrimPrefix(webURL, "ssh://")
+		if at := strings.Index(rest, "@"); at >= 0 {
+			rest = rest[at+1:]
+		}
+		host, path, _ := strings.Cut(rest, "/")
+		host, _, _ = strings.Cut(host, ":")
+		webURL = "https://" + host + "/" + path
+	case strings.HasPrefix(webURL, "git@"):
+		// git@host:path -> https://host/path
+		host, path, _ := strings.Cut(strings.TrimPrefix(webURL, "git@"), ":")
+		webURL = "https://" + host + "/" + path
+	}
+
+	return webURL
+}
@@ -0,0 +1,42 @@
+package github
+
+import (
+	"net/http"
+	"time"
+)
+
+// limiterTransport waits for a RateLimiter before sending each request.
+type limiterTransport struct {
+	base    http.RoundTripper
+	limiter RateLimiter
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *limiterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// hookTransport calls Hooks around each request.
+type hookTransport struct {
+	base  http.RoundTripper
+	hooks Hooks
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *hookTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.hooks.Request != nil {
+		t.hooks.Request(req)
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if t.hooks.Response != nil {
+		t.hooks.Response(req, resp, err, time.Since(start))
+	}
+	return resp, err
+}
@@ -0,0 +1,154 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// Option customizes the requests of a Client created by NewClient or
+// NewOrgClient.
+type Option func(*options)
+
+// options are the settings of Option.
+type options struct {
+	httpClient *http.Client
+	timeout    time.Duration
+	baseURL    string
+	userAgent  string
+
+	limiter    RateLimiter
+	hooks      Hooks
+	middleware []Middleware
+}
+
+// RateLimiter paces API requests, e.g. to share a quota between tools;
+// *rate.Limiter of golang.org/x/time/rate implements it.
+type RateLimiter interface {
+	// Wait blocks until a request may be sent or ctx is done
+	Wait(ctx context.Context) error
+}
+
+// Middleware wraps the transport sending the API requests.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// Hooks are called around each API request sent, e.g. to collect metrics.
+// Either may be nil.
+type Hooks struct {
+	// Request is called before req is sent
+	Request func(req *http.Request)
+
+	// Response is called with the response to req, or the error sending it,
+	// and the time it took
+	Response func(req *http.Request, resp *http.Response, err error, elapsed time.Duration)
+}
+
+// WithHTTPClient makes the client send its requests with httpClient, e.g. to
+// go through a proxy or to a test server. Authentication, rate limit
+// handling and caching are added on top of its transport.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = httpClient
+	}
+}
+
+// WithTimeout bounds the time of each API request, including reading the
+// response.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithBaseURL makes the client talk to the API at baseURL instead of
+// api.github.com, e.g. https://github.example.com/api/v3/ of a GitHub
+// Enterprise Server; /api/v3/ is added when it is missing.
+func WithBaseURL(baseURL string) Option {
+	return func(o *options) {
+		o.baseURL = baseURL
+	}
+}
+
+// WithUserAgent sets the User-Agent header of the API requests.
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithRateLimiter makes each API request wait for limiter before it is sent.
+// Requests retried after hitting a GitHub rate limit wait again.
+func WithRateLimiter(limiter RateLimiter) Option {
+	return func(o *options) {
+		o.limiter = limiter
+	}
+}
+
+// WithMiddleware wraps the transport of the API requests with middleware,
+// the first being the outermost. The requests reach the middleware after
+// rate limiting and before authentication, so they carry no credentials;
+// each retry after hitting a GitHub rate limit passes through it again.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+// WithHooks calls hooks around each API request, including retries after
+// hitting a GitHub rate limit. Responses carry the rate limit headers, such
+// as X-RateLimit-Remaining.
+func WithHooks(hooks Hooks) Option {
+	return func(o *options) {
+		o.hooks = hooks
+	}
+}
+
+// newOptions returns the settings of opts.
+func newOptions(opts []Option) *options {
+	o := &options{httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.timeout == 0 {
+		o.timeout = o.httpClient.Timeout
+	}
+	return o
+}
+
+// newGitHubClient creates a go-github client sending its requests with
+// httpClient to the configured API.
+func (o *options) newGitHubClient(httpClient *http.Client) (*github.Client, error) {
+	client := github.NewClient(httpClient)
+	if o.baseURL != "" {
+		var err error
+		client, err = client.WithEnterpriseURLs(o.baseURL, o.baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GitHub API base URL: %w", err)
+		}
+	}
+	if o.userAgent != "" {
+		client.UserAgent = o.userAgent
+	}
+	return client, nil
+}
+
+// wrapTransport adds the rate limiter, hooks and middleware of o to
+// transport, in this order from the outside in.
+func (o *options) wrapTransport(transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for i := len(o.middleware) - 1; i >= 0; i-- {
+		transport = o.middleware[i](transport)
+	}
+	if o.hooks.Request != nil || o.hooks.Response != nil {
+		transport = &hookTransport{base: transport, hooks: o.hooks}
+	}
+	if o.limiter != nil {
+		transport = &limiterTransport{base: transport, limiter: o.limiter}
+	}
+	return transport
+}
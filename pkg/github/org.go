@@ -0,0 +1,39 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// ListRepositories returns all repositories of the client's organization.
+func (c *Client) ListRepositories(ctx context.Context) ([]*github.Repository, error) {
+	var all []*github.Repository
+
+	opts := &github.RepositoryListByOrgOptions{
+		Type:        "all",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		var repos []*github.Repository
+		var resp *github.Response
+		err := c.withRetry(ctx, "list organization repositories", func() (*github.Response, error) {
+			var err error
+			repos, resp, err = c.api.Repositories().ListByOrg(ctx, c.owner, opts)
+			return resp, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories of %s: %w", c.owner, err)
+		}
+
+		all = append(all, repos...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	c.log.Debug("Listed organization repositories", "org", c.owner, "count", len(all))
+	return all, nil
+}
@@ -0,0 +1,112 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/v61/github"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/events"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/provenance"
+)
+
+// setupBranch is the branch the workflow is committed to by SetupWorkflowPR.
+const setupBranch = "gh-mirror/sync-workflow"
+
+// SetupWorkflowPR commits the workflow file to a dedicated branch and opens a
+// pull request against the default branch, for repositories that require
+// changes to be reviewed. An existing open pull request is updated in place.
+// A nil pull request is returned when the default branch is already up to date.
+func (c *Client) SetupWorkflowPR(ctx context.Context, workflowContent string) (*github.PullRequest, error) {
+	c.log.Info("Setting up workflow via pull request", "owner", c.owner, "repo", c.repo, "branch", setupBranch)
+
+	installed, found, err := c.GetWorkflow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if found && provenance.Equal(installed, workflowContent) && !c.cfg.ReadmeBanner {
+		c.log.Info("Workflow file is up to date, no pull request needed")
+		return nil, nil
+	}
+
+	repository, _, err := c.api.Repositories().Get(ctx, c.owner, c.repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up mirror repository: %w", err)
+	}
+	base := repository.GetDefaultBranch()
+
+	if err := c.ensureBranch(ctx, setupBranch, base); err != nil {
+		return nil, err
+	}
+
+	if _, err := c.putWorkflow(ctx, workflowContent, setupBranch); err != nil {
+		return nil, err
+	}
+
+	// Reuse an open pull request from a previous run
+	prs, _, err := c.api.PullRequests().List(ctx, c.owner, c.repo, &github.PullRequestListOptions{
+		State: "open",
+		Head:  c.owner + ":" + setupBranch,
+		Base:  base,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pull requests: %w", err)
+	}
+	if len(prs) > 0 {
+		c.log.Info("Updated existing pull request", "url", prs[0].GetHTMLURL())
+		return prs[0], nil
+	}
+
+	title := "Add repository sync workflow"
+	body := "This pull request adds a GitHub Actions workflow that keeps this repository in sync with its primary repository " +
+		c.cfg.PrimaryRepo + ".\n\nIt was generated by go-github-sync."
+	head := setupBranch
+	pr, _, err := c.api.PullRequests().Create(ctx, c.owner, c.repo, &github.NewPullRequest{
+		Title: &title,
+		Head:  &head,
+		Base:  &base,
+		Body:  &body,
+	})
+	c.audit(audit.OpOpenPullRequest, head+" -> "+base, pr.GetHTMLURL(), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pull request: %w", err)
+	}
+	c.emit(events.Event{Type: events.TypePullRequestOpened, Ref: head + " -> " + base, URL: pr.GetHTMLURL()})
+
+	c.log.Info("Created pull request", "url", pr.GetHTMLURL())
+	return pr, nil
+}
+
+// ensureBranch creates branch from the head of base unless it already exists.
+func (c *Client) ensureBranch(ctx context.Context, branch, base string) error {
+	_, _, err := c.api.Git().GetRef(ctx, c.owner, c.repo, "refs/heads/"+branch)
+	if err == nil {
+		c.log.Debug("Branch already exists", "branch", branch)
+		return nil
+	}
+	var errResp *github.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to look up branch %s: %w", branch, err)
+	}
+
+	baseRef, _, err := c.api.Git().GetRef(ctx, c.owner, c.repo, "refs/heads/"+base)
+	if err != nil {
+		return fmt.Errorf("failed to look up base branch %s: %w", base, err)
+	}
+
+	ref := "refs/heads/" + branch
+	_, _, err = c.api.Git().CreateRef(ctx, c.owner, c.repo, &github.Reference{
+		Ref:    &ref,
+		Object: &github.GitObject{SHA: baseRef.Object.SHA},
+	})
+	c.audit(audit.OpCreateBranch, branch, baseRef.GetObject().GetSHA(), err)
+	if err != nil {
+		return fmt.Errorf("failed to create branch %s: %w", branch, err)
+	}
+
+	c.log.Debug("Created branch", "branch", branch, "base", base)
+	return nil
+}
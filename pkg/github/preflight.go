@@ -0,0 +1,76 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+// Preflight verifies that the configured credentials can write repository
+// contents and workflow files, so that setup fails early with an actionable
+// message instead of midway through committing.
+func (c *Client) Preflight(ctx context.Context) error {
+	repository, resp, err := c.api.Repositories().Get(ctx, c.owner, c.repo)
+	if err != nil {
+		return fmt.Errorf("failed to look up mirror repository %s/%s: %w", c.owner, c.repo, err)
+	}
+
+	if c.appTokens != nil {
+		return c.checkAppPermissions()
+	}
+
+	// The permissions map reflects the token owner's access to the repository
+	if perms := repository.GetPermissions(); perms != nil && !perms["push"] && !perms["admin"] {
+		return config.WithKind(config.ErrAuthFailed,
+			fmt.Errorf("the token owner has no write access to %s/%s; grant the account push access to the repository", c.owner, c.repo))
+	}
+
+	// Classic tokens report their scopes, fine-grained tokens do not
+	scopesHeader, classic := resp.Header["X-Oauth-Scopes"]
+	if !classic {
+		c.log.Warn("Fine-grained token permissions cannot be inspected; make sure the token grants " +
+			"'Contents: Read and write' and 'Workflows: Read and write' on the mirror repository")
+		return nil
+	}
+
+	scopes := make(map[string]bool)
+	for _, header := range scopesHeader {
+		for _, scope := range strings.Split(header, ",") {
+			scopes[strings.TrimSpace(scope)] = true
+		}
+	}
+	c.log.Debug("Token scopes", "scopes", scopesHeader)
+
+	if !scopes["repo"] && !(scopes["public_repo"] && !repository.GetPrivate()) {
+		return config.WithKind(config.ErrAuthFailed, fmt.Errorf("the token is missing the 'repo' scope required to write repository contents; "+
+			"regenerate it at https://github.com/settings/tokens with the 'repo' scope"))
+	}
+	if !scopes["workflow"] {
+		return config.WithKind(config.ErrAuthFailed, fmt.Errorf("the token is missing the 'workflow' scope required to create files under .github/workflows; "+
+			"regenerate it at https://github.com/settings/tokens with the 'workflow' scope"))
+	}
+
+	return nil
+}
+
+// checkAppPermissions verifies the permissions granted to the GitHub App installation.
+func (c *Client) checkAppPermissions() error {
+	perms := c.appTokens.Permissions()
+	if perms == nil {
+		c.log.Warn("GitHub App installation permissions unknown, skipping permission check")
+		return nil
+	}
+
+	if perms.GetContents() != "write" {
+		return config.WithKind(config.ErrAuthFailed, fmt.Errorf("the GitHub App installation lacks 'Contents: Read and write' permission on %s/%s; "+
+			"update the app's repository permissions and accept them for the installation", c.owner, c.repo))
+	}
+	if perms.GetWorkflows() != "write" {
+		return config.WithKind(config.ErrAuthFailed, fmt.Errorf("the GitHub App installation lacks 'Workflows: Read and write' permission on %s/%s; "+
+			"update the app's repository permissions and accept them for the installation", c.owner, c.repo))
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/v61/github"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+)
+
+// ProtectionIssue describes a branch protection rule that will make the
+// workflow's push to the mirror branch fail.
+type ProtectionIssue struct {
+	Rule string
+	// Fixable reports whether ConfigureBranchProtection can resolve the issue
+	Fixable bool
+}
+
+// CheckBranchProtection inspects protection rules on the mirror branch and
+// returns the rules that would block the sync workflow from pushing.
+func (c *Client) CheckBranchProtection(ctx context.Context) ([]ProtectionIssue, error) {
+	protection, err := c.branchProtection(ctx)
+	if err != nil || protection == nil {
+		return nil, err
+	}
+
+	var issues []ProtectionIssue
+	if reviews := protection.RequiredPullRequestReviews; reviews != nil && !c.bypassesReviews(reviews) {
+		issues = append(issues, ProtectionIssue{Rule: "pull request reviews are required", Fixable: true})
+	}
+	if restrictions := protection.Restrictions; restrictions != nil && !c.bypassesRestrictions(restrictions) {
+		issues = append(issues, ProtectionIssue{Rule: "pushes are restricted to specific users, teams or apps", Fixable: true})
+	}
+	if checks := protection.RequiredStatusChecks; checks != nil && len(checks.GetContexts())+len(checks.GetChecks()) > 0 {
+		issues = append(issues, ProtectionIssue{Rule: "status checks are required"})
+	}
+	if c.cfg.ForceSync && (protection.AllowForcePushes == nil || !protection.AllowForcePushes.Enabled) {
+		issues = append(issues, ProtectionIssue{Rule: "force pushes are not allowed but --force is enabled"})
+	}
+	if protection.RequiredSignatures != nil && protection.RequiredSignatures.GetEnabled() {
+		issues = append(issues, ProtectionIssue{Rule: "signed commits are required"})
+	}
+	if protection.LockBranch != nil && protection.LockBranch.GetEnabled() {
+		issues = append(issues, ProtectionIssue{Rule: "the branch is locked"})
+	}
+
+	return issues, nil
+}
+
+// ConfigureBranchProtection adds the sync app to the push restrictions and
+// pull request review bypass list of the mirror branch. It requires a token
+// with admin access to the repository.
+func (c *Client) ConfigureBranchProtection(ctx context.Context) error {
+	protection, err := c.branchProtection(ctx)
+	if err != nil || protection == nil {
+		return err
+	}
+	branch := c.cfg.MirrorBranch
+	app := c.cfg.BypassApp
+
+	if restrictions := protection.Restrictions; restrictions != nil && !c.bypassesRestrictions(restrictions) {
+		_, _, err := c.api.Repositories().AddAppRestrictions(ctx, c.owner, c.repo, branch, []string{app})
+		c.audit(audit.OpUpdateProtection, branch, "allow app "+app+" to push", err)
+		if err != nil {
+			return fmt.Errorf("failed to allow app %s to push to %s: %w", app, branch, err)
+		}
+		c.log.Info("Allowed app to push to protected branch", "app", app, "branch", branch)
+	}
+
+	if reviews := protection.RequiredPullRequestReviews; reviews != nil && !c.bypassesReviews(reviews) {
+		bypass := &github.BypassPullRequestAllowancesRequest{Users: []string{}, Teams: []string{}, Apps: []string{app}}
+		if existing := reviews.BypassPullRequestAllowances; existing != nil {
+			for _, user := range existing.Users {
+				bypass.Users = append(bypass.Users, user.GetLogin())
+			}
+			for _, team := range existing.Teams {
+				bypass.Teams = append(bypass.Teams, team.GetSlug())
+			}
+			for _, existingApp := range existing.Apps {
+				bypass.Apps = append(bypass.Apps, existingApp.GetSlug())
+			}
+		}
+
+		_, _, err := c.api.Repositories().UpdatePullRequestReviewEnforcement(ctx, c.owner, c.repo, branch,
+			&github.PullRequestReviewsEnforcementUpdate{
+				BypassPullRequestAllowancesRequest: bypass,
+				RequiredApprovingReviewCount:       reviews.RequiredApprovingReviewCount,
+			})
+		c.audit(audit.OpUpdateProtection, branch, "allow app "+app+" to bypass reviews", err)
+		if err != nil {
+			return fmt.Errorf("failed to add app %s to the review bypass list of %s: %w", app, branch, err)
+		}
+		c.log.Info("Added app to pull request review bypass list", "app", app, "branch", branch)
+	}
+
+	return nil
+}
+
+// branchProtection returns the protection of the mirror branch, or nil when
+// the branch is unprotected or does not exist yet.
+func (c *Client) branchProtection(ctx context.Context) (*github.Protection, error) {
+	var protection *github.Protection
+	err := c.withRetry(ctx, "get branch protection", func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		protection, resp, err = c.api.Repositories().GetBranchProtection(ctx, c.owner, c.repo, c.cfg.MirrorBranch)
+		return resp, err
+	})
+
+	var errResp *github.ErrorResponse
+	if errors.Is(err, github.ErrBranchNotProtected) ||
+		errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get protection of branch %s: %w", c.cfg.MirrorBranch, err)
+	}
+
+	return protection, nil
+}
+
+// bypassesReviews reports whether the sync app may bypass pull request reviews.
+func (c *Client) bypassesReviews(reviews *github.PullRequestReviewsEnforcement) bool {
+	if reviews.BypassPullRequestAllowances == nil {
+		return false
+	}
+	for _, app := range reviews.BypassPullRequestAllowances.Apps {
+		if app.GetSlug() == c.cfg.BypassApp {
+			return true
+		}
+	}
+	return false
+}
+
+// bypassesRestrictions reports whether the sync app is allowed to push.
+func (c *Client) bypassesRestrictions(restrictions *github.BranchRestrictions) bool {
+	for _, app := range restrictions.Apps {
+		if app.GetSlug() == c.cfg.BypassApp {
+			return true
+		}
+	}
+	return false
+}
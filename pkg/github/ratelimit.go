@@ -0,0 +1,157 @@
+package github
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+)
+
+const (
+	// maxRateLimitRetries bounds how often a single request is retried after hitting a limit.
+	maxRateLimitRetries = 5
+	// secondaryRateLimitWait is used when a secondary limit response carries no Retry-After header.
+	secondaryRateLimitWait = time.Minute
+	// lowRateLimitThreshold is the remaining request count below which a warning is logged.
+	lowRateLimitThreshold = 50
+)
+
+// rateLimitTransport retries requests rejected by GitHub's primary or secondary
+// rate limits after waiting for the limit to reset.
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	log     *logger.Logger
+	maxWait time.Duration
+}
+
+// newRateLimitTransport wraps base with rate limit handling. Waits longer than
+// maxWait are not attempted; a zero maxWait disables waiting entirely.
+func newRateLimitTransport(base http.RoundTripper, log *logger.Logger, maxWait time.Duration) *rateLimitTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &rateLimitTransport{base: base, log: log, maxWait: maxWait}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+		t.log.Trace("GitHub API request", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode)
+
+		t.logRemaining(resp)
+
+		wait, limited := rateLimitWait(resp)
+		if !limited || attempt >= maxRateLimitRetries || req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+		if wait > t.maxWait {
+			t.log.Warn("GitHub rate limit exceeded, wait exceeds maximum",
+				"url", req.URL.String(), "wait", wait, "max_wait", t.maxWait)
+			return resp, nil
+		}
+
+		t.log.Warn("GitHub rate limit exceeded, waiting before retry",
+			"url", req.URL.String(), "wait", wait, "attempt", attempt+1)
+		drainBody(resp)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// logRemaining warns when the primary rate limit is close to exhaustion.
+func (t *rateLimitTransport) logRemaining(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	if remaining < lowRateLimitThreshold {
+		t.log.Warn("GitHub rate limit nearly exhausted", "remaining", remaining, "reset", resetTime(resp))
+		return
+	}
+	t.log.Debug("GitHub rate limit", "remaining", remaining)
+}
+
+// rateLimitWait reports whether resp was rejected by a rate limit and how
+// long to wait before retrying.
+func rateLimitWait(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	// Secondary limits and abuse detection announce the wait explicitly
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	// Primary limit: wait until the window resets
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset := resetTime(resp); !reset.IsZero() {
+			return max(time.Until(reset), 0) + time.Second, true
+		}
+	}
+
+	// Secondary limits without Retry-After are only recognizable by the message
+	if isSecondaryRateLimit(resp) {
+		return secondaryRateLimitWait, true
+	}
+
+	return 0, false
+}
+
+// resetTime returns the time at which the primary rate limit window resets.
+func resetTime(resp *http.Response) time.Time {
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(reset, 0)
+}
+
+// isSecondaryRateLimit inspects the response body for a secondary rate limit
+// message, restoring the body so callers can still read it.
+func isSecondaryRateLimit(resp *http.Response) bool {
+	if resp.Body == nil {
+		return false
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(data)), "secondary rate limit")
+}
+
+// drainBody discards and closes a response body so the connection can be reused.
+func drainBody(resp *http.Response) {
+	if resp.Body != nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
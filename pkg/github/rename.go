@@ -0,0 +1,39 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// FollowRenames looks up the mirror repository and, when GitHub redirected the
+// request because the repository was renamed or transferred, switches the
+// client and configuration over to the canonical owner and name.
+// It returns the previous mirror URL, or an empty string if nothing changed.
+func (c *Client) FollowRenames(ctx context.Context) (string, error) {
+	repository, _, err := c.api.Repositories().Get(ctx, c.owner, c.repo)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up mirror repository: %w", err)
+	}
+	return c.followRename(repository), nil
+}
+
+// followRename updates the client when repository, as returned by the API,
+// has a different full name than the configured mirror.
+func (c *Client) followRename(repository *github.Repository) string {
+	owner, repo := repository.GetOwner().GetLogin(), repository.GetName()
+	if owner == "" || repo == "" || strings.EqualFold(owner+"/"+repo, c.owner+"/"+c.repo) {
+		return ""
+	}
+
+	previous := c.cfg.MirrorRepo
+	c.log.Warn("Mirror repository was renamed or transferred, using its canonical name",
+		"old", c.owner+"/"+c.repo,
+		"new", owner+"/"+repo)
+
+	c.owner, c.repo = owner, repo
+	c.cfg.MirrorRepo = repository.GetHTMLURL()
+	return previous
+}
@@ -0,0 +1,57 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// withRetry runs fn, retrying with exponential backoff while it fails with a
+// server error or a network error. Other failures are returned immediately.
+func (c *Client) withRetry(ctx context.Context, op string, fn func() (*github.Response, error)) error {
+	delay := c.cfg.RetryDelay
+
+	for attempt := 0; ; attempt++ {
+		resp, err := fn()
+		if err == nil || attempt >= c.cfg.Retries || !isTransient(ctx, resp, err) {
+			return err
+		}
+
+		c.log.Warn("Transient GitHub API error, retrying",
+			"operation", op, "attempt", attempt+1, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// isTransient reports whether a failed API call may succeed when retried.
+func isTransient(ctx context.Context, resp *github.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if resp != nil && resp.Response != nil {
+		return resp.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// IsAPIError reports whether err is an error response of the GitHub API,
+// including rate limit rejections.
+func IsAPIError(err error) bool {
+	var errResp *github.ErrorResponse
+	var rateErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	return errors.As(err, &errResp) || errors.As(err, &rateErr) || errors.As(err, &abuseErr)
+}
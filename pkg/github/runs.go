@@ -0,0 +1,118 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+)
+
+// runPollInterval is how often the state of a dispatched workflow run is checked.
+const runPollInterval = 10 * time.Second
+
+// VerifyRun dispatches the sync workflow and waits for the resulting run to
+// complete, returning an error unless it concludes successfully.
+func (c *Client) VerifyRun(ctx context.Context, timeout time.Duration) (*github.WorkflowRun, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Runs created before the dispatch belong to earlier triggers, allowing for clock skew
+	dispatched := time.Now().Add(-time.Minute)
+	if err := c.DispatchWorkflow(ctx); err != nil {
+		return nil, err
+	}
+
+	workflowFile := path.Base(workflowPath)
+	run, err := c.waitForRun(ctx, workflowFile, dispatched)
+	if err != nil {
+		return nil, err
+	}
+
+	c.log.Info("Workflow run finished",
+		"run_id", run.GetID(),
+		"conclusion", run.GetConclusion(),
+		"url", run.GetHTMLURL())
+
+	if run.GetConclusion() != "success" {
+		return run, fmt.Errorf("workflow run %d concluded with %q, see %s", run.GetID(), run.GetConclusion(), run.GetHTMLURL())
+	}
+	return run, nil
+}
+
+// DispatchWorkflow triggers a run of the sync workflow on the default branch
+// of the mirror repository.
+func (c *Client) DispatchWorkflow(ctx context.Context) error {
+	repository, _, err := c.api.Repositories().Get(ctx, c.owner, c.repo)
+	if err != nil {
+		return fmt.Errorf("failed to look up mirror repository: %w", err)
+	}
+	ref := repository.GetDefaultBranch()
+	workflowFile := path.Base(workflowPath)
+
+	c.log.Info("Dispatching sync workflow run", "workflow", workflowFile, "ref", ref)
+	err = c.withRetry(ctx, "dispatch workflow", func() (*github.Response, error) {
+		return c.api.Actions().CreateWorkflowDispatchEventByFileName(ctx, c.owner, c.repo, workflowFile,
+			github.CreateWorkflowDispatchEventRequest{Ref: ref})
+	})
+	c.audit(audit.OpDispatchWorkflow, workflowFile, ref, err)
+	if err != nil {
+		return fmt.Errorf("failed to dispatch workflow: %w", err)
+	}
+	return nil
+}
+
+// waitForRun polls until the dispatched run of workflowFile appears and completes.
+func (c *Client) waitForRun(ctx context.Context, workflowFile string, since time.Time) (*github.WorkflowRun, error) {
+	var runID int64
+
+	ticker := time.NewTicker(runPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if runID == 0 {
+				return nil, fmt.Errorf("dispatched workflow run did not start: %w", ctx.Err())
+			}
+			return nil, fmt.Errorf("workflow run %d did not complete: %w", runID, ctx.Err())
+		case <-ticker.C:
+		}
+
+		if runID == 0 {
+			runs, _, err := c.api.Actions().ListWorkflowRunsByFileName(ctx, c.owner, c.repo, workflowFile,
+				&github.ListWorkflowRunsOptions{
+					Event:   "workflow_dispatch",
+					Created: ">=" + since.UTC().Format(time.RFC3339),
+				})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list workflow runs: %w", err)
+			}
+			if len(runs.WorkflowRuns) == 0 {
+				c.log.Debug("Waiting for dispatched workflow run to appear")
+				continue
+			}
+			runID = runs.WorkflowRuns[0].GetID()
+			c.log.Info("Workflow run started", "run_id", runID, "url", runs.WorkflowRuns[0].GetHTMLURL())
+		}
+
+		run, _, err := c.api.Actions().GetWorkflowRunByID(ctx, c.owner, c.repo, runID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get workflow run %d: %w", runID, err)
+		}
+		if run.GetStatus() == "completed" {
+			return run, nil
+		}
+		c.log.Debug("Waiting for workflow run to complete", "run_id", runID, "status", run.GetStatus())
+	}
+}
+
+// DispatchURL returns the API endpoint that triggers the sync workflow via a
+// workflow_dispatch event.
+func (c *Client) DispatchURL() string {
+	return fmt.Sprintf("%srepos/%s/%s/actions/workflows/%s/dispatches",
+		c.api.BaseURL(), c.owner, c.repo, path.Base(workflowPath))
+}
@@ -0,0 +1,141 @@
+package github
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/v61/github"
+	"golang.org/x/crypto/nacl/box"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+// SetSecret encrypts value with the repository's public key and stores it as
+// a GitHub Actions secret named name. When an environment is configured the
+// secret is scoped to that environment.
+func (c *Client) SetSecret(ctx context.Context, name, value string) error {
+	if err := config.ValidateSecretName(name); err != nil {
+		return err
+	}
+
+	env := c.cfg.Environment
+	var repoID int
+	var publicKey *github.PublicKey
+	var err error
+
+	if env == "" {
+		publicKey, _, err = c.api.Actions().GetRepoPublicKey(ctx, c.owner, c.repo)
+	} else {
+		var repository *github.Repository
+		repository, _, err = c.api.Repositories().Get(ctx, c.owner, c.repo)
+		if err != nil {
+			return fmt.Errorf("failed to look up mirror repository: %w", err)
+		}
+		repoID = int(repository.GetID())
+		publicKey, _, err = c.api.Actions().GetEnvPublicKey(ctx, repoID, env)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get repository public key: %w", err)
+	}
+
+	encrypted, err := sealSecret(publicKey.GetKey(), value)
+	if err != nil {
+		return err
+	}
+
+	secret := &github.EncryptedSecret{
+		Name:           name,
+		KeyID:          publicKey.GetKeyID(),
+		EncryptedValue: encrypted,
+	}
+	if env == "" {
+		_, err = c.api.Actions().CreateOrUpdateRepoSecret(ctx, c.owner, c.repo, secret)
+	} else {
+		_, err = c.api.Actions().CreateOrUpdateEnvSecret(ctx, repoID, env, secret)
+	}
+	c.audit(audit.OpSetSecret, name, env, err)
+	if err != nil {
+		return fmt.Errorf("failed to upload secret %s: %w", name, err)
+	}
+
+	c.log.Info("Repository secret set", "owner", c.owner, "repo", c.repo, "name", name, "environment", env)
+	return nil
+}
+
+// HasSecret reports whether the Actions secret name exists on the mirror,
+// in the configured environment if there is one. A missing repository or
+// environment has no secrets.
+func (c *Client) HasSecret(ctx context.Context, name string) (bool, error) {
+	var resp *github.Response
+	var err error
+
+	if env := c.cfg.Environment; env == "" {
+		_, resp, err = c.api.Actions().GetRepoSecret(ctx, c.owner, c.repo, name)
+	} else {
+		var repository *github.Repository
+		repository, resp, err = c.api.Repositories().Get(ctx, c.owner, c.repo)
+		if err == nil {
+			_, resp, err = c.api.Actions().GetEnvSecret(ctx, int(repository.GetID()), env, name)
+		}
+	}
+	if err == nil {
+		return true, nil
+	}
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to look up secret %s: %w", name, err)
+}
+
+// DeleteSecret deletes the Actions secret name from the mirror, in the
+// configured environment if there is one. It reports whether it existed.
+func (c *Client) DeleteSecret(ctx context.Context, name string) (bool, error) {
+	var resp *github.Response
+	var err error
+
+	if env := c.cfg.Environment; env == "" {
+		resp, err = c.api.Actions().DeleteRepoSecret(ctx, c.owner, c.repo, name)
+	} else {
+		var repository *github.Repository
+		repository, resp, err = c.api.Repositories().Get(ctx, c.owner, c.repo)
+		if err == nil {
+			resp, err = c.api.Actions().DeleteEnvSecret(ctx, int(repository.GetID()), env, name)
+		}
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		c.audit(audit.OpDeleteSecret, name, c.cfg.Environment, err)
+	}
+	if err == nil {
+		c.log.Info("Repository secret deleted", "owner", c.owner, "repo", c.repo, "name", name, "environment", c.cfg.Environment)
+		return true, nil
+	}
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to delete secret %s: %w", name, err)
+}
+
+// sealSecret encrypts value as a libsodium sealed box for the base64 encoded public key.
+func sealSecret(encodedKey, value string) (string, error) {
+	keyBytes, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode repository public key: %w", err)
+	}
+	if len(keyBytes) != 32 {
+		return "", fmt.Errorf("unexpected repository public key length: %d", len(keyBytes))
+	}
+
+	var recipient [32]byte
+	copy(recipient[:], keyBytes)
+
+	sealed, err := box.SealAnonymous(nil, []byte(value), &recipient, rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
@@ -0,0 +1,110 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// syncedSHAPattern matches the marker line the generated sync script logs after a sync.
+var syncedSHAPattern = regexp.MustCompile(`Synced primary commit: ([0-9a-f]{40})`)
+
+// RunStatus summarizes a single run of the sync workflow.
+type RunStatus struct {
+	ID         int64
+	Event      string
+	Status     string
+	Conclusion string
+	StartedAt  time.Time
+	Duration   time.Duration
+	SyncedSHA  string
+	URL        string
+}
+
+// RecentRuns returns up to limit of the most recent sync workflow runs. When
+// withSHA is set, job logs are downloaded to determine the synced primary commit.
+func (c *Client) RecentRuns(ctx context.Context, limit int, withSHA bool) ([]RunStatus, error) {
+	var runs *github.WorkflowRuns
+	err := c.withRetry(ctx, "list workflow runs", func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		runs, resp, err = c.api.Actions().ListWorkflowRunsByFileName(ctx, c.owner, c.repo, path.Base(workflowPath),
+			&github.ListWorkflowRunsOptions{ListOptions: github.ListOptions{PerPage: limit}})
+		return resp, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflow runs: %w", err)
+	}
+
+	statuses := make([]RunStatus, 0, len(runs.WorkflowRuns))
+	for _, run := range runs.WorkflowRuns {
+		if len(statuses) >= limit {
+			break
+		}
+
+		status := RunStatus{
+			ID:         run.GetID(),
+			Event:      run.GetEvent(),
+			Status:     run.GetStatus(),
+			Conclusion: run.GetConclusion(),
+			StartedAt:  run.GetRunStartedAt().Time,
+			URL:        run.GetHTMLURL(),
+		}
+		if status.Status == "completed" {
+			status.Duration = run.GetUpdatedAt().Sub(status.StartedAt)
+		}
+
+		if withSHA && status.Conclusion == "success" {
+			sha, err := c.syncedSHA(ctx, status.ID)
+			if err != nil {
+				c.log.Debug("Could not determine synced commit", "run_id", status.ID, "error", err)
+			}
+			status.SyncedSHA = sha
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// syncedSHA extracts the synced primary commit from the logs of a run's jobs.
+func (c *Client) syncedSHA(ctx context.Context, runID int64) (string, error) {
+	jobs, _, err := c.api.Actions().ListWorkflowJobs(ctx, c.owner, c.repo, runID, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	for _, job := range jobs.Jobs {
+		logURL, _, err := c.api.Actions().GetWorkflowJobLogs(ctx, c.owner, c.repo, job.GetID(), 2)
+		if err != nil {
+			return "", fmt.Errorf("failed to locate job logs: %w", err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, logURL.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("failed to download job logs: %w", err)
+		}
+		logs, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to read job logs: %w", err)
+		}
+
+		if match := syncedSHAPattern.FindSubmatch(logs); match != nil {
+			return string(match[1]), nil
+		}
+	}
+
+	return "", fmt.Errorf("no synced commit marker found in logs")
+}
@@ -0,0 +1,72 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// syncStatePath is the file the generated workflow records each sync in
+// with --record-state.
+const syncStatePath = ".github/sync-state.json"
+
+// SyncState is the last sync of a mirror branch recorded by the generated
+// workflow with --record-state.
+type SyncState struct {
+	PrimaryRepo     string    `json:"primary_repo"`
+	PrimaryBranch   string    `json:"primary_branch"`
+	MirrorBranch    string    `json:"mirror_branch"`
+	SyncedSHA       string    `json:"synced_sha"`
+	SyncedAt        time.Time `json:"synced_at"`
+	ToolVersion     string    `json:"tool_version"`
+	TemplateVersion int       `json:"template_version"`
+}
+
+// SyncStateRef returns the ref on the mirror holding the sync state of
+// branch, shared with the generated workflow.
+func SyncStateRef(branch string) string {
+	return "refs/gh-mirror/state/" + branch
+}
+
+// SyncState reads the sync state of the mirror branch recorded by the
+// workflow. A nil state and nil error are returned when the workflow has not
+// recorded one, e.g. because it runs without --record-state.
+func (c *Client) SyncState(ctx context.Context) (*SyncState, error) {
+	var ref *github.Reference
+	err := c.withRetry(ctx, "get sync state ref", func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		ref, resp, err = c.api.Git().GetRef(ctx, c.owner, c.repo, SyncStateRef(c.cfg.MirrorBranch))
+		return resp, err
+	})
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up sync state: %w", err)
+	}
+
+	file, err := c.getFile(ctx, syncStatePath, ref.GetObject().GetSHA())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sync state: %w", err)
+	}
+	if file == nil {
+		return nil, nil
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sync state: %w", err)
+	}
+
+	var state SyncState
+	if err := json.Unmarshal([]byte(content), &state); err != nil {
+		return nil, fmt.Errorf("failed to parse sync state: %w", err)
+	}
+	return &state, nil
+}
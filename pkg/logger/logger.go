@@ -2,24 +2,129 @@
 package logger
 
 import (
+	"fmt"
 	"os"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"golang.org/x/term"
 )
 
+// Log output formats
+const (
+	// FormatText writes human-readable lines to stdout
+	FormatText = "text"
+	// FormatJSON writes one JSON object per line to stderr, keeping stdout
+	// free for command output such as the generated workflow
+	FormatJSON = "json"
+)
+
+// Color modes of text logs
+const (
+	// ColorAuto colors levels on terminals and in CI logs that render
+	// colors, unless NO_COLOR is set
+	ColorAuto = "auto"
+	// ColorAlways colors levels wherever the logs go
+	ColorAlways = "always"
+	// ColorNever never colors levels
+	ColorNever = "never"
+)
+
+// TraceLevel logs details below debug, such as each GitHub API request.
+const TraceLevel = zapcore.DebugLevel - 1
+
+// colorCIVariables are set by CI systems whose log viewers render ANSI
+// colors although the output is not a terminal.
+var colorCIVariables = []string{"GITHUB_ACTIONS", "GITLAB_CI", "GITEA_ACTIONS", "FORGEJO_ACTIONS"}
+
+var (
+	// level is the minimum level of loggers created by New
+	level = zapcore.WarnLevel
+
+	// format is the output format of loggers created by New
+	format = FormatText
+
+	// color is the color mode of loggers created by New
+	color = ColorAuto
+
+	// stderr sends text logs to stderr as well
+	stderr bool
+
+	// warnings records the warnings of loggers created by New, if set
+	warnings *Warnings
+)
+
+// SetVerbosity sets the level of loggers created afterwards from the number
+// of -v flags given: warnings and errors only without any, then info, debug
+// and trace messages.
+func SetVerbosity(verbosity int) {
+	switch {
+	case verbosity <= 0:
+		level = zapcore.WarnLevel
+	case verbosity == 1:
+		level = zapcore.InfoLevel
+	case verbosity == 2:
+		level = zapcore.DebugLevel
+	default:
+		level = TraceLevel
+	}
+}
+
+// SetFormat sets the output format of loggers created afterwards.
+func SetFormat(f string) error {
+	switch f {
+	case FormatText, FormatJSON:
+		format = f
+		return nil
+	}
+	return fmt.Errorf("invalid log format %q (must be %s or %s)", f, FormatText, FormatJSON)
+}
+
+// SetColor sets the color mode of text logs of loggers created afterwards.
+func SetColor(mode string) error {
+	switch mode {
+	case ColorAuto, ColorAlways, ColorNever:
+		color = mode
+		return nil
+	}
+	return fmt.Errorf("invalid color mode %q (must be %s, %s or %s)", mode, ColorAuto, ColorAlways, ColorNever)
+}
+
+// colored reports whether text logs written to output are colored.
+func colored(output *os.File) bool {
+	switch color {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	for _, name := range colorCIVariables {
+		if os.Getenv(name) == "true" {
+			return true
+		}
+	}
+	return term.IsTerminal(int(output.Fd()))
+}
+
+// LogToStderr sends the logs of loggers created afterwards to stderr in any
+// format, for commands whose stdout is parsed.
+func LogToStderr() {
+	stderr = true
+}
+
 // Logger wraps zap.Logger to provide a simpler interface.
 type Logger struct {
 	*zap.SugaredLogger
 }
 
-// New creates a new Logger instance.
-func New(debug bool) *Logger {
-	level := zapcore.InfoLevel
-	if debug {
-		level = zapcore.DebugLevel
-	}
-
+// New creates a new Logger instance with the level, format and color mode
+// set so far. Credentials in messages and fields are masked, see package
+// redact.
+func New() *Logger {
 	encoderConfig := zapcore.EncoderConfig{
 		TimeKey:        "time",
 		LevelKey:       "level",
@@ -28,22 +133,76 @@ func New(debug bool) *Logger {
 		MessageKey:     "msg",
 		StacktraceKey:  "stacktrace",
 		LineEnding:     zapcore.DefaultLineEnding,
-		EncodeLevel:    zapcore.CapitalLevelEncoder,
+		EncodeLevel:    traceLevelEncoder(zapcore.CapitalLevelEncoder, "TRACE"),
 		EncodeTime:     zapcore.ISO8601TimeEncoder,
 		EncodeDuration: zapcore.SecondsDurationEncoder,
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 
+	output := os.Stdout
+	if format == FormatJSON || stderr {
+		output = os.Stderr
+	}
+
+	var encoder zapcore.Encoder
+	switch {
+	case format == FormatJSON:
+		encoderConfig.EncodeLevel = traceLevelEncoder(zapcore.LowercaseLevelEncoder, "trace")
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case colored(output):
+		encoderConfig.EncodeLevel = traceLevelEncoder(zapcore.CapitalColorLevelEncoder, "\x1b[35mTRACE\x1b[0m")
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	}
+
 	core := zapcore.NewCore(
-		zapcore.NewConsoleEncoder(encoderConfig),
-		zapcore.Lock(os.Stdout),
+		encoder,
+		zapcore.Lock(output),
 		level,
 	)
 
-	return &Logger{zap.New(core).Sugar()}
+	return &Logger{zap.New(redactedCore(core)).Sugar()}
+}
+
+// traceLevelEncoder encodes TraceLevel, which zap does not know, as name and
+// the other levels with encode.
+func traceLevelEncoder(encode zapcore.LevelEncoder, name string) zapcore.LevelEncoder {
+	return func(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+		if l == TraceLevel {
+			enc.AppendString(name)
+			return
+		}
+		encode(l, enc)
+	}
 }
 
 // With adds structured context to the logger.
 func (l *Logger) With(args ...interface{}) *Logger {
 	return &Logger{l.SugaredLogger.With(args...)}
 }
+
+// Trace logs a message with alternating key-value pairs as structured context.
+func (l *Logger) Trace(msg string, keysAndValues ...interface{}) {
+	l.SugaredLogger.Logw(TraceLevel, msg, keysAndValues...)
+}
+
+// Debug logs a message with alternating key-value pairs as structured context.
+func (l *Logger) Debug(msg string, keysAndValues ...interface{}) {
+	l.SugaredLogger.Debugw(msg, keysAndValues...)
+}
+
+// Info logs a message with alternating key-value pairs as structured context.
+func (l *Logger) Info(msg string, keysAndValues ...interface{}) {
+	l.SugaredLogger.Infow(msg, keysAndValues...)
+}
+
+// Warn logs a message with alternating key-value pairs as structured context.
+func (l *Logger) Warn(msg string, keysAndValues ...interface{}) {
+	l.SugaredLogger.Warnw(msg, keysAndValues...)
+}
+
+// Error logs a message with alternating key-value pairs as structured context.
+func (l *Logger) Error(msg string, keysAndValues ...interface{}) {
+	l.SugaredLogger.Errorw(msg, keysAndValues...)
+}
@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+)
+
+// redactCore is a zapcore.Core that masks credentials in the messages and
+// fields of entries before passing them to core, so that no logger output,
+// including recorded warnings, contains tokens or URL credentials.
+type redactCore struct {
+	core zapcore.Core
+}
+
+// redactedCore returns core, teed with the core recording warnings if they
+// are recorded, each masking credentials on its own: a redactCore wrapping
+// the tee would pass every entry to both cores.
+func redactedCore(core zapcore.Core) zapcore.Core {
+	redacted := zapcore.Core(&redactCore{core: core})
+	if warnings != nil {
+		redacted = zapcore.NewTee(redacted, &redactCore{core: &warningCore{warnings: warnings}})
+	}
+	return redacted
+}
+
+func (c *redactCore) Enabled(level zapcore.Level) bool {
+	return c.core.Enabled(level)
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{core: c.core.With(redactFields(fields))}
+}
+
+func (c *redactCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = redact.String(entry.Message)
+	entry.Stack = redact.String(entry.Stack)
+	return c.core.Write(entry, redactFields(fields))
+}
+
+func (c *redactCore) Sync() error {
+	return c.core.Sync()
+}
+
+// redactFields returns fields with the credentials in their values masked.
+// Numbers, booleans, durations and times are kept; the other fields are
+// encoded, so that errors, stringers and structures are masked as they
+// would be logged.
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, 0, len(fields))
+	for _, field := range fields {
+		switch field.Type {
+		case zapcore.StringType:
+			field.String = redact.String(field.String)
+			redacted = append(redacted, field)
+			continue
+		case zapcore.ErrorType, zapcore.StringerType, zapcore.ReflectType, zapcore.ByteStringType,
+			zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType, zapcore.InlineMarshalerType:
+		default:
+			redacted = append(redacted, field)
+			continue
+		}
+
+		enc := zapcore.NewMapObjectEncoder()
+		field.AddTo(enc)
+		for key, value := range enc.Fields {
+			redacted = append(redacted, zap.Any(key, redactValue(value)))
+		}
+	}
+	return redacted
+}
+
+// redactValue masks the strings in a value encoded by a
+// zapcore.MapObjectEncoder.
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return redact.String(v)
+	case []string:
+		redacted := make([]string, len(v))
+		for i := range v {
+			redacted[i] = redact.String(v[i])
+		}
+		return redacted
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = redactValue(v[key])
+		}
+	}
+	return value
+}
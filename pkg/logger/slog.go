@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SlogTraceLevel is the slog level of trace messages, below slog.LevelDebug.
+const SlogTraceLevel = slog.LevelDebug - 4
+
+// NewWithHandler creates a Logger that passes its messages to handler, so
+// that programs using the packages of gh-mirror can route their logs into
+// their own logging, e.g. with slog.Default().Handler(). The handler decides
+// which levels are logged and how; the level, format and color mode set
+// for New are ignored. Credentials are masked before the handler sees them.
+func NewWithHandler(handler slog.Handler) *Logger {
+	return &Logger{zap.New(redactedCore(&slogCore{handler: handler})).Sugar()}
+}
+
+// slogCore is a zapcore.Core that passes entries to a slog.Handler.
+type slogCore struct {
+	handler slog.Handler
+}
+
+func (c *slogCore) Enabled(level zapcore.Level) bool {
+	return c.handler.Enabled(context.Background(), slogLevel(level))
+}
+
+func (c *slogCore) With(fields []zapcore.Field) zapcore.Core {
+	return &slogCore{handler: c.handler.WithAttrs(slogAttrs(fields))}
+}
+
+func (c *slogCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *slogCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	record := slog.NewRecord(entry.Time, slogLevel(entry.Level), entry.Message, 0)
+	record.AddAttrs(slogAttrs(fields)...)
+	return c.handler.Handle(context.Background(), record)
+}
+
+func (c *slogCore) Sync() error {
+	return nil
+}
+
+// slogLevel returns the slog level of a zap level.
+func slogLevel(level zapcore.Level) slog.Level {
+	switch {
+	case level <= TraceLevel:
+		return SlogTraceLevel
+	case level == zapcore.DebugLevel:
+		return slog.LevelDebug
+	case level == zapcore.InfoLevel:
+		return slog.LevelInfo
+	case level == zapcore.WarnLevel:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
+
+// slogAttrs returns fields as slog attributes, sorted by key.
+func slogAttrs(fields []zapcore.Field) []slog.Attr {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+
+	keys := make([]string, 0, len(enc.Fields))
+	for key := range enc.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, key := range keys {
+		attrs = append(attrs, slog.Any(key, enc.Fields[key]))
+	}
+	return attrs
+}
@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Warning is a logged warning with its structured context.
+type Warning struct {
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Warnings collects the warnings logged by loggers created after
+// RecordWarnings, so that they can be reported along with a command's result.
+type Warnings struct {
+	mu       sync.Mutex
+	recorded []Warning
+}
+
+// RecordWarnings starts recording the warnings of loggers created afterwards.
+func RecordWarnings() *Warnings {
+	warnings = &Warnings{}
+	return warnings
+}
+
+// List returns the warnings recorded so far.
+func (w *Warnings) List() []Warning {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Warning{}, w.recorded...)
+}
+
+// warningCore is a zapcore.Core that records warning entries.
+type warningCore struct {
+	warnings *Warnings
+	fields   []zapcore.Field
+}
+
+func (c *warningCore) Enabled(level zapcore.Level) bool {
+	return level == zapcore.WarnLevel
+}
+
+func (c *warningCore) With(fields []zapcore.Field) zapcore.Core {
+	return &warningCore{
+		warnings: c.warnings,
+		fields:   append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+func (c *warningCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *warningCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range c.fields {
+		field.AddTo(enc)
+	}
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+
+	warning := Warning{Message: entry.Message}
+	if len(enc.Fields) > 0 {
+		warning.Fields = enc.Fields
+	}
+
+	c.warnings.mu.Lock()
+	c.warnings.recorded = append(c.warnings.recorded, warning)
+	c.warnings.mu.Unlock()
+	return nil
+}
+
+func (c *warningCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,113 @@
+// Package manifest records what was set up on each mirror, so that it can be
+// uninstalled later.
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Secret is an Actions secret stored on a mirror.
+type Secret struct {
+	Name        string `json:"name"`
+	Environment string `json:"environment,omitempty"`
+}
+
+// Webhook is a push webhook registered on the primary of a mirror.
+type Webhook struct {
+	Primary string `json:"primary"`
+	Forge   string `json:"forge"`
+	URL     string `json:"url"`
+}
+
+// Install records what was set up on one mirror.
+type Install struct {
+	// WorkflowFormat is the format of the installed workflow, empty when
+	// no workflow was installed
+	WorkflowFormat string `json:"workflow_format,omitempty"`
+	// ReadmeBanner is set when the mirror notice was added to the README
+	ReadmeBanner bool `json:"readme_banner,omitempty"`
+	// DeployKey is set when a deploy key was registered on the mirror
+	DeployKey bool      `json:"deploy_key,omitempty"`
+	Secrets   []Secret  `json:"secrets,omitempty"`
+	Webhooks  []Webhook `json:"webhooks,omitempty"`
+}
+
+// AddSecret records a secret unless it is recorded already.
+func (i *Install) AddSecret(secret Secret) {
+	if !slices.Contains(i.Secrets, secret) {
+		i.Secrets = append(i.Secrets, secret)
+	}
+}
+
+// AddWebhook records a webhook unless it is recorded already.
+func (i *Install) AddWebhook(hook Webhook) {
+	if !slices.Contains(i.Webhooks, hook) {
+		i.Webhooks = append(i.Webhooks, hook)
+	}
+}
+
+// Manifest is the persisted record of installs, keyed by mirror repository URL.
+type Manifest struct {
+	path    string
+	Mirrors map[string]*Install `json:"mirrors"`
+}
+
+// Load reads the manifest at path. An empty manifest is returned when
+// nothing has been recorded yet.
+func Load(path string) (*Manifest, error) {
+	m := &Manifest{path: path, Mirrors: make(map[string]*Install)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read install manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse install manifest %s: %w", path, err)
+	}
+	if m.Mirrors == nil {
+		m.Mirrors = make(map[string]*Install)
+	}
+
+	return m, nil
+}
+
+// Mirror returns the record of mirror, adding an empty one if there is none.
+func (m *Manifest) Mirror(mirror string) *Install {
+	install, ok := m.Mirrors[mirror]
+	if !ok {
+		install = &Install{}
+		m.Mirrors[mirror] = install
+	}
+	return install
+}
+
+// Save writes the manifest back to its file.
+func (m *Manifest) Save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode install manifest: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+
+	// Write atomically so an interrupted save cannot corrupt the manifest
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write install manifest: %w", err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("failed to write install manifest: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+// Package mirror is the programmatic interface of gh-mirror. It generates the
+// sync workflow of a primary repository, installs it in GitHub mirrors and
+// syncs mirrors from the calling machine, like the generate, setup and run
+// commands:
+//
+//	cfg := config.Default()
+//	cfg.PrimaryRepo = "https://i2pgit.org/go-i2p/reseed-tools.git"
+//	cfg.MirrorRepos = []string{"https://github.com/go-i2p/reseed-tools"}
+//	cfg.GithubToken = os.Getenv("GH_TOKEN")
+//
+//	results, err := mirror.Setup(ctx, cfg, logger.New())
+//
+// The configuration is used as given; unlike the command line, nothing is
+// read from flags or the environment. Failures can be told apart with
+// errors.Is and the kinds of package config:
+//
+//	if errors.Is(err, config.ErrRepoNotFound) {
+//		// create the mirror, or set cfg.CreateMissing
+//	}
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/git"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/workflow"
+)
+
+// SetupResult describes the installation of the workflow in one mirror.
+type SetupResult struct {
+	Mirror       string
+	WorkflowPath string
+
+	// Commit is the commit adding or updating the workflow, empty when the
+	// workflow was up to date or a pull request was opened
+	Commit string
+
+	// PullRequest is the URL of the pull request adding the workflow when
+	// cfg.SetupViaPR is set
+	PullRequest string
+}
+
+// Generate renders the sync workflow of cfg for cfg.MirrorRepo, or else for
+// the first of cfg.MirrorRepos.
+func Generate(ctx context.Context, cfg *config.Config, log *logger.Logger) (string, error) {
+	if cfg.MirrorRepo == "" && len(cfg.MirrorRepos) > 0 {
+		mirrorCfg := *cfg
+		mirrorCfg.MirrorRepo = cfg.MirrorRepos[0]
+		cfg = &mirrorCfg
+	}
+	if cfg.PrimaryRepo == "" || cfg.MirrorRepo == "" {
+		return "", fmt.Errorf("primary and mirror repository URLs are required")
+	}
+
+	// The SSH host keys of the primary are pinned in the workflow
+	knownHosts, err := git.NewClient(cfg.GitPath, log).HostKeys(ctx, cfg)
+	if err != nil {
+		return "", fmt.Errorf("failed to pin SSH host keys: %w", err)
+	}
+	opts := workflow.OptionsFromConfig(cfg)
+	opts.KnownHosts = knownHosts
+
+	workflowYAML, err := workflow.NewGenerator(opts, log).Generate(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate workflow file: %w", err)
+	}
+	return workflowYAML, nil
+}
+
+// Setup validates the repositories of cfg and installs the generated workflow
+// in each of its mirrors, which must be on GitHub. A failing mirror does not
+// stop the others; the errors of all failed mirrors are returned together.
+func Setup(ctx context.Context, cfg *config.Config, log *logger.Logger) ([]*SetupResult, error) {
+	var results []*SetupResult
+	var errs []error
+	for _, mirror := range mirrors(cfg) {
+		mirrorCfg := *cfg
+		mirrorCfg.MirrorRepo = mirror
+
+		result, err := setup(ctx, &mirrorCfg, log.With("mirror_repo", mirror))
+		result.Mirror = mirror
+		results = append(results, result)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", mirror, err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// setup installs the workflow in the single mirror of cfg.
+func setup(ctx context.Context, cfg *config.Config, log *logger.Logger) (*SetupResult, error) {
+	if !config.IsGitHubURL(cfg.MirrorRepo) {
+		return &SetupResult{}, fmt.Errorf("mirror repository %s is not on GitHub", cfg.MirrorRepo)
+	}
+	if err := git.NewClient(cfg.GitPath, log).ValidateRepos(ctx, cfg); err != nil {
+		return &SetupResult{}, fmt.Errorf("repository validation failed: %w", err)
+	}
+
+	workflowYAML, err := Generate(ctx, cfg, log)
+	if err != nil {
+		return &SetupResult{}, err
+	}
+
+	githubClient, err := github.NewClient(ctx, cfg, log)
+	if err != nil {
+		return &SetupResult{}, fmt.Errorf("failed to create GitHub client: %w", err)
+	}
+	return Install(ctx, cfg, log, githubClient, workflowYAML)
+}
+
+// Install commits workflowYAML to the mirror repository of githubClient,
+// preparing the repository first as requested by cfg: following renames,
+// replacing competing sync workflows, allowing the sync through branch
+// protection and creating the environment and metadata. With
+// cfg.SetupViaPR, the workflow is proposed in a pull request instead, and
+// with cfg.VerifyRun a run of it is awaited.
+func Install(ctx context.Context, cfg *config.Config, log *logger.Logger, githubClient *github.Client, workflowYAML string) (*SetupResult, error) {
+	result := &SetupResult{Mirror: cfg.MirrorRepo, WorkflowPath: githubClient.WorkflowPath()}
+
+	previousMirror := cfg.MirrorRepo
+	if err := githubClient.EnsureRepository(ctx); err != nil {
+		return result, fmt.Errorf("failed to verify mirror repository: %w", err)
+	}
+	if cfg.MirrorRepo != previousMirror {
+		UpdateRemote(ctx, cfg, log, previousMirror)
+	}
+
+	if err := githubClient.Preflight(ctx); err != nil {
+		return result, fmt.Errorf("permission check failed: %w", err)
+	}
+
+	if err := handleExistingWorkflows(ctx, cfg, log, githubClient); err != nil {
+		return result, err
+	}
+
+	if err := handleBranchProtection(ctx, cfg, log, githubClient); err != nil {
+		return result, err
+	}
+
+	if cfg.Environment != "" {
+		if err := githubClient.EnsureEnvironment(ctx); err != nil {
+			return result, err
+		}
+	}
+
+	if cfg.SetMetadata {
+		if err := githubClient.SetMetadata(ctx); err != nil {
+			return result, fmt.Errorf("failed to set mirror repository metadata: %w", err)
+		}
+	}
+
+	if cfg.SetupViaPR {
+		pr, err := githubClient.SetupWorkflowPR(ctx, workflowYAML)
+		if err != nil {
+			return result, fmt.Errorf("failed to open workflow pull request: %w", err)
+		}
+		if pr != nil {
+			log.Info("GitHub workflow pull request ready for review", "url", pr.GetHTMLURL())
+			result.PullRequest = pr.GetHTMLURL()
+		}
+		return result, nil
+	}
+
+	sha, err := githubClient.SetupWorkflow(ctx, workflowYAML)
+	if err != nil {
+		return result, fmt.Errorf("failed to setup GitHub workflow: %w", err)
+	}
+	log.Info("GitHub workflow set up successfully")
+	result.Commit = sha
+
+	if cfg.VerifyRun {
+		if _, err := githubClient.VerifyRun(ctx, cfg.VerifyTimeout); err != nil {
+			return result, fmt.Errorf("workflow verification failed: %w", err)
+		}
+		log.Info("Mirror sync verified successfully")
+	}
+
+	return result, nil
+}
+
+// UpdateRemote rewrites the git remotes of the current directory pointing at
+// previousMirror, the URL of the mirror of cfg before it was renamed, when
+// cfg.UpdateRemote is set, or suggests doing so otherwise.
+func UpdateRemote(ctx context.Context, cfg *config.Config, log *logger.Logger, previousMirror string) {
+	if !cfg.UpdateRemote {
+		log.Info("Pass the new mirror URL or use --update-remote to update local git remotes", "mirror_repo", cfg.MirrorRepo)
+		return
+	}
+
+	gitClient := git.NewClient(cfg.GitPath, log)
+	if _, err := gitClient.UpdateRemoteURL(ctx, previousMirror, cfg.MirrorRepo); err != nil {
+		log.Warn("Failed to update local git remotes", "error", err)
+	}
+}
+
+// handleExistingWorkflows warns about, or removes, sync workflows on the mirror
+// that would compete with the generated one.
+func handleExistingWorkflows(ctx context.Context, cfg *config.Config, log *logger.Logger, githubClient *github.Client) error {
+	existing, err := githubClient.FindSyncWorkflows(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to inspect existing workflows: %w", err)
+	}
+
+	for _, wf := range existing {
+		if !cfg.ReplaceExisting {
+			log.Warn("Found another sync workflow on the mirror, it may compete with the generated one (use --replace-existing to remove it)",
+				"path", wf.Path, "reason", wf.Reason)
+			continue
+		}
+		if err := githubClient.RemoveWorkflow(ctx, wf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handleBranchProtection warns about protection rules on the mirror branch
+// that would block the sync push, and resolves them when requested.
+func handleBranchProtection(ctx context.Context, cfg *config.Config, log *logger.Logger, githubClient *github.Client) error {
+	issues, err := githubClient.CheckBranchProtection(ctx)
+	if err != nil {
+		// Reading protection requires admin access, so this is not fatal
+		log.Warn("Could not check mirror branch protection", "branch", cfg.MirrorBranch, "error", err)
+		return nil
+	}
+
+	fixable := false
+	for _, issue := range issues {
+		if issue.Fixable && cfg.ConfigureProtection {
+			fixable = true
+			continue
+		}
+		log.Warn("Mirror branch protection will block the sync push", "branch", cfg.MirrorBranch, "rule", issue.Rule)
+	}
+
+	if fixable {
+		if err := githubClient.ConfigureBranchProtection(ctx); err != nil {
+			return fmt.Errorf("failed to configure branch protection: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// mirrors returns the mirror repositories of cfg.
+func mirrors(cfg *config.Config) []string {
+	if len(cfg.MirrorRepos) > 0 {
+		return cfg.MirrorRepos
+	}
+	if cfg.MirrorRepo != "" {
+		return []string{cfg.MirrorRepo}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/git"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+)
+
+// defaultWorkers is the number of repositories an Orchestrator handles at
+// once when Workers is not set.
+const defaultWorkers = 4
+
+// Operation is the work an Orchestrator does for each repository.
+type Operation int
+
+const (
+	// OpValidate checks that the primary repository and branch and each
+	// mirror are accessible
+	OpValidate Operation = iota
+
+	// OpGenerate validates the repositories and renders the workflow
+	OpGenerate
+
+	// OpSetup validates the repositories and installs the workflow in each
+	// mirror, like Setup
+	OpSetup
+)
+
+// String returns the name of the operation.
+func (op Operation) String() string {
+	switch op {
+	case OpValidate:
+		return "validate"
+	case OpGenerate:
+		return "generate"
+	case OpSetup:
+		return "setup"
+	default:
+		return fmt.Sprintf("Operation(%d)", int(op))
+	}
+}
+
+// Repo is a primary repository and its mirrors, handled by an Orchestrator.
+type Repo struct {
+	Primary string
+	Mirrors []string
+
+	// Config replaces the configuration of the Orchestrator for this
+	// repository when set; Primary and Mirrors still take precedence over
+	// its repositories unless empty
+	Config *config.Config
+}
+
+// RepoResult is the outcome of an operation for one repository.
+type RepoResult struct {
+	Repo Repo
+
+	// Workflow is the rendered workflow of the first mirror, set by
+	// OpGenerate
+	Workflow string
+
+	// Setup holds the installation in each mirror, set by OpSetup
+	Setup []*SetupResult
+
+	// Err is the failure of the repository, nil when it succeeded
+	Err error
+}
+
+// Orchestrator runs an operation for many repositories concurrently, the
+// building block of organization rollouts:
+//
+//	orch := mirror.NewOrchestrator(cfg, log)
+//	results, err := orch.Run(ctx, mirror.OpSetup, []mirror.Repo{
+//		{Primary: "https://i2pgit.org/go-i2p/reseed-tools.git", Mirrors: []string{"https://github.com/go-i2p/reseed-tools"}},
+//		{Primary: "https://i2pgit.org/go-i2p/go-i2p.git", Mirrors: []string{"https://github.com/go-i2p/go-i2p"}},
+//	})
+//
+// A failing repository does not stop the others.
+type Orchestrator struct {
+	// Config is the configuration shared by the repositories
+	Config *config.Config
+
+	Log *logger.Logger
+
+	// Workers is the number of repositories handled at once, 4 if not set
+	Workers int
+}
+
+// NewOrchestrator creates an Orchestrator for repositories sharing cfg.
+func NewOrchestrator(cfg *config.Config, log *logger.Logger) *Orchestrator {
+	return &Orchestrator{Config: cfg, Log: log, Workers: defaultWorkers}
+}
+
+// Run performs op for each of repos and returns their results in the same
+// order. The failures of all repositories are returned together, each
+// prefixed with its primary repository; a repository not yet started when
+// ctx is cancelled fails with the error of ctx.
+func (o *Orchestrator) Run(ctx context.Context, op Operation, repos []Repo) ([]*RepoResult, error) {
+	workers := o.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	workers = min(workers, len(repos))
+
+	results := make([]*RepoResult, len(repos))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = o.run(ctx, op, repos[i])
+			}
+		}()
+	}
+
+	for i := range repos {
+		if err := ctx.Err(); err != nil {
+			results[i] = &RepoResult{Repo: repos[i], Err: err}
+			continue
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.Repo.Primary, result.Err))
+		}
+	}
+	if len(errs) > 0 {
+		err := fmt.Errorf("%s failed for %d of %d repositories", op, len(errs), len(repos))
+		return results, errors.Join(append([]error{err}, errs...)...)
+	}
+	return results, nil
+}
+
+// run performs op for a single repository.
+func (o *Orchestrator) run(ctx context.Context, op Operation, repo Repo) *RepoResult {
+	result := &RepoResult{Repo: repo}
+
+	cfg := o.repoConfig(repo)
+	result.Repo.Primary, result.Repo.Mirrors = cfg.PrimaryRepo, mirrors(cfg)
+	log := o.Log.With("primary_repo", cfg.PrimaryRepo)
+
+	if cfg.PrimaryRepo == "" || len(result.Repo.Mirrors) == 0 {
+		result.Err = fmt.Errorf("primary and mirror repository URLs are required")
+		return result
+	}
+
+	switch op {
+	case OpValidate, OpGenerate:
+		result.Err = validate(ctx, cfg, log)
+		if result.Err == nil && op == OpGenerate {
+			result.Workflow, result.Err = Generate(ctx, cfg, log)
+		}
+	case OpSetup:
+		result.Setup, result.Err = Setup(ctx, cfg, log)
+	default:
+		result.Err = fmt.Errorf("unknown operation %s", op)
+	}
+	return result
+}
+
+// repoConfig returns a copy of the configuration of repo, with its
+// repositories filled in.
+func (o *Orchestrator) repoConfig(repo Repo) *config.Config {
+	base := o.Config
+	if repo.Config != nil {
+		base = repo.Config
+	}
+
+	cfg := *base
+	if repo.Primary != "" {
+		cfg.PrimaryRepo = repo.Primary
+	}
+	if len(repo.Mirrors) > 0 {
+		cfg.MirrorRepo, cfg.MirrorRepos = "", repo.Mirrors
+	}
+	return &cfg
+}
+
+// validate checks the primary repository of cfg against each of its mirrors.
+func validate(ctx context.Context, cfg *config.Config, log *logger.Logger) error {
+	gitClient := git.NewClient(cfg.GitPath, log)
+
+	var errs []error
+	for _, mirror := range mirrors(cfg) {
+		mirrorCfg := *cfg
+		mirrorCfg.MirrorRepo = mirror
+		if err := gitClient.ValidateRepos(ctx, &mirrorCfg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: repository validation failed: %w", mirror, err))
+		}
+	}
+	return errors.Join(errs...)
+}
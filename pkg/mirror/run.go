@@ -0,0 +1,170 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/audit"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/forge"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/git"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/github"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+)
+
+// Run syncs each mirror of cfg from its primary on this machine, as the
+// generated workflow would. Working copies are kept in cfg.WorkDir, and the
+// outcome of each sync is recorded in the sync state of cfg.StateDir as soon
+// as it is known, when cfg.StateDir is set. A failing mirror does not stop the others; the errors of all
+// failed mirrors are returned together.
+func Run(ctx context.Context, cfg *config.Config, log *logger.Logger) ([]*git.SyncResult, error) {
+	if cfg.WorkDir == "" {
+		return nil, fmt.Errorf("a working directory is required")
+	}
+
+	var state *git.SyncState
+	if cfg.StateDir != "" {
+		var err error
+		if state, err = git.LoadSyncState(cfg.StateDir); err != nil {
+			return nil, err
+		}
+		if _, err := state.BeginRun(mirrors(cfg), false); err != nil {
+			log.Warn("Could not save sync state", "error", err)
+		}
+	}
+
+	var results []*git.SyncResult
+	var errs []error
+	for _, mirror := range mirrors(cfg) {
+		mirrorCfg := *cfg
+		mirrorCfg.MirrorRepo = mirror
+
+		result, err := Sync(ctx, &mirrorCfg, log.With("mirror_repo", mirror))
+		if state != nil {
+			if _, err := state.Record(&mirrorCfg, result, err); err != nil {
+				log.Warn("Could not save sync state", "error", err)
+			}
+		}
+		results = append(results, result)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", mirror, err))
+		}
+	}
+
+	if state != nil {
+		if err := state.EndRun(); err != nil {
+			log.Warn("Could not save sync state", "error", err)
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// Sync syncs the mirror repository of cfg from its primary on this machine.
+// Mirrors outside GitHub are pushed to with the credentials configured for git.
+// When the primary history was rewritten and cfg.RewritePolicy is issue, an
+// issue is opened on the GitHub mirror. The GitHub client is created with opts.
+func Sync(ctx context.Context, cfg *config.Config, log *logger.Logger, opts ...github.Option) (*git.SyncResult, error) {
+	var token string
+	var githubClient *github.Client
+	if config.IsGitHubURL(cfg.MirrorRepo) {
+		var err error
+		githubClient, err = github.NewClient(ctx, cfg, log, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GitHub client: %w", err)
+		}
+		token, err = githubClient.GitToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get token for pushing to the mirror: %w", err)
+		}
+	}
+
+	gitClient := git.NewClient(cfg.GitPath, log)
+	result, err := gitClient.Sync(ctx, cfg, token)
+
+	// Alert the mirror's maintainers about the rewritten primary history
+	var rewriteErr *git.RewriteError
+	if errors.As(err, &rewriteErr) && cfg.RewritePolicy == config.RewritePolicyIssue && githubClient != nil {
+		if _, _, issueErr := githubClient.EnsureIssue(ctx, rewriteIssueTitle(rewriteErr.Branch), rewriteIssueBody(cfg, rewriteErr.Synced)); issueErr != nil {
+			log.Warn("Could not open rewrite issue", "error", issueErr)
+		}
+	}
+
+	if err == nil && cfg.CommitStatus {
+		setCommitStatus(ctx, cfg, log, githubClient, result)
+	}
+	if cfg.BadgeBranch != "" {
+		if badgeErr := gitClient.PublishBadge(ctx, cfg, token, git.NewBadge(time.Now(), err)); badgeErr != nil {
+			log.Warn("Could not publish badge", "branch", cfg.BadgeBranch, "error", badgeErr)
+		}
+	}
+	return result, err
+}
+
+// Lag measures how far the mirror of cfg trails its primary, see
+// git.Client.Lag. The GitHub client reading private GitHub mirrors is
+// created with opts.
+func Lag(ctx context.Context, cfg *config.Config, log *logger.Logger, opts ...github.Option) (*git.Lag, error) {
+	var token string
+	if config.IsGitHubURL(cfg.MirrorRepo) {
+		githubClient, err := github.NewClient(ctx, cfg, log, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GitHub client: %w", err)
+		}
+		if token, err = githubClient.GitToken(); err != nil {
+			return nil, fmt.Errorf("failed to get token for reading the mirror: %w", err)
+		}
+	}
+	return git.NewClient(cfg.GitPath, log).Lag(ctx, cfg, token)
+}
+
+// setCommitStatus marks the mirror commit of result with the primary commit
+// it was synced from, through the API of the GitHub client or else of the
+// mirror's forge. Failures are only logged, since the sync itself succeeded.
+func setCommitStatus(ctx context.Context, cfg *config.Config, log *logger.Logger, githubClient *github.Client, result *git.SyncResult) {
+	description := config.CommitStatusDescription(cfg.PrimaryRepo, result.SHA[:min(len(result.SHA), 12)])
+	if githubClient != nil {
+		if _, err := githubClient.EnsureCommitStatus(ctx, result.Head, description, ""); err != nil {
+			log.Warn("Could not set commit status", "error", err)
+		}
+		return
+	}
+
+	target, err := forge.NewTarget(ctx, cfg.MirrorForge, cfg.MirrorRepo, cfg.MirrorToken, log)
+	if err != nil {
+		log.Warn("Could not set commit status", "error", err)
+		return
+	}
+	setter, ok := target.(forge.StatusSetter)
+	if !ok {
+		log.Warn("Commit statuses are not supported on the mirror's forge", "forge", target.Kind())
+		return
+	}
+	status := forge.CommitStatus{Context: config.CommitStatusContext, Description: description}
+	created, err := setter.EnsureCommitStatus(ctx, result.Head, status)
+	if created || err != nil {
+		entry := audit.Entry{Operation: audit.OpSetCommitStatus, Repo: cfg.MirrorRepo, Target: result.Head, Detail: description, Credential: audit.Credential(cfg.MirrorToken, 0)}
+		if auditErr := audit.Record(cfg.AuditLog, entry, err); auditErr != nil {
+			log.Warn("Could not record change in the audit log", "operation", entry.Operation, "error", auditErr)
+		}
+	}
+	if err != nil {
+		log.Warn("Could not set commit status", "error", err)
+	}
+}
+
+// rewriteIssueTitle returns the title of the issue opened when branch of the
+// primary was rewritten. The generated workflow uses the same title, so each
+// rewrite is reported once.
+func rewriteIssueTitle(branch string) string {
+	return "Primary branch " + branch + " was rewritten"
+}
+
+// rewriteIssueBody returns the body of the issue opened when the primary
+// branch no longer contains the synced commit.
+func rewriteIssueBody(cfg *config.Config, synced string) string {
+	return fmt.Sprintf("The history of branch %s of the primary repository %s was rewritten: it no longer contains %s, the commit synced last.\n\n"+
+		"The mirror was not updated. To accept the new history, delete the ref refs/gh-mirror/synced/%s from the mirror and sync again.",
+		cfg.PrimaryBranch, cfg.PrimaryRepo, synced, cfg.MirrorBranch)
+}
@@ -0,0 +1,99 @@
+// Package notify posts the outcome of syncs and setups to a webhook URL, so
+// other systems can react to them without polling.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/redact"
+)
+
+// Kinds of events.
+const (
+	EventSync  = "sync"
+	EventSetup = "setup"
+)
+
+// Results of events.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// timeout bounds each notification, so an unresponsive endpoint does not
+// hold up the syncs.
+const timeout = 10 * time.Second
+
+// Event is the JSON payload posted for a sync or setup of a mirror.
+type Event struct {
+	Event   string `json:"event"`
+	Repo    string `json:"repo"`
+	Primary string `json:"primary,omitempty"`
+	Result  string `json:"result"`
+
+	// Action is what setup did, e.g. committed or up-to-date
+	Action string `json:"action,omitempty"`
+
+	// OldSHA and NewSHA are the primary commits synced before and by a
+	// sync, or the commit created by a setup in NewSHA
+	OldSHA  string `json:"old_sha,omitempty"`
+	NewSHA  string `json:"new_sha,omitempty"`
+	Updated bool   `json:"updated"`
+
+	Duration float64   `json:"duration_seconds"`
+	Time     time.Time `json:"time"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// NewEvent returns an event of kind about repo, succeeded unless err is not
+// nil, that took elapsed.
+func NewEvent(kind, repo string, elapsed time.Duration, err error) Event {
+	event := Event{
+		Event:    kind,
+		Repo:     repo,
+		Result:   ResultSuccess,
+		Duration: elapsed.Seconds(),
+		Time:     time.Now().UTC(),
+	}
+	if err != nil {
+		event.Result, event.Error = ResultFailure, redact.String(err.Error())
+	}
+	return event
+}
+
+// Post sends event to url as a JSON POST request. Responses with a status
+// other than 2xx are errors.
+func Post(ctx context.Context, url string, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gh-mirror")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("notification rejected with status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Package provenance records in comments of a generated YAML file which
+// gh-mirror generated it, from which options and when, together with a hash
+// of the file, so that files edited by hand after their generation can be
+// told apart from files generated by another version or with other options.
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Errors of Verify.
+var (
+	// ErrMissing is returned for files without a recorded provenance, like
+	// files generated before provenance was recorded
+	ErrMissing = errors.New("no provenance recorded")
+
+	// ErrModified is returned for files whose content does not match the
+	// hash recorded at their generation
+	ErrModified = errors.New("modified after its generation")
+)
+
+// prefix starts the comment lines holding the fields of a provenance.
+const prefix = "# gh-mirror-"
+
+// Fields of a provenance, as recorded after the prefix.
+const (
+	fieldTool      = "tool"
+	fieldConfig    = "config"
+	fieldGenerated = "generated"
+	fieldIntegrity = "integrity"
+)
+
+// notice introduces the recorded provenance.
+const notice = "# Provenance of this file, verified by `gh-mirror audit`. Edit the options it is generated with instead of the file."
+
+// Provenance describes the generation of a file.
+type Provenance struct {
+	// Tool is the version of gh-mirror that generated the file
+	Tool string
+
+	// Config is the hash of the options the file was generated with, see Hash
+	Config string
+
+	// Generated is when the file was generated
+	Generated time.Time
+}
+
+// Hash returns the hash of data, as recorded in a Provenance.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Stamp returns content with p and the hash of the result recorded in
+// comments at its end.
+func Stamp(content string, p Provenance) string {
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	stamped := content + "\n" + notice + "\n" +
+		prefix + fieldTool + ": " + p.Tool + "\n" +
+		prefix + fieldConfig + ": " + p.Config + "\n" +
+		prefix + fieldGenerated + ": " + p.Generated.UTC().Format(time.RFC3339) + "\n"
+	return stamped + prefix + fieldIntegrity + ": " + Hash([]byte(stamped)) + "\n"
+}
+
+// Verify returns the provenance recorded in content. It fails with
+// ErrMissing when none is recorded, and with ErrModified when content, or the
+// provenance itself, was changed after it was recorded; the provenance as
+// recorded is returned with ErrModified as far as it could be read.
+func Verify(content string) (Provenance, error) {
+	var (
+		p         Provenance
+		integrity string
+		unsigned  strings.Builder
+	)
+	fields := make(map[string]string)
+	for _, line := range strings.SplitAfter(content, "\n") {
+		name, value, ok := parseField(line)
+		if ok {
+			fields[name] = value
+		}
+		if ok && name == fieldIntegrity {
+			integrity = value
+			continue
+		}
+		unsigned.WriteString(line)
+	}
+	if integrity == "" {
+		return p, ErrMissing
+	}
+
+	p.Tool, p.Config = fields[fieldTool], fields[fieldConfig]
+	generated, err := time.Parse(time.RFC3339, fields[fieldGenerated])
+	if err != nil {
+		return p, fmt.Errorf("%w: invalid generation time %q", ErrModified, fields[fieldGenerated])
+	}
+	p.Generated = generated
+	if Hash([]byte(unsigned.String())) != integrity {
+		return p, ErrModified
+	}
+	return p, nil
+}
+
+// Equal reports whether a and b have the same content apart from their
+// recorded provenance, which differs at least in the generation time for
+// every generated file.
+func Equal(a, b string) bool {
+	return strip(a) == strip(b)
+}
+
+// strip returns content without the fields of its provenance.
+func strip(content string) string {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if _, _, ok := parseField(line); !ok {
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+// parseField returns the name and value of a provenance field on line.
+func parseField(line string) (name, value string, ok bool) {
+	rest, found := strings.CutPrefix(strings.TrimRight(line, "\r\n"), prefix)
+	if !found {
+		return "", "", false
+	}
+	name, value, ok = strings.Cut(rest, ": ")
+	return name, value, ok
+}
@@ -0,0 +1,115 @@
+// Package redact scrubs credentials from text before gh-mirror logs or
+// reports it: GitHub and GitLab tokens, Authorization header values and
+// credentials embedded in URLs, as well as the secrets registered with
+// Secret. Logs of CI runs are often public, and errors of git and the forge
+// APIs may quote the authenticated URL or request.
+package redact
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Mask replaces redacted credentials.
+const Mask = "***"
+
+// minSecretLength is the length below which registered secrets are ignored,
+// since masking short strings would garble unrelated text.
+const minSecretLength = 8
+
+// patterns match credentials, replaced by their replacement.
+var patterns = []struct {
+	re          *regexp.Regexp
+	replacement string
+}{
+	// GitHub personal access, OAuth, app installation and refresh tokens,
+	// keeping the prefix that tells their kind
+	{regexp.MustCompile(`\b(gh[pousr]_)[A-Za-z0-9]{20,}`), "${1}" + Mask},
+	{regexp.MustCompile(`\b(github_pat_)[A-Za-z0-9_]{20,}`), "${1}" + Mask},
+	// GitLab personal, project and group access tokens
+	{regexp.MustCompile(`\b(glpat-)[A-Za-z0-9_-]{20,}`), "${1}" + Mask},
+	// Authorization headers, with or without their scheme
+	{regexp.MustCompile(`(?i)(authorization"?\s*[:=]\s*"?(?:bearer|token|basic)?\s*)[^\s"',]+`), "${1}" + Mask},
+	{regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9._~+/-]+=*`), "${1}" + Mask},
+	// Passwords in URLs, keeping the user name
+	{regexp.MustCompile(`([A-Za-z][A-Za-z0-9+.-]*://[^/?#@\s"':]*):[^/?#@\s"']+@`), "${1}:" + Mask + "@"},
+	// Tokens given as the user name of HTTP URLs
+	{regexp.MustCompile(`(https?://)[^/?#@\s"':]+@`), "${1}" + Mask + "@"},
+}
+
+var (
+	mu      sync.RWMutex
+	secrets []string
+)
+
+// Secret registers values, such as tokens read from the environment, to be
+// masked wherever they appear, including those that match no pattern.
+// Empty and short values are ignored.
+func Secret(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, value := range values {
+		if len(value) >= minSecretLength {
+			secrets = append(secrets, value)
+		}
+	}
+}
+
+// String returns s with the credentials it contains masked.
+func String(s string) string {
+	mu.RLock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, Mask)
+	}
+	mu.RUnlock()
+
+	for _, p := range patterns {
+		s = p.re.ReplaceAllString(s, p.replacement)
+	}
+	return s
+}
+
+// Error returns err with the credentials in its message masked, or nil. The
+// returned error wraps err, so errors.Is and errors.As still see its chain.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*redactedError); ok {
+		return err
+	}
+	return &redactedError{err: err}
+}
+
+// redactedError masks the credentials in the message of err.
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return String(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// Writer returns a writer masking the credentials in what is written to w.
+// Each write is redacted on its own, so credentials split across writes are
+// not masked; it suits writers of whole lines, such as cobra's error output.
+func Writer(w io.Writer) io.Writer {
+	return &writer{w: w}
+}
+
+type writer struct {
+	w io.Writer
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,194 @@
+// Package report sends the failed syncs and panics of long-running gh-mirror
+// processes to an error reporting service, such as Sentry, or to a callback,
+// so teams running the mirrors as infrastructure learn about them.
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Levels of reports.
+const (
+	LevelError = "error"
+	LevelFatal = "fatal"
+)
+
+// timeout bounds the delivery of each report, so an unresponsive service
+// does not hold up the syncs.
+const timeout = 10 * time.Second
+
+// Report describes a failed sync of a mirror, or a panic.
+type Report struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+
+	// Repo is the mirror repository and Primary its primary, empty when a
+	// panic did not happen while syncing a mirror
+	Repo    string `json:"repo,omitempty"`
+	Primary string `json:"primary,omitempty"`
+
+	// Failures is the number of consecutive failed syncs of the mirror
+	Failures int `json:"consecutive_failures,omitempty"`
+
+	// Stack is the stack trace of a panic
+	Stack string `json:"stack,omitempty"`
+
+	Time time.Time `json:"time"`
+}
+
+// Reporter sends reports.
+type Reporter interface {
+	Report(ctx context.Context, report Report) error
+}
+
+// Func is a Reporter calling a function, for programs handling reports
+// themselves.
+type Func func(ctx context.Context, report Report) error
+
+// Report implements Reporter.
+func (f Func) Report(ctx context.Context, report Report) error {
+	return f(ctx, report)
+}
+
+// Sentry is a Reporter sending reports to a Sentry project as events.
+type Sentry struct {
+	dsn      string
+	endpoint string
+	key      string
+}
+
+// NewSentry returns a Reporter for the Sentry project of dsn, e.g.
+// https://<key>@o0.ingest.sentry.io/<project>.
+func NewSentry(dsn string) (*Sentry, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN: %w", err)
+	}
+	if parsed.Scheme != "https" && parsed.Scheme != "http" || parsed.Host == "" || parsed.User.Username() == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: must be http(s)://<key>@<host>/<project>")
+	}
+	path := strings.TrimSuffix(parsed.Path, "/")
+	i := strings.LastIndex(path, "/")
+	if i < 0 || path[i+1:] == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: project ID is missing")
+	}
+
+	return &Sentry{
+		dsn:      dsn,
+		endpoint: parsed.Scheme + "://" + parsed.Host + path[:i] + "/api/" + path[i+1:] + "/envelope/",
+		key:      parsed.User.Username(),
+	}, nil
+}
+
+// sentryEvent is the part of a Sentry event filled in from a Report.
+type sentryEvent struct {
+	EventID    string            `json:"event_id"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Platform   string            `json:"platform"`
+	Level      string            `json:"level"`
+	Logger     string            `json:"logger"`
+	ServerName string            `json:"server_name,omitempty"`
+	Message    string            `json:"message"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Extra      map[string]any    `json:"extra,omitempty"`
+}
+
+// Report implements Reporter. The mirror and primary are sent as tags, so
+// Sentry can filter the events by repository.
+func (s *Sentry) Report(ctx context.Context, report Report) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("failed to create event ID: %w", err)
+	}
+	event := sentryEvent{
+		EventID:   hex.EncodeToString(id),
+		Timestamp: report.Time,
+		Platform:  "go",
+		Level:     report.Level,
+		Logger:    "gh-mirror",
+		Message:   report.Message,
+		Tags:      map[string]string{},
+		Extra:     map[string]any{},
+	}
+	event.ServerName, _ = os.Hostname()
+	if report.Repo != "" {
+		event.Tags["mirror_repo"] = report.Repo
+	}
+	if report.Primary != "" {
+		event.Tags["primary_repo"] = report.Primary
+	}
+	if report.Failures > 0 {
+		event.Extra["consecutive_failures"] = report.Failures
+	}
+	if report.Stack != "" {
+		event.Extra["stack"] = report.Stack
+	}
+
+	// An envelope holds its header, the header of the event and the event,
+	// each on a line of its own
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, line := range []any{
+		map[string]any{"event_id": event.EventID, "dsn": s.dsn, "sent_at": time.Now().UTC()},
+		map[string]string{"type": "event"},
+		event,
+	} {
+		if err := encoder.Encode(line); err != nil {
+			return fmt.Errorf("failed to encode Sentry event: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create Sentry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_client=gh-mirror, sentry_key="+s.key)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send Sentry event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("Sentry rejected event with status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// Command is a Reporter running a shell command for each report, which it
+// receives as JSON on standard input.
+type Command string
+
+// Report implements Reporter.
+func (c Command) Report(ctx context.Context, report Report) error {
+	input, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", string(c))
+	cmd.Stdin = bytes.NewReader(input)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error report command failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
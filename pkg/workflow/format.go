@@ -0,0 +1,133 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+// Formatter renders the sync job in one CI format. Formats are looked up by
+// name in a registry holding the github, forgejo and gitlab formats; other
+// programs can add their own with Register.
+type Formatter interface {
+	// Path returns the repository path the rendered file is installed at
+	Path() string
+
+	// Render returns the file syncing the mirror described by data. Formats
+	// that look anything up while rendering stop when ctx is done
+	Render(ctx context.Context, data WorkflowTemplate) (string, error)
+}
+
+var (
+	formattersMu sync.RWMutex
+	formatters   = make(map[string]Formatter)
+)
+
+func init() {
+	Register(config.FormatGitHub, NewActionsFormatter(".github/workflows/sync-mirror.yml", "ubuntu-latest"))
+	// Forgejo runners are selected by label and run jobs in containers
+	Register(config.FormatForgejo, NewActionsFormatter(".forgejo/workflows/sync-mirror.yml", "docker"))
+	Register(config.FormatGitLab, gitlabFormatter{})
+}
+
+// Register makes formatter available as the format name, replacing the
+// formatter registered under that name before.
+func Register(name string, formatter Formatter) {
+	if formatter == nil {
+		panic("workflow: Register of nil formatter for format " + name)
+	}
+
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	formatters[name] = formatter
+}
+
+// Lookup returns the formatter registered as the format name; an empty name
+// selects the github format.
+func Lookup(name string) (Formatter, error) {
+	if name == "" {
+		name = config.FormatGitHub
+	}
+
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	formatter, ok := formatters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown workflow format %q (must be one of %s)", name, strings.Join(formatNames(), ", "))
+	}
+	return formatter, nil
+}
+
+// Formats returns the names of the registered formats, sorted.
+func Formats() []string {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	return formatNames()
+}
+
+// formatNames returns the sorted names of the registered formats. The caller
+// holds formattersMu.
+func formatNames() []string {
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// actionsFormatter renders GitHub Actions style workflows, which Forgejo and
+// Gitea Actions run as well.
+type actionsFormatter struct {
+	path   string
+	runsOn string
+}
+
+// NewActionsFormatter returns a formatter of GitHub Actions style workflows
+// installed at path, whose job runs on runners with the label runsOn.
+func NewActionsFormatter(path, runsOn string) Formatter {
+	return actionsFormatter{path: path, runsOn: runsOn}
+}
+
+// Path implements Formatter.
+func (f actionsFormatter) Path() string {
+	return f.path
+}
+
+// Render implements Formatter.
+func (f actionsFormatter) Render(ctx context.Context, data WorkflowTemplate) (string, error) {
+	workflowYAML, err := f.workflow(data).Marshal()
+	if err != nil {
+		return "", err
+	}
+
+	// Add comments to the generated YAML
+	return addComments(workflowYAML), nil
+}
+
+// workflow returns the workflow of data.
+func (f actionsFormatter) workflow(data WorkflowTemplate) *Workflow {
+	runsOn := f.runsOn
+	// Local primaries are only on the disk of a self-hosted runner
+	if config.IsLocalURL(data.PrimaryRepo) {
+		runsOn = "self-hosted"
+	}
+	return newWorkflow(data, runsOn)
+}
+
+// gitlabFormatter renders GitLab CI/CD pipelines.
+type gitlabFormatter struct{}
+
+// Path implements Formatter.
+func (gitlabFormatter) Path() string {
+	return ".gitlab-ci.yml"
+}
+
+// Render implements Formatter.
+func (gitlabFormatter) Render(ctx context.Context, data WorkflowTemplate) (string, error) {
+	return generateGitLabCI(data)
+}
@@ -3,19 +3,177 @@ package workflow
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"text/template"
-
-	"gopkg.in/yaml.v3"
+	"time"
 
 	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
 	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/provenance"
 )
 
+// TemplateVersion identifies the revision of the generated workflow layout.
+// Increase it whenever the generated output changes for an unchanged configuration.
+const TemplateVersion = 4
+
+// templateVersionPattern matches the template version comment in generated workflows.
+var templateVersionPattern = regexp.MustCompile(`(?m)^# Template version: (\d+)$`)
+
 // Generator generates GitHub Actions workflow files.
 type Generator struct {
-	cfg *config.Config
-	log *logger.Logger
+	opts Options
+	log  *logger.Logger
+}
+
+// Options describe the workflow a Generator produces, independently of the
+// rest of the configuration. Start from DefaultOptions, which holds the
+// defaults of the command line flags, or convert a configuration with
+// OptionsFromConfig.
+type Options struct {
+	PrimaryRepo   string
+	MirrorRepo    string
+	PrimaryBranch string
+
+	// MirrorBranches are the mirror branches the primary branch is pushed
+	// to; the first one is checked out by the workflow
+	MirrorBranches []string
+
+	// Interval is the sync interval, hourly, daily or weekly
+	Interval string
+
+	ForceSync  bool
+	PushMirror bool
+	SyncNotes  bool
+	Refspecs   []config.Refspec
+
+	// RecordState records each sync in .github/sync-state.json on the
+	// mirror ref refs/gh-mirror/state/<mirror branch>
+	RecordState bool
+
+	// CommitStatus marks the synced mirror commit with a commit status
+	// naming the primary, on GitHub mirrors
+	CommitStatus bool
+
+	// BadgeBranch is the branch of the mirror the badge of the last sync is
+	// published to, if not empty
+	BadgeBranch string
+
+	// AuthMode is one of the config.AuthMode constants, with AuthSecret
+	// naming the secret holding its credential
+	AuthMode    string
+	AuthSecret  string
+	Environment string
+
+	// KnownHosts are the known_hosts entries of the SSH host keys of the
+	// primary trusted in the ssh auth mode, see git.Client.HostKeys
+	KnownHosts string
+
+	// MirrorUser is the user pushing to mirrors outside GitHub
+	MirrorUser string
+
+	// TorProxySecret names the secret holding a proxy for onion primaries
+	TorProxySecret string
+
+	// Format is the workflow format, one of the config.Format constants
+	Format string
+
+	CachePrimary bool
+
+	FilterPaths          []string
+	StripBlobsBiggerThan string
+	Subdirectory         string
+
+	RequireSigned  bool
+	AllowedSigners string
+	SigningKeys    string
+
+	// ScanSecrets refuses to push primary commits in which gitleaks finds
+	// credentials
+	ScanSecrets bool
+
+	// RewritePolicy is one of the config.RewritePolicy constants
+	RewritePolicy string
+
+	// Extra holds template values, like those given with --set
+	Extra map[string]string
+
+	// PreSyncHook and PostSyncHook are scripts run before and after the
+	// sync, in steps of their own
+	PreSyncHook  string
+	PostSyncHook string
+
+	// TemplateDir is a directory of templates overriding the built-in
+	// templates of the same name, see TemplateNames
+	TemplateDir string
+}
+
+// DefaultOptions returns the options of the command line flag defaults,
+// without repositories.
+func DefaultOptions() Options {
+	return Options{
+		PrimaryBranch:  "main",
+		MirrorBranches: []string{"main"},
+		Interval:       "hourly",
+		ForceSync:      true,
+		AuthMode:       config.AuthModeToken,
+		Format:         config.FormatGitHub,
+		RewritePolicy:  config.RewritePolicyForce,
+	}
+}
+
+// OptionsFromConfig returns the workflow options of cfg.
+func OptionsFromConfig(cfg *config.Config) Options {
+	branches := []string{cfg.MirrorBranch}
+	if len(cfg.MirrorBranches) > 1 {
+		branches = append(branches, cfg.MirrorBranches[1:]...)
+	}
+
+	return Options{
+		PrimaryRepo:    cfg.PrimaryRepo,
+		MirrorRepo:     cfg.MirrorRepo,
+		PrimaryBranch:  cfg.PrimaryBranch,
+		MirrorBranches: branches,
+		Interval:       cfg.SyncInterval,
+		ForceSync:      cfg.ForceSync,
+		PushMirror:     cfg.PushMirror,
+		SyncNotes:      cfg.SyncNotes,
+		Refspecs:       cfg.Refspecs,
+		RecordState:    cfg.RecordState,
+		CommitStatus:   cfg.CommitStatus,
+		BadgeBranch:    cfg.BadgeBranch,
+		AuthMode:       cfg.AuthMode,
+		AuthSecret:     cfg.AuthSecret,
+		Environment:    cfg.Environment,
+		KnownHosts:     cfg.KnownHosts,
+		MirrorUser:     cfg.MirrorUser,
+		TorProxySecret: cfg.TorProxySecret,
+		Format:         cfg.WorkflowFormat,
+		CachePrimary:   cfg.CachePrimary,
+
+		FilterPaths:          cfg.FilterPaths,
+		StripBlobsBiggerThan: cfg.StripBlobsBiggerThan,
+		Subdirectory:         cfg.Subdirectory,
+
+		RequireSigned:  cfg.RequireSigned,
+		AllowedSigners: cfg.AllowedSigners,
+		SigningKeys:    cfg.SigningKeys,
+		ScanSecrets:    cfg.ScanSecrets,
+
+		RewritePolicy: cfg.RewritePolicy,
+		Extra:         cfg.Extra,
+
+		PreSyncHook:  cfg.PreSyncHook,
+		PostSyncHook: cfg.PostSyncHook,
+		TemplateDir:  cfg.TemplateDir,
+	}
 }
 
 // WorkflowTemplate is the structure for the GitHub Actions workflow.
@@ -26,43 +184,332 @@ type WorkflowTemplate struct {
 	MirrorBranch  string
 	CronSchedule  string
 	ForceSync     bool
+	PushMirror    bool
+	SyncNotes     bool
+	Refspecs      []config.Refspec
+	AuthMode      string
+	AuthSecret    string
+	Environment   string
+
+	// KnownHosts are the pinned SSH host keys of the primary, which the
+	// checkout adds to the keys of GitHub it trusts with strict host key
+	// checking
+	KnownHosts string
+
+	// I2PHost is the host of a primary on the I2P network, reached through
+	// an I2P router started on the runner
+	I2PHost string
+
+	// OnionOrigin is the scheme and host of a primary onion service, reached
+	// through Tor started on the runner or through the proxy in TorProxySecret
+	OnionOrigin    string
+	TorProxySecret string
+
+	// MirrorURL and MirrorUser are set for mirrors outside GitHub, which
+	// are pushed to over HTTPS with the token in AuthSecret
+	MirrorURL  string
+	MirrorUser string
+
+	// Format is the workflow format, one of the config.Format constants
+	Format string
+
+	// CacheKey is set when the objects of the primary are cached between
+	// runs in a bare clone, under keys starting with it
+	CacheKey string
+
+	// FilterPaths and StripBlobsBiggerThan select content removed from the
+	// primary history with git-filter-repo before it is pushed
+	FilterPaths          []string
+	StripBlobsBiggerThan string
+
+	// Subdirectory is the directory of the primary that becomes the root of
+	// the mirror, also with git-filter-repo
+	Subdirectory string
+
+	// RequireSigned refuses primary commits that are not signed by one of
+	// the AllowedSigners SSH keys or SigningKeys GPG keys
+	RequireSigned  bool
+	AllowedSigners string
+	SigningKeys    string
+
+	// ScanSecrets refuses to push primary commits in which gitleaks finds
+	// credentials, before they become public on the mirror
+	ScanSecrets bool
+
+	// ExtraBranches are the mirror branches besides MirrorBranch that the
+	// synced primary branch is pushed to
+	ExtraBranches []string
+
+	// RewritePolicy is set when the sync checks the primary branch for
+	// rewritten history against the commit recorded on the mirror by the
+	// previous sync, and selects what it then does
+	RewritePolicy string
+
+	// Extra holds the values given with --set, for templates parameterized
+	// beyond the options above
+	Extra map[string]string
+
+	// PreSyncHook and PostSyncHook are the scripts of the hooks run before
+	// and after the sync script
+	PreSyncHook  string
+	PostSyncHook string
+
+	// RecordState is set when the sync is recorded in a state file on the
+	// mirror, with ToolVersion as the version of gh-mirror that generated
+	// the workflow
+	RecordState bool
+	ToolVersion string
+
+	// CommitStatus is set when the synced mirror commit is marked with a
+	// commit status, described by StatusDescription
+	CommitStatus      bool
+	StatusDescription string
+
+	// BadgeBranch is the branch the badge of the last sync is published to
+	BadgeBranch string
+
+	// templates are the templates of the sync script, the built-in ones if nil
+	templates *template.Template
+}
+
+// StatusContext returns the context of the commit status set with
+// CommitStatus.
+func (t WorkflowTemplate) StatusContext() string {
+	return config.CommitStatusContext
+}
+
+// TemplateVersion returns the TemplateVersion of the generated workflow.
+func (t WorkflowTemplate) TemplateVersion() int {
+	return TemplateVersion
+}
+
+// FilterArgs returns the git-filter-repo options that remove the filtered
+// content, or an empty string when the history is pushed unchanged.
+func (t WorkflowTemplate) FilterArgs() string {
+	var args []string
+	if t.Subdirectory != "" {
+		args = append(args, "--subdirectory-filter '"+t.Subdirectory+"'")
+	}
+	for _, path := range t.FilterPaths {
+		args = append(args, "--path '"+path+"'")
+	}
+	if len(t.FilterPaths) > 0 {
+		args = append(args, "--invert-paths")
+	}
+	if t.StripBlobsBiggerThan != "" {
+		args = append(args, "--strip-blobs-bigger-than "+t.StripBlobsBiggerThan)
+	}
+	return strings.Join(args, " ")
+}
+
+// SyncScript returns the shell script that syncs the mirror, for formatters
+// running it in a job of their own.
+func (t WorkflowTemplate) SyncScript() string {
+	return generateSyncScript(t)
+}
+
+// Path returns the repository path the workflow is installed at for format,
+// the path of the github format when format is not registered.
+func Path(format string) string {
+	formatter, err := Lookup(format)
+	if err != nil {
+		formatter, _ = Lookup(config.FormatGitHub)
+	}
+	return formatter.Path()
 }
 
 // NewGenerator creates a new workflow generator.
-func NewGenerator(cfg *config.Config, log *logger.Logger) *Generator {
+func NewGenerator(opts Options, log *logger.Logger) *Generator {
 	return &Generator{
-		cfg: cfg,
-		log: log,
+		opts: opts,
+		log:  log,
 	}
 }
 
-// Generate creates a GitHub Actions workflow YAML file.
-func (g *Generator) Generate() (string, error) {
+// Generate creates a GitHub Actions workflow YAML file, or the file of
+// another registered format. It fails without rendering when ctx is done.
+func (g *Generator) Generate(ctx context.Context) (string, error) {
+	formatter, err := Lookup(g.opts.Format)
+	if err != nil {
+		return "", err
+	}
+	data, err := g.templateData()
+	if err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	// Generate workflow file from template
+	workflowYAML, err := formatter.Render(ctx, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate workflow YAML: %w", err)
+	}
+
+	// Record the provenance, so that audit detects edits of the installed file
+	hash, err := g.configHash()
+	if err != nil {
+		return "", err
+	}
+	return provenance.Stamp(workflowYAML, provenance.Provenance{
+		Tool:      toolVersion(),
+		Config:    hash,
+		Generated: time.Now(),
+	}), nil
+}
+
+// configHash returns the hash of the options of g, which identifies the
+// options a workflow was generated with.
+func (g *Generator) configHash() (string, error) {
+	options, err := json.Marshal(g.opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode workflow options: %w", err)
+	}
+	return provenance.Hash(options), nil
+}
+
+// Workflow returns the workflow Generate renders, which may be modified
+// before it is written with Marshal. Only formats of Actions workflows, like
+// github and forgejo, have a workflow model.
+func (g *Generator) Workflow() (*Workflow, error) {
+	formatter, err := Lookup(g.opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	actions, ok := formatter.(actionsFormatter)
+	if !ok {
+		return nil, fmt.Errorf("the %s format does not generate an Actions workflow", g.opts.Format)
+	}
+	data, err := g.templateData()
+	if err != nil {
+		return nil, err
+	}
+	return actions.workflow(data), nil
+}
+
+// templateData checks the options and returns the template data of the
+// workflow.
+func (g *Generator) templateData() (WorkflowTemplate, error) {
+	opts := g.opts
+	if opts.PrimaryRepo == "" || opts.MirrorRepo == "" {
+		return WorkflowTemplate{}, fmt.Errorf("primary and mirror repository URLs are required")
+	}
+	if len(opts.MirrorBranches) == 0 {
+		return WorkflowTemplate{}, fmt.Errorf("at least one mirror branch is required")
+	}
+
 	// Determine cron schedule based on sync interval
-	cronSchedule := getCronSchedule(g.cfg.SyncInterval)
+	cronSchedule := CronSchedule(opts.Interval)
 	g.log.Debug("Using cron schedule", "schedule", cronSchedule)
 
 	// Prepare template data
 	data := WorkflowTemplate{
-		PrimaryRepo:   g.cfg.PrimaryRepo,
-		MirrorRepo:    g.cfg.MirrorRepo,
-		PrimaryBranch: g.cfg.PrimaryBranch,
-		MirrorBranch:  g.cfg.MirrorBranch,
+		PrimaryRepo:   opts.PrimaryRepo,
+		MirrorRepo:    opts.MirrorRepo,
+		PrimaryBranch: opts.PrimaryBranch,
+		MirrorBranch:  opts.MirrorBranches[0],
+		ExtraBranches: opts.MirrorBranches[1:],
 		CronSchedule:  cronSchedule,
-		ForceSync:     g.cfg.ForceSync,
+		ForceSync:     opts.ForceSync,
+		PushMirror:    opts.PushMirror,
+		SyncNotes:     opts.SyncNotes,
+		Refspecs:      opts.Refspecs,
+		AuthMode:      opts.AuthMode,
+		AuthSecret:    opts.AuthSecret,
+		Environment:   opts.Environment,
+		KnownHosts:    opts.KnownHosts,
+		I2PHost:       config.I2PHost(opts.PrimaryRepo),
+
+		OnionOrigin:    config.OnionOrigin(opts.PrimaryRepo),
+		TorProxySecret: opts.TorProxySecret,
+		Format:         opts.Format,
+
+		FilterPaths:          opts.FilterPaths,
+		StripBlobsBiggerThan: opts.StripBlobsBiggerThan,
+		Subdirectory:         opts.Subdirectory,
+
+		RequireSigned:  opts.RequireSigned,
+		AllowedSigners: opts.AllowedSigners,
+		SigningKeys:    opts.SigningKeys,
+		ScanSecrets:    opts.ScanSecrets,
+
+		Extra: opts.Extra,
+
+		PreSyncHook:  opts.PreSyncHook,
+		PostSyncHook: opts.PostSyncHook,
+
+		BadgeBranch: opts.BadgeBranch,
+	}
+	if opts.RecordState {
+		data.RecordState, data.ToolVersion = true, toolVersion()
+	}
+	if !config.IsGitHubURL(opts.MirrorRepo) {
+		data.MirrorURL, data.MirrorUser = genericMirror(opts.MirrorRepo, opts.MirrorUser)
+	}
+	// Commit statuses are set through the API of the GitHub mirror the
+	// workflow runs in
+	if opts.CommitStatus && data.MirrorURL == "" && opts.Format == config.FormatGitHub {
+		data.CommitStatus = true
+		data.StatusDescription = config.CommitStatusDescription(opts.PrimaryRepo, "${SYNCED_SHA:0:12}")
+	}
+	if opts.CachePrimary {
+		data.CacheKey = cacheKey(opts.PrimaryRepo)
+	}
+	if opts.ForceSync && opts.RewritePolicy != "" && opts.RewritePolicy != config.RewritePolicyForce {
+		data.RewritePolicy = opts.RewritePolicy
 	}
 
-	// Generate workflow file from template
-	workflowYAML, err := generateWorkflowYAML(data)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate workflow YAML: %w", err)
+	if err := checkInputs(data); err != nil {
+		return WorkflowTemplate{}, err
 	}
 
-	return workflowYAML, nil
+	// Actions workflows download gitleaks, which is only safe with a pinned
+	// checksum
+	if data.ScanSecrets && gitleaksSHA256 == "" {
+		if formatter, err := Lookup(opts.Format); err == nil {
+			if _, ok := formatter.(actionsFormatter); ok {
+				return WorkflowTemplate{}, fmt.Errorf("--scan-secrets is not available for the %s format: no checksum of the gitleaks download is pinned in this build", opts.Format)
+			}
+		}
+	}
+
+	// A mirror push replaces the branches of the mirror, which would remove
+	// a workflow running from them
+	if data.PushMirror && data.MirrorURL == "" {
+		return WorkflowTemplate{}, fmt.Errorf("--push-mirror would remove the workflow from the mirror it runs in, use the run subcommand or a workflow that pushes to a mirror on another host")
+	}
+
+	// The badge reports the outcome of the job, which GitLab pipelines only
+	// expose to scripts running after it
+	if data.BadgeBranch != "" && data.Format == config.FormatGitLab {
+		return WorkflowTemplate{}, fmt.Errorf("--badge-branch is not supported by GitLab pipelines, publish the badge with the run or serve subcommand")
+	}
+
+	// User templates are checked here, where their errors can be reported
+	if opts.TemplateDir != "" {
+		templates, err := loadTemplates(opts.TemplateDir)
+		if err != nil {
+			return WorkflowTemplate{}, err
+		}
+		data.templates = templates
+		if _, err := renderSyncScript(data); err != nil {
+			return WorkflowTemplate{}, err
+		}
+	}
+
+	return data, nil
+}
+
+// cacheKey returns the prefix of the cache keys of the primary's objects.
+// The keys differ between primaries so switching the primary starts afresh.
+func cacheKey(primaryRepo string) string {
+	sum := sha256.Sum256([]byte(primaryRepo))
+	return "gh-mirror-primary-" + hex.EncodeToString(sum[:8])
 }
 
-// getCronSchedule converts a sync interval to a cron schedule.
-func getCronSchedule(interval string) string {
+// CronSchedule converts a sync interval to a cron schedule.
+func CronSchedule(interval string) string {
 	switch interval {
 	case "hourly":
 		return "0 * * * *"
@@ -75,118 +522,332 @@ func getCronSchedule(interval string) string {
 	}
 }
 
-// generateWorkflowYAML creates the complete workflow YAML from the template.
-func generateWorkflowYAML(data WorkflowTemplate) (string, error) {
-	// Create the workflow structure using maps to maintain comment ordering
-	workflow := map[string]interface{}{
-		"name": "Sync Primary Repository to GitHub Mirror",
-		"on": map[string]interface{}{
-			"push": map[string]interface{}{},
-			"schedule": []map[string]string{
-				{"cron": data.CronSchedule},
-			},
-			"workflow_dispatch": map[string]interface{}{}, // Allow manual triggering
+// newWorkflow creates the workflow from the template data, with the sync job
+// running on runners labeled runsOn.
+func newWorkflow(data WorkflowTemplate, runsOn string) *Workflow {
+	steps := []*Step{
+		{
+			Name: "Validate Github Actions Environment",
+			Run:  "if [ \"$GITHUB_ACTIONS\" != \"true\" ]; then echo 'This script must be run in a GitHub Actions environment.'; exit 1; fi",
 		},
-		"jobs": map[string]interface{}{
-			"sync": map[string]interface{}{
-				"runs-on": "ubuntu-latest",
-				"steps": []map[string]interface{}{
-					{
-						"name": "Validate Github Actions Environment",
-						"run":  "if [ \"$GITHUB_ACTIONS\" != \"true\" ]; then echo 'This script must be run in a GitHub Actions environment.'; exit 1; fi",
-					},
-					{
-						"name": "Checkout GitHub Mirror",
-						"uses": "actions/checkout@v3",
-						"with": map[string]interface{}{
-							"fetch-depth": 0,
-						},
-					},
-					{
-						"name": "Configure Git",
-						"run":  "git config user.name 'GitHub Actions'\ngit config user.email 'actions@github.com'",
-					},
-					{
-						"name": "Sync Primary Repository",
-						"run":  generateSyncScript(data),
-						"env": map[string]string{
-							"GITHUB_TOKEN": "${{ secrets.GITHUB_TOKEN }}",
-						},
-					},
-				},
-			},
+		{
+			Name: "Checkout GitHub Mirror",
+			Uses: "actions/checkout@v3",
+			With: checkoutOptions(data),
+		},
+		{
+			Name: "Configure Git",
+			Run:  "git config user.name 'GitHub Actions'\ngit config user.email 'actions@github.com'",
 		},
 	}
 
-	// Convert workflow to YAML
-	var buf bytes.Buffer
-	yamlEncoder := yaml.NewEncoder(&buf)
-	yamlEncoder.SetIndent(2)
-	err := yamlEncoder.Encode(workflow)
-	if err != nil {
-		return "", fmt.Errorf("failed to encode workflow to YAML: %w", err)
+	// Keep the primary's objects between runs so only new history is fetched
+	if data.CacheKey != "" {
+		steps = append(steps, cacheStep(data))
+	}
+
+	// Filtering the history needs git-filter-repo, which is not preinstalled
+	if data.FilterArgs() != "" {
+		steps = append(steps, &Step{
+			Name: "Install git-filter-repo",
+			Run:  "sudo apt-get update\nsudo apt-get install -y git-filter-repo\n",
+		})
 	}
 
-	// Add comments to the generated YAML
-	result := addComments(buf.String())
-	return result, nil
+	// gitleaks is not preinstalled either
+	if data.ScanSecrets {
+		steps = append(steps, &Step{
+			Name: "Install gitleaks",
+			Run: "curl -sSfLo gitleaks.tar.gz " + gitleaksURL + "\n" +
+				"echo '" + gitleaksSHA256 + "  gitleaks.tar.gz' | sha256sum -c -\n" +
+				"sudo tar -xzf gitleaks.tar.gz -C /usr/local/bin gitleaks\n" +
+				"rm gitleaks.tar.gz\n",
+		})
+	}
+
+	// Primaries on the I2P network are only reachable through a router
+	if data.I2PHost != "" {
+		steps = append(steps, i2pStep(data))
+	}
+
+	// Onion services are only reachable through Tor
+	if data.OnionOrigin != "" {
+		steps = append(steps, torStep(data))
+	}
+
+	syncEnv := map[string]string{
+		"GITHUB_TOKEN": "${{ secrets.GITHUB_TOKEN }}",
+	}
+	if data.MirrorURL != "" {
+		syncEnv["MIRROR_TOKEN"] = "${{ secrets." + data.AuthSecret + " }}"
+	}
+	if data.CacheKey != "" {
+		syncEnv["PRIMARY_CACHE"] = primaryCachePath
+	}
+	// User hooks run around the sync, e.g. to validate the primary or to
+	// notify downstream projects
+	if data.PreSyncHook != "" {
+		steps = append(steps, &Step{Name: "Pre-Sync Hook", Run: data.PreSyncHook})
+	}
+	steps = append(steps, &Step{
+		Name: "Sync Primary Repository",
+		Run:  generateSyncScript(data),
+		Env:  syncEnv,
+	})
+	if data.PostSyncHook != "" {
+		steps = append(steps, &Step{Name: "Post-Sync Hook", Run: data.PostSyncHook})
+	}
+	if data.BadgeBranch != "" {
+		steps = append(steps, badgeStep(data))
+	}
+
+	job := &Job{
+		RunsOn: Labels{runsOn},
+		Steps:  steps,
+
+		// Bind the job to an environment so environment secrets and protection rules apply
+		Environment: data.Environment,
+	}
+
+	// Opening the rewrite issue needs a token that may write issues, and
+	// setting the commit status one that may write statuses
+	if data.RewritePolicy == config.RewritePolicyIssue || data.CommitStatus {
+		job.Permissions = map[string]string{"contents": "write"}
+		if data.RewritePolicy == config.RewritePolicyIssue {
+			job.Permissions["issues"] = "write"
+		}
+		if data.CommitStatus {
+			job.Permissions["statuses"] = "write"
+		}
+	}
+
+	return &Workflow{
+		Name: "Sync Primary Repository to GitHub Mirror",
+		On: Triggers{
+			Push:             &Event{},
+			Schedule:         []Schedule{{Cron: data.CronSchedule}},
+			WorkflowDispatch: &Event{}, // Allow manual triggering
+		},
+		Jobs: map[string]*Job{"sync": job},
+	}
 }
 
-// generateSyncScript creates the Git commands for syncing repositories.
-func generateSyncScript(data WorkflowTemplate) string {
-	tmpl := `# Add the primary repository as a remote
-git remote add primary {{.PrimaryRepo}}
+// checkoutOptions returns the checkout step inputs, including the credentials
+// used to push to the mirror for the configured auth mode.
+func checkoutOptions(data WorkflowTemplate) map[string]interface{} {
+	options := map[string]interface{}{
+		"fetch-depth": 0,
+	}
+
+	switch data.AuthMode {
+	case config.AuthModePAT:
+		if data.MirrorURL != "" {
+			// The token belongs to the mirror host and is only used for pushing
+			break
+		}
+		// A personal access token can push changes to workflow files, GITHUB_TOKEN cannot
+		options["token"] = "${{ secrets." + data.AuthSecret + " }}"
+	case config.AuthModeSSH:
+		// The deploy key makes checkout configure origin over SSH
+		options["ssh-key"] = "${{ secrets." + data.AuthSecret + " }}"
+		// The fetches from an SSH primary go through the same SSH command,
+		// which only trusts the pinned keys besides those of GitHub
+		if data.KnownHosts != "" {
+			options["ssh-known-hosts"] = data.KnownHosts
+			options["ssh-strict"] = true
+		}
+	}
+
+	return options
+}
+
+// primaryCachePath is where the cached bare clone of the primary is kept on
+// the runner, outside the workspace of the mirror checkout.
+const primaryCachePath = "${{ runner.temp }}/gh-mirror-primary.git"
+
+// cacheStep returns the step that restores the cached bare clone of the
+// primary, and saves it again at the end of the job under a new key.
+func cacheStep(data WorkflowTemplate) *Step {
+	return &Step{
+		Name: "Cache Primary Repository",
+		Uses: "actions/cache@v4",
+		With: map[string]interface{}{
+			"path":         primaryCachePath,
+			"key":          data.CacheKey + "-${{ github.run_id }}",
+			"restore-keys": data.CacheKey + "-",
+		},
+	}
+}
+
+// gitleaksURL is the release archive of the gitleaks version the workflow
+// scans for secrets with.
+const gitleaksURL = "https://github.com/gitleaks/gitleaks/releases/download/v8.21.2/gitleaks_8.21.2_linux_x64.tar.gz"
+
+// gitleaksSHA256 is the SHA-256 checksum of the archive at gitleaksURL, as
+// listed in gitleaks_8.21.2_checksums.txt of the release, which the workflow
+// verifies before installing gitleaks. It has to be pinned, and updated
+// along with gitleaksURL; until then, Actions workflows that scan for
+// secrets are not generated.
+const gitleaksSHA256 = ""
 
-# Fetch the latest changes from the primary repository
-git fetch primary
+// i2pProxy is the HTTP proxy address of the I2P router started on the runner.
+const i2pProxy = "http://127.0.0.1:4444"
 
-# Check if the primary branch exists in the primary repository
-if git ls-remote --heads primary {{.PrimaryBranch}} | grep -q {{.PrimaryBranch}}; then
-  echo "Primary branch {{.PrimaryBranch}} found in primary repository"
+// i2pStep returns the step that starts an I2P router on the runner and
+// routes git traffic for the primary through its HTTP proxy.
+func i2pStep(data WorkflowTemplate) *Step {
+	return &Step{
+		Name: "Start I2P Router",
+		Run: `# Install and start the i2pd router, which serves an HTTP proxy on ` + strings.TrimPrefix(i2pProxy, "http://") + `
+sudo apt-get update
+sudo apt-get install -y i2pd
+sudo systemctl start i2pd
+
+# Route git traffic for the primary through the I2P proxy
+git config --global http.http://` + data.I2PHost + `/.proxy ` + i2pProxy + `
+
+# Building tunnels takes a while after the router starts
+for attempt in $(seq 1 30); do
+  if git ls-remote ` + data.PrimaryRepo + ` > /dev/null 2>&1; then
+    echo "Primary repository is reachable over I2P"
+    break
+  fi
+  echo "Waiting for I2P tunnels ($attempt/30)"
+  sleep 10
+done
+`,
+	}
+}
+
+// torProxy is the SOCKS proxy address of the Tor client started on the runner.
+const torProxy = "socks5h://127.0.0.1:9050"
+
+// torStep returns the step that routes git traffic for an onion service
+// primary through Tor, started on the runner unless a proxy secret is used.
+func torStep(data WorkflowTemplate) *Step {
+	if data.TorProxySecret != "" {
+		return &Step{
+			Name: "Configure Tor Proxy",
+			Run:  "# Route git traffic for the primary through the configured SOCKS proxy\ngit config --global http." + data.OnionOrigin + "/.proxy \"$TOR_PROXY\"\n",
+			Env: map[string]string{
+				"TOR_PROXY": "${{ secrets." + data.TorProxySecret + " }}",
+			},
+		}
+	}
+
+	return &Step{
+		Name: "Start Tor",
+		Run: `# Install and start Tor, which serves a SOCKS proxy on ` + strings.TrimPrefix(torProxy, "socks5h://") + `
+sudo apt-get update
+sudo apt-get install -y tor
+sudo systemctl start tor
+
+# Route git traffic for the primary through Tor
+git config --global http.` + data.OnionOrigin + `/.proxy ` + torProxy + `
+
+# Tor needs to bootstrap before onion services are reachable
+for attempt in $(seq 1 30); do
+  if git ls-remote ` + data.PrimaryRepo + ` > /dev/null 2>&1; then
+    echo "Primary repository is reachable over Tor"
+    break
+  fi
+  echo "Waiting for Tor to bootstrap ($attempt/30)"
+  sleep 10
+done
+`,
+	}
+}
+
+// badgeStep returns the step that publishes the outcome of the job as a
+// shields.io endpoint badge to the badge branch, also when the sync failed.
+// The badge is committed on top of the branch with an index of its own, so
+// other files of the branch, e.g. of a GitHub Pages site, are kept.
+func badgeStep(data WorkflowTemplate) *Step {
+	return &Step{
+		Name: "Publish Sync Badge",
+		If:   "always()",
+		Run: `# Describe the outcome of the sync in the badge
+if [ "$SYNC_STATUS" = success ]; then
+  BADGE_MESSAGE="synced $(date -u '+%Y-%m-%d %H:%M UTC')"
+  BADGE_COLOR=brightgreen
 else
-  echo "Error: Primary branch {{.PrimaryBranch}} not found in primary repository"
-  exit 1
+  BADGE_MESSAGE="sync failed $(date -u '+%Y-%m-%d %H:%M UTC')"
+  BADGE_COLOR=red
 fi
+BADGE_BLOB=$(printf '{"schemaVersion":1,"label":"mirror","message":"%s","color":"%s"}\n' "$BADGE_MESSAGE" "$BADGE_COLOR" | git hash-object -w --stdin)
 
-# Check if we're already on the mirror branch
-if git rev-parse --verify --quiet {{.MirrorBranch}}; then
-  git checkout {{.MirrorBranch}}
+# Commit the badge on top of the badge branch, creating it when missing
+export GIT_INDEX_FILE="$RUNNER_TEMP/gh-mirror-badge.index"
+BADGE_PARENT=
+if git fetch origin '+refs/heads/` + data.BadgeBranch + `:refs/gh-mirror/badge'; then
+  git read-tree refs/gh-mirror/badge
+  BADGE_PARENT="-p $(git rev-parse refs/gh-mirror/badge)"
 else
-  # Create the mirror branch if it doesn't exist
-  git checkout -b {{.MirrorBranch}}
+  git read-tree --empty
 fi
+git update-index --add --cacheinfo "100644,$BADGE_BLOB,` + config.BadgeFile + `"
+BADGE_COMMIT=$(git commit-tree "$(git write-tree)" $BADGE_PARENT -m "Update mirror badge: $BADGE_MESSAGE")
+git push origin "$BADGE_COMMIT:refs/heads/` + data.BadgeBranch + `"
+`,
+		Env: map[string]string{
+			"SYNC_STATUS": "${{ job.status }}",
+		},
+	}
+}
 
-{{if .ForceSync}}
-# Force-apply all changes from primary, overriding any conflicts
-echo "Performing force sync from primary/{{.PrimaryBranch}} to {{.MirrorBranch}}"
-git reset --hard primary/{{.PrimaryBranch}}
-{{else}}
-# Attempt to merge changes from primary
-echo "Attempting to merge changes from primary/{{.PrimaryBranch}} to {{.MirrorBranch}}"
-if ! git merge primary/{{.PrimaryBranch}} --no-edit; then
-  # If merge fails, prefer the primary repository's changes
-  echo "Merge conflict detected, preferring primary repository's changes"
-  git checkout --theirs .
-  git add .
-  git commit -m "Merge primary repository, preferring primary changes in conflicts"
-fi
-{{end}}
+// genericMirror returns the host and path of an HTTPS mirror URL outside
+// GitHub, and the user name to push with, which defaults to the owner in the
+// URL path.
+func genericMirror(mirrorURL, user string) (string, string) {
+	hostPath := strings.TrimPrefix(mirrorURL, "https://")
+	if !strings.HasSuffix(hostPath, ".git") {
+		hostPath += ".git"
+	}
+	if user == "" {
+		parts := strings.Split(hostPath, "/")
+		if len(parts) > 1 {
+			user = parts[1]
+		}
+	}
+	return hostPath, user
+}
 
-# Push changes back to the mirror repository
-git push origin {{.MirrorBranch}}`
+// toolVersion returns the module version of the running gh-mirror, or
+// "(devel)" when it was not built from a released version.
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" {
+		return "(devel)"
+	}
+	return info.Main.Version
+}
 
-	t, err := template.New("sync").Parse(tmpl)
+// generateSyncScript creates the Git commands for syncing repositories.
+func generateSyncScript(data WorkflowTemplate) string {
+	script, err := renderSyncScript(data)
 	if err != nil {
 		return "echo 'Error generating sync script'" // Fallback
 	}
+	return script
+}
 
-	var buf bytes.Buffer
-	err = t.Execute(&buf, data)
-	if err != nil {
-		return "echo 'Error generating sync script'" // Fallback
+// renderSyncScript renders the template of the sync script, from the
+// templates of data or else the built-in ones.
+func renderSyncScript(data WorkflowTemplate) (string, error) {
+	templates := data.templates
+	if templates == nil {
+		templates = builtin
+	}
+	name := syncBranchTemplate
+	if data.PushMirror {
+		name = pushMirrorTemplate
 	}
 
-	return buf.String()
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.String(), nil
 }
 
 // addComments adds explanatory comments to the YAML.
@@ -202,7 +863,24 @@ func addComments(yaml string) string {
 # - Pushes the updated content back to the GitHub mirror
 #
 # Authentication is handled by the GITHUB_TOKEN secret provided by GitHub Actions.
+#
+# Template version: ` + strconv.Itoa(TemplateVersion) + `
 
 `
 	return header + yaml
 }
+
+// InstalledTemplateVersion extracts the template version from a previously
+// generated workflow. The boolean result is false when no version is recorded,
+// which is the case for workflows generated before versions were introduced.
+func InstalledTemplateVersion(workflowYAML string) (int, bool) {
+	match := templateVersionPattern.FindStringSubmatch(workflowYAML)
+	if match == nil {
+		return 0, false
+	}
+	version, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
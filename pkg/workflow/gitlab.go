@@ -0,0 +1,125 @@
+package workflow
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+// gitlabImage is the container image the GitLab sync job runs in.
+const gitlabImage = "alpine:3"
+
+// gitlabCacheDir is the directory of the cached bare clone of the primary,
+// relative to the project directory.
+const gitlabCacheDir = ".gh-mirror-primary.git"
+
+// generateGitLabCI creates a GitLab CI/CD pipeline running the sync script on
+// the mirror. The pipeline is started by a pipeline schedule, which GitLab
+// keeps outside the repository, or manually.
+func generateGitLabCI(data WorkflowTemplate) (string, error) {
+	// A mirror push replaces the branches of the mirror, which would remove
+	// the pipeline running from them
+	if data.PushMirror {
+		return "", fmt.Errorf("--push-mirror would remove the workflow from the mirror it runs in, use the run subcommand or a workflow that pushes to a mirror on another host")
+	}
+	// The sync job cannot run the routers the GitHub workflow starts on its runner
+	if data.I2PHost != "" || data.OnionOrigin != "" {
+		return "", fmt.Errorf("I2P and onion service primaries are not supported with the gitlab format")
+	}
+	if config.IsLocalURL(data.PrimaryRepo) {
+		return "", fmt.Errorf("local primaries are not supported with the gitlab format, whose job runs in a container")
+	}
+
+	packages := "git"
+	if data.FilterArgs() != "" {
+		packages += " git-filter-repo"
+	}
+	if data.RequireSigned {
+		packages += " gnupg openssh-keygen"
+	}
+	if data.ScanSecrets {
+		packages += " gitleaks"
+	}
+	beforeScript := []string{
+		"apk add --no-cache " + packages,
+		"git config --global user.name 'GitLab CI'",
+		"git config --global user.email 'gitlab-ci@localhost'",
+	}
+	variables := map[string]string{
+		"GIT_DEPTH":    "0",
+		"MIRROR_TOKEN": "$" + data.AuthSecret,
+	}
+
+	job := map[string]interface{}{
+		"image": gitlabImage,
+		// Pushes made by the sync must not start it again
+		"rules": []map[string]string{
+			{"if": `$CI_PIPELINE_SOURCE == "schedule" || $CI_PIPELINE_SOURCE == "web" || $CI_PIPELINE_SOURCE == "api"`},
+		},
+		"script": gitlabScript(data),
+	}
+
+	// GitLab caches paths inside the project directory, which are excluded
+	// from the mirror checkout so they are never committed
+	if data.CacheKey != "" {
+		job["cache"] = map[string]interface{}{
+			"key":   data.CacheKey,
+			"paths": []string{gitlabCacheDir + "/"},
+		}
+		variables["PRIMARY_CACHE"] = "$CI_PROJECT_DIR/" + gitlabCacheDir
+		beforeScript = append(beforeScript, "echo '/"+gitlabCacheDir+"/' >> .git/info/exclude")
+	}
+	job["variables"] = variables
+	job["before_script"] = beforeScript
+
+	// Environments scope CI/CD variables like GitHub Environments scope secrets
+	if data.Environment != "" {
+		job["environment"] = data.Environment
+	}
+
+	var buf bytes.Buffer
+	yamlEncoder := yaml.NewEncoder(&buf)
+	yamlEncoder.SetIndent(2)
+	if err := yamlEncoder.Encode(map[string]interface{}{"sync": job}); err != nil {
+		return "", fmt.Errorf("failed to encode pipeline to YAML: %w", err)
+	}
+
+	return gitlabHeader(data.AuthSecret) + buf.String(), nil
+}
+
+// gitlabScript returns the script of the sync job, the sync script between
+// the sync hooks.
+func gitlabScript(data WorkflowTemplate) []string {
+	var script []string
+	if data.PreSyncHook != "" {
+		script = append(script, data.PreSyncHook)
+	}
+	script = append(script, generateSyncScript(data))
+	if data.PostSyncHook != "" {
+		script = append(script, data.PostSyncHook)
+	}
+	return script
+}
+
+// gitlabHeader returns the explanatory comments of the GitLab pipeline.
+func gitlabHeader(secret string) string {
+	return `# GitLab CI/CD pipeline to sync an external repository to this GitLab mirror.
+# This file was automatically generated by go-github-sync.
+#
+# The sync job does the following:
+# - Runs from a pipeline schedule (and can also be started manually)
+# - Fetches changes from the primary external repository
+# - Applies those changes to the mirror repository
+# - Pushes the updated content back to the GitLab mirror
+#
+# Pushes authenticate with the token in the ` + secret + ` CI/CD variable, which
+# should be masked.
+#
+# Template version: ` + strconv.Itoa(TemplateVersion) + `
+
+`
+}
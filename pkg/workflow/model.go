@@ -0,0 +1,149 @@
+package workflow
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The fields of the workflow types are declared in the order of their YAML
+// keys, which keeps generated workflows identical to those of earlier
+// versions, where they were built from maps with sorted keys.
+
+// Workflow is a GitHub Actions or Forgejo Actions workflow.
+type Workflow struct {
+	Jobs map[string]*Job `yaml:"jobs"`
+	Name string          `yaml:"name,omitempty"`
+	On   Triggers        `yaml:"on"`
+
+	// Other holds the keys without a field, which are kept when a parsed
+	// workflow is written back
+	Other map[string]interface{} `yaml:",inline"`
+}
+
+// Triggers are the events that start a workflow.
+type Triggers struct {
+	Push             *Event     `yaml:"push,omitempty"`
+	Schedule         []Schedule `yaml:"schedule,omitempty"`
+	WorkflowDispatch *Event     `yaml:"workflow_dispatch,omitempty"`
+
+	// Other holds the other events by name
+	Other map[string]interface{} `yaml:",inline"`
+}
+
+// Event is the configuration of a workflow trigger, empty to trigger on
+// every occurrence of the event.
+type Event struct {
+	Branches []string `yaml:"branches,omitempty"`
+	Paths    []string `yaml:"paths,omitempty"`
+
+	Other map[string]interface{} `yaml:",inline"`
+}
+
+// Schedule is a scheduled trigger.
+type Schedule struct {
+	Cron string `yaml:"cron"`
+}
+
+// Job is a job of a workflow.
+type Job struct {
+	Environment string            `yaml:"environment,omitempty"`
+	Permissions map[string]string `yaml:"permissions,omitempty"`
+	RunsOn      Labels            `yaml:"runs-on,omitempty"`
+	Steps       []*Step           `yaml:"steps,omitempty"`
+
+	Other map[string]interface{} `yaml:",inline"`
+}
+
+// Labels select the runners of a job. A single label is written as a plain
+// value rather than a list.
+type Labels []string
+
+// Step is a step of a job, running either a command or an action.
+type Step struct {
+	Env  map[string]string      `yaml:"env,omitempty"`
+	ID   string                 `yaml:"id,omitempty"`
+	If   string                 `yaml:"if,omitempty"`
+	Name string                 `yaml:"name,omitempty"`
+	Run  string                 `yaml:"run,omitempty"`
+	Uses string                 `yaml:"uses,omitempty"`
+	With map[string]interface{} `yaml:"with,omitempty"`
+
+	Other map[string]interface{} `yaml:",inline"`
+}
+
+// ParseWorkflow parses a workflow file.
+func ParseWorkflow(data []byte) (*Workflow, error) {
+	var workflow Workflow
+	if err := yaml.Unmarshal(data, &workflow); err != nil {
+		return nil, fmt.Errorf("failed to parse workflow: %w", err)
+	}
+	return &workflow, nil
+}
+
+// Marshal returns w as YAML, without comments.
+func (w *Workflow) Marshal() (string, error) {
+	var buf bytes.Buffer
+	yamlEncoder := yaml.NewEncoder(&buf)
+	yamlEncoder.SetIndent(2)
+	if err := yamlEncoder.Encode(w); err != nil {
+		return "", fmt.Errorf("failed to encode workflow to YAML: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Step returns the first step of j named name, or nil when there is none.
+func (j *Job) Step(name string) *Step {
+	for _, step := range j.Steps {
+		if step.Name == name {
+			return step
+		}
+	}
+	return nil
+}
+
+// UnmarshalYAML accepts the short forms of triggers, a single event name or
+// a list of them, besides the mapping of events to their configuration.
+func (t *Triggers) UnmarshalYAML(node *yaml.Node) error {
+	type plain Triggers
+	if node.Kind == yaml.MappingNode {
+		return node.Decode((*plain)(t))
+	}
+
+	var names []string
+	if node.Kind == yaml.ScalarNode {
+		names = []string{node.Value}
+	} else if err := node.Decode(&names); err != nil {
+		return err
+	}
+	mapping := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, name := range names {
+		mapping.Content = append(mapping.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name},
+			&yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+	}
+	return mapping.Decode((*plain)(t))
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (l Labels) MarshalYAML() (interface{}, error) {
+	if len(l) == 1 {
+		return l[0], nil
+	}
+	return []string(l), nil
+}
+
+// UnmarshalYAML accepts a single label as well as a list.
+func (l *Labels) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = Labels{node.Value}
+		return nil
+	}
+	var labels []string
+	if err := node.Decode(&labels); err != nil {
+		return err
+	}
+	*l = labels
+	return nil
+}
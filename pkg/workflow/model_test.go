@@ -0,0 +1,109 @@
+package workflow_test
+
+import (
+	"testing"
+
+	"i2pgit.org/go-i2p/go-github-sync/pkg/config"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/logger"
+	"i2pgit.org/go-i2p/go-github-sync/pkg/workflow"
+)
+
+func TestWorkflowRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*workflow.Options)
+	}{
+		{"defaults", func(*workflow.Options) {}},
+		{"merge to several branches", func(o *workflow.Options) {
+			o.ForceSync = false
+			o.MirrorBranches = []string{"main", "stable"}
+			o.Interval = "daily"
+		}},
+		{"SSH deploy key in an environment", func(o *workflow.Options) {
+			o.AuthMode = config.AuthModeSSH
+			o.AuthSecret = "MIRROR_DEPLOY_KEY"
+			o.Environment = "mirror"
+		}},
+		{"I2P primary with cache and hooks", func(o *workflow.Options) {
+			o.PrimaryRepo = "http://git.idk.i2p/go-i2p/reseed-tools.git"
+			o.CachePrimary = true
+			o.PreSyncHook = "echo before\n"
+			o.PostSyncHook = "echo after\n"
+		}},
+		{"Forgejo", func(o *workflow.Options) {
+			o.Format = config.FormatForgejo
+			o.MirrorRepo = "https://codeberg.org/go-i2p/reseed-tools"
+			o.MirrorUser = "mirror-bot"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := workflow.DefaultOptions()
+			opts.PrimaryRepo = "https://i2pgit.org/go-i2p/reseed-tools.git"
+			opts.MirrorRepo = "https://github.com/go-i2p/reseed-tools"
+			tt.change(&opts)
+
+			generated, err := workflow.NewGenerator(opts, logger.New()).Workflow()
+			if err != nil {
+				t.Fatalf("Workflow: %v", err)
+			}
+			want, err := generated.Marshal()
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			parsed, err := workflow.ParseWorkflow([]byte(want))
+			if err != nil {
+				t.Fatalf("ParseWorkflow: %v", err)
+			}
+			got, err := parsed.Marshal()
+			if err != nil {
+				t.Fatalf("Marshal of the parsed workflow: %v", err)
+			}
+			if got != want {
+				t.Errorf("parsed workflow is written as\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseWorkflow(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "single trigger and label",
+			input: "on: push\njobs:\n  sync:\n    runs-on: ubuntu-latest\n    steps:\n      - run: make\n",
+			want:  "jobs:\n  sync:\n    runs-on: ubuntu-latest\n    steps:\n      - run: make\n\"on\":\n  push: {}\n",
+		},
+		{
+			name:  "trigger list and several labels",
+			input: "on: [push, workflow_dispatch]\njobs:\n  sync:\n    runs-on: [self-hosted, linux]\n",
+			want:  "jobs:\n  sync:\n    runs-on:\n      - self-hosted\n      - linux\n\"on\":\n  push: {}\n  workflow_dispatch: {}\n",
+		},
+		{
+			name: "keys without a field",
+			input: "name: Sync\nconcurrency: mirror\non:\n  release:\n    types: [published]\n  push:\n    branches: [main]\n    tags: ['v*']\n" +
+				"jobs:\n  sync:\n    timeout-minutes: 10\n    steps:\n      - name: Build\n        run: make\n        shell: bash\n",
+			want: "jobs:\n  sync:\n    steps:\n      - name: Build\n        run: make\n        shell: bash\n    timeout-minutes: 10\n" +
+				"name: Sync\n\"on\":\n  push:\n    branches:\n      - main\n    tags:\n      - v*\n  release:\n    types:\n      - published\n" +
+				"concurrency: mirror\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := workflow.ParseWorkflow([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("ParseWorkflow: %v", err)
+			}
+			got, err := parsed.Marshal()
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package workflow
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Names of the templates, which are the file names of the built-in templates
+// and of the templates overriding them in a template directory.
+const (
+	// syncBranchTemplate syncs the primary branch onto the mirror branches
+	syncBranchTemplate = "sync-branch.sh.tmpl"
+	// pushMirrorTemplate replicates all refs of the primary with git push
+	// --mirror from a bare mirror clone, using the credentials of the
+	// mirror checkout
+	pushMirrorTemplate = "push-mirror.sh.tmpl"
+)
+
+// templateNames lists the names of the templates.
+var templateNames = []string{syncBranchTemplate, pushMirrorTemplate}
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// builtin holds the built-in templates.
+var builtin = template.Must(loadTemplates(""))
+
+// TemplateNames returns the names of the templates a template directory can
+// override.
+func TemplateNames() []string {
+	return append([]string{}, templateNames...)
+}
+
+// loadTemplates parses the templates, taking those found in dir, unless it
+// is empty, instead of the built-in ones of the same name. The newline
+// ending a template file is not part of the template.
+func loadTemplates(dir string) (*template.Template, error) {
+	templates := template.New("")
+	for _, name := range templateNames {
+		content, err := readTemplate(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := templates.New(name).Parse(strings.TrimSuffix(string(content), "\n")); err != nil {
+			return nil, fmt.Errorf("failed to parse template: %w", err)
+		}
+	}
+	return templates, nil
+}
+
+// readTemplate returns the content of the template name from dir, or the
+// built-in template when dir is empty or has no such file.
+func readTemplate(dir, name string) ([]byte, error) {
+	if dir != "" {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return content, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+	}
+	return builtinTemplates.ReadFile("templates/" + name)
+}